@@ -0,0 +1,50 @@
+package netconf
+
+import "context"
+
+// Caller issues a rpc with `req` as the operation and decodes the reply into
+// `resp`.  It is implemented by [Session] and can be used to wrap calls with
+// middleware (i.e for logging or metrics) with [WithCallMiddleware].
+type Caller interface {
+	Call(ctx context.Context, req any, resp any) error
+}
+
+// CallerFunc is an adapter to allow the use of an ordinary function as a
+// [Caller].
+type CallerFunc func(ctx context.Context, req any, resp any) error
+
+// Call calls f(ctx, req, resp).
+func (f CallerFunc) Call(ctx context.Context, req any, resp any) error {
+	return f(ctx, req, resp)
+}
+
+// CallMiddleware wraps a [Caller] returning a new Caller that usually does some
+// work before and/or after calling `next`.
+type CallMiddleware func(next Caller) Caller
+
+// Operations are the NETCONF operations implemented by [Session].  Code that
+// accepts Operations instead of a *Session can be tested against a
+// [MockSession] (or any other implementation) without a real device.
+type Operations interface {
+	Get(ctx context.Context, opts ...RPCOption) ([]byte, error)
+	GetConfig(ctx context.Context, source Datastore, opts ...RPCOption) ([]byte, error)
+	EditConfig(ctx context.Context, target Datastore, config any, opts ...EditConfigOption) error
+	CopyConfig(ctx context.Context, source, target any, opts ...CopyConfigOption) error
+	DeleteConfig(ctx context.Context, target Datastore, opts ...DeleteConfigOption) error
+	DeleteConfigURL(ctx context.Context, url URL) error
+	Lock(ctx context.Context, target Datastore) error
+	Unlock(ctx context.Context, target Datastore) error
+	Validate(ctx context.Context, source any, opts ...ValidateOption) error
+	Commit(ctx context.Context, opts ...CommitOption) error
+	CancelCommit(ctx context.Context, opts ...CancelCommitOption) error
+	KillSession(ctx context.Context, sessionID uint32) error
+	CreateSubscription(ctx context.Context, opts ...CreateSubscriptionOption) error
+	Do(ctx context.Context, req any) (*Reply, error)
+	Caller
+}
+
+var (
+	_ Operations = (*Session)(nil)
+	_ Operations = (*ReconnectingSession)(nil)
+	_ Operations = (*MockSession)(nil)
+)
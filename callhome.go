@@ -0,0 +1,168 @@
+package netconf
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+
+	"github.com/DinbandhuKumarSingh/netconf/transport"
+	nctls "github.com/DinbandhuKumarSingh/netconf/transport/tls"
+)
+
+// Ports assigned to NETCONF call home in [RFC8071].
+//
+// [RFC8071]: https://www.rfc-editor.org/rfc/rfc8071.html
+const (
+	DefaultCallHomeSSHPort = "4334"
+	DefaultCallHomeTLSPort = "4335"
+)
+
+// CallHomeTransport establishes the client side of a transport over a
+// connection opened by a device and accepted by [ListenCallHome] (i.e
+// [github.com/DinbandhuKumarSingh/netconf/transport/ssh.CallHome]).
+type CallHomeTransport func(ctx context.Context, conn net.Conn) (transport.Transport, error)
+
+type callHomeConfig struct {
+	transport   CallHomeTransport
+	port        string
+	sessionOpts []SessionOption
+}
+
+// CallHomeOption is a optional argument to [ListenCallHome].
+type CallHomeOption interface {
+	applyCallHome(*callHomeConfig)
+}
+
+type callHomeTransportOpt CallHomeTransport
+
+func (o callHomeTransportOpt) applyCallHome(cfg *callHomeConfig) {
+	cfg.transport = CallHomeTransport(o)
+	cfg.port = DefaultCallHomeSSHPort
+}
+
+// WithCallHomeTransport uses `fn` to establish the transport of every accepted
+// connection.  This is used for call home over SSH with
+// [github.com/DinbandhuKumarSingh/netconf/transport/ssh.CallHome]:
+//
+//	netconf.ListenCallHome(ctx, ":4334", handler,
+//		netconf.WithCallHomeTransport(ncssh.CallHome(sshConfig)))
+//
+// The default port is [DefaultCallHomeSSHPort].
+func WithCallHomeTransport(fn CallHomeTransport) CallHomeOption {
+	return callHomeTransportOpt(fn)
+}
+
+type callHomeTLSOpt struct{ cfg *tls.Config }
+
+func (o callHomeTLSOpt) applyCallHome(cfg *callHomeConfig) {
+	cfg.transport = func(ctx context.Context, conn net.Conn) (transport.Transport, error) {
+		return nctls.DialConn(ctx, conn, o.cfg)
+	}
+	cfg.port = DefaultCallHomeTLSPort
+	if username := tlsCertUsername(o.cfg); username != "" {
+		// prepend so an explicit WithUsername still wins.
+		cfg.sessionOpts = append([]SessionOption{usernameOpt(username)}, cfg.sessionOpts...)
+	}
+}
+
+// WithCallHomeTLS runs NETCONF over TLS as the client on every accepted
+// connection with the given config (see [DialTLS]).  The default port is
+// [DefaultCallHomeTLSPort].
+func WithCallHomeTLS(cfg *tls.Config) CallHomeOption {
+	return callHomeTLSOpt{cfg}
+}
+
+type callHomeSessionOpts []SessionOption
+
+func (o callHomeSessionOpts) applyCallHome(cfg *callHomeConfig) {
+	cfg.sessionOpts = append(cfg.sessionOpts, o...)
+}
+
+// WithCallHomeSessionOptions sets the options used to open every call home
+// session.
+func WithCallHomeSessionOptions(opts ...SessionOption) CallHomeOption {
+	return callHomeSessionOpts(opts)
+}
+
+// ListenCallHome listens on `addr` for NETCONF call home connections as defined
+// in [RFC8071].  For every connection opened by a device the client side of the
+// transport (set with [WithCallHomeTLS] or [WithCallHomeTransport]) and the
+// hello exchange are completed and the new session is passed to `handler` in
+// it's own goroutine.  The handler owns the session and must close it.
+//
+// If `addr` has no port the default port of the transport is used.
+// Connections that fail to establish a session are closed and logged.
+//
+// ListenCallHome blocks until `ctx` is canceled (returning ctx.Err()) or
+// accepting a connection fails.
+//
+// [RFC8071]: https://www.rfc-editor.org/rfc/rfc8071.html
+func ListenCallHome(ctx context.Context, addr string, handler func(*Session), opts ...CallHomeOption) error {
+	var cfg callHomeConfig
+	for _, opt := range opts {
+		opt.applyCallHome(&cfg)
+	}
+
+	if cfg.transport == nil {
+		return errors.New("netconf: call home requires WithCallHomeTLS or WithCallHomeTransport")
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, cfg.port)
+	}
+
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		go func() {
+			sess, err := openCallHome(ctx, conn, &cfg)
+			if err != nil {
+				log.Printf("netconf: call home from %s failed: %v", conn.RemoteAddr(), err)
+				return
+			}
+			handler(sess)
+		}()
+	}
+}
+
+func openCallHome(ctx context.Context, conn net.Conn, cfg *callHomeConfig) (*Session, error) {
+	// abort the handshake (including the hello exchange) if the listener is
+	// canceled.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	tr, err := cfg.transport(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	sess, err := Open(tr, cfg.sessionOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !stop() {
+		sess.tr.Close()
+		return nil, ctx.Err()
+	}
+	return sess, nil
+}
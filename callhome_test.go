@@ -0,0 +1,105 @@
+package netconf
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// freeAddr returns a local address that is (most likely) free to listen on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// callHomeDevice connects to a call home listener as a device acting as the
+// TLS server.
+func callHomeDevice(t *testing.T, addr string) {
+	t.Helper()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("device failed to connect: %v", err)
+		return
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		Certificates: []tls.Certificate{newTestCert(t, "device")},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	defer tlsConn.Close()
+
+	io.WriteString(tlsConn, `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities><capability>urn:ietf:params:netconf:base:1.0</capability></capabilities><session-id>7</session-id></hello>]]>]]>`)
+	io.Copy(io.Discard, tlsConn)
+}
+
+func TestListenCallHomeTLS(t *testing.T) {
+	addr := freeAddr(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessions := make(chan *Session, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ListenCallHome(ctx, addr, func(s *Session) { sessions <- s },
+			WithCallHomeTLS(&tls.Config{
+				InsecureSkipVerify: true,
+				Certificates:       []tls.Certificate{newTestCert(t, "controller")},
+			}),
+			WithCallHomeSessionOptions(WithUsername("admin")),
+		)
+	}()
+
+	go callHomeDevice(t, addr)
+
+	select {
+	case sess := <-sessions:
+		assert.Equal(t, uint64(7), sess.SessionID())
+		assert.Equal(t, "admin", sess.Username())
+		sess.tr.Close()
+	case err := <-errCh:
+		t.Fatalf("listener failed: %v", err)
+	case <-ctx.Done():
+		t.Fatal("no call home session")
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+}
+
+func TestListenCallHomeNoTransport(t *testing.T) {
+	err := ListenCallHome(context.Background(), freeAddr(t), func(*Session) {})
+	assert.Error(t, err)
+}
+
+func TestListenCallHomeListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	err = ListenCallHome(context.Background(), ln.Addr().String(), func(*Session) {}, WithCallHomeTLS(&tls.Config{}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,286 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// xmlNamespace is the namespace bound to the reserved `xml` prefix.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// CanonicalizeConfig normalizes encoded config xml so that two configs that
+// only differ in their encoding are byte for byte equal after canonicalization
+// (i.e to detect config drift with bytes.Equal).  The canonical form:
+//
+//   - has leading and trailing whitespace of text removed and whitespace-only
+//     text dropped, including in mixed content.
+//   - decodes CDATA sections into (escaped) text.
+//   - drops comments, processing instructions and the xml declaration.
+//   - declares the namespace of elements as the default namespace (`xmlns`)
+//     when it differs from the parent element.
+//   - renames namespace prefixes of attributes to `ns0`, `ns1`, ... in order of
+//     first use.  The same is done for prefixes in leaf values that look like
+//     a qualified name (i.e identityrefs like `ianaift:ethernetCsmacd`) if
+//     the prefix is declared.
+//   - sorts attributes by namespace and name.
+//   - encodes empty elements as self-closing tags.
+//
+// The order of elements is significant and is kept as-is.
+func CanonicalizeConfig(config []byte) ([]byte, error) {
+	c := canonicalizer{
+		prefixes: make(map[string]string),
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(config))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to canonicalize config: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			c.start(tok)
+		case xml.EndElement:
+			c.end()
+		case xml.CharData:
+			c.text.Write(tok)
+		}
+	}
+
+	if len(c.stack) > 0 {
+		return nil, errors.New("failed to canonicalize config: unexpected EOF")
+	}
+	c.flushText()
+	return c.out.Bytes(), nil
+}
+
+type canonicalElem struct {
+	name  xml.Name
+	attrs []xml.Attr
+	// scope holds the prefixes declared on the element in the input.
+	scope map[string]string
+	// decls are the canonical prefixes (by namespace) declared on the element
+	// in the output.
+	decls map[string]string
+	// written is set once the start tag has been written.
+	written bool
+}
+
+type canonicalizer struct {
+	out   bytes.Buffer
+	text  bytes.Buffer
+	stack []*canonicalElem
+	// prefixes maps namespaces to their canonical prefix.
+	prefixes map[string]string
+}
+
+func (c *canonicalizer) start(tok xml.StartElement) {
+	c.openParent()
+
+	el := &canonicalElem{
+		name:  tok.Name,
+		scope: make(map[string]string),
+		decls: make(map[string]string),
+	}
+	for _, attr := range tok.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			el.scope[attr.Name.Local] = attr.Value
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			// the default namespace is already resolved by the decoder.
+		default:
+			el.attrs = append(el.attrs, attr)
+		}
+	}
+	sort.Slice(el.attrs, func(i, j int) bool {
+		a, b := el.attrs[i].Name, el.attrs[j].Name
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.Local < b.Local
+	})
+
+	c.stack = append(c.stack, el)
+}
+
+// openParent writes the start tag of the current element (if not already
+// written) as it has children.  Any text before the child is mixed content.
+func (c *canonicalizer) openParent() {
+	if len(c.stack) == 0 {
+		c.flushText()
+		return
+	}
+
+	el := c.stack[len(c.stack)-1]
+	if !el.written {
+		c.writeStart(el)
+	}
+	c.flushText()
+}
+
+func (c *canonicalizer) end() {
+	el := c.stack[len(c.stack)-1]
+
+	if el.written {
+		c.flushText()
+	} else {
+		// a leaf: the value may contain a prefix that needs to be declared on
+		// the element itself.
+		text := c.leafValue(el)
+		c.writeStart(el)
+		if text == "" {
+			// turn the start tag into a self-closing tag.
+			c.out.Truncate(c.out.Len() - 1)
+			c.out.WriteString("/>")
+			c.stack = c.stack[:len(c.stack)-1]
+			return
+		}
+		escapeCanonical(&c.out, text)
+	}
+
+	c.out.WriteString("</")
+	c.out.WriteString(el.name.Local)
+	c.out.WriteByte('>')
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+// flushText writes any pending (mixed) text content.
+func (c *canonicalizer) flushText() {
+	text := strings.TrimSpace(c.text.String())
+	c.text.Reset()
+	if text != "" {
+		escapeCanonical(&c.out, text)
+	}
+}
+
+// leafValue returns the pending text as the value of a leaf, rewriting a
+// declared namespace prefix to it's canonical prefix.
+func (c *canonicalizer) leafValue(el *canonicalElem) string {
+	text := strings.TrimSpace(c.text.String())
+	c.text.Reset()
+
+	prefix, local, ok := strings.Cut(text, ":")
+	if !ok || !isNCName(prefix) || !isNCName(local) {
+		return text
+	}
+
+	ns, ok := c.lookupInput(prefix)
+	if !ok {
+		return text
+	}
+	return c.declare(el, ns) + ":" + local
+}
+
+// lookupInput returns the namespace bound to prefix in the input document.
+func (c *canonicalizer) lookupInput(prefix string) (string, bool) {
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		if ns, ok := c.stack[i].scope[prefix]; ok {
+			return ns, ns != ""
+		}
+	}
+	return "", false
+}
+
+// declare returns the canonical prefix of ns, declaring it on el unless an
+// ancestor already did.
+func (c *canonicalizer) declare(el *canonicalElem, ns string) string {
+	if ns == xmlNamespace {
+		return "xml"
+	}
+
+	prefix, ok := c.prefixes[ns]
+	if !ok {
+		prefix = "ns" + strconv.Itoa(len(c.prefixes))
+		c.prefixes[ns] = prefix
+	}
+
+	for _, parent := range c.stack {
+		if parent == el {
+			break
+		}
+		if _, ok := parent.decls[ns]; ok {
+			return prefix
+		}
+	}
+	el.decls[ns] = prefix
+	return prefix
+}
+
+func (c *canonicalizer) writeStart(el *canonicalElem) {
+	el.written = true
+
+	var parentNS string
+	if len(c.stack) > 1 {
+		parentNS = c.stack[len(c.stack)-2].name.Space
+	}
+
+	// attribute prefixes need to be known before writing the declarations.
+	attrs := make([]string, 0, len(el.attrs))
+	for _, attr := range el.attrs {
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = c.declare(el, attr.Name.Space) + ":" + name
+		}
+		attrs = append(attrs, name)
+	}
+
+	c.out.WriteByte('<')
+	c.out.WriteString(el.name.Local)
+	if el.name.Space != parentNS {
+		writeCanonicalAttr(&c.out, "xmlns", el.name.Space)
+	}
+
+	decls := make([]string, 0, len(el.decls))
+	for ns := range el.decls {
+		decls = append(decls, ns)
+	}
+	sort.Slice(decls, func(i, j int) bool { return el.decls[decls[i]] < el.decls[decls[j]] })
+	for _, ns := range decls {
+		writeCanonicalAttr(&c.out, "xmlns:"+el.decls[ns], ns)
+	}
+
+	for i, attr := range el.attrs {
+		writeCanonicalAttr(&c.out, attrs[i], attr.Value)
+	}
+	c.out.WriteByte('>')
+}
+
+func writeCanonicalAttr(buf *bytes.Buffer, name, value string) {
+	buf.WriteByte(' ')
+	buf.WriteString(name)
+	buf.WriteString(`="`)
+	escapeCanonical(buf, value)
+	buf.WriteByte('"')
+}
+
+func escapeCanonical(buf *bytes.Buffer, s string) {
+	// writing to a bytes.Buffer never fails.
+	_ = xml.EscapeText(buf, []byte(s))
+}
+
+// isNCName reports if s is a non-colonized xml name (simplified to ascii
+// letters, digits, `_`, `-` and `.` not starting with a digit, `-` or `.`).
+func isNCName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case i > 0 && (r == '-' || r == '.' || r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
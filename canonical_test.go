@@ -0,0 +1,98 @@
+package netconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalizeConfig(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name: "whitespace",
+			input: `<interfaces xmlns="urn:example:if">
+  <interface>
+    <name> eth0 </name>
+    <enabled>true</enabled>
+  </interface>
+</interfaces>`,
+			want: `<interfaces xmlns="urn:example:if"><interface><name>eth0</name><enabled>true</enabled></interface></interfaces>`,
+		},
+		{
+			name:  "prefixes",
+			input: `<if:interfaces xmlns:if="urn:example:if"><if:interface><if:name>eth0</if:name></if:interface></if:interfaces>`,
+			want:  `<interfaces xmlns="urn:example:if"><interface><name>eth0</name></interface></interfaces>`,
+		},
+		{
+			name:  "attributes",
+			input: `<system xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" b="2" nc:operation="merge" a="1"><motd/></system>`,
+			want:  `<system xmlns:ns0="urn:ietf:params:xml:ns:netconf:base:1.0" a="1" b="2" ns0:operation="merge"><motd/></system>`,
+		},
+		{
+			name:  "identityref",
+			input: `<interface xmlns="urn:example:if"><type xmlns:ianaift="urn:ietf:params:xml:ns:yang:iana-if-type">ianaift:ethernetCsmacd</type></interface>`,
+			want:  `<interface xmlns="urn:example:if"><type xmlns:ns0="urn:ietf:params:xml:ns:yang:iana-if-type">ns0:ethernetCsmacd</type></interface>`,
+		},
+		{
+			name:  "undeclaredPrefixValue",
+			input: `<time>10:30</time><url>http:example</url>`,
+			want:  `<time>10:30</time><url>http:example</url>`,
+		},
+		{
+			name:  "cdata",
+			input: `<motd><![CDATA[a < b & c]]></motd>`,
+			want:  `<motd>a &lt; b &amp; c</motd>`,
+		},
+		{
+			name:  "mixed",
+			input: `<p> hello <b>world</b> again </p>`,
+			want:  `<p>hello<b>world</b>again</p>`,
+		},
+		{
+			name:  "comments",
+			input: `<?xml version="1.0"?><!-- comment --><system><!-- inner --><host-name>r1</host-name></system>`,
+			want:  `<system><host-name>r1</host-name></system>`,
+		},
+		{
+			name:  "emptyElements",
+			input: `<system><motd></motd><banner/></system>`,
+			want:  `<system><motd/><banner/></system>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := CanonicalizeConfig([]byte(tc.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestCanonicalizeConfigEqual(t *testing.T) {
+	a := `<interfaces xmlns="urn:example:if" xmlns:ianaift="urn:ietf:params:xml:ns:yang:iana-if-type">
+  <interface><name>eth0</name><type>ianaift:ethernetCsmacd</type></interface>
+</interfaces>`
+	b := `<x:interfaces xmlns:x="urn:example:if"><x:interface>
+    <x:name>eth0</x:name>
+    <x:type xmlns:t="urn:ietf:params:xml:ns:yang:iana-if-type">t:ethernetCsmacd</x:type>
+  </x:interface></x:interfaces>`
+
+	canonA, err := CanonicalizeConfig([]byte(a))
+	assert.NoError(t, err)
+	canonB, err := CanonicalizeConfig([]byte(b))
+	assert.NoError(t, err)
+	assert.Equal(t, string(canonA), string(canonB))
+}
+
+func TestCanonicalizeConfigInvalid(t *testing.T) {
+	_, err := CanonicalizeConfig([]byte(`<system><host-name>r1</system>`))
+	assert.Error(t, err)
+
+	_, err = CanonicalizeConfig([]byte(`<system>`))
+	assert.Error(t, err)
+}
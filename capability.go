@@ -1,5 +1,14 @@
 package netconf
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
 const (
 	baseCap      = "urn:ietf:params:netconf:base"
 	stdCapPrefix = "urn:ietf:params:netconf:capability"
@@ -44,25 +53,78 @@ func ExpandCapability(s string) string {
 // XXX: may want to expose this type publicly in the future when the api has
 // stabilized?
 type capabilitySet struct {
+	// mu guards caps which is updated by capability change notifications
+	// (see [WithCapabilityChangeUpdates]) while operations look it up.
+	mu   *sync.RWMutex
 	caps map[string]struct{}
 }
 
 func newCapabilitySet(capabilities ...string) capabilitySet {
 	cs := capabilitySet{
+		mu:   new(sync.RWMutex),
 		caps: make(map[string]struct{}),
 	}
 	cs.Add(capabilities...)
 	return cs
 }
 
+// rlock read-locks the set returning the function to unlock it.  The zero
+// value of a set is empty and needs no locking.
+func (cs capabilitySet) rlock() func() {
+	if cs.mu == nil {
+		return func() {}
+	}
+	cs.mu.RLock()
+	return cs.mu.RUnlock
+}
+
 func (cs *capabilitySet) Add(capabilities ...string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	for _, cap := range capabilities {
 		cap = ExpandCapability(cap)
 		cs.caps[cap] = struct{}{}
 	}
 }
 
+// Remove removes the capabilities with the URN and version of the given
+// capabilities, ignoring any parameters.
+func (cs *capabilitySet) Remove(capabilities ...string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.remove(capabilities)
+}
+
+// Replace replaces the capabilities with the URN and version of the given
+// capabilities (i.e with different parameters) with the given ones.
+func (cs *capabilitySet) Replace(capabilities ...string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.remove(capabilities)
+	for _, cap := range capabilities {
+		cs.caps[ExpandCapability(cap)] = struct{}{}
+	}
+}
+
+func (cs *capabilitySet) remove(capabilities []string) {
+	for _, cap := range capabilities {
+		want, err := ParseCapability(cap)
+		if err != nil {
+			delete(cs.caps, ExpandCapability(cap))
+			continue
+		}
+		for raw := range cs.caps {
+			c, err := ParseCapability(raw)
+			if err == nil && c.URN == want.URN && c.Version == want.Version {
+				delete(cs.caps, raw)
+			}
+		}
+	}
+}
+
 func (cs capabilitySet) Has(s string) bool {
+	defer cs.rlock()()
+
 	// XXX: need to figure out how to handle versions (i.e always map to 1.0 or
 	// map to latest/any?)
 	s = ExpandCapability(s)
@@ -70,10 +132,180 @@ func (cs capabilitySet) Has(s string) bool {
 	return ok
 }
 
+// Lookup returns the capability matching the URN (and the version, if `s` has
+// one) of `s`, ignoring any parameters.
+func (cs capabilitySet) Lookup(s string) (Capability, bool) {
+	want, err := ParseCapability(s)
+	if err != nil {
+		return Capability{}, false
+	}
+
+	defer cs.rlock()()
+	for raw := range cs.caps {
+		c, err := ParseCapability(raw)
+		if err != nil {
+			continue
+		}
+		if c.URN == want.URN && (want.Version == "" || c.Version == want.Version) {
+			return c, true
+		}
+	}
+	return Capability{}, false
+}
+
 func (cs capabilitySet) All() []string {
+	defer cs.rlock()()
+
 	out := make([]string, 0, len(cs.caps))
 	for cap := range cs.caps {
 		out = append(out, cap)
 	}
 	return out
 }
+
+// ErrCapabilityMissing is returned (wrapped) when an operation or option
+// requires a capability that was not advertised by the server.
+var ErrCapabilityMissing = errors.New("netconf: capability not supported by server")
+
+// requireCapability returns an error wrapping ErrCapabilityMissing unless the
+// server advertised any of the given capabilities.
+func (s *Session) requireCapability(what string, capabilities ...string) error {
+	for _, c := range capabilities {
+		if _, ok := s.serverCaps.Lookup(c); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s requires %s", ErrCapabilityMissing, what, strings.Join(capabilities, " or "))
+}
+
+// checkURL returns an error wrapping ErrCapabilityMissing unless the server
+// advertised the `:url` capability with the scheme of `u`.  If the capability
+// has no scheme list any scheme is allowed.
+func (s *Session) checkURL(u URL) error {
+	c, ok := s.serverCaps.Lookup(":url")
+	if !ok {
+		return fmt.Errorf("%w: url %q requires :url", ErrCapabilityMissing, u)
+	}
+
+	parsed, err := url.Parse(string(u))
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %w", u, err)
+	}
+
+	schemes := c.ParamValues("scheme")
+	if len(schemes) == 0 {
+		return nil
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(scheme, parsed.Scheme) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: url scheme %q not in the supported schemes %s", ErrCapabilityMissing, parsed.Scheme, strings.Join(schemes, ","))
+}
+
+// Capability is a parsed capability URI as exchanged in the `<hello>` messages.
+//
+// For example the capability
+// `urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit&also-supported=report-all-tagged,trim`
+// is parsed into:
+//
+//	Capability{
+//		URN:     "urn:ietf:params:netconf:capability:with-defaults",
+//		Version: "1.0",
+//		Params: map[string]string{
+//			"basic-mode":     "explicit",
+//			"also-supported": "report-all-tagged,trim",
+//		},
+//	}
+type Capability struct {
+	// URN is the capability identifier without the version or parameters.
+	URN string
+
+	// Version is the version of the capability (i.e `1.0`) if the capability
+	// has one.
+	Version string
+
+	// Params are the url decoded query parameters of the capability.  If a
+	// parameter is given multiple times the values are joined with a comma.
+	Params map[string]string
+}
+
+var capVersionRe = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// ParseCapability parses a capability URI into it's URN, version and
+// parameters.  Short capabilities (i.e `:candidate:1.0`) are expanded with
+// [ExpandCapability] first.
+func ParseCapability(s string) (Capability, error) {
+	s = ExpandCapability(s)
+	if s == "" {
+		return Capability{}, fmt.Errorf("empty capability")
+	}
+
+	var c Capability
+	urn, query, hasQuery := strings.Cut(s, "?")
+	if i := strings.LastIndexByte(urn, ':'); i >= 0 && capVersionRe.MatchString(urn[i+1:]) {
+		urn, c.Version = urn[:i], urn[i+1:]
+	}
+	c.URN = urn
+
+	if !hasQuery {
+		return c, nil
+	}
+
+	c.Params = make(map[string]string)
+	for _, param := range strings.Split(query, "&") {
+		if param == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(param, "=")
+		key, err := url.PathUnescape(key)
+		if err != nil {
+			return Capability{}, fmt.Errorf("invalid capability parameter %q: %w", param, err)
+		}
+		value, err = url.PathUnescape(value)
+		if err != nil {
+			return Capability{}, fmt.Errorf("invalid capability parameter %q: %w", param, err)
+		}
+
+		if existing, ok := c.Params[key]; ok {
+			value = existing + "," + value
+		}
+		c.Params[key] = value
+	}
+
+	return c, nil
+}
+
+// ParamValues returns the comma separated values of the given parameter (i.e
+// the `also-supported` parameter of the `:with-defaults` capability or the
+// `scheme` parameter of the `:url` capability).
+func (c Capability) ParamValues(key string) []string {
+	v, ok := c.Params[key]
+	if !ok || v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// String returns the capability as a URI.  Parameters are not guaranteed to
+// be in the same order they were parsed in.
+func (c Capability) String() string {
+	var sb strings.Builder
+	sb.WriteString(c.URN)
+	if c.Version != "" {
+		sb.WriteByte(':')
+		sb.WriteString(c.Version)
+	}
+
+	sep := byte('?')
+	for key, value := range c.Params {
+		sb.WriteByte(sep)
+		sb.WriteString(url.PathEscape(key))
+		sb.WriteByte('=')
+		sb.WriteString(url.PathEscape(value))
+		sep = '&'
+	}
+	return sb.String()
+}
@@ -0,0 +1,115 @@
+package netconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCapability(t *testing.T) {
+	tt := []struct {
+		name      string
+		input     string
+		want      Capability
+		shouldErr bool
+	}{
+		{
+			name:  "base",
+			input: "urn:ietf:params:netconf:base:1.1",
+			want:  Capability{URN: "urn:ietf:params:netconf:base", Version: "1.1"},
+		},
+		{
+			name:  "short",
+			input: ":candidate:1.0",
+			want:  Capability{URN: "urn:ietf:params:netconf:capability:candidate", Version: "1.0"},
+		},
+		{
+			name:  "noVersion",
+			input: "urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring",
+			want:  Capability{URN: "urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"},
+		},
+		{
+			name:  "withDefaults",
+			input: "urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit&also-supported=report-all-tagged,trim",
+			want: Capability{
+				URN:     "urn:ietf:params:netconf:capability:with-defaults",
+				Version: "1.0",
+				Params: map[string]string{
+					"basic-mode":     "explicit",
+					"also-supported": "report-all-tagged,trim",
+				},
+			},
+		},
+		{
+			name:  "repeatedParam",
+			input: "urn:ietf:params:netconf:capability:url:1.0?scheme=http&scheme=ftp",
+			want: Capability{
+				URN:     "urn:ietf:params:netconf:capability:url",
+				Version: "1.0",
+				Params:  map[string]string{"scheme": "http,ftp"},
+			},
+		},
+		{
+			name:  "yangModule",
+			input: "http://example.com/ns/mod?module=mod&revision=2023-01-01&deviations=mod%2Ddev",
+			want: Capability{
+				URN: "http://example.com/ns/mod",
+				Params: map[string]string{
+					"module":     "mod",
+					"revision":   "2023-01-01",
+					"deviations": "mod-dev",
+				},
+			},
+		},
+		{
+			name:      "badEscape",
+			input:     "urn:foo?bar=%zz",
+			shouldErr: true,
+		},
+		{
+			name:      "empty",
+			input:     "",
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseCapability(tc.input)
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCapabilityParamValues(t *testing.T) {
+	c, err := ParseCapability("urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit&also-supported=report-all-tagged,trim")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"explicit"}, c.ParamValues("basic-mode"))
+	assert.Equal(t, []string{"report-all-tagged", "trim"}, c.ParamValues("also-supported"))
+	assert.Nil(t, c.ParamValues("missing"))
+}
+
+func TestCapabilitySetLookup(t *testing.T) {
+	cs := newCapabilitySet(
+		"urn:ietf:params:netconf:base:1.1",
+		":url:1.0?scheme=file,sftp",
+	)
+
+	c, ok := cs.Lookup(":url")
+	assert.True(t, ok)
+	assert.Equal(t, "file,sftp", c.Params["scheme"])
+
+	_, ok = cs.Lookup(":url:1.0")
+	assert.True(t, ok)
+
+	_, ok = cs.Lookup("urn:ietf:params:netconf:base:1.0")
+	assert.False(t, ok)
+
+	_, ok = cs.Lookup(":startup")
+	assert.False(t, ok)
+}
@@ -0,0 +1,339 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrComparisonUnsupported is returned by the Compare function of a
+// [DatastoreComparer] that cannot compare the given datastores so that
+// [Session.CompareDatastores] falls back to comparing them locally.
+var ErrComparisonUnsupported = errors.New("netconf: datastore comparison not supported")
+
+// DatastoreComparer describes a vendor specific rpc comparing two datastores
+// on the device.
+type DatastoreComparer struct {
+	// Capability is the capability the device must advertise for the
+	// comparer to be used.
+	Capability string
+	// Compare returns the differences from datastore `a` to datastore `b` in
+	// the format of the device.  It returns [ErrComparisonUnsupported] if the
+	// device can't compare these datastores.
+	Compare func(ctx context.Context, s *Session, a, b Datastore) ([]byte, error)
+}
+
+// JunosDatastoreComparer compares the candidate to the running config of
+// Juniper devices with `<get-configuration compare="rollback">`.  The
+// differences are returned as text in the format of `show | compare`.
+var JunosDatastoreComparer = DatastoreComparer{
+	Capability: "http://xml.juniper.net/netconf/junos/1.0",
+	Compare:    compareJunos,
+}
+
+var (
+	datastoreComparersMu sync.RWMutex
+	datastoreComparers   = []*DatastoreComparer{&JunosDatastoreComparer}
+)
+
+// RegisterDatastoreComparer registers `c` to be used by
+// [Session.CompareDatastores] when the device advertises its capability.
+// Comparers are tried in the reverse order of registration so the last one
+// registered for a capability takes precedence.
+//
+// [JunosDatastoreComparer] is registered by default.
+func RegisterDatastoreComparer(c DatastoreComparer) {
+	datastoreComparersMu.Lock()
+	defer datastoreComparersMu.Unlock()
+	datastoreComparers = append(datastoreComparers, &c)
+}
+
+func compareJunos(ctx context.Context, s *Session, a, b Datastore) ([]byte, error) {
+	// rollback 0 is the committed config.
+	if a != Running || b != Candidate {
+		return nil, ErrComparisonUnsupported
+	}
+
+	type getConfiguration struct {
+		XMLName  xml.Name `xml:"get-configuration"`
+		Compare  string   `xml:"compare,attr"`
+		Rollback string   `xml:"rollback,attr"`
+		Format   string   `xml:"format,attr"`
+	}
+	var resp struct {
+		XMLName xml.Name `xml:"configuration-information"`
+		Output  string   `xml:"configuration-output"`
+	}
+	req := getConfiguration{Compare: "rollback", Rollback: "0", Format: "text"}
+	if err := s.Call(ctx, &req, &resp); err != nil {
+		return nil, err
+	}
+	return []byte(resp.Output), nil
+}
+
+// CompareDatastores returns the differences from datastore `a` to datastore `b`
+// (i.e from [Running] to [Candidate] to review the changes before a commit).
+// An empty result means the datastores are the same.
+//
+// If the device advertises the capability of a registered [DatastoreComparer]
+// (see [RegisterDatastoreComparer]) the comparison is done by the device with
+// a single rpc and the differences are returned in the format of the device.
+// Otherwise both datastores are fetched with [Session.GetConfig] and compared
+// with [CompareConfigs], prefixed with `--- a` and `+++ b` lines naming the
+// datastores.  This fallback has some limitations:
+//
+//   - it isn't atomic, the datastores may change between the two requests.
+//   - both configs are transferred in full, which may be slow for large ones.
+//   - it has no knowledge of the YANG model, so entries of a list are compared
+//     by position and reordered entries show up as changes (see
+//     [CompareConfigs]).
+func (s *Session) CompareDatastores(ctx context.Context, a, b Datastore) ([]byte, error) {
+	datastoreComparersMu.RLock()
+	comparers := append([]*DatastoreComparer(nil), datastoreComparers...)
+	datastoreComparersMu.RUnlock()
+
+	for i := len(comparers) - 1; i >= 0; i-- {
+		c := comparers[i]
+		if _, ok := s.serverCaps.Lookup(c.Capability); !ok {
+			continue
+		}
+		diff, err := c.Compare(ctx, s, a, b)
+		if errors.Is(err, ErrComparisonUnsupported) {
+			continue
+		}
+		return diff, err
+	}
+
+	configA, err := s.GetConfig(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s config: %w", a, err)
+	}
+	configB, err := s.GetConfig(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s config: %w", b, err)
+	}
+
+	diff, err := CompareConfigs(configA, configB)
+	if err != nil || len(diff) == 0 {
+		return nil, err
+	}
+	return append([]byte(fmt.Sprintf("--- %s\n+++ %s\n", a, b)), diff...), nil
+}
+
+// diffContext is the number of unchanged lines around the changes in the
+// output of [CompareConfigs].
+const diffContext = 3
+
+// CompareConfigs compares two encoded configs and returns the differences from
+// `a` to `b` as the hunks of a unified diff (without the file headers), or
+// nil if they are the same.
+//
+// The configs are normalized with [CanonicalizeConfig] first so that
+// differences in their encoding are ignored, and then compared line by line
+// with one element per line (leaves are kept on the line of their element).
+// Without the YANG model the entries of a list are compared by position, so
+// entries in a different order show up as changes.
+func CompareConfigs(a, b []byte) ([]byte, error) {
+	canonA, err := CanonicalizeConfig(a)
+	if err != nil {
+		return nil, err
+	}
+	canonB, err := CanonicalizeConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(canonA, canonB) {
+		return nil, nil
+	}
+
+	lines := diffLines(configLines(canonA), configLines(canonB))
+	return unifiedDiff(lines, diffContext), nil
+}
+
+// configLines splits a canonical config into one line per element indented by
+// depth.  Elements with only text are kept on a single line.
+func configLines(canon []byte) []string {
+	// the text and attribute values of a canonical config are escaped so `<`
+	// and `>` only delimit tags.
+	var tokens []string
+	for len(canon) > 0 {
+		i := bytes.IndexByte(canon, '<')
+		if i < 0 {
+			tokens = append(tokens, string(canon))
+			break
+		}
+		if i > 0 {
+			tokens = append(tokens, string(canon[:i]))
+		}
+		end := bytes.IndexByte(canon[i:], '>')
+		if end < 0 {
+			tokens = append(tokens, string(canon[i:]))
+			break
+		}
+		tokens = append(tokens, string(canon[i:i+end+1]))
+		canon = canon[i+end+1:]
+	}
+
+	isStart := func(tok string) bool {
+		return strings.HasPrefix(tok, "<") && !strings.HasPrefix(tok, "</") && !strings.HasSuffix(tok, "/>")
+	}
+	isText := func(tok string) bool { return !strings.HasPrefix(tok, "<") }
+
+	var (
+		lines []string
+		depth int
+	)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		indent := strings.Repeat("  ", depth)
+		switch {
+		case isStart(tok) && i+2 < len(tokens) && isText(tokens[i+1]) && strings.HasPrefix(tokens[i+2], "</"):
+			lines = append(lines, indent+tok+tokens[i+1]+tokens[i+2])
+			i += 2
+		case isStart(tok):
+			lines = append(lines, indent+tok)
+			depth++
+		case strings.HasPrefix(tok, "</"):
+			depth--
+			lines = append(lines, strings.Repeat("  ", depth)+tok)
+		default:
+			lines = append(lines, indent+tok)
+		}
+	}
+	return lines
+}
+
+// diffLine is a line of a diff: unchanged (' '), removed ('-') or added ('+').
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// diffLines returns the shortest edit script from `a` to `b` using the Myers
+// diff algorithm.
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	// trace holds v before each round to backtrack the path.
+	var trace [][]int
+	var d int
+search:
+	for d = 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var out []diffLine
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			out = append(out, diffLine{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			out = append(out, diffLine{'+', b[y]})
+		} else {
+			x--
+			out = append(out, diffLine{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		out = append(out, diffLine{' ', a[x]})
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// unifiedDiff formats the hunks of `lines` with `context` unchanged lines
+// around the changes.
+func unifiedDiff(lines []diffLine, context int) []byte {
+	// aPos and bPos are the number of lines of a and b before each line.
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if l.op != '+' {
+			aPos[i+1]++
+		}
+		if l.op != '-' {
+			bPos[i+1]++
+		}
+	}
+
+	// hunkStart returns the 1-based start line of a hunk as in diff(1) which
+	// uses the line before for an empty range.
+	hunkStart := func(pos, count int) int {
+		if count == 0 {
+			return pos
+		}
+		return pos + 1
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// extend the hunk to the changes closer than twice the context.
+		start := max(i-context, 0)
+		end := i + 1
+		for j := i + 1; j < len(lines) && j-end <= 2*context; j++ {
+			if lines[j].op != ' ' {
+				end = j + 1
+			}
+		}
+		end = min(end+context, len(lines))
+
+		aCount, bCount := aPos[end]-aPos[start], bPos[end]-bPos[start]
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n",
+			hunkStart(aPos[start], aCount), aCount, hunkStart(bPos[start], bCount), bCount)
+		for _, l := range lines[start:end] {
+			buf.WriteByte(l.op)
+			buf.WriteString(l.text)
+			buf.WriteByte('\n')
+		}
+		i = end
+	}
+	return buf.Bytes()
+}
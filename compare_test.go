@@ -0,0 +1,184 @@
+package netconf
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareConfigs(t *testing.T) {
+	const running = `<system xmlns="urn:example:system"><hostname>r1</hostname>` +
+		`<ntp><server><name>a</name></server><server><name>b</name></server></ntp>` +
+		`<dns><search>example.com</search></dns></system>`
+
+	t.Run("equal", func(t *testing.T) {
+		// only the encoding differs.
+		diff, err := CompareConfigs([]byte(running), []byte(`<?xml version="1.0"?>
+<sys:system xmlns:sys="urn:example:system">
+  <sys:hostname> r1 </sys:hostname>
+  <sys:ntp><sys:server><sys:name>a</sys:name></sys:server><sys:server><sys:name>b</sys:name></sys:server></sys:ntp>
+  <sys:dns><sys:search>example.com</sys:search></sys:dns>
+</sys:system>`))
+		require.NoError(t, err)
+		assert.Nil(t, diff)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		candidate := strings.NewReplacer("<hostname>r1", "<hostname>r2", "<server><name>b</name></server>", "").Replace(running)
+		diff, err := CompareConfigs([]byte(running), []byte(candidate))
+		require.NoError(t, err)
+		assert.Equal(t, `@@ -1,12 +1,9 @@
+ <system xmlns="urn:example:system">
+-  <hostname>r1</hostname>
++  <hostname>r2</hostname>
+   <ntp>
+     <server>
+       <name>a</name>
+     </server>
+-    <server>
+-      <name>b</name>
+-    </server>
+   </ntp>
+   <dns>
+     <search>example.com</search>
+`, string(diff))
+	})
+
+	t.Run("separateHunks", func(t *testing.T) {
+		var a, b strings.Builder
+		a.WriteString("<list>")
+		b.WriteString("<list>")
+		for i := 0; i < 20; i++ {
+			v := strings.Repeat("x", i+1)
+			a.WriteString("<e>" + v + "</e>")
+			if i == 2 || i == 15 {
+				v += "!"
+			}
+			b.WriteString("<e>" + v + "</e>")
+		}
+		a.WriteString("</list>")
+		b.WriteString("</list>")
+
+		diff, err := CompareConfigs([]byte(a.String()), []byte(b.String()))
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(diff), "@@ -"))
+		assert.Contains(t, string(diff), "@@ -1,7 +1,7 @@\n")
+		assert.Contains(t, string(diff), "@@ -14,7 +14,7 @@\n")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := CompareConfigs([]byte(running), []byte("<system>"))
+		assert.Error(t, err)
+	})
+}
+
+func TestDiffLines(t *testing.T) {
+	tt := []struct {
+		name string
+		a, b string
+	}{
+		{"empty", "", ""},
+		{"added", "", "abc"},
+		{"removed", "abc", ""},
+		{"same", "abc", "abc"},
+		{"mixed", "abcabba", "cbabac"},
+		{"prefixSuffix", "xaaay", "xbby"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := strings.Split(tc.a, ""), strings.Split(tc.b, "")
+			lines := diffLines(a, b)
+
+			// the script must turn a into b.
+			var gotA, gotB []string
+			edits := 0
+			for _, l := range lines {
+				if l.op != '+' {
+					gotA = append(gotA, l.text)
+				}
+				if l.op != '-' {
+					gotB = append(gotB, l.text)
+				}
+				if l.op != ' ' {
+					edits++
+				}
+			}
+			assert.Equal(t, tc.a, strings.Join(gotA, ""))
+			assert.Equal(t, tc.b, strings.Join(gotB, ""))
+			if tc.name == "mixed" {
+				// the example of the Myers paper has a shortest script of 5.
+				assert.Equal(t, 5, edits)
+			}
+		})
+	}
+}
+
+func TestCompareDatastores(t *testing.T) {
+	t.Run("fallback", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>` +
+			`<system xmlns="urn:example:system"><hostname>r1</hostname></system></data></rpc-reply>`)
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><data>` +
+			`<system xmlns="urn:example:system"><hostname>r2</hostname></system></data></rpc-reply>`)
+
+		diff, err := sess.CompareDatastores(context.Background(), Running, Candidate)
+		require.NoError(t, err)
+		assert.Equal(t, `--- running
++++ candidate
+@@ -1,3 +1,3 @@
+ <system xmlns="urn:example:system">
+-  <hostname>r1</hostname>
++  <hostname>r2</hostname>
+ </system>
+`, string(diff))
+
+		for _, want := range []string{"<running/>", "<candidate/>"} {
+			sent, err := ts.popReqString()
+			require.NoError(t, err)
+			assert.Contains(t, sent, "<get-config><source>"+want+"</source></get-config>")
+		}
+	})
+
+	t.Run("junos", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(JunosDatastoreComparer.Capability, ":candidate")
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` +
+			`<configuration-information><configuration-output>[edit system]
+-  host-name r1;
++  host-name r2;
+</configuration-output></configuration-information></rpc-reply>`)
+
+		diff, err := sess.CompareDatastores(context.Background(), Running, Candidate)
+		require.NoError(t, err)
+		assert.Equal(t, "[edit system]\n-  host-name r1;\n+  host-name r2;\n", string(diff))
+
+		sent, err := ts.popReqString()
+		require.NoError(t, err)
+		assert.Contains(t, sent, `<get-configuration compare="rollback" rollback="0" format="text"></get-configuration>`)
+	})
+
+	t.Run("junosUnsupported", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(JunosDatastoreComparer.Capability, ":candidate")
+		go sess.recv()
+
+		// the reverse comparison falls back to fetching the configs.
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`)
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><data/></rpc-reply>`)
+
+		diff, err := sess.CompareDatastores(context.Background(), Candidate, Running)
+		require.NoError(t, err)
+		assert.Empty(t, diff)
+	})
+}
@@ -0,0 +1,192 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeLeafList returns the values of all the `xmlName` elements in subtree
+// (i.e a reply of [Session.GetConfig] or [Session.Get]) in document order.  This
+// is the order of the entries of a leaf-list, which is significant for
+// `ordered-by user` leaf-lists.  The values are trimmed of leading and trailing
+// whitespace.
+//
+// Elements are matched anywhere in the subtree.  If the namespace of `xmlName`
+// is empty elements with the local name in any namespace are matched.  An
+// error is returned if a matched element is not a leaf (i.e it has child
+// elements).
+func DecodeLeafList(subtree []byte, xmlName xml.Name) ([]string, error) {
+	leaves, err := decodeLeaves(subtree, xmlName)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(leaves))
+	for _, leaf := range leaves {
+		values = append(values, leaf.value)
+	}
+	return values, nil
+}
+
+// PathNode is a node of an instance-identifier.
+type PathNode struct {
+	// Name is the name of the node with the prefix resolved to it's
+	// namespace.  A node without a prefix has the namespace of it's parent.
+	Name xml.Name
+
+	// Predicates are the predicates of the node without the brackets and
+	// with the prefix of key names removed (i.e `name='eth0'` or `.='foo'` for
+	// a leaf-list entry).
+	Predicates []string
+}
+
+// InstanceIdentifier is a parsed YANG instance-identifier value (see
+// [RFC7950 Section 9.13]).
+//
+// [RFC7950 Section 9.13]: https://www.rfc-editor.org/rfc/rfc7950.html#section-9.13
+type InstanceIdentifier []PathNode
+
+// ParseInstanceIdentifier parses an instance-identifier value like
+//
+//	/if:interfaces/if:interface[if:name='eth0']/if:mtu
+//
+// into it's nodes.  The prefixes of the path are resolved with `prefixes`
+// which maps prefixes to namespaces (i.e the namespaces declared in scope of
+// the leaf holding the value).
+func ParseInstanceIdentifier(value string, prefixes map[string]string) (InstanceIdentifier, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "/") {
+		return nil, fmt.Errorf("invalid instance-identifier %q: must be an absolute path", value)
+	}
+
+	steps, ok := splitPath(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid instance-identifier %q: unterminated predicate", value)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid instance-identifier %q: empty path", value)
+	}
+
+	id := make(InstanceIdentifier, 0, len(steps))
+	var namespace string
+	for i, step := range steps {
+		if !isNCName(step.name) || (step.prefix != "" && !isNCName(step.prefix)) {
+			return nil, fmt.Errorf("invalid instance-identifier %q: invalid node name %q", value, step.name)
+		}
+		switch {
+		case step.prefix != "":
+			ns, ok := prefixes[step.prefix]
+			if !ok {
+				return nil, fmt.Errorf("invalid instance-identifier %q: undeclared prefix %q", value, step.prefix)
+			}
+			namespace = ns
+		case i == 0:
+			return nil, fmt.Errorf("invalid instance-identifier %q: first node %q must have a prefix", value, step.name)
+		}
+		id = append(id, PathNode{
+			Name:       xml.Name{Space: namespace, Local: step.name},
+			Predicates: step.predicates,
+		})
+	}
+	return id, nil
+}
+
+// DecodeInstanceIdentifiers parses the instance-identifier values of all the
+// `xmlName` elements in subtree in document order.  Elements are matched like
+// with [DecodeLeafList] and the prefixes of each value are resolved against
+// the namespaces declared in scope of it's element.
+func DecodeInstanceIdentifiers(subtree []byte, xmlName xml.Name) ([]InstanceIdentifier, error) {
+	leaves, err := decodeLeaves(subtree, xmlName)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]InstanceIdentifier, 0, len(leaves))
+	for _, leaf := range leaves {
+		id, err := ParseInstanceIdentifier(leaf.value, leaf.prefixes)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+type decodedLeaf struct {
+	value string
+	// prefixes are the namespace prefixes in scope of the leaf.
+	prefixes map[string]string
+}
+
+// decodeLeaves returns all the `xmlName` leaves of subtree in document order.
+func decodeLeaves(subtree []byte, xmlName xml.Name) ([]decodedLeaf, error) {
+	if xmlName.Local == "" {
+		return nil, errors.New("failed to decode leaf: element name cannot be empty")
+	}
+
+	var (
+		leaves []decodedLeaf
+		// scopes holds the prefixes declared on every open element.
+		scopes []map[string]string
+		leaf   *decodedLeaf
+		text   bytes.Buffer
+	)
+
+	dec := xml.NewDecoder(bytes.NewReader(subtree))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", xmlName.Local, err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if leaf != nil {
+				return nil, fmt.Errorf("failed to decode %s: not a leaf, has child element %s", xmlName.Local, tok.Name.Local)
+			}
+
+			var scope map[string]string
+			for _, attr := range tok.Attr {
+				if attr.Name.Space == "xmlns" {
+					if scope == nil {
+						scope = make(map[string]string)
+					}
+					scope[attr.Name.Local] = attr.Value
+				}
+			}
+			scopes = append(scopes, scope)
+
+			if tok.Name.Local == xmlName.Local && (xmlName.Space == "" || tok.Name.Space == xmlName.Space) {
+				leaf = &decodedLeaf{prefixes: make(map[string]string)}
+				for _, scope := range scopes {
+					for prefix, ns := range scope {
+						leaf.prefixes[prefix] = ns
+					}
+				}
+				text.Reset()
+			}
+		case xml.EndElement:
+			scopes = scopes[:len(scopes)-1]
+			if leaf != nil {
+				leaf.value = strings.TrimSpace(text.String())
+				leaves = append(leaves, *leaf)
+				leaf = nil
+			}
+		case xml.CharData:
+			if leaf != nil {
+				text.Write(tok)
+			}
+		}
+	}
+	if len(scopes) > 0 {
+		return nil, fmt.Errorf("failed to decode %s: unexpected EOF", xmlName.Local)
+	}
+	return leaves, nil
+}
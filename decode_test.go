@@ -0,0 +1,157 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeLeafList(t *testing.T) {
+	const subtree = `
+<system xmlns="urn:example:system">
+  <dns>
+    <search>c.example</search>
+    <search> a.example </search>
+    <search>b.example</search>
+  </dns>
+  <ntp xmlns="urn:example:ntp">
+    <search>ntp.example</search>
+  </ntp>
+</system>`
+
+	tt := []struct {
+		name    string
+		subtree string
+		xmlName xml.Name
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "ordered",
+			subtree: subtree,
+			xmlName: xml.Name{Space: "urn:example:system", Local: "search"},
+			want:    []string{"c.example", "a.example", "b.example"},
+		},
+		{
+			name:    "otherNamespace",
+			subtree: subtree,
+			xmlName: xml.Name{Space: "urn:example:ntp", Local: "search"},
+			want:    []string{"ntp.example"},
+		},
+		{
+			name:    "anyNamespace",
+			subtree: subtree,
+			xmlName: xml.Name{Local: "search"},
+			want:    []string{"c.example", "a.example", "b.example", "ntp.example"},
+		},
+		{
+			name:    "missing",
+			subtree: subtree,
+			xmlName: xml.Name{Local: "domain"},
+			want:    []string{},
+		},
+		{
+			name:    "notLeaf",
+			subtree: subtree,
+			xmlName: xml.Name{Local: "dns"},
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			subtree: `<dns><search>a.example</search>`,
+			xmlName: xml.Name{Local: "search"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeLeafList([]byte(tc.subtree), tc.xmlName)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseInstanceIdentifier(t *testing.T) {
+	prefixes := map[string]string{
+		"if": "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+		"ip": "urn:ietf:params:xml:ns:yang:ietf-ip",
+	}
+
+	tt := []struct {
+		name    string
+		value   string
+		want    InstanceIdentifier
+		wantErr bool
+	}{
+		{
+			name:  "keyed",
+			value: "/if:interfaces/if:interface[if:name='eth0']/ip:ipv4/ip:mtu",
+			want: InstanceIdentifier{
+				{Name: xml.Name{Space: prefixes["if"], Local: "interfaces"}},
+				{Name: xml.Name{Space: prefixes["if"], Local: "interface"}, Predicates: []string{"name='eth0'"}},
+				{Name: xml.Name{Space: prefixes["ip"], Local: "ipv4"}},
+				{Name: xml.Name{Space: prefixes["ip"], Local: "mtu"}},
+			},
+		},
+		{
+			name:  "inheritedNamespace",
+			value: "/if:interfaces/interface[name='a/b[0]']/description",
+			want: InstanceIdentifier{
+				{Name: xml.Name{Space: prefixes["if"], Local: "interfaces"}},
+				{Name: xml.Name{Space: prefixes["if"], Local: "interface"}, Predicates: []string{"name='a/b[0]'"}},
+				{Name: xml.Name{Space: prefixes["if"], Local: "description"}},
+			},
+		},
+		{name: "relative", value: "if:interfaces", wantErr: true},
+		{name: "empty", value: "/", wantErr: true},
+		{name: "undeclaredPrefix", value: "/sys:system", wantErr: true},
+		{name: "unprefixedRoot", value: "/interfaces", wantErr: true},
+		{name: "unterminated", value: "/if:interfaces/if:interface[if:name='eth0'", wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseInstanceIdentifier(tc.value, prefixes)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDecodeInstanceIdentifiers(t *testing.T) {
+	const subtree = `
+<refs xmlns="urn:example:refs" xmlns:if="urn:ietf:params:xml:ns:yang:ietf-interfaces">
+  <target>/if:interfaces/if:interface[if:name='eth1']</target>
+  <target xmlns:sys="urn:example:system">/sys:system/sys:hostname</target>
+</refs>`
+
+	got, err := DecodeInstanceIdentifiers([]byte(subtree), xml.Name{Space: "urn:example:refs", Local: "target"})
+	require.NoError(t, err)
+	assert.Equal(t, []InstanceIdentifier{
+		{
+			{Name: xml.Name{Space: "urn:ietf:params:xml:ns:yang:ietf-interfaces", Local: "interfaces"}},
+			{Name: xml.Name{Space: "urn:ietf:params:xml:ns:yang:ietf-interfaces", Local: "interface"}, Predicates: []string{"name='eth1'"}},
+		},
+		{
+			{Name: xml.Name{Space: "urn:example:system", Local: "system"}},
+			{Name: xml.Name{Space: "urn:example:system", Local: "hostname"}},
+		},
+	}, got)
+
+	// the sys prefix is only in scope of the second target.
+	_, err = DecodeInstanceIdentifiers([]byte(`<refs xmlns="urn:example:refs"><a xmlns:sys="urn:example:system"/><target>/sys:system</target></refs>`),
+		xml.Name{Local: "target"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultsMode is a with-defaults retrieval mode defined in [RFC6243 3] that
+// controls how leaves with default values are returned.
+//
+// [RFC6243 3]: https://www.rfc-editor.org/rfc/rfc6243.html#section-3
+type DefaultsMode string
+
+const (
+	// DefaultsReportAll returns all leaves including the ones with default
+	// values.
+	DefaultsReportAll DefaultsMode = "report-all"
+	// DefaultsReportAllTagged is like DefaultsReportAll but tags the default
+	// values with the `wd:default` attribute (see [StripDefaultsTagging]).
+	DefaultsReportAllTagged DefaultsMode = "report-all-tagged"
+	// DefaultsTrim omits the leaves set to their default value.
+	DefaultsTrim DefaultsMode = "trim"
+	// DefaultsExplicit returns the leaves that were explicitly set, even to
+	// their default value.
+	DefaultsExplicit DefaultsMode = "explicit"
+)
+
+// checkDefaultsMode returns an error wrapping [ErrCapabilityMissing] unless
+// the device advertised the `:with-defaults` capability with `mode` as it's
+// basic mode or in it's `also-supported` modes.
+func (s *Session) checkDefaultsMode(mode DefaultsMode) error {
+	c, ok := s.serverCaps.Lookup(":with-defaults")
+	if !ok {
+		return fmt.Errorf("%w: with-defaults mode %s requires :with-defaults", ErrCapabilityMissing, mode)
+	}
+
+	if c.Params["basic-mode"] == string(mode) {
+		return nil
+	}
+	for _, supported := range c.ParamValues("also-supported") {
+		if supported == string(mode) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: with-defaults mode %s is not supported by the device", ErrCapabilityMissing, mode)
+}
+
+// StripDefaultsTagging removes the `wd:default` attributes (and the
+// declarations of their namespace prefix) that a device adds to default values
+// when returning config in the `report-all-tagged` with-defaults mode defined in
+// [RFC6243].
+//
+// This makes a config returned by [Session.GetConfig] safe to be used again as
+// the config for [Session.EditConfig] or [Session.CopyConfig] as strict devices
+// will reject the unknown attributes.  Everything else, including the prefixes
+// of any other namespace, is kept as-is.
+//
+// [RFC6243]: https://www.rfc-editor.org/rfc/rfc6243.html
+func StripDefaultsTagging(config []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(config))
+
+	var (
+		buf bytes.Buffer
+		// prefixes bound to the with-defaults namespace for each open element
+		scopes [][]string
+	)
+	buf.Grow(len(config))
+
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var declared []string
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" && attr.Value == NamespaceDefaultAttr {
+					declared = append(declared, attr.Name.Local)
+				}
+			}
+			scopes = append(scopes, declared)
+
+			attrs := make([]xml.Attr, 0, len(t.Attr))
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" && attr.Value == NamespaceDefaultAttr {
+					continue
+				}
+				if isDefaultsPrefix(scopes, attr.Name.Space) {
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			t.Attr = attrs
+			tok = t
+		case xml.EndElement:
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+		}
+
+		if err := writeRawToken(&buf, tok); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func isDefaultsPrefix(scopes [][]string, prefix string) bool {
+	if prefix == "" {
+		return false
+	}
+	for _, declared := range scopes {
+		for _, p := range declared {
+			if p == prefix {
+				return true
+			}
+		}
+	}
+	return false
+}
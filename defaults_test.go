@@ -0,0 +1,59 @@
+package netconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripDefaultsTagging(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "untagged",
+			input: `<interfaces xmlns="urn:example:interfaces"><interface><name>eth0</name></interface></interfaces>`,
+			want:  `<interfaces xmlns="urn:example:interfaces"><interface><name>eth0</name></interface></interfaces>`,
+		},
+		{
+			name: "reportAllTagged",
+			input: `<interfaces xmlns="urn:example:interfaces" xmlns:wd="urn:ietf:params:xml:ns:netconf:default:1.0">` +
+				`<interface><name>eth0</name><mtu wd:default="true">1500</mtu><status>up</status></interface>` +
+				`</interfaces>`,
+			want: `<interfaces xmlns="urn:example:interfaces">` +
+				`<interface><name>eth0</name><mtu>1500</mtu><status>up</status></interface>` +
+				`</interfaces>`,
+		},
+		{
+			name: "otherPrefix",
+			input: `<if:interfaces xmlns:if="urn:example:interfaces">` +
+				`<if:interface xmlns:d="urn:ietf:params:xml:ns:netconf:default:1.0">` +
+				`<if:name>a&amp;b</if:name><if:mtu d:default="true" if:foo="bar">1500</if:mtu>` +
+				`</if:interface></if:interfaces>`,
+			want: `<if:interfaces xmlns:if="urn:example:interfaces">` +
+				`<if:interface>` +
+				`<if:name>a&amp;b</if:name><if:mtu if:foo="bar">1500</if:mtu>` +
+				`</if:interface></if:interfaces>`,
+		},
+		{
+			name:  "multiLine",
+			input: "<banner xmlns:wd=\"urn:ietf:params:xml:ns:netconf:default:1.0\" note=\"a &quot;b&quot;\">line 1\n\tline 2 &lt;x&gt;</banner>",
+			want:  "<banner note=\"a &quot;b&quot;\">line 1\n\tline 2 &lt;x&gt;</banner>",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := StripDefaultsTagging([]byte(tc.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestStripDefaultsTaggingInvalid(t *testing.T) {
+	_, err := StripDefaultsTagging([]byte(`<foo><bar a="1></bar></foo>`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,344 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EditBuilder builds a single config with a different operation (see
+// [MergeStrategy]) for each of the edited nodes, so that several changes can be
+// sent in one `<edit-config>`.  Together with [RollbackOnError] the changes are
+// applied atomically.
+//
+// Nodes are addressed with the same XPath subset as [WithFilter], where
+// `[key='value']` predicates select list entries:
+//
+//	b := netconf.NewEditBuilder("urn:ietf:params:xml:ns:yang:ietf-interfaces").
+//		Create("/interfaces/interface[name='eth1']/mtu", 9000).
+//		Merge("/interfaces/interface[name='eth0']/description", "uplink").
+//		Delete("/interfaces/interface[name='eth2']")
+//	if err := sess.EditConfig(ctx, netconf.Candidate, b); err != nil { /* ... */ }
+//
+// Entries of `ordered-by user` lists (i.e firewall rules) are positioned with
+// [EditBuilder.InsertFirst], [EditBuilder.InsertLast], [EditBuilder.InsertBefore]
+// and [EditBuilder.InsertAfter]:
+//
+//	b := netconf.NewEditBuilder("urn:example:acl").
+//		Create("/acl/rule[name='deny-ssh']/action", "deny").
+//		InsertAfter("/acl/rule[name='deny-ssh']", "[name='allow-icmp']")
+//
+// An EditBuilder is used directly as the config argument of
+// [Session.EditConfig].  Any invalid path is reported when the request is
+// encoded (or with [EditBuilder.Err]).
+type EditBuilder struct {
+	namespace string
+	root      editNode
+	err       error
+}
+
+type editNode struct {
+	name     string
+	keys     []xpathMatch
+	op       MergeStrategy
+	value    any
+	insert   *editInsert
+	children []*editNode
+}
+
+// editInsert is the position of a list entry set with one of the Insert
+// methods of [EditBuilder].
+type editInsert struct {
+	where string
+	// keys are the keys of the entry the node is inserted before or after.
+	keys []xpathMatch
+}
+
+// child returns the child with the given name and keys creating it if it
+// doesn't exist.
+func (n *editNode) child(name string, keys []xpathMatch) *editNode {
+	for _, c := range n.children {
+		if c.name == name && reflect.DeepEqual(c.keys, keys) {
+			return c
+		}
+	}
+	c := &editNode{name: name, keys: keys}
+	n.children = append(n.children, c)
+	return c
+}
+
+// NewEditBuilder returns a new [EditBuilder].  `namespace` is set as the default
+// namespace of the top level elements (usually the namespace of the YANG model)
+// and may be empty.
+func NewEditBuilder(namespace string) *EditBuilder {
+	return &EditBuilder{namespace: namespace}
+}
+
+// Create adds the node at `path` with the `create` operation which fails if
+// the node already exists.
+func (b *EditBuilder) Create(path string, value any) *EditBuilder {
+	return b.add(CreateConfig, path, value)
+}
+
+// Merge adds the node at `path` with the `merge` operation.
+func (b *EditBuilder) Merge(path string, value any) *EditBuilder {
+	return b.add(MergeConfig, path, value)
+}
+
+// Replace adds the node at `path` with the `replace` operation.
+func (b *EditBuilder) Replace(path string, value any) *EditBuilder {
+	return b.add(ReplaceConfig, path, value)
+}
+
+// Delete deletes the node at `path`.  This fails if the node doesn't exist.
+func (b *EditBuilder) Delete(path string) *EditBuilder {
+	return b.add(DeleteConfig, path, nil)
+}
+
+// Remove removes the node at `path` if it exists.
+func (b *EditBuilder) Remove(path string) *EditBuilder {
+	return b.add(RemoveConfig, path, nil)
+}
+
+// Err returns the first error from adding an invalid path.
+func (b *EditBuilder) Err() error {
+	return b.err
+}
+
+// InsertFirst inserts the list entry at `path` as the first entry of the
+// list.  The list must be `ordered-by user`.  The entry is created or updated
+// with the operation of any other edit of the same path (or merged by default).
+func (b *EditBuilder) InsertFirst(path string) *EditBuilder {
+	return b.insert(path, "first", "")
+}
+
+// InsertLast inserts the list entry at `path` as the last entry of the list.
+// See [EditBuilder.InsertFirst].
+func (b *EditBuilder) InsertLast(path string) *EditBuilder {
+	return b.insert(path, "last", "")
+}
+
+// InsertBefore inserts the list entry at `path` before the entry with the
+// given keys (i.e `[name='allow-icmp']`).  See [EditBuilder.InsertFirst].
+func (b *EditBuilder) InsertBefore(path, keyPredicate string) *EditBuilder {
+	return b.insert(path, "before", keyPredicate)
+}
+
+// InsertAfter inserts the list entry at `path` after the entry with the given
+// keys (i.e `[name='allow-icmp']`).  See [EditBuilder.InsertFirst].
+func (b *EditBuilder) InsertAfter(path, keyPredicate string) *EditBuilder {
+	return b.insert(path, "after", keyPredicate)
+}
+
+// isInsertable reports if a node with the operation can be positioned.
+func isInsertable(op MergeStrategy) bool {
+	return op != DeleteConfig && op != RemoveConfig
+}
+
+func (b *EditBuilder) add(op MergeStrategy, path string, value any) *EditBuilder {
+	if b.err != nil {
+		return b
+	}
+
+	n, err := b.node(path)
+	if err != nil {
+		b.err = err
+		return b
+	}
+
+	if n.op != "" && n.op != op {
+		b.err = fmt.Errorf("conflicting operations %q and %q on path %q", n.op, op, path)
+		return b
+	}
+	if n.insert != nil && !isInsertable(op) {
+		b.err = fmt.Errorf("operation %q on path %q cannot be combined with insert", op, path)
+		return b
+	}
+	n.op = op
+	n.value = value
+	return b
+}
+
+func (b *EditBuilder) insert(path, where, keyPredicate string) *EditBuilder {
+	if b.err != nil {
+		return b
+	}
+
+	n, err := b.node(path)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	if len(n.keys) == 0 {
+		b.err = fmt.Errorf("invalid path %q: insert requires a list entry selected with [key='value'] predicates", path)
+		return b
+	}
+	if !isInsertable(n.op) {
+		b.err = fmt.Errorf("operation %q on path %q cannot be combined with insert", n.op, path)
+		return b
+	}
+
+	ins := &editInsert{where: where}
+	if where == "before" || where == "after" {
+		steps, err := parseXPath("/entry" + keyPredicate)
+		if err != nil || len(steps) != 1 || len(steps[0].matches) != len(n.keys) {
+			b.err = fmt.Errorf("invalid key predicate %q for path %q: must be [key='value'] predicates for all the keys of the list", keyPredicate, path)
+			return b
+		}
+		for _, k := range n.keys {
+			if !steps[0].hasMatch(k.name) {
+				b.err = fmt.Errorf("invalid key predicate %q for path %q: missing key %q", keyPredicate, path, k.name)
+				return b
+			}
+		}
+		ins.keys = steps[0].matches
+	}
+
+	if n.insert != nil && !reflect.DeepEqual(n.insert, ins) {
+		b.err = fmt.Errorf("conflicting insert positions on path %q", path)
+		return b
+	}
+	n.insert = ins
+	return b
+}
+
+// node returns the node at `path` creating it (and it's ancestors) if it
+// doesn't exist.
+func (b *EditBuilder) node(path string) (*editNode, error) {
+	steps, err := parseXPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &b.root
+	for _, step := range steps {
+		if step.prefix != "" {
+			return nil, fmt.Errorf("invalid path %q: namespace prefixes are not supported", path)
+		}
+		n = n.child(step.name, step.matches)
+	}
+	return n, nil
+}
+
+// MarshalXML encodes the edits as a `<config>` element.
+func (b *EditBuilder) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if b.err != nil {
+		return b.err
+	}
+
+	start.Attr = append(start.Attr, xml.Attr{
+		Name:  xml.Name{Local: "xmlns:nc"},
+		Value: NamespaceBase10,
+	})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, n := range b.root.children {
+		if err := encodeEditNode(e, n, b.namespace, true); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// insertKeyPrefix is the prefix declared for the namespace of a list to
+// qualify the key names of the `yang:key` attribute.
+const insertKeyPrefix = "ins"
+
+// encodeEditNode encodes `n` and it's children.  `namespace` is the namespace
+// of the node which is declared if `top` is set.
+func encodeEditNode(e *xml.Encoder, n *editNode, namespace string, top bool) error {
+	start := xml.StartElement{Name: xml.Name{Local: n.name}}
+	if top && namespace != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: namespace})
+	}
+	if n.op != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nc:operation"}, Value: string(n.op)})
+	}
+	if n.insert != nil {
+		attrs, err := insertAttrs(n.insert, namespace)
+		if err != nil {
+			return fmt.Errorf("invalid insert for %q: %w", n.name, err)
+		}
+		start.Attr = append(start.Attr, attrs...)
+	}
+
+	// structs are encoded as the contents of the node.
+	if n.value != nil && len(n.keys) == 0 && len(n.children) == 0 && isStruct(n.value) {
+		return e.EncodeElement(n.value, start)
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	// list keys must come first
+	for _, k := range n.keys {
+		if err := e.EncodeElement(k.value, xml.StartElement{Name: xml.Name{Local: k.name}}); err != nil {
+			return err
+		}
+	}
+
+	if n.value != nil {
+		if isStruct(n.value) {
+			return fmt.Errorf("struct value for %q cannot be combined with list keys or child nodes", n.name)
+		}
+		if err := e.EncodeToken(xml.CharData(fmt.Sprint(n.value))); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range n.children {
+		if err := encodeEditNode(e, c, namespace, false); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// insertAttrs returns the `yang:insert` and `yang:key` attributes (see [RFC7950
+// 7.8.6]) positioning a list entry in `namespace`.  The key names are
+// qualified as required for the instance-identifier predicates of the
+// `yang:key` attribute.
+//
+// [RFC7950 7.8.6]: https://www.rfc-editor.org/rfc/rfc7950.html#section-7.8.6
+func insertAttrs(ins *editInsert, namespace string) ([]xml.Attr, error) {
+	attrs := []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:yang"}, Value: NamespaceYANG},
+		{Name: xml.Name{Local: "yang:insert"}, Value: ins.where},
+	}
+	if len(ins.keys) == 0 {
+		return attrs, nil
+	}
+
+	prefix := ""
+	if namespace != "" {
+		prefix = insertKeyPrefix + ":"
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "xmlns:" + insertKeyPrefix}, Value: namespace})
+	}
+
+	var sb strings.Builder
+	for _, k := range ins.keys {
+		quote := "'"
+		if strings.Contains(k.value, quote) {
+			quote = `"`
+			if strings.Contains(k.value, quote) {
+				return nil, fmt.Errorf("key value %q cannot contain both quote characters", k.value)
+			}
+		}
+		sb.WriteString("[" + prefix + k.name + "=" + quote + k.value + quote + "]")
+	}
+	return append(attrs, xml.Attr{Name: xml.Name{Local: "yang:key"}, Value: sb.String()}), nil
+}
+
+func isStruct(v any) bool {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return rv.Kind() == reflect.Struct
+}
@@ -0,0 +1,116 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditBuilder(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(":candidate")
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	b := NewEditBuilder("urn:example:interfaces").
+		Create("/interfaces/interface[name='eth1']/mtu", 9000).
+		Create("/interfaces/interface[name='eth1']/description", "a & b").
+		Delete("/interfaces/interface[name='eth2']")
+
+	err := sess.EditConfig(context.Background(), Candidate, b)
+	assert.NoError(t, err)
+
+	want := `<config xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0">` +
+		`<interfaces xmlns="urn:example:interfaces">` +
+		`<interface><name>eth1</name>` +
+		`<mtu nc:operation="create">9000</mtu>` +
+		`<description nc:operation="create">a &amp; b</description>` +
+		`</interface>` +
+		`<interface nc:operation="delete"><name>eth2</name></interface>` +
+		`</interfaces></config>`
+	req, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, req, want)
+}
+
+func TestEditBuilderStruct(t *testing.T) {
+	type system struct {
+		Hostname string `xml:"host-name"`
+	}
+
+	b := NewEditBuilder("").Replace("/system", &system{Hostname: "darkstar"})
+	out, err := xml.Marshal(b)
+	assert.NoError(t, err)
+	assert.Equal(t, `<EditBuilder xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0"><system nc:operation="replace"><host-name>darkstar</host-name></system></EditBuilder>`, string(out))
+}
+
+func TestEditBuilderInsert(t *testing.T) {
+	tt := []struct {
+		name string
+		b    *EditBuilder
+		want string
+	}{
+		{
+			name: "first",
+			b: NewEditBuilder("urn:example:acl").
+				Create("/acl/rule[name='deny-ssh']/action", "deny").
+				InsertFirst("/acl/rule[name='deny-ssh']"),
+			want: `<acl xmlns="urn:example:acl">` +
+				`<rule xmlns:yang="urn:ietf:params:xml:ns:yang:1" yang:insert="first"><name>deny-ssh</name>` +
+				`<action nc:operation="create">deny</action></rule></acl>`,
+		},
+		{
+			name: "afterKey",
+			b: NewEditBuilder("urn:example:acl").
+				InsertAfter("/acl/rule[name='deny-ssh']", "[name='allow-icmp']").
+				Create("/acl/rule[name='deny-ssh']", nil),
+			want: `<acl xmlns="urn:example:acl">` +
+				`<rule nc:operation="create" xmlns:yang="urn:ietf:params:xml:ns:yang:1" yang:insert="after" ` +
+				`xmlns:ins="urn:example:acl" yang:key="[ins:name=&#39;allow-icmp&#39;]"><name>deny-ssh</name></rule></acl>`,
+		},
+		{
+			name: "beforeMultipleKeys",
+			b: NewEditBuilder("").
+				InsertBefore("/nat/rule[zone='wan'][seq='10']", `[seq="5"][zone="it's"]`),
+			want: `<nat><rule xmlns:yang="urn:ietf:params:xml:ns:yang:1" yang:insert="before" ` +
+				`yang:key="[seq=&#39;5&#39;][zone=&#34;it&#39;s&#34;]"><zone>wan</zone><seq>10</seq></rule></nat>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := xml.Marshal(tc.b)
+			assert.NoError(t, err)
+			assert.Equal(t, `<EditBuilder xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0">`+tc.want+`</EditBuilder>`, string(out))
+		})
+	}
+}
+
+func TestEditBuilderInvalid(t *testing.T) {
+	tt := []struct {
+		name string
+		b    *EditBuilder
+	}{
+		{"relative path", NewEditBuilder("").Merge("system", "x")},
+		{"positional", NewEditBuilder("").Delete("/interfaces/interface[1]")},
+		{"prefix", NewEditBuilder("").Delete("/if:interfaces")},
+		{"conflict", NewEditBuilder("").Delete("/system").Merge("/system", "x")},
+		{"insertNotListEntry", NewEditBuilder("").InsertFirst("/acl/rule")},
+		{"insertMissingKey", NewEditBuilder("").InsertAfter("/nat/rule[zone='wan'][seq='10']", "[seq='5']")},
+		{"insertInvalidKey", NewEditBuilder("").InsertBefore("/acl/rule[name='a']", "name='b'")},
+		{"insertDelete", NewEditBuilder("").Delete("/acl/rule[name='a']").InsertLast("/acl/rule[name='a']")},
+		{"insertConflict", NewEditBuilder("").InsertFirst("/acl/rule[name='a']").InsertLast("/acl/rule[name='a']")},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Error(t, tc.b.Err())
+			_, err := xml.Marshal(tc.b)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package netconf_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DinbandhuKumarSingh/netconf"
+)
+
+// hostname is the code under test.  It accepts netconf.Operations so it can be
+// used with both a real session and a mock.
+func hostname(ctx context.Context, ops netconf.Operations) (string, error) {
+	config, err := ops.GetConfig(ctx, netconf.Running)
+	if err != nil {
+		return "", err
+	}
+	return string(config), nil
+}
+
+func ExampleMockSession() {
+	mock := netconf.NewMockSession()
+	defer mock.Close(context.Background())
+
+	mock.On(&netconf.GetConfigReq{Source: netconf.Running}).
+		Return([]byte("<system><hostname>router1</hostname></system>"))
+
+	name, err := hostname(context.Background(), mock)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(name)
+	// Output: <system><hostname>router1</hostname></system>
+}
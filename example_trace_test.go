@@ -0,0 +1,38 @@
+package netconf_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DinbandhuKumarSingh/netconf"
+)
+
+// printTracer is a netconf.Tracer that prints the spans.  An adapter for a real
+// tracing library (i.e OpenTelemetry) would start a span on the library's
+// tracer instead.
+type printTracer struct{}
+
+func (printTracer) Start(ctx context.Context, name string) (context.Context, netconf.Span) {
+	fmt.Println("start", name)
+	return ctx, printSpan{}
+}
+
+type printSpan struct{}
+
+func (printSpan) SetAttribute(key string, value any) { fmt.Println(" ", key, "=", value) }
+func (printSpan) RecordError(err error)              { fmt.Println("  error:", err) }
+func (printSpan) End()                               { fmt.Println("end") }
+
+func ExampleWithTracer() {
+	sess := netconf.NewMockSession(netconf.WithTracer(printTracer{}))
+	sess.On(&netconf.LockReq{Target: netconf.Candidate})
+
+	if err := sess.Lock(context.Background(), netconf.Candidate); err != nil {
+		panic(err)
+	}
+	// Output:
+	// start netconf lock
+	//   netconf.message_id = 1
+	//   netconf.target = candidate
+	// end
+}
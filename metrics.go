@@ -0,0 +1,125 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// MetricOutcome is the result of a rpc reported in a [MetricEvent].
+type MetricOutcome string
+
+const (
+	// OutcomeOK is a rpc that got a reply without any rpc-errors of severity
+	// `error`.
+	OutcomeOK MetricOutcome = "ok"
+	// OutcomeRPCError is a rpc that got a reply with rpc-errors.
+	OutcomeRPCError MetricOutcome = "rpc-error"
+	// OutcomeTransportError is a rpc that failed on the transport (see
+	// [TransportError]).
+	OutcomeTransportError MetricOutcome = "transport-error"
+	// OutcomeError is a rpc that failed for any other reason like a canceled
+	// context or a request that cannot be encoded.
+	OutcomeError MetricOutcome = "error"
+)
+
+// MetricEvent describes a single rpc issued on a session.  See
+// [WithMetricsObserver].
+type MetricEvent struct {
+	// Operation is the name of the operation element (i.e `get-config`).
+	Operation string
+	// MessageID is the message-id of the rpc.  It is 0 if the id is not a
+	// number (see [WithMessageID]).
+	MessageID uint64
+	// RawMessageID is the message-id of the rpc as sent.
+	RawMessageID string
+	// Duration is the time from sending the request until receiving the reply
+	// or the failure.
+	Duration time.Duration
+	// BytesSent is the size of the encoded request.
+	BytesSent int
+	// BytesReceived is the size of the encoded reply.  0 if no reply was
+	// received.
+	BytesReceived int
+	Outcome       MetricOutcome
+	// Err is the error for the rpc, if any.  rpc-errors of a reply are
+	// included.
+	Err error
+}
+
+func (s *Session) observe(req any, msgID string, start time.Time, sent int, reply *Reply, err error) {
+	ev := MetricEvent{
+		Operation:    operationName(req),
+		MessageID:    numericMessageID(msgID),
+		RawMessageID: msgID,
+		Duration:     time.Since(start),
+		BytesSent:    sent,
+		Err:          err,
+	}
+	if reply != nil {
+		ev.BytesReceived = reply.size
+		if ev.Err == nil {
+			ev.Err = reply.Err()
+		}
+	}
+
+	var rpcErr RPCError
+	switch {
+	case ev.Err == nil:
+		ev.Outcome = OutcomeOK
+	case IsTransportError(ev.Err):
+		ev.Outcome = OutcomeTransportError
+	case errors.As(ev.Err, &rpcErr):
+		ev.Outcome = OutcomeRPCError
+	default:
+		ev.Outcome = OutcomeError
+	}
+
+	s.metricsObserver(ev)
+}
+
+// operationName returns the xml element name of a operation struct using the
+// same rules as encoding/xml (XMLName value, then the XMLName tag, then the
+// type name).
+func operationName(v any) string {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return rv.Type().Name()
+	}
+
+	if f, ok := rv.Type().FieldByName("XMLName"); ok {
+		if name, ok := rv.FieldByIndex(f.Index).Interface().(xml.Name); ok && name.Local != "" {
+			return name.Local
+		}
+		tag, _, _ := strings.Cut(f.Tag.Get("xml"), ",")
+		if i := strings.LastIndexByte(tag, ' '); i >= 0 {
+			tag = tag[i+1:]
+		}
+		if tag != "" {
+			return tag
+		}
+	}
+	return rv.Type().Name()
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += n
+	return n, err
+}
@@ -0,0 +1,82 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsObserver(t *testing.T) {
+	var events []MetricEvent
+	observer := func(ev MetricEvent) { events = append(events, ev) }
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithMetricsObserver(observer))
+	go sess.recv()
+
+	okReply := `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`
+	ts.queueRespString(okReply)
+	assert.NoError(t, sess.Lock(context.Background(), Candidate))
+	sent, err := ts.popReq()
+	assert.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><rpc-error><error-type>protocol</error-type><error-tag>lock-denied</error-tag><error-severity>error</error-severity></rpc-error></rpc-reply>`)
+	assert.Error(t, sess.Unlock(context.Background(), Candidate))
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+
+	assert.Len(t, events, 2)
+
+	assert.Equal(t, "lock", events[0].Operation)
+	assert.Equal(t, uint64(1), events[0].MessageID)
+	assert.Equal(t, OutcomeOK, events[0].Outcome)
+	assert.Equal(t, len(sent), events[0].BytesSent)
+	assert.Equal(t, len(okReply), events[0].BytesReceived)
+	assert.NoError(t, events[0].Err)
+
+	assert.Equal(t, "unlock", events[1].Operation)
+	assert.Equal(t, uint64(2), events[1].MessageID)
+	assert.Equal(t, OutcomeRPCError, events[1].Outcome)
+	assert.Error(t, events[1].Err)
+}
+
+func TestMetricsObserverTransportError(t *testing.T) {
+	var events []MetricEvent
+	sess := newSession(&failingTransport{err: io.ErrClosedPipe}, WithMetricsObserver(func(ev MetricEvent) {
+		events = append(events, ev)
+	}))
+
+	assert.Error(t, sess.Lock(context.Background(), Candidate))
+	assert.Len(t, events, 1)
+	assert.Equal(t, OutcomeTransportError, events[0].Outcome)
+	assert.Equal(t, 0, events[0].BytesReceived)
+}
+
+func TestOperationName(t *testing.T) {
+	type noName struct{}
+	type tagged struct {
+		XMLName xml.Name `xml:"urn:example foo"`
+	}
+
+	tt := []struct {
+		name string
+		req  any
+		want string
+	}{
+		{"tagged", &ValidateReq{}, "validate"},
+		{"namespaced", &tagged{}, "foo"},
+		{"value", &struct{ XMLName xml.Name }{XMLName: xml.Name{Local: "bar"}}, "bar"},
+		{"typeName", noName{}, "noName"},
+		{"nil", (*LockReq)(nil), ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var req any = tc.req
+			assert.Equal(t, tc.want, operationName(&req))
+		})
+	}
+}
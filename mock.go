@@ -0,0 +1,230 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// MockSession is a [Session] backed by an in-memory device that answers with
+// canned replies.  It is meant for unit testing code that uses this package.
+//
+// Expected requests are registered with [MockSession.On].  A request that does
+// not match any expectation is answered with an `operation-not-supported`
+// rpc-error.  The server capabilities of the mock are the client capabilities
+// of the session (see [WithCapability]).
+type MockSession struct {
+	*Session
+
+	mu    sync.Mutex
+	calls []*MockCall
+}
+
+// MockCall is an expected request of a [MockSession] and the reply to answer it
+// with.  By default a matching request is answered with `<ok/>`.
+type MockCall struct {
+	req   []byte
+	reply []byte
+	count int
+}
+
+// NewMockSession returns a new [MockSession].  `opts` are applied to the
+// underlying [Session].
+func NewMockSession(opts ...SessionOption) *MockSession {
+	m := &MockSession{}
+
+	tr := &mockTransport{
+		handle:  m.handle,
+		replies: make(chan []byte),
+		closed:  make(chan struct{}),
+	}
+	m.Session = newSession(tr, opts...)
+	m.Session.sessionID = 1
+	m.Session.serverCaps = newCapabilitySet(m.Session.clientCaps.All()...)
+	go m.Session.recv()
+
+	return m
+}
+
+// On registers an expected request.  `req` is a operation struct (i.e
+// `&GetConfigReq{Source: Running}`) and matches a request issued on the session
+// that encodes to the same xml.  Expectations are matched in the order they are
+// registered and may match any number of times.
+func (m *MockSession) On(req any) *MockCall {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	selfClosing := &selfClosingWriter{w: &buf}
+	if m.Session.selfClosingTags {
+		w = selfClosing
+	}
+	if err := xml.NewEncoder(w).Encode(req); err != nil {
+		panic(fmt.Sprintf("netconf: mock cannot encode request %T: %v", req, err))
+	}
+	selfClosing.Flush()
+
+	call := &MockCall{
+		req:   buf.Bytes(),
+		reply: []byte("<ok/>"),
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	m.mu.Unlock()
+	return call
+}
+
+// Return answers the request with `data` as the contents of a `<data>` element
+// (i.e the config returned by [Session.GetConfig]).
+func (c *MockCall) Return(data []byte) *MockCall {
+	var buf bytes.Buffer
+	buf.WriteString("<data>")
+	buf.Write(data)
+	buf.WriteString("</data>")
+	c.reply = buf.Bytes()
+	return c
+}
+
+// ReturnReply answers the request with `body` as the raw contents of the
+// `<rpc-reply>`.
+func (c *MockCall) ReturnReply(body []byte) *MockCall {
+	c.reply = body
+	return c
+}
+
+// ReturnError answers the request with the given rpc-errors.
+func (c *MockCall) ReturnError(errs ...RPCError) *MockCall {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	for _, e := range errs {
+		if err := enc.EncodeElement(e, xml.StartElement{Name: xml.Name{Local: "rpc-error"}}); err != nil {
+			panic(fmt.Sprintf("netconf: mock cannot encode rpc-error: %v", err))
+		}
+	}
+	enc.Flush()
+	c.reply = buf.Bytes()
+	return c
+}
+
+// Called returns how many requests matched the expectation.
+func (m *MockSession) Called(call *MockCall) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return call.count
+}
+
+// AssertExpectations reports an error to `t` for every expectation that was
+// never matched.
+func (m *MockSession) AssertExpectations(t interface {
+	Helper()
+	Errorf(format string, args ...any)
+},
+) bool {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok := true
+	for _, call := range m.calls {
+		if call.count == 0 {
+			t.Errorf("netconf: expected request was not issued: %s", call.req)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// handle answers a single `<rpc>` message written to the mock transport.
+func (m *MockSession) handle(msg []byte) []byte {
+	var rpc struct {
+		MessageID string `xml:"message-id,attr"`
+		Inner     []byte `xml:",innerxml"`
+	}
+	if err := xml.Unmarshal(msg, &rpc); err != nil {
+		panic(fmt.Sprintf("netconf: mock received invalid message: %v", err))
+	}
+
+	var reply []byte
+	if bytes.HasPrefix(rpc.Inner, []byte("<close-session")) {
+		reply = []byte("<ok/>")
+	} else {
+		reply = m.match(rpc.Inner)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<rpc-reply xmlns="%s" message-id="%s">`, NamespaceBase10, rpc.MessageID)
+	buf.Write(reply)
+	buf.WriteString(`</rpc-reply>`)
+	return buf.Bytes()
+}
+
+func (m *MockSession) match(req []byte) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, call := range m.calls {
+		if bytes.Equal(call.req, req) {
+			call.count++
+			return call.reply
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<rpc-error><error-type>protocol</error-type><error-tag>operation-not-supported</error-tag><error-severity>error</error-severity><error-message>netconf mock: unexpected request: ")
+	xml.EscapeText(&buf, req)
+	buf.WriteString("</error-message></rpc-error>")
+	return buf.Bytes()
+}
+
+// mockTransport is a in-memory transport that hands every written message to
+// `handle` and returns the result as the next message to be read.
+type mockTransport struct {
+	handle  func(msg []byte) []byte
+	replies chan []byte
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (t *mockTransport) MsgReader() (io.ReadCloser, error) {
+	select {
+	case reply := <-t.replies:
+		return io.NopCloser(bytes.NewReader(reply)), nil
+	case <-t.closed:
+		return nil, io.EOF
+	}
+}
+
+func (t *mockTransport) MsgWriter() (io.WriteCloser, error) {
+	select {
+	case <-t.closed:
+		return nil, io.ErrClosedPipe
+	default:
+	}
+	return &mockMsgWriter{t: t}, nil
+}
+
+func (t *mockTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+type mockMsgWriter struct {
+	bytes.Buffer
+	t *mockTransport
+}
+
+func (w *mockMsgWriter) Close() error {
+	reply := w.t.handle(w.Bytes())
+
+	// replies are delivered asynchronously as the session holds it's lock while
+	// writing.
+	go func() {
+		select {
+		case w.t.replies <- reply:
+		case <-w.t.closed:
+		}
+	}()
+	return nil
+}
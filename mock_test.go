@@ -0,0 +1,61 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockSession(t *testing.T) {
+	mock := NewMockSession()
+	defer mock.Close(context.Background())
+
+	getCall := mock.On(&GetConfigReq{Source: Running}).Return([]byte("<foo>bar</foo>"))
+	lockCall := mock.On(&LockReq{Target: Candidate}).ReturnError(RPCError{
+		Type:     ErrTypeProtocol,
+		Tag:      ErrLockDenied,
+		Severity: SevError,
+		Message:  "locked",
+	})
+
+	ctx := context.Background()
+
+	config, err := mock.GetConfig(ctx, Running)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("<foo>bar</foo>"), config)
+
+	err = mock.Lock(ctx, Candidate)
+	var rpcErr RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, ErrLockDenied, rpcErr.Tag)
+
+	// not expected
+	err = mock.Lock(ctx, Running)
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, ErrOperationNotSupported, rpcErr.Tag)
+
+	assert.Equal(t, 1, mock.Called(getCall))
+	assert.Equal(t, 1, mock.Called(lockCall))
+	assert.True(t, mock.AssertExpectations(t))
+}
+
+type recordingT struct {
+	errors int
+}
+
+func (t *recordingT) Helper()                           {}
+func (t *recordingT) Errorf(format string, args ...any) { t.errors++ }
+
+func TestMockSessionAssertExpectations(t *testing.T) {
+	mock := NewMockSession()
+	defer mock.Close(context.Background())
+
+	mock.On(&LockReq{XMLName: xml.Name{Local: "unlock"}, Target: Candidate})
+
+	var rt recordingT
+	assert.False(t, mock.AssertExpectations(&rt))
+	assert.Equal(t, 1, rt.errors)
+}
@@ -0,0 +1,168 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SchemaInfo describes a schema (i.e a YANG module) available on the device as
+// listed in `/netconf-state/schemas` of the ietf-netconf-monitoring model.
+type SchemaInfo struct {
+	// Identifier is the name of the schema (i.e the YANG module name).
+	Identifier string `xml:"identifier"`
+	// Version is the version of the schema (i.e the YANG revision).
+	Version string `xml:"version"`
+	// Format is the schema language (i.e `yang` or `yin`) without any
+	// namespace prefix.
+	Format string `xml:"format"`
+	// Namespace is the XML namespace defined by the schema.
+	Namespace string `xml:"namespace"`
+	// Location is where the schema can be retrieved from.  `NETCONF` means the
+	// schema can be retrieved with `<get-schema>`.
+	Location []string `xml:"location"`
+}
+
+// ListSchemas returns the schemas the device exposes as listed in the
+// ietf-netconf-monitoring model ([RFC6022 2.1.3]).  An error wrapping
+// [ErrCapabilityMissing] is returned if the device doesn't advertise the
+// model.
+//
+// [RFC6022 2.1.3]: https://www.rfc-editor.org/rfc/rfc6022.html#section-2.1.3
+func (s *Session) ListSchemas(ctx context.Context) ([]SchemaInfo, error) {
+	var state struct {
+		Schemas []SchemaInfo `xml:"schemas>schema"`
+	}
+	if err := s.getMonitoringState(ctx, "schemas", &state); err != nil {
+		return nil, err
+	}
+
+	for i := range state.Schemas {
+		state.Schemas[i].Format = stripPrefix(state.Schemas[i].Format)
+	}
+	return state.Schemas, nil
+}
+
+// getMonitoringState fetches the `/netconf-state/<container>` subtree with
+// `<get>` and decodes the `<netconf-state>` element into v.
+func (s *Session) getMonitoringState(ctx context.Context, container string, v any) error {
+	if err := s.requireCapability("netconf-state", NamespaceMonitoring); err != nil {
+		return err
+	}
+
+	data, err := s.Get(ctx,
+		WithFilter("/netconf-state/"+container),
+		WithFilterNamespace(NamespaceMonitoring))
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode netconf-state %s: %w", container, err)
+	}
+	return nil
+}
+
+// stripPrefix removes the namespace prefix from a YANG identityref value.
+func stripPrefix(s string) string {
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// NetconfStatistics are the global counters of the NETCONF server as listed in
+// `/netconf-state/statistics` of the ietf-netconf-monitoring model.
+type NetconfStatistics struct {
+	// StartTime is when the server was started.
+	StartTime        time.Time `xml:"netconf-start-time"`
+	InBadHellos      uint32    `xml:"in-bad-hellos"`
+	InSessions       uint32    `xml:"in-sessions"`
+	DroppedSessions  uint32    `xml:"dropped-sessions"`
+	InRPCs           uint32    `xml:"in-rpcs"`
+	InBadRPCs        uint32    `xml:"in-bad-rpcs"`
+	OutRPCErrors     uint32    `xml:"out-rpc-errors"`
+	OutNotifications uint32    `xml:"out-notifications"`
+}
+
+// Statistics returns the global statistics of the NETCONF server from the
+// ietf-netconf-monitoring model ([RFC6022 2.1.5]).  An error wrapping
+// [ErrCapabilityMissing] is returned if the device doesn't advertise the
+// model.
+//
+// [RFC6022 2.1.5]: https://www.rfc-editor.org/rfc/rfc6022.html#section-2.1.5
+func (s *Session) Statistics(ctx context.Context) (*NetconfStatistics, error) {
+	var state struct {
+		Statistics NetconfStatistics `xml:"statistics"`
+	}
+	if err := s.getMonitoringState(ctx, "statistics", &state); err != nil {
+		return nil, err
+	}
+	return &state.Statistics, nil
+}
+
+// SessionInfo describes a session on the NETCONF server as listed in
+// `/netconf-state/sessions` of the ietf-netconf-monitoring model.
+type SessionInfo struct {
+	SessionID uint32 `xml:"session-id"`
+	// Transport is the transport of the session (i.e `netconf-ssh`) without
+	// any namespace prefix.
+	Transport  string    `xml:"transport"`
+	Username   string    `xml:"username"`
+	SourceHost string    `xml:"source-host"`
+	LoginTime  time.Time `xml:"login-time"`
+
+	InRPCs           uint32 `xml:"in-rpcs"`
+	InBadRPCs        uint32 `xml:"in-bad-rpcs"`
+	OutRPCErrors     uint32 `xml:"out-rpc-errors"`
+	OutNotifications uint32 `xml:"out-notifications"`
+}
+
+// Sessions returns the sessions currently open on the NETCONF server from the
+// ietf-netconf-monitoring model ([RFC6022 2.1.4]).  Together with the
+// session-id reported in a `lock-denied` error this can be used to find out who
+// holds a lock (and to [Session.KillSession] it).  An error wrapping
+// [ErrCapabilityMissing] is returned if the device doesn't advertise the
+// model.
+//
+// [RFC6022 2.1.4]: https://www.rfc-editor.org/rfc/rfc6022.html#section-2.1.4
+func (s *Session) Sessions(ctx context.Context) ([]SessionInfo, error) {
+	var state struct {
+		Sessions []SessionInfo `xml:"sessions>session"`
+	}
+	if err := s.getMonitoringState(ctx, "sessions", &state); err != nil {
+		return nil, err
+	}
+
+	for i := range state.Sessions {
+		state.Sessions[i].Transport = stripPrefix(state.Sessions[i].Transport)
+	}
+	return state.Sessions, nil
+}
+
+// monitoringLockHolder returns the session-id holding the global lock on the
+// `target` datastore as listed in `/netconf-state/datastores`.
+func (s *Session) monitoringLockHolder(ctx context.Context, target Datastore) (uint32, error) {
+	var state struct {
+		Datastores []struct {
+			Name     string  `xml:"name"`
+			LockedBy *uint32 `xml:"locks>global-lock>locked-by-session"`
+		} `xml:"datastores>datastore"`
+	}
+	if err := s.getMonitoringState(ctx, "datastores", &state); err != nil {
+		return 0, err
+	}
+
+	for _, ds := range state.Datastores {
+		if ds.Name == string(target) && ds.LockedBy != nil {
+			return *ds.LockedBy, nil
+		}
+	}
+	return 0, fmt.Errorf("no global lock on the %s datastore", target)
+}
@@ -0,0 +1,148 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSchemas(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(monitoringCap)
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>
+<netconf-state xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring" xmlns:ncm="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring">
+  <schemas>
+    <schema>
+      <identifier>ietf-interfaces</identifier>
+      <version>2018-02-20</version>
+      <format>ncm:yang</format>
+      <namespace>urn:ietf:params:xml:ns:yang:ietf-interfaces</namespace>
+      <location>NETCONF</location>
+    </schema>
+    <schema>
+      <identifier>foo</identifier>
+      <version></version>
+      <format>yin</format>
+      <namespace>urn:example:foo</namespace>
+      <location>NETCONF</location>
+      <location>https://example.com/foo.yin</location>
+    </schema>
+  </schemas>
+</netconf-state>
+</data></rpc-reply>`)
+
+	got, err := sess.ListSchemas(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []SchemaInfo{
+		{
+			Identifier: "ietf-interfaces",
+			Version:    "2018-02-20",
+			Format:     "yang",
+			Namespace:  "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+			Location:   []string{"NETCONF"},
+		},
+		{
+			Identifier: "foo",
+			Format:     "yin",
+			Namespace:  "urn:example:foo",
+			Location:   []string{"NETCONF", "https://example.com/foo.yin"},
+		},
+	}, got)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<get><filter type="subtree"><netconf-state xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"><schemas/></netconf-state></filter></get>`)
+}
+
+func TestListSchemasUnsupported(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+
+	_, err := sess.ListSchemas(context.Background())
+	assert.ErrorIs(t, err, ErrCapabilityMissing)
+}
+
+const monitoringCap = "urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring?module=ietf-netconf-monitoring&revision=2010-10-04"
+
+func TestStatistics(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(monitoringCap)
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>
+<netconf-state xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring">
+  <statistics>
+    <netconf-start-time>2024-01-02T03:04:05Z</netconf-start-time>
+    <in-bad-hellos>1</in-bad-hellos>
+    <in-sessions>20</in-sessions>
+    <dropped-sessions>2</dropped-sessions>
+    <in-rpcs>300</in-rpcs>
+    <in-bad-rpcs>3</in-bad-rpcs>
+    <out-rpc-errors>4</out-rpc-errors>
+    <out-notifications>50</out-notifications>
+  </statistics>
+</netconf-state>
+</data></rpc-reply>`)
+
+	got, err := sess.Statistics(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &NetconfStatistics{
+		StartTime:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		InBadHellos:      1,
+		InSessions:       20,
+		DroppedSessions:  2,
+		InRPCs:           300,
+		InBadRPCs:        3,
+		OutRPCErrors:     4,
+		OutNotifications: 50,
+	}, got)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<netconf-state xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"><statistics/></netconf-state>`)
+}
+
+func TestSessions(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(monitoringCap)
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>
+<netconf-state xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring" xmlns:ncm="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring">
+  <sessions>
+    <session>
+      <session-id>42</session-id>
+      <transport>ncm:netconf-ssh</transport>
+      <username>admin</username>
+      <source-host>192.0.2.1</source-host>
+      <login-time>2024-01-02T03:04:05+02:00</login-time>
+      <in-rpcs>10</in-rpcs>
+      <in-bad-rpcs>0</in-bad-rpcs>
+      <out-rpc-errors>1</out-rpc-errors>
+      <out-notifications>0</out-notifications>
+    </session>
+  </sessions>
+</netconf-state>
+</data></rpc-reply>`)
+
+	got, err := sess.Sessions(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, uint32(42), got[0].SessionID)
+	assert.Equal(t, "netconf-ssh", got[0].Transport)
+	assert.Equal(t, "admin", got[0].Username)
+	assert.Equal(t, "192.0.2.1", got[0].SourceHost)
+	assert.True(t, time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC).Equal(got[0].LoginTime))
+	assert.Equal(t, uint32(10), got[0].InRPCs)
+	assert.Equal(t, uint32(1), got[0].OutRPCErrors)
+
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+}
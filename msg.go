@@ -1,9 +1,15 @@
 package netconf
 
 import (
+	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/exp/slices"
@@ -37,6 +43,233 @@ func (x *RawXML) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	return e.EncodeElement(&inner, start)
 }
 
+// selfClosingWriter collapses the empty elements (i.e `<tag></tag>`) of the
+// xml written by a xml.Encoder into self-closing tags (`<tag/>`) as it is
+// encoded.  The encoding/xml package will never emit self-closing tags on it's
+// own.  Only the tags are changed: text, attribute values, comments, CDATA
+// sections and processing instructions are written as-is.
+//
+// See https://github.com/golang/go/issues/21399
+type selfClosingWriter struct {
+	w     *bytes.Buffer
+	state xmlLexState
+	// name is the name of the start tag being written.
+	name []byte
+	// quote is the quote of the attribute value being written, if any.
+	quote byte
+	// slash is set when the last byte of a start tag was a `/`.
+	slash bool
+	// tail are the last bytes of a comment, CDATA section or processing
+	// instruction to find it's end.
+	tail [2]byte
+	// hold is the `>` of the last start tag followed by the part of the
+	// matching end tag written so far.
+	hold []byte
+}
+
+type xmlLexState int
+
+const (
+	lexText xmlLexState = iota
+	lexOpen
+	lexStartName
+	lexStartAttrs
+	lexEndTag
+	lexBang
+	lexComment
+	lexCDATA
+	lexDirective
+	lexPI
+	lexHold
+)
+
+func (w *selfClosingWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		w.writeByte(b)
+	}
+	return len(p), nil
+}
+
+// Flush writes any held back bytes.
+func (w *selfClosingWriter) Flush() {
+	if w.state == lexHold {
+		w.w.Write(w.hold)
+		w.hold = w.hold[:0]
+		w.state = lexText
+	}
+}
+
+func (w *selfClosingWriter) writeByte(b byte) {
+	switch w.state {
+	case lexText:
+		if b == '<' {
+			w.state = lexOpen
+		}
+	case lexOpen:
+		switch b {
+		case '/':
+			w.state = lexEndTag
+		case '!':
+			w.state = lexBang
+		case '?':
+			w.state, w.tail = lexPI, [2]byte{}
+		default:
+			w.state, w.name, w.slash = lexStartName, append(w.name[:0], b), false
+		}
+	case lexStartName, lexStartAttrs:
+		switch {
+		case w.quote != 0:
+			if b == w.quote {
+				w.quote = 0
+			}
+		case b == '>':
+			if w.slash {
+				w.state = lexText
+				break
+			}
+			w.state, w.hold = lexHold, append(w.hold[:0], '>')
+			return
+		case b == '"' || b == '\'':
+			w.quote = b
+		case w.state == lexStartName && !isXMLSpace(b) && b != '/':
+			w.name = append(w.name, b)
+		default:
+			w.state = lexStartAttrs
+		}
+		w.slash = b == '/' && w.quote == 0
+	case lexEndTag:
+		if b == '>' {
+			w.state = lexText
+		}
+	case lexBang:
+		switch b {
+		case '-':
+			w.state, w.tail = lexComment, [2]byte{}
+		case '[':
+			w.state, w.tail = lexCDATA, [2]byte{}
+		default:
+			w.state = lexDirective
+		}
+	case lexComment, lexCDATA, lexPI:
+		if b == '>' && w.atEnd() {
+			w.state = lexText
+		}
+		w.tail = [2]byte{w.tail[1], b}
+	case lexDirective:
+		if b == '>' {
+			w.state = lexText
+		}
+	case lexHold:
+		// `>` followed by `</name>`
+		i := len(w.hold) - 1
+		var want byte
+		switch {
+		case i == 0:
+			want = '<'
+		case i == 1:
+			want = '/'
+		case i-2 < len(w.name):
+			want = w.name[i-2]
+		default:
+			want = '>'
+		}
+		if b != want {
+			// not empty, write what was held back and go on from there.
+			held := append([]byte(nil), w.hold[1:]...)
+			w.w.WriteByte('>')
+			w.hold = w.hold[:0]
+			w.state = lexText
+			for _, c := range held {
+				w.writeByte(c)
+			}
+			w.writeByte(b)
+			return
+		}
+		if want == '>' {
+			w.w.WriteString("/>")
+			w.hold = w.hold[:0]
+			w.state = lexText
+			return
+		}
+		w.hold = append(w.hold, b)
+		return
+	}
+	w.w.WriteByte(b)
+}
+
+// atEnd reports if a `>` ends the comment, CDATA section or processing
+// instruction being written.
+func (w *selfClosingWriter) atEnd() bool {
+	switch w.state {
+	case lexComment:
+		return w.tail == [2]byte{'-', '-'}
+	case lexCDATA:
+		return w.tail == [2]byte{']', ']'}
+	default:
+		return w.tail[1] == '?'
+	}
+}
+
+func isXMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+var streamSeq atomic.Uint64
+
+// streamedXML streams already encoded xml from an io.Reader as the contents of
+// an element.  As encoding/xml has no way to write raw bytes from a reader only
+// a placeholder comment is encoded.  When the message is written to the
+// transport the placeholder is replaced by the contents of the reader (see
+// [writeStreamed]).
+type streamedXML struct {
+	r  io.Reader
+	id uint64
+}
+
+func newStreamedXML(r io.Reader) *streamedXML {
+	return &streamedXML{
+		r:  r,
+		id: streamSeq.Add(1),
+	}
+}
+
+func (x *streamedXML) placeholder() []byte {
+	return []byte(fmt.Sprintf("<!--netconf-stream-%d-->", x.id))
+}
+
+func (x *streamedXML) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(xml.Comment(fmt.Sprintf("netconf-stream-%d", x.id))); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// writeStreamed writes the encoded message `msg` to w replacing the placeholder
+// of each stream with the contents of it's reader.
+func writeStreamed(w io.Writer, msg []byte, streams []*streamedXML) error {
+	for _, stream := range streams {
+		placeholder := stream.placeholder()
+		i := bytes.Index(msg, placeholder)
+		if i < 0 {
+			continue
+		}
+
+		if _, err := w.Write(msg[:i]); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, stream.r); err != nil {
+			return fmt.Errorf("failed to stream config: %w", err)
+		}
+		msg = msg[i+len(placeholder):]
+	}
+
+	_, err := w.Write(msg)
+	return err
+}
+
 // helloMsg maps the xml value of the <hello> message in RFC6241
 type helloMsg struct {
 	XMLName      xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 hello"`
@@ -44,11 +277,73 @@ type helloMsg struct {
 	Capabilities []string `xml:"capabilities>capability"`
 }
 
+// Hello is a `<hello>` message exchanged by the client and the server when a
+// session is opened ([RFC6241 8.1]).
+//
+// [RFC6241 8.1]: https://www.rfc-editor.org/rfc/rfc6241.html#section-8.1
+type Hello struct {
+	// SessionID is the session-id assigned by the server.  It is 0 in the
+	// hello of a client.
+	SessionID uint32
+	// Capabilities are the capabilities advertised by the peer.
+	Capabilities []string
+}
+
+// MarshalHello encodes a `<hello>` message advertising `capabilities`, i.e to
+// implement a test server or mock device.  `sessionID` is the session-id
+// assigned by a server and must be 0 for the hello of a client.  Capabilities
+// starting with `:` are expanded with [ExpandCapability].  Every peer must
+// advertise at least one of the base capabilities.
+func MarshalHello(capabilities []string, sessionID uint32) ([]byte, error) {
+	msg := helloMsg{
+		SessionID:    uint64(sessionID),
+		Capabilities: make([]string, 0, len(capabilities)),
+	}
+	for _, c := range capabilities {
+		msg.Capabilities = append(msg.Capabilities, ExpandCapability(c))
+	}
+
+	caps := newCapabilitySet(msg.Capabilities...)
+	if !caps.Has(baseCap10) && !caps.Has(baseCap11) {
+		return nil, errors.New("netconf: hello must advertise a base capability")
+	}
+	return xml.Marshal(&msg)
+}
+
+// ParseHello decodes a `<hello>` message of a client or a server (see
+// [MarshalHello]).  It fails if the message has no capabilities.
+func ParseHello(raw []byte) (*Hello, error) {
+	var msg helloMsg
+	if err := xml.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("netconf: failed to decode hello: %w", err)
+	}
+	if len(msg.Capabilities) == 0 {
+		return nil, errors.New("netconf: hello has no capabilities")
+	}
+	if msg.SessionID > math.MaxUint32 {
+		return nil, fmt.Errorf("netconf: hello session-id %d out of range", msg.SessionID)
+	}
+
+	hello := Hello{
+		SessionID:    uint32(msg.SessionID),
+		Capabilities: make([]string, 0, len(msg.Capabilities)),
+	}
+	for _, c := range msg.Capabilities {
+		hello.Capabilities = append(hello.Capabilities, strings.TrimSpace(c))
+	}
+	return &hello, nil
+}
+
 // request maps the xml value of <rpc> in RFC6241
 type request struct {
-	XMLName   xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc"`
-	MessageID uint64   `xml:"message-id,attr"`
-	Operation any      `xml:",innerxml"`
+	XMLName   xml.Name   `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc"`
+	MessageID string     `xml:"message-id,attr"`
+	Attrs     []xml.Attr `xml:",any,attr"`
+	Operation any        `xml:",innerxml"`
+
+	// streams are the configs of the operation streamed from an io.Reader
+	// (see [withStreamedConfig]).
+	streams []*streamedXML
 }
 
 func (msg *request) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
@@ -66,10 +361,45 @@ func (msg *request) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 
 // Reply maps the xml value of <rpc-reply> in RFC6241
 type Reply struct {
-	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc-reply"`
-	MessageID uint64    `xml:"message-id,attr"`
-	Errors    RPCErrors `xml:"rpc-error,omitempty"`
-	Body      []byte    `xml:",innerxml"`
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc-reply"`
+	// MessageID is the message-id of the reply.  It is 0 if the id is not a
+	// number (see [WithMessageID]).
+	MessageID uint64 `xml:"-"`
+	// RawMessageID is the message-id of the reply as sent by the server.
+	RawMessageID string    `xml:"message-id,attr"`
+	Errors       RPCErrors `xml:"rpc-error,omitempty"`
+	Body         []byte    `xml:",innerxml"`
+
+	// size of the encoded reply in bytes.
+	size int
+	// raw is the complete reply message if requested with
+	// [Session.CallWithReply].
+	raw []byte
+	// stream streams the contents of `<data>` if requested with
+	// [Session.GetConfigStream].
+	stream *io.PipeReader
+	// err is set if the reply could not be read (i.e it was too large).
+	err error
+}
+
+func (r *Reply) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// alias the type to not cause recursion calling d.DecodeElement
+	type reply Reply
+	if err := d.DecodeElement((*reply)(r), &start); err != nil {
+		return err
+	}
+	r.MessageID = numericMessageID(r.RawMessageID)
+	return nil
+}
+
+// numericMessageID returns the message-id `id` as a number or 0 if it is not
+// one.
+func numericMessageID(id string) uint64 {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
 }
 
 // Decode will decode the body of a reply into a value pointed to by v.  This is
@@ -78,6 +408,19 @@ func (r Reply) Decode(v interface{}) error {
 	return xml.Unmarshal(r.Body, v)
 }
 
+// hasOK reports if the body of the reply contains a `<ok/>` element, which
+// may come after any rpc-errors.
+func (r Reply) hasOK() (bool, error) {
+	var resp struct {
+		OK ExtantBool `xml:"ok"`
+	}
+	raw := append(append([]byte("<rpc-reply>"), r.Body...), "</rpc-reply>"...)
+	if err := xml.Unmarshal(raw, &resp); err != nil {
+		return false, err
+	}
+	return bool(resp.OK), nil
+}
+
 // Err will return go error(s) from a Reply that are of the given severities. If
 // no severity is given then it defaults to `ErrSevError`.
 //
@@ -122,6 +465,11 @@ type Notification struct {
 	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:netconf:notification:1.0 notification"`
 	EventTime time.Time `xml:"eventTime"`
 	Body      []byte    `xml:",innerxml"`
+
+	// Event is the typed event of the notification if a decoder is
+	// registered for it with [RegisterNotificationDecoder] (i.e a
+	// *[ConfigChangeEvent]), otherwise nil.
+	Event any `xml:"-"`
 }
 
 // Decode will decode the body of a noticiation into a value pointed to by v.
@@ -179,12 +527,172 @@ type RPCError struct {
 	Path     string      `xml:"error-path,omitempty"`
 	Message  string      `xml:"error-message,omitempty"`
 	Info     RawXML      `xml:"error-info,omitempty"`
+
+	// pathPrefixes are the namespace prefixes declared on the `<rpc-error>`
+	// and `<error-path>` elements used to resolve the prefixes of Path.
+	pathPrefixes map[string]string
 }
 
 func (e RPCError) Error() string {
 	return fmt.Sprintf("netconf error: %s %s: %s", e.Type, e.Tag, e.Message)
 }
 
+// UnmarshalXML implements xml.Unmarshaler to keep the namespace declarations
+// in scope of the `<error-path>`.
+func (e *RPCError) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type rpcError RPCError
+	var v struct {
+		rpcError
+		Path struct {
+			Value string     `xml:",chardata"`
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"error-path"`
+	}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+
+	*e = RPCError(v.rpcError)
+	e.Path = strings.TrimSpace(v.Path.Value)
+	for _, attr := range append(start.Attr, v.Path.Attrs...) {
+		if attr.Name.Space != "xmlns" {
+			continue
+		}
+		if e.pathPrefixes == nil {
+			e.pathPrefixes = make(map[string]string)
+		}
+		e.pathPrefixes[attr.Name.Local] = attr.Value
+	}
+	return nil
+}
+
+// PathElements splits the instance-identifier in the `<error-path>` of the
+// error into it's node names so it can be mapped back to the config, i.e
+//
+//	/if:interfaces/if:interface[if:name='eth0']/if:mtu
+//
+// is split into
+//
+//	[]string{"interfaces", "interface[name='eth0']", "mtu"}
+//
+// Namespace prefixes are removed from the node names and the names of list
+// keys, use [RPCError.PathNamespaces] for the namespaces of the nodes.
+// Predicates (list keys, leaf-list values and positions) are kept with the node
+// they belong to.  PathElements returns nil if the error has no path.
+func (e RPCError) PathElements() []string {
+	steps := splitErrorPath(e.Path)
+	if len(steps) == 0 {
+		return nil
+	}
+
+	elems := make([]string, 0, len(steps))
+	for _, step := range steps {
+		var sb strings.Builder
+		sb.WriteString(step.name)
+		for _, pred := range step.predicates {
+			sb.WriteByte('[')
+			sb.WriteString(pred)
+			sb.WriteByte(']')
+		}
+		elems = append(elems, sb.String())
+	}
+	return elems
+}
+
+// PathNamespaces returns the namespace of every element returned by
+// [RPCError.PathElements].  The prefixes of the path are resolved against the
+// namespaces declared on the `<error-path>` or `<rpc-error>` elements.  A node
+// without a prefix has the namespace of it's parent like in YANG
+// instance-identifiers.  The namespace of a node whose prefix is not declared
+// is empty.
+func (e RPCError) PathNamespaces() []string {
+	steps := splitErrorPath(e.Path)
+	if len(steps) == 0 {
+		return nil
+	}
+
+	namespaces := make([]string, 0, len(steps))
+	var namespace string
+	for _, step := range steps {
+		if step.prefix != "" {
+			namespace = e.pathPrefixes[step.prefix]
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces
+}
+
+type errorPathStep struct {
+	prefix string
+	name   string
+	// predicates are the predicates of the step without the brackets and
+	// with the prefix of key names removed.
+	predicates []string
+}
+
+// splitErrorPath splits an instance-identifier into it's steps.  Slashes and
+// brackets inside of quoted key values are not separators.
+func splitErrorPath(path string) []errorPathStep {
+	steps, _ := splitPath(path)
+	return steps
+}
+
+// splitPath is like splitErrorPath but also reports if all predicates of the
+// path are terminated.
+func splitPath(path string) ([]errorPathStep, bool) {
+	var steps []errorPathStep
+	ok := true
+
+	rest := strings.TrimSpace(path)
+	for rest != "" {
+		rest = strings.TrimLeft(rest, "/")
+		if rest == "" {
+			break
+		}
+
+		end := strings.IndexAny(rest, "/[")
+		if end < 0 {
+			end = len(rest)
+		}
+		var step errorPathStep
+		step.name = strings.TrimSpace(rest[:end])
+		if prefix, name, ok := strings.Cut(step.name, ":"); ok {
+			step.prefix, step.name = prefix, name
+		}
+		rest = rest[end:]
+
+		for strings.HasPrefix(rest, "[") {
+			end := xpathPredicateEnd(rest)
+			if end < 0 {
+				// unterminated predicate, keep it as it is.
+				step.predicates = append(step.predicates, rest[1:])
+				rest = ""
+				ok = false
+				break
+			}
+			step.predicates = append(step.predicates, stripKeyPrefix(rest[1:end]))
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+
+		steps = append(steps, step)
+	}
+	return steps, ok
+}
+
+// stripKeyPrefix removes the namespace prefix of the key name of a
+// `prefix:key='value'` predicate.
+func stripKeyPrefix(pred string) string {
+	key, value, ok := strings.Cut(pred, "=")
+	if !ok {
+		return strings.TrimSpace(pred)
+	}
+	key = strings.TrimSpace(key)
+	if _, name, ok := strings.Cut(key, ":"); ok {
+		key = name
+	}
+	return key + "=" + strings.TrimSpace(value)
+}
+
 type RPCErrors []RPCError
 
 func (errs RPCErrors) Filter(severity ...ErrSeverity) RPCErrors {
@@ -206,6 +714,12 @@ func (errs RPCErrors) Filter(severity ...ErrSeverity) RPCErrors {
 	return filteredErrs
 }
 
+// FatalOnly returns the errors with the `error` severity which fail an
+// operation, dropping the warnings.
+func (errs RPCErrors) FatalOnly() RPCErrors {
+	return errs.Filter(SevError)
+}
+
 func (errs RPCErrors) Error() string {
 	var sb strings.Builder
 	for i, err := range errs {
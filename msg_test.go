@@ -1,7 +1,10 @@
 package netconf
 
 import (
+	"bytes"
 	"encoding/xml"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -144,6 +147,73 @@ func TestMarshalHelloMsg(t *testing.T) {
 	}
 }
 
+func TestHelloRoundTrip(t *testing.T) {
+	tt := []struct {
+		name      string
+		caps      []string
+		sessionID uint32
+		want      Hello
+	}{
+		{
+			name: "client",
+			caps: []string{"urn:ietf:params:netconf:base:1.0", "urn:ietf:params:netconf:base:1.1"},
+			want: Hello{Capabilities: []string{"urn:ietf:params:netconf:base:1.0", "urn:ietf:params:netconf:base:1.1"}},
+		},
+		{
+			name:      "server",
+			caps:      []string{"urn:ietf:params:netconf:base:1.1", ":candidate:1.0", "http://xml.juniper.net/netconf/junos/1.0"},
+			sessionID: math.MaxUint32,
+			want: Hello{
+				SessionID: math.MaxUint32,
+				Capabilities: []string{
+					"urn:ietf:params:netconf:base:1.1",
+					"urn:ietf:params:netconf:capability:candidate:1.0",
+					"http://xml.juniper.net/netconf/junos/1.0",
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			raw, err := MarshalHello(tc.caps, tc.sessionID)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.sessionID != 0, bytes.Contains(raw, []byte("<session-id>")))
+
+			got, err := ParseHello(raw)
+			assert.NoError(t, err)
+			assert.Equal(t, &tc.want, got)
+
+			// a server hello is accepted by the client side of a session.
+			if tc.sessionID != 0 {
+				msg, err := parseServerHello(raw)
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(tc.sessionID), msg.SessionID)
+			}
+		})
+	}
+}
+
+func TestHelloInvalid(t *testing.T) {
+	_, err := MarshalHello([]string{":candidate:1.0"}, 1)
+	assert.ErrorContains(t, err, "base capability")
+	_, err = MarshalHello(nil, 0)
+	assert.Error(t, err)
+
+	for name, raw := range map[string]string{
+		"noCapabilities": `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><session-id>1</session-id></hello>`,
+		"wrongNamespace": `<hello xmlns="urn:example"><capabilities><capability>urn:ietf:params:netconf:base:1.0</capability></capabilities></hello>`,
+		"notHello":       `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"/>`,
+		"sessionIDRange": `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities><capability>urn:ietf:params:netconf:base:1.0</capability></capabilities><session-id>4294967296</session-id></hello>`,
+		"truncated":      `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseHello([]byte(raw))
+			assert.Error(t, err)
+		})
+	}
+}
+
 func TestMarshalRPCMsg(t *testing.T) {
 	tt := []struct {
 		name      string
@@ -195,7 +265,7 @@ func TestMarshalRPCMsg(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			out, err := xml.Marshal(&request{
-				MessageID: 1,
+				MessageID: "1",
 				Operation: tc.operation,
 			})
 			t.Logf("out: %s", out)
@@ -239,7 +309,8 @@ func TestUnmarshalRPCReply(t *testing.T) {
 					Space: "urn:ietf:params:xml:ns:netconf:base:1.0",
 					Local: "rpc-reply",
 				},
-				MessageID: 1,
+				MessageID:    1,
+				RawMessageID: "1",
 				Errors: []RPCError{
 					{
 						Type:     ErrTypeProtocol,
@@ -276,3 +347,143 @@ func TestUnmarshalRPCReply(t *testing.T) {
 	}
 
 }
+
+func TestRPCErrorPathElements(t *testing.T) {
+	tt := []struct {
+		name           string
+		reply          string
+		wantElements   []string
+		wantNamespaces []string
+	}{
+		{
+			name:           "plain",
+			reply:          `<rpc-error><error-path>/interfaces/interface[name='eth0']/mtu</error-path></rpc-error>`,
+			wantElements:   []string{"interfaces", "interface[name='eth0']", "mtu"},
+			wantNamespaces: []string{"", "", ""},
+		},
+		{
+			name: "prefixed",
+			reply: `<rpc-error><error-path xmlns:if="urn:ietf:params:xml:ns:yang:ietf-interfaces" xmlns:ip="urn:ietf:params:xml:ns:yang:ietf-ip">
+    /if:interfaces/if:interface[if:name="eth0"]/ip:ipv4/ip:address[ip:ip='10.0.0.1']/ip:prefix-length
+  </error-path></rpc-error>`,
+			wantElements: []string{"interfaces", `interface[name="eth0"]`, "ipv4", "address[ip='10.0.0.1']", "prefix-length"},
+			wantNamespaces: []string{
+				"urn:ietf:params:xml:ns:yang:ietf-interfaces",
+				"urn:ietf:params:xml:ns:yang:ietf-interfaces",
+				"urn:ietf:params:xml:ns:yang:ietf-ip",
+				"urn:ietf:params:xml:ns:yang:ietf-ip",
+				"urn:ietf:params:xml:ns:yang:ietf-ip",
+			},
+		},
+		{
+			name: "declaredOnRPCError",
+			reply: `<rpc-error xmlns:sys="urn:ietf:params:xml:ns:yang:ietf-system">` +
+				`<error-path>/sys:system/sys:dns-resolver/server[name='a/b[1]']/sys:search[.='example.com']</error-path></rpc-error>`,
+			wantElements: []string{"system", "dns-resolver", "server[name='a/b[1]']", "search[.='example.com']"},
+			wantNamespaces: []string{
+				"urn:ietf:params:xml:ns:yang:ietf-system",
+				"urn:ietf:params:xml:ns:yang:ietf-system",
+				"urn:ietf:params:xml:ns:yang:ietf-system",
+				"urn:ietf:params:xml:ns:yang:ietf-system",
+			},
+		},
+		{
+			name:           "undeclaredPrefix",
+			reply:          `<rpc-error><error-path>/x:top/x:list[x:k='1'][2]</error-path></rpc-error>`,
+			wantElements:   []string{"top", "list[k='1'][2]"},
+			wantNamespaces: []string{"", ""},
+		},
+		{
+			name:  "noPath",
+			reply: `<rpc-error><error-tag>operation-failed</error-tag></rpc-error>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var got RPCError
+			err := xml.Unmarshal([]byte(tc.reply), &got)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantElements, got.PathElements())
+			assert.Equal(t, tc.wantNamespaces, got.PathNamespaces())
+		})
+	}
+}
+
+// selfClose writes `p` through a selfClosingWriter, `chunk` bytes at a time.
+func selfClose(p []byte, chunk int) string {
+	var buf bytes.Buffer
+	w := selfClosingWriter{w: &buf}
+	for len(p) > 0 {
+		n := min(chunk, len(p))
+		w.Write(p[:n])
+		p = p[n:]
+	}
+	w.Flush()
+	return buf.String()
+}
+
+func TestSelfClosingWriter(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"noEmpty", "<foo>bar</foo>", "<foo>bar</foo>"},
+		{"single", "<foo></foo>", "<foo/>"},
+		{"nested", "<commit><confirmed></confirmed></commit>", "<commit><confirmed/></commit>"},
+		{"attrs", `<a><b xmlns="urn:x" c="d"></b></a>`, `<a><b xmlns="urn:x" c="d"/></a>`},
+		{"siblings", "<a><b></b><c>1</c><d></d></a>", "<a><b/><c>1</c><d/></a>"},
+		{"mismatched", "<a><b>x</b></a>", "<a><b>x</b></a>"},
+		{"prefixOfName", "<a><b></bc></a>", "<a><b></bc></a>"},
+		{"alreadyClosed", "<a><b/></a>", "<a><b/></a>"},
+		{"attrValue", `<a x="></a>"></a>`, `<a x="></a>"/>`},
+		{"cdata", "<a><![CDATA[<b></b>]]></a>", "<a><![CDATA[<b></b>]]></a>"},
+		{"comment", "<a><!--<b></b>--><c></c></a>", "<a><!--<b></b>--><c/></a>"},
+		{"procInst", `<?xml version="1.0"?><a><?pi <b></b>?></a>`, `<?xml version="1.0"?><a><?pi <b></b>?></a>`},
+		{"unfinished", "<a>", "<a>"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, chunk := range []int{1, 3, 1024} {
+				assert.Equal(t, tc.want, selfClose([]byte(tc.input), chunk))
+			}
+		})
+	}
+}
+
+func TestWriteStreamed(t *testing.T) {
+	type editConfig struct {
+		XMLName xml.Name `xml:"edit-config"`
+		Config  any      `xml:"config"`
+	}
+
+	config := strings.Repeat("<item>foo</item>", 1000)
+	stream := newStreamedXML(strings.NewReader(config))
+	req := &editConfig{
+		Config: stream,
+	}
+
+	msg, err := xml.Marshal(req)
+	assert.NoError(t, err)
+	// the reader must not be consumed during encoding
+	assert.NotContains(t, string(msg), "<item>")
+
+	var buf bytes.Buffer
+	err = writeStreamed(&buf, msg, []*streamedXML{stream})
+	assert.NoError(t, err)
+	assert.Equal(t, "<edit-config><config>"+config+"</config></edit-config>", buf.String())
+}
+
+func TestRPCErrorsFatalOnly(t *testing.T) {
+	errs := RPCErrors{
+		{Severity: SevWarning, Message: "deprecated"},
+		{Severity: SevError, Message: "bad mtu"},
+		{Severity: SevWarning, Message: "unused"},
+	}
+	assert.Equal(t, RPCErrors{{Severity: SevError, Message: "bad mtu"}}, errs.FatalOnly())
+	assert.Empty(t, errs[:1].FatalOnly())
+	assert.Empty(t, RPCErrors(nil).FatalOnly())
+}
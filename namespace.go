@@ -0,0 +1,127 @@
+package netconf
+
+import "strings"
+
+// XML namespaces of the NETCONF protocol and the YANG models commonly used
+// with it.  These can be used with [WithFilterNamespace] or [Config] to build
+// correctly namespaced filters and configs.
+const (
+	// NamespaceBase10 is the namespace of the NETCONF base protocol
+	// (`<rpc>`, `<rpc-reply>`, `<hello>`, the `operation` attribute, ...)
+	// defined in [RFC6241].
+	//
+	// [RFC6241]: https://www.rfc-editor.org/rfc/rfc6241.html
+	NamespaceBase10 = "urn:ietf:params:xml:ns:netconf:base:1.0"
+
+	// NamespaceBase11 is the namespace used for NETCONF 1.1.  RFC6241 keeps
+	// the 1.0 namespace for the 1.1 protocol so it is the same as
+	// [NamespaceBase10].
+	NamespaceBase11 = NamespaceBase10
+
+	// NamespaceNotification is the namespace of `<notification>` and
+	// `<create-subscription>` defined in [RFC5277].
+	//
+	// [RFC5277]: https://www.rfc-editor.org/rfc/rfc5277.html
+	NamespaceNotification = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+
+	// NamespaceNetmodNotification is the namespace of the `nc-notifications`
+	// YANG model (`<replayComplete>`, `<notificationComplete>` and the
+	// `/netconf/streams` list) defined in RFC5277.
+	NamespaceNetmodNotification = "urn:ietf:params:xml:ns:netmod:notification"
+
+	// NamespaceNetconfNotifications is the namespace of the
+	// ietf-netconf-notifications YANG model (i.e `<netconf-config-change>`)
+	// defined in [RFC6470].
+	//
+	// [RFC6470]: https://www.rfc-editor.org/rfc/rfc6470.html
+	NamespaceNetconfNotifications = "urn:ietf:params:xml:ns:yang:ietf-netconf-notifications"
+
+	// NamespaceMonitoring is the namespace of the ietf-netconf-monitoring YANG
+	// model defined in [RFC6022].
+	//
+	// [RFC6022]: https://www.rfc-editor.org/rfc/rfc6022.html
+	NamespaceMonitoring = "urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"
+
+	// NamespaceNMDA is the namespace of the ietf-netconf-nmda YANG model
+	// (`<get-data>` and `<edit-data>`) defined in [RFC8526].
+	//
+	// [RFC8526]: https://www.rfc-editor.org/rfc/rfc8526.html
+	NamespaceNMDA = "urn:ietf:params:xml:ns:yang:ietf-netconf-nmda"
+
+	// NamespaceDatastores is the namespace of the ietf-datastores YANG model
+	// (the datastore identities like `ds:running`) defined in [RFC8342].
+	//
+	// [RFC8342]: https://www.rfc-editor.org/rfc/rfc8342.html
+	NamespaceDatastores = "urn:ietf:params:xml:ns:yang:ietf-datastores"
+
+	// NamespaceWithDefaults is the namespace of the ietf-netconf-with-defaults
+	// YANG model (the `<with-defaults>` parameter) defined in [RFC6243].
+	//
+	// [RFC6243]: https://www.rfc-editor.org/rfc/rfc6243.html
+	NamespaceWithDefaults = "urn:ietf:params:xml:ns:yang:ietf-netconf-with-defaults"
+
+	// NamespaceDefaultAttr is the namespace of the `default` attribute used to
+	// tag default values in the `report-all-tagged` with-defaults mode defined
+	// in RFC6243.
+	NamespaceDefaultAttr = "urn:ietf:params:xml:ns:netconf:default:1.0"
+
+	// NamespaceYANGLibrary is the namespace of the ietf-yang-library YANG model
+	// defined in [RFC7895] and [RFC8525].
+	//
+	// [RFC7895]: https://www.rfc-editor.org/rfc/rfc7895.html
+	// [RFC8525]: https://www.rfc-editor.org/rfc/rfc8525.html
+	NamespaceYANGLibrary = "urn:ietf:params:xml:ns:yang:ietf-yang-library"
+
+	// NamespaceYANG is the namespace of the XML attributes defined by YANG
+	// (`insert`, `key` and `value` used to position entries of `ordered-by
+	// user` lists and leaf-lists) in [RFC7950].
+	//
+	// [RFC7950]: https://www.rfc-editor.org/rfc/rfc7950.html
+	NamespaceYANG = "urn:ietf:params:xml:ns:yang:1"
+
+	// NamespaceYANGPatch is the namespace of the ietf-yang-patch YANG model
+	// (the `<yang-patch>` document) defined in [RFC8072].
+	//
+	// [RFC8072]: https://www.rfc-editor.org/rfc/rfc8072.html
+	NamespaceYANGPatch = "urn:ietf:params:xml:ns:yang:ietf-yang-patch"
+)
+
+// capabilityNamespaces maps capability URNs (without version) to the namespace
+// of the elements they define.
+var capabilityNamespaces = map[string]string{
+	baseCap:                             NamespaceBase10,
+	stdCapPrefix + ":writable-running":  NamespaceBase10,
+	stdCapPrefix + ":candidate":         NamespaceBase10,
+	stdCapPrefix + ":confirmed-commit":  NamespaceBase10,
+	stdCapPrefix + ":rollback-on-error": NamespaceBase10,
+	stdCapPrefix + ":validate":          NamespaceBase10,
+	stdCapPrefix + ":startup":           NamespaceBase10,
+	stdCapPrefix + ":url":               NamespaceBase10,
+	stdCapPrefix + ":xpath":             NamespaceBase10,
+	stdCapPrefix + ":notification":      NamespaceNotification,
+	stdCapPrefix + ":interleave":        NamespaceNotification,
+	stdCapPrefix + ":with-defaults":     NamespaceWithDefaults,
+	stdCapPrefix + ":yang-library":      NamespaceYANGLibrary,
+}
+
+// CapabilityNamespace returns the XML namespace of the elements defined by the
+// given capability.  Short capabilities (i.e `:with-defaults:1.0`) are expanded
+// with [ExpandCapability] and any version or parameters are ignored.
+//
+// YANG module capabilities (i.e
+// `urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring?module=ietf-netconf-monitoring`)
+// are identified by the namespace of the module which is returned as-is.
+func CapabilityNamespace(capability string) (string, bool) {
+	c, err := ParseCapability(capability)
+	if err != nil {
+		return "", false
+	}
+	if ns, ok := capabilityNamespaces[c.URN]; ok {
+		return ns, true
+	}
+	if _, ok := c.Params["module"]; ok {
+		uri, _, _ := strings.Cut(capability, "?")
+		return uri, true
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilityNamespace(t *testing.T) {
+	tt := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{input: "urn:ietf:params:netconf:base:1.1", want: NamespaceBase11, ok: true},
+		{input: ":candidate:1.0", want: NamespaceBase10, ok: true},
+		{input: ":notification:1.0", want: NamespaceNotification, ok: true},
+		{input: "urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit", want: NamespaceWithDefaults, ok: true},
+		{input: ":yang-library:1.1?content-id=1", want: NamespaceYANGLibrary, ok: true},
+		{
+			input: "urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring?module=ietf-netconf-monitoring&revision=2010-10-04",
+			want:  NamespaceMonitoring,
+			ok:    true,
+		},
+		{input: "urn:example:unknown:1.0"},
+		{input: ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			got, ok := CapabilityNamespace(tc.input)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// TestNamespaceTags checks that the namespaces in struct tags (which cannot
+// reference constants) match the namespace constants.
+func TestNamespaceTags(t *testing.T) {
+	tt := []struct {
+		v    any
+		want string
+	}{
+		{helloMsg{}, NamespaceBase10},
+		{request{}, NamespaceBase10},
+		{Reply{}, NamespaceBase10},
+		{Notification{}, NamespaceNotification},
+		{CreateSubscriptionReq{}, NamespaceNotification},
+	}
+
+	for _, tc := range tt {
+		typ := reflect.TypeOf(tc.v)
+		t.Run(typ.Name(), func(t *testing.T) {
+			out, err := xml.Marshal(tc.v)
+			assert.NoError(t, err)
+
+			var name struct {
+				XMLName xml.Name
+			}
+			assert.NoError(t, xml.Unmarshal(out, &name))
+			assert.Equal(t, tc.want, name.XMLName.Space)
+		})
+	}
+}
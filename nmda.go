@@ -0,0 +1,95 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+)
+
+// maxDepthLimit is the largest `max-depth` allowed by RFC8526.
+const maxDepthLimit = 65535
+
+// nmdaDatastore encodes a datastore as the identity of the ietf-datastores
+// model (i.e `ds:running`) used by the NMDA operations.
+type nmdaDatastore Datastore
+
+func (d nmdaDatastore) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if d == "" {
+		return fmt.Errorf("datastores cannot be empty")
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:ds"}, Value: NamespaceDatastores})
+	return e.EncodeElement("ds:"+string(d), start)
+}
+
+type GetDataReq struct {
+	XMLName   xml.Name      `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-nmda get-data"`
+	Datastore nmdaDatastore `xml:"datastore"`
+	Filter    string        `xml:",innerxml"`
+	MaxDepth  int           `xml:"max-depth,omitempty"`
+	Defaults  DefaultsMode  `xml:"with-defaults,omitempty"`
+}
+
+// WithMaxDepth limits how deep the data returned by [Session.GetData] descends
+// below the filter (or the top level nodes without a filter), i.e a depth of 1
+// returns only the top level containers without their contents.  `depth` must
+// be between 1 and 65535.
+//
+// The `max-depth` parameter is only defined for the `<get-data>` operation of
+// [RFC8526] so [Session.GetConfig] and [Session.Get] fail with a error instead
+// of silently returning the whole subtree.
+//
+// [RFC8526]: https://www.rfc-editor.org/rfc/rfc8526.html
+func WithMaxDepth(depth int) RPCOption {
+	return func(c *GetConfigReq) {
+		c.maxDepth = depth
+	}
+}
+
+// GetData implements the `<get-data>` rpc operation defined in [RFC8526 3.1.1]
+// to retrieve the data of any NMDA datastore, including [Intended] and
+// [Operational].  The same filter options as for [Session.GetConfig] can be
+// used as well as [WithMaxDepth].  The device must advertise the
+// `:yang-library:1.1` capability required for NMDA servers or the operation
+// fails with an error wrapping [ErrCapabilityMissing].
+//
+// [RFC8526 3.1.1]: https://www.rfc-editor.org/rfc/rfc8526.html#section-3.1.1
+func (s *Session) GetData(ctx context.Context, datastore Datastore, opts ...RPCOption) ([]byte, error) {
+	if err := s.requireCapability("get-data", yangLibraryCap11); err != nil {
+		return nil, err
+	}
+
+	var filterReq GetConfigReq
+	for _, opt := range opts {
+		opt(&filterReq)
+	}
+	subtree, err := filterReq.subtreeFilter()
+	if err != nil {
+		return nil, err
+	}
+	if filterReq.maxDepth < 0 || filterReq.maxDepth > maxDepthLimit {
+		return nil, fmt.Errorf("max-depth %d must be between 1 and %d", filterReq.maxDepth, maxDepthLimit)
+	}
+	if filterReq.Defaults != "" {
+		if err := s.checkDefaultsMode(filterReq.Defaults); err != nil {
+			return nil, err
+		}
+	}
+
+	req := GetDataReq{
+		Datastore: nmdaDatastore(datastore),
+		MaxDepth:  filterReq.maxDepth,
+		Defaults:  filterReq.Defaults,
+	}
+	if subtree != "" {
+		req.Filter = "<subtree-filter>" + subtree + "</subtree-filter>"
+	}
+
+	var resp GetConfigReply
+	if err := s.Call(ctx, &req, &resp); err != nil {
+		return nil, err
+	}
+	if err := resp.checkData(); err != nil {
+		return nil, err
+	}
+	return resp.Config, nil
+}
@@ -0,0 +1,75 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetData(t *testing.T) {
+	const yangLibraryCap = "urn:ietf:params:netconf:capability:yang-library:1.1?revision=2019-01-04&content-id=1"
+
+	t.Run("maxDepth", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(yangLibraryCap)
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` +
+			`<data xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-nmda"><interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"></interfaces></data></rpc-reply>`)
+
+		data, err := sess.GetData(context.Background(), Operational,
+			WithFilterNamespace("urn:ietf:params:xml:ns:yang:ietf-interfaces"),
+			WithFilter("/interfaces"),
+			WithMaxDepth(1))
+		assert.NoError(t, err)
+		assert.Equal(t, `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"></interfaces>`, string(data))
+
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<get-data xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-nmda">`+
+			`<datastore xmlns:ds="urn:ietf:params:xml:ns:yang:ietf-datastores">ds:operational</datastore>`+
+			`<subtree-filter><interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"/></subtree-filter>`+
+			`<max-depth>1</max-depth></get-data>`)
+	})
+
+	t.Run("noOptions", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(yangLibraryCap)
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-nmda"/></rpc-reply>`)
+
+		data, err := sess.GetData(context.Background(), Intended)
+		assert.NoError(t, err)
+		assert.Empty(t, data)
+
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<get-data xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-nmda">`+
+			`<datastore xmlns:ds="urn:ietf:params:xml:ns:yang:ietf-datastores">ds:intended</datastore></get-data>`)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		_, err := sess.GetData(context.Background(), Operational)
+		assert.ErrorIs(t, err, ErrCapabilityMissing)
+
+		sess.serverCaps = newCapabilitySet(yangLibraryCap)
+		_, err = sess.GetData(context.Background(), Operational, WithMaxDepth(65536))
+		assert.Error(t, err)
+		_, err = sess.GetData(context.Background(), Operational, WithMaxDepth(-1))
+		assert.Error(t, err)
+
+		// max-depth is not defined for get-config and get.
+		_, err = sess.GetConfig(context.Background(), Running, WithMaxDepth(1))
+		assert.ErrorContains(t, err, "get-data")
+		_, err = sess.Get(context.Background(), WithMaxDepth(1))
+		assert.ErrorContains(t, err, "get-data")
+	})
+}
@@ -0,0 +1,150 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// NotificationDecoder decodes the event element of a notification (i.e
+// `<netconf-config-change>`) starting with `start` into a typed event.
+type NotificationDecoder func(d *xml.Decoder, start xml.StartElement) (any, error)
+
+var (
+	notifDecodersMu sync.RWMutex
+	notifDecoders   = map[xml.Name]NotificationDecoder{
+		{Space: NamespaceNetconfNotifications, Local: "netconf-config-change"}:     decodeEvent[ConfigChangeEvent],
+		{Space: NamespaceNetconfNotifications, Local: "netconf-capability-change"}: decodeEvent[CapabilityChangeEvent],
+	}
+)
+
+// RegisterNotificationDecoder registers `decode` for the events whose element
+// has the given name.  The decoded event is set as [Notification.Event] of the
+// notifications passed to the [NotificationHandler].  A decoder registered for
+// a name that already has one replaces it.
+//
+// Decoders for `<netconf-config-change>` ([ConfigChangeEvent]) and
+// `<netconf-capability-change>` ([CapabilityChangeEvent]) are registered by
+// default.
+func RegisterNotificationDecoder(name xml.Name, decode NotificationDecoder) {
+	notifDecodersMu.Lock()
+	defer notifDecodersMu.Unlock()
+	notifDecoders[name] = decode
+}
+
+// decodeEvent decodes an event into a new *T.
+func decodeEvent[T any](d *xml.Decoder, start xml.StartElement) (any, error) {
+	v := new(T)
+	if err := d.DecodeElement(v, &start); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeEvent sets the Event of the notification with the decoder registered
+// for the event element, if any.
+func (n *Notification) decodeEvent() error {
+	dec := xml.NewDecoder(bytes.NewReader(n.Body))
+	for {
+		start, err := startElement(dec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// the body starts with the eventTime which is already decoded.
+		if start.Name.Local == "eventTime" {
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		notifDecodersMu.RLock()
+		decode, ok := notifDecoders[start.Name]
+		notifDecodersMu.RUnlock()
+		if !ok {
+			return nil
+		}
+
+		event, err := decode(dec, *start)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", start.Name.Local, err)
+		}
+		n.Event = event
+		return nil
+	}
+}
+
+// ConfigChangeEvent is the `<netconf-config-change>` notification sent on the
+// `NETCONF` stream whenever the configuration of a datastore changes as defined
+// in [RFC6470 2.1.2].  It is set as the [Notification.Event] (as a
+// *ConfigChangeEvent) of the notifications passed to the
+// [NotificationHandler].
+//
+// [RFC6470 2.1.2]: https://www.rfc-editor.org/rfc/rfc6470.html#section-2.1.2
+type ConfigChangeEvent struct {
+	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-notifications netconf-config-change"`
+	ChangedBy ChangedBy `xml:"changed-by"`
+	// Datastore is the datastore that changed.  It defaults to [Running] if
+	// the device doesn't name it.
+	Datastore Datastore    `xml:"datastore"`
+	Edits     []ConfigEdit `xml:"edit"`
+}
+
+// UnmarshalXML implements xml.Unmarshaler to apply the default datastore.
+func (e *ConfigChangeEvent) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type event ConfigChangeEvent
+	if err := d.DecodeElement((*event)(e), &start); err != nil {
+		return err
+	}
+	if e.Datastore == "" {
+		e.Datastore = Running
+	}
+	return nil
+}
+
+// CapabilityChangeEvent is the `<netconf-capability-change>` notification sent
+// on the `NETCONF` stream whenever the capabilities of the server change (i.e a
+// YANG module is loaded) as defined in [RFC6470 2.1.1].  It is set as the
+// [Notification.Event] (as a *CapabilityChangeEvent) of the notifications
+// passed to the [NotificationHandler].  See [WithCapabilityChangeUpdates] to
+// apply the changes to [Session.ServerCapabilities].
+//
+// [RFC6470 2.1.1]: https://www.rfc-editor.org/rfc/rfc6470.html#section-2.1.1
+type CapabilityChangeEvent struct {
+	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-notifications netconf-capability-change"`
+	ChangedBy ChangedBy `xml:"changed-by"`
+	// Added are the capabilities added.
+	Added []string `xml:"added-capability"`
+	// Deleted are the capabilities removed.
+	Deleted []string `xml:"deleted-capability"`
+	// Modified are the capabilities whose parameters changed, with the new
+	// parameters.
+	Modified []string `xml:"modified-capability"`
+}
+
+// ChangedBy identifies who caused an event defined in RFC6470.  It is either
+// the server itself (i.e a confirmed commit timing out), with Server set, or
+// the user of a session.
+type ChangedBy struct {
+	Server ExtantBool `xml:"server"`
+	// Username, SessionID and SourceHost identify the session that caused the
+	// event if it was not the server.  The SessionID is 0 for sessions not
+	// using NETCONF and SourceHost is empty if unknown.
+	Username   string `xml:"username"`
+	SessionID  uint32 `xml:"session-id"`
+	SourceHost string `xml:"source-host"`
+}
+
+// ConfigEdit is a single edit of a [ConfigChangeEvent].
+type ConfigEdit struct {
+	// Target is the instance-identifier of the node that changed.
+	Target string `xml:"target"`
+	// Operation is the operation on the node, i.e [ReplaceConfig].
+	Operation MergeStrategy `xml:"operation"`
+}
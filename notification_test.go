@@ -0,0 +1,165 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigChangeEvent(t *testing.T) {
+	notifs := make(chan Notification, 2)
+	ts := newTestServer(t)
+	tr := ts.transport()
+	sess := newSession(tr, WithNotificationHandler(func(n Notification) { notifs <- n }))
+	go sess.recv()
+
+	for _, msg := range []string{
+		`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+  <eventTime>2024-03-01T10:00:00Z</eventTime>
+  <netconf-config-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+    <changed-by>
+      <username>fred</username>
+      <session-id>42</session-id>
+      <source-host>192.0.2.1</source-host>
+    </changed-by>
+    <datastore>candidate</datastore>
+    <edit>
+      <target xmlns:if="urn:ietf:params:xml:ns:yang:ietf-interfaces">/if:interfaces/if:interface[if:name='eth0']</target>
+      <operation>replace</operation>
+    </edit>
+    <edit>
+      <target xmlns:sys="urn:ietf:params:xml:ns:yang:ietf-system">/sys:system/sys:hostname</target>
+      <operation>merge</operation>
+    </edit>
+  </netconf-config-change>
+</notification>`,
+		`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+  <eventTime>2024-03-01T10:10:00Z</eventTime>
+  <netconf-config-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+    <changed-by><server/></changed-by>
+  </netconf-config-change>
+</notification>`,
+	} {
+		tr.out <- io.NopCloser(strings.NewReader(msg))
+	}
+
+	var got []*ConfigChangeEvent
+	for i := 0; i < 2; i++ {
+		select {
+		case n := <-notifs:
+			event, ok := n.Event.(*ConfigChangeEvent)
+			if !assert.True(t, ok, "event is %T", n.Event) {
+				return
+			}
+			got = append(got, event)
+		case <-time.After(time.Second):
+			t.Fatal("notification not delivered")
+		}
+	}
+
+	assert.Equal(t, ChangedBy{Username: "fred", SessionID: 42, SourceHost: "192.0.2.1"}, got[0].ChangedBy)
+	assert.Equal(t, Candidate, got[0].Datastore)
+	assert.Equal(t, []ConfigEdit{
+		{Target: "/if:interfaces/if:interface[if:name='eth0']", Operation: ReplaceConfig},
+		{Target: "/sys:system/sys:hostname", Operation: MergeConfig},
+	}, got[0].Edits)
+
+	assert.Equal(t, ChangedBy{Server: true}, got[1].ChangedBy)
+	assert.Equal(t, Running, got[1].Datastore)
+	assert.Empty(t, got[1].Edits)
+}
+
+func TestCapabilityChangeEvent(t *testing.T) {
+	const msg = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+  <eventTime>2024-03-01T10:00:00Z</eventTime>
+  <netconf-capability-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+    <changed-by><server/></changed-by>
+    <added-capability>urn:example:acl?module=example-acl&amp;revision=2024-01-01</added-capability>
+    <added-capability>urn:ietf:params:netconf:capability:interleave:1.0</added-capability>
+    <deleted-capability>urn:ietf:params:netconf:capability:startup:1.0</deleted-capability>
+    <modified-capability>urn:ietf:params:netconf:capability:url:1.0?scheme=file,https</modified-capability>
+  </netconf-capability-change>
+</notification>`
+
+	want := &CapabilityChangeEvent{
+		XMLName:   xml.Name{Space: NamespaceNetconfNotifications, Local: "netconf-capability-change"},
+		ChangedBy: ChangedBy{Server: true},
+		Added: []string{
+			"urn:example:acl?module=example-acl&revision=2024-01-01",
+			"urn:ietf:params:netconf:capability:interleave:1.0",
+		},
+		Deleted:  []string{"urn:ietf:params:netconf:capability:startup:1.0"},
+		Modified: []string{"urn:ietf:params:netconf:capability:url:1.0?scheme=file,https"},
+	}
+
+	for _, enabled := range []bool{false, true} {
+		notifs := make(chan Notification, 1)
+		ts := newTestServer(t)
+		tr := ts.transport()
+		sess := newSession(tr,
+			WithNotificationHandler(func(n Notification) { notifs <- n }),
+			WithCapabilityChangeUpdates(enabled))
+		sess.serverCaps = newCapabilitySet(
+			"urn:ietf:params:netconf:base:1.1",
+			":startup:1.0",
+			":url:1.0?scheme=file",
+		)
+		go sess.recv()
+
+		tr.out <- io.NopCloser(strings.NewReader(msg))
+
+		select {
+		case n := <-notifs:
+			assert.Equal(t, want, n.Event)
+		case <-time.After(time.Second):
+			t.Fatal("notification not delivered")
+		}
+
+		wantCaps := []string{
+			"urn:ietf:params:netconf:base:1.1",
+			"urn:ietf:params:netconf:capability:startup:1.0",
+			"urn:ietf:params:netconf:capability:url:1.0?scheme=file",
+		}
+		if enabled {
+			wantCaps = []string{
+				"urn:example:acl?module=example-acl&revision=2024-01-01",
+				"urn:ietf:params:netconf:base:1.1",
+				"urn:ietf:params:netconf:capability:interleave:1.0",
+				"urn:ietf:params:netconf:capability:url:1.0?scheme=file,https",
+			}
+		}
+		assert.ElementsMatch(t, wantCaps, sess.ServerCapabilities(), "updates enabled: %v", enabled)
+	}
+}
+
+func TestRegisterNotificationDecoder(t *testing.T) {
+	type linkDown struct {
+		Interface string `xml:"if-name"`
+	}
+	name := xml.Name{Space: "urn:example:events", Local: "link-down"}
+	RegisterNotificationDecoder(name, decodeEvent[linkDown])
+	defer func() {
+		notifDecodersMu.Lock()
+		delete(notifDecoders, name)
+		notifDecodersMu.Unlock()
+	}()
+
+	var notif Notification
+	err := xml.Unmarshal([]byte(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">`+
+		`<eventTime>2024-03-01T10:00:00Z</eventTime>`+
+		`<link-down xmlns="urn:example:events"><if-name>eth0</if-name></link-down>`+
+		`</notification>`), &notif)
+	assert.NoError(t, err)
+	assert.NoError(t, notif.decodeEvent())
+	assert.Equal(t, &linkDown{Interface: "eth0"}, notif.Event)
+
+	// unknown events are left undecoded.
+	notif.Event = nil
+	notif.Body = []byte(`<eventTime>2024-03-01T10:00:00Z</eventTime><other xmlns="urn:example:events"/>`)
+	assert.NoError(t, notif.decodeEvent())
+	assert.Nil(t, notif.Event)
+}
@@ -7,9 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"html"
-	"regexp"
+	"io"
+	"math"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 type ExtantBool bool
@@ -20,12 +24,18 @@ func (b ExtantBool) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	}
 	// This produces a empty start/end tag (i.e <tag></tag>) vs a self-closing
 	// tag (<tag/>() which should be the same in XML, however I know certain
-	// vendors may have issues with this format. We may have to process this
-	// after xml encoding.
+	// vendors may have issues with this format.  Sessions opened with
+	// [WithSelfClosingTags] collapse these while encoding.
 	//
 	// See https://github.com/golang/go/issues/21399
 	// or https://github.com/golang/go/issues/26756 for a different hack.
-	return e.EncodeElement(struct{}{}, start)
+	//
+	// The tokens are written directly which is the same output as encoding
+	// an empty struct without the cost of reflection.
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
 }
 
 func (b *ExtantBool) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
@@ -39,6 +49,78 @@ func (b *ExtantBool) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 
 type OKResp struct {
 	OK ExtantBool `xml:"ok"`
+	// Warnings are the rpc-errors with a `warning` severity that came with
+	// the `<ok/>`, if any.  They don't fail the operation.
+	Warnings RPCErrors `xml:"-"`
+}
+
+// UnmarshalXML sets OK when decoding the `<ok/>` element of a reply body
+// directly.
+func (r *OKResp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if start.Name.Local == "ok" {
+		r.OK = true
+		return d.Skip()
+	}
+
+	type okResp OKResp
+	return d.DecodeElement((*okResp)(r), &start)
+}
+
+// ErrNoOK is returned by operations that expect a `<ok/>` reply when the
+// reply contains neither `<ok/>` nor any rpc-errors.
+var ErrNoOK = errors.New("netconf: reply contains neither <ok/> nor rpc-error")
+
+// PartialSuccessError is returned by [Session.Call] when a reply contains a
+// `<ok/>` together with rpc-errors that fail the operation (of the `error`
+// severity unless [WithWarningsAsErrors] is used), as sent by some
+// devices for partially applied operations (i.e with [ContinueOnError]).  The
+// `<ok/>` doesn't mean the operation succeeded as a whole so it is not treated
+// as a success and the caller has to decide how to handle the errors.  The
+// errors can be matched with errors.As as for any other failed reply.
+type PartialSuccessError struct {
+	// Errors are all the rpc-errors of the reply (including warnings).
+	Errors RPCErrors
+}
+
+func (e *PartialSuccessError) Error() string {
+	return "netconf: reply contains <ok/> with rpc-errors: " + e.Errors.Error()
+}
+
+func (e *PartialSuccessError) Unwrap() []error { return e.Errors.Unwrap() }
+
+// callOK issues a operation that replies with `<ok/>` on success.
+func (s *Session) callOK(ctx context.Context, req any) error {
+	_, err := s.callOKResp(ctx, req)
+	return err
+}
+
+// callOKResp is like callOK but also returns the reply for its warnings.
+func (s *Session) callOKResp(ctx context.Context, req any) (*OKResp, error) {
+	var resp OKResp
+	if err := s.Call(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.OK && ctx.Value(noOKKey{}) == nil {
+		return nil, ErrNoOK
+	}
+	return &resp, nil
+}
+
+// noOKKey is the context key set with [WithoutOK].
+type noOKKey struct{}
+
+// WithoutOK returns a copy of `ctx` for the operations that require a `<ok/>`
+// reply (i.e [Session.Lock] or [Session.Commit]) to accept any reply without
+// rpc-errors instead of failing with [ErrNoOK].  This is meant for wrapping the
+// operations for non-compliant devices that reply with content instead of
+// `<ok/>`.  If `reply` is not nil it is set to the complete `<rpc-reply>`
+// message as with [Session.CallWithReply].
+func WithoutOK(ctx context.Context, reply *[]byte) context.Context {
+	ctx = context.WithValue(ctx, noOKKey{}, true)
+	if reply != nil {
+		ctx = context.WithValue(ctx, rawReplyKey{}, reply)
+	}
+	return ctx
 }
 
 type Datastore string
@@ -90,95 +172,715 @@ const (
 	// Startup configuration configuration datastore.  Supported with the
 	// `:startup` capability defined in RFC6241 section 8.7
 	Startup Datastore = "startup" //
+
+	// Intended configuration datastore defined by the Network Management
+	// Datastore Architecture (NMDA) in RFC8342 section 5.1.4.  It is
+	// read-only.
+	Intended Datastore = "intended"
+
+	// Operational state datastore defined by the Network Management Datastore
+	// Architecture (NMDA) in RFC8342 section 5.3.  It is read-only.
+	Operational Datastore = "operational"
 )
 
+// checkLockable returns an error for the read-only NMDA datastores which cannot
+// be locked.
+func checkLockable(target Datastore) error {
+	switch target {
+	case Intended, Operational:
+		return fmt.Errorf("netconf: the %s datastore cannot be locked: it is read-only in NMDA (RFC8342 section 5) and only configuration datastores can be locked", target)
+	}
+	return nil
+}
+
 type GetConfigReq struct {
 	XMLName xml.Name  `xml:"get-config"`
 	Source  Datastore `xml:"source"`
-	Filter string `xml:",innerxml"`
-	// Filter
+	Filter  string    `xml:",innerxml"`
+	// Defaults is the with-defaults mode set with [WithDefaults].  It is
+	// encoded after the filter as required by RFC6243.
+	Defaults DefaultsMode `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-with-defaults with-defaults,omitempty"`
+
+	// filterXPaths and filterSpec are set by the [GetConfig] options and used
+	// to build the subtree Filter once all the options are applied.
+	filterXPaths []string
+	filterSpec   filterSpec
+	// maxDepth is set with [WithMaxDepth] which is only supported by
+	// [Session.GetData].
+	maxDepth int
+	// filterNoType is set with [WithFilterNoType].
+	filterNoType bool
+}
+
+// filterSpec holds the options on how an XPath expression is converted into a
+// subtree filter.
+type filterSpec struct {
+	// namespace is declared as the default namespace of the first element.
+	namespace string
+	// prefixes maps the namespace prefixes used in the XPath expressions to
+	// namespaces.
+	prefixes map[string]string
+	// selectLeaves are added as empty selection nodes to the last element.
+	selectLeaves []string
+	// contentMatches are added as content match nodes to the last element.
+	contentMatches []xpathMatch
+}
+
+// buildFilter converts the XPath expressions given with [WithFilter] or
+// [WithFilters] into the subtree filter of the request.
+func (r *GetConfigReq) buildFilter() error {
+	if r.maxDepth != 0 {
+		return fmt.Errorf("max-depth is only supported by get-data")
+	}
+	subtree, err := r.subtreeFilter()
+	if err != nil || subtree == "" {
+		return err
+	}
+	if r.filterNoType {
+		r.Filter = fmt.Sprintf(`<filter>%s</filter>`, subtree)
+		return nil
+	}
+	r.Filter = fmt.Sprintf(`<filter type="subtree">%s</filter>`, subtree)
+	return nil
+}
+
+// subtreeFilter returns the contents of the subtree filter built from the
+// XPath expressions, if any.
+func (r *GetConfigReq) subtreeFilter() (string, error) {
+	if len(r.filterXPaths) == 0 {
+		return "", nil
+	}
+	subtree, err := parseXPathsToXML(r.filterXPaths, r.filterSpec)
+	if err != nil {
+		return "", fmt.Errorf("invalid filter: %w", err)
+	}
+	return subtree, nil
 }
 
 type GetConfigReply struct {
-	XMLName xml.Name `xml:"data"`
-	Config  []byte   `xml:",innerxml"`
+	XMLName xml.Name   `xml:"data"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Config  []byte     `xml:",innerxml"`
 }
 
-// parseXPathToXML converts an XPath expression into an XML subtree
-func parseXPathToXML(xpath string) (string, error) {
-	if !strings.HasPrefix(xpath, "/") {
-		return "", errors.New("invalid XPath format: must start with '/'")
+// ErrMissingData is returned by [Session.GetConfig] and [Session.Get] if the
+// reply has neither a `<data>` element nor any rpc-errors.  This is a malformed
+// reply unlike an empty `<data/>` returned when the filter matched nothing
+// (see [ErrNoData]).
+var ErrMissingData = errors.New("netconf: reply contains neither <data> nor rpc-error")
+
+// checkData returns ErrMissingData if the reply had no `<data>` element.  An empty
+// `<data>` is returned as an empty (but non-nil) Config.
+func (r *GetConfigReply) checkData() error {
+	if r.XMLName.Local == "" {
+		return ErrMissingData
 	}
-	// Regular expression to extract elements and conditions (e.g., `/library/book[title="Go Programming"]`)
-	re := regexp.MustCompile(`/([\w-]+)(?:\[(.+?)=['"](.+?)['"]\])?`)
-	matches := re.FindAllStringSubmatch(xpath, -1)
+	if r.Config == nil {
+		r.Config = []byte{}
+	}
+	return nil
+}
+
+// parseXPathToXML converts an XPath expression into an XML subtree as
+// configured by `spec`.  See [parseXPath] for the supported expressions.
+func parseXPathToXML(xpath string, spec filterSpec) (string, error) {
+	return parseXPathsToXML([]string{xpath}, spec)
+}
+
+// parseXPathsToXML converts XPath expressions into a single XML subtree.  The
+// common leading steps of the expressions are merged so every expression
+// becomes a branch of the same tree.  An expression selecting an ancestor of
+// another one already selects all of it, so the more specific one is dropped
+// instead of narrowing the ancestor down to it.
+func parseXPathsToXML(xpaths []string, spec filterSpec) (string, error) {
+	var root filterNode
+next:
+	for _, xpath := range xpaths {
+		steps, err := parseXPath(xpath)
+		if err != nil {
+			return "", err
+		}
 
-	if len(matches) == 0 {
-		return "", fmt.Errorf("invalid XPath format")
+		n := &root
+		for i, step := range steps {
+			var namespace string
+			switch {
+			case step.prefix != "":
+				var ok bool
+				if namespace, ok = spec.prefixes[step.prefix]; !ok {
+					return "", fmt.Errorf("invalid XPath %q: unknown namespace prefix %q", xpath, step.prefix)
+				}
+			case i == 0:
+				namespace = spec.namespace
+			}
+			n = n.child(step.name, namespace, step.matches)
+			if n.selected && i < len(steps)-1 {
+				continue next
+			}
+		}
+		n.selected = true
+		n.children = nil
+
+		// Content match and selection nodes for the last element.  A leaf
+		// that is already used as a content match node is returned anyway.
+		last := steps[len(steps)-1]
+		for _, m := range spec.contentMatches {
+			if !n.hasExtraMatch(m) {
+				n.extraMatches = append(n.extraMatches, m)
+			}
+		}
+		for _, leaf := range spec.selectLeaves {
+			if !last.hasMatch(leaf) && !n.matchesLeaf(leaf) && !n.selects(leaf) {
+				n.selections = append(n.selections, leaf)
+			}
+		}
 	}
 
 	var buffer bytes.Buffer
-	buffer.WriteString("") // Start XML
+	for _, n := range root.children {
+		n.write(&buffer, "")
+	}
+	return buffer.String(), nil
+}
 
-	// Track open tags to properly close them later
-	openTags := []string{}
+// filterNode is a element of a subtree filter built from XPath expressions.
+type filterNode struct {
+	name      string
+	namespace string
+	// matches are the content match nodes from the XPath predicates which
+	// identify the node.
+	matches []xpathMatch
+	// extraMatches are the content match nodes given with
+	// [WithContentMatch].
+	extraMatches []xpathMatch
+	selections   []string
+	children     []*filterNode
+	// selected is set if a expression ends at this node selecting all of
+	// it's subtree.
+	selected bool
+}
 
-	// Build XML from parsed XPath
-	for _, match := range matches {
-		element := match[1] // XML tag name (e.g., library, book)
+// child returns the child with the given name, namespace and content matches
+// creating it if it doesn't exist.
+func (n *filterNode) child(name, namespace string, matches []xpathMatch) *filterNode {
+	for _, c := range n.children {
+		if c.name == name && c.namespace == namespace && reflect.DeepEqual(c.matches, matches) {
+			return c
+		}
+	}
+	c := &filterNode{name: name, namespace: namespace, matches: matches}
+	n.children = append(n.children, c)
+	return c
+}
 
-		// Open tag
-		buffer.WriteString(fmt.Sprintf("<%s>", element))
-		openTags = append(openTags, element)
+func (n *filterNode) hasExtraMatch(m xpathMatch) bool {
+	for _, em := range n.extraMatches {
+		if em == m {
+			return true
+		}
+	}
+	return false
+}
 
-		// If there's a condition (e.g., title="Go Programming"), add a child node
-		if match[2] != "" && match[3] != "" {
-			conditionTag := match[2] // e.g., title
-			value := match[3]        // e.g., "Go Programming"
+func (n *filterNode) matchesLeaf(leaf string) bool {
+	for _, em := range n.extraMatches {
+		if em.name == leaf {
+			return true
+		}
+	}
+	return false
+}
 
-			buffer.WriteString(fmt.Sprintf("<%s>%s</%s>", conditionTag, html.EscapeString(value), conditionTag))
+func (n *filterNode) selects(leaf string) bool {
+	for _, s := range n.selections {
+		if s == leaf {
+			return true
 		}
 	}
+	return false
+}
+
+// write writes the node to buf.  The namespace is only declared (as the
+// default namespace) when it differs from the one of the parent so the
+// declarations of different branches never collide.  A node without content
+// (i.e the last element of `/interfaces/interface` selecting all entries of
+// the list) is written as an empty selection node (`<interface/>`).
+func (n *filterNode) write(buf *bytes.Buffer, parentNamespace string) {
+	empty := len(n.matches) == 0 && len(n.extraMatches) == 0 && len(n.selections) == 0 && len(n.children) == 0
+	end := ">"
+	if empty {
+		end = "/>"
+	}
 
-	// Close all open tags in reverse order
-	for i := len(openTags) - 1; i >= 0; i-- {
-		buffer.WriteString(fmt.Sprintf("</%s>", openTags[i]))
+	namespace := parentNamespace
+	if n.namespace != "" && n.namespace != parentNamespace {
+		namespace = n.namespace
+		fmt.Fprintf(buf, `<%s xmlns="%s"%s`, n.name, html.EscapeString(namespace), end)
+	} else {
+		fmt.Fprintf(buf, "<%s%s", n.name, end)
+	}
+	if empty {
+		return
 	}
 
-	return buffer.String(), nil
+	// predicates (e.g., title="Go Programming") become content match nodes.
+	for _, m := range n.matches {
+		fmt.Fprintf(buf, "<%s>%s</%s>", m.name, html.EscapeString(m.value), m.name)
+	}
+	for _, m := range n.extraMatches {
+		fmt.Fprintf(buf, "<%s>%s</%s>", m.name, html.EscapeString(m.value), m.name)
+	}
+	for _, leaf := range n.selections {
+		fmt.Fprintf(buf, "<%s/>", leaf)
+	}
+	for _, c := range n.children {
+		c.write(buf, namespace)
+	}
+
+	fmt.Fprintf(buf, "</%s>", n.name)
+}
+
+// RPCOption configures the filter and other parameters of the retrieval
+// operations such as [Session.Get] and [Session.GetConfig].
+type RPCOption func(*GetConfigReq)
+
+// WithFilter limits the config returned by [Session.GetConfig] to the subtree
+// selected by the given XPath expression which is converted to a subtree filter.
+func WithFilter(xpath string) RPCOption {
+	return func(c *GetConfigReq) {
+		c.filterXPaths = []string{xpath}
+	}
 }
 
-type rpcOptions func(*GetConfigReq)
+// WithFilters is like [WithFilter] but combines several XPath expressions into
+// a single subtree filter with a branch for each of them, so disjoint subtrees
+// are returned with a single rpc:
+//
+//	GetConfig(ctx, Running,
+//		WithFilterPrefix("if", "urn:ietf:params:xml:ns:yang:ietf-interfaces"),
+//		WithFilterPrefix("rt", "urn:ietf:params:xml:ns:yang:ietf-routing"),
+//		WithFilters("/if:interfaces", "/rt:routing"))
+//
+// Each branch declares it's own namespace so different modules don't collide.
+// An expression below another one (i.e `/if:interfaces/interface` along with
+// `/if:interfaces`) is already covered by it and is dropped.
+func WithFilters(xpaths ...string) RPCOption {
+	return func(c *GetConfigReq) {
+		c.filterXPaths = append([]string(nil), xpaths...)
+	}
+}
 
-func WithFilter(xpath string) rpcOptions{
-	return func(c *GetConfigReq){
-		subtree,err:=parseXPathToXML(xpath)
-		if(err==nil){
-			str:=`<filter type="subtree">%s</filter>`
-			c.Filter=fmt.Sprintf(str,subtree)
+// WithFilterPrefix maps a namespace prefix used in the filter XPath
+// expressions (i.e `if` in `/if:interfaces`) to `namespace`.  The namespace is
+// declared as the default namespace of the prefixed element.
+func WithFilterPrefix(prefix, namespace string) RPCOption {
+	return func(c *GetConfigReq) {
+		if c.filterSpec.prefixes == nil {
+			c.filterSpec.prefixes = make(map[string]string)
 		}
+		c.filterSpec.prefixes[prefix] = namespace
+	}
+}
+
+// WithFilterNoType omits the `type="subtree"` attribute from the `<filter>`
+// built from [WithFilter] or [WithFilters].  Subtree is the default type of a
+// filter and a few old devices reject the attribute.  Only use it for those
+// devices: by default the attribute is sent as recommended by RFC6241.
+func WithFilterNoType() RPCOption {
+	return func(c *GetConfigReq) {
+		c.filterNoType = true
+	}
+}
+
+// WithFilterNamespace declares `namespace` as the default namespace (`xmlns`)
+// of the first element of the filter given with [WithFilter] (or of every
+// branch given with [WithFilters]) unless it has a prefix.  Most devices
+// match nothing for a filter whose top level element is not in the namespace of
+// the YANG model.
+func WithFilterNamespace(namespace string) RPCOption {
+	return func(c *GetConfigReq) {
+		c.filterSpec.namespace = namespace
+	}
+}
+
+// WithSelectLeaves adds the given leaves as empty selection nodes to the last
+// element of the filter given with [WithFilter].
+//
+// In a subtree filter a leaf with a value (e.g. `<name>eth0</name>` from the
+// predicate `[name='eth0']`) is a content match node: it only selects the list
+// entries where the leaf matches.  If a list entry only contains content match
+// nodes the device returns the whole entry.  As soon as an entry has any
+// selection node (an empty element, e.g. `<enabled/>`) only the content match
+// nodes and the selected nodes are returned.  So
+//
+//	GetConfig(ctx, Running,
+//		WithFilter("/interfaces/interface[name='eth0']"),
+//		WithSelectLeaves("enabled", "mtu"))
+//
+// returns only the `name`, `enabled` and `mtu` leaves of the interface `eth0`.
+// See [RFC6241 6.2] for the complete rules.
+//
+// [RFC6241 6.2]: https://www.rfc-editor.org/rfc/rfc6241.html#section-6.2
+func WithSelectLeaves(leaves ...string) RPCOption {
+	return func(c *GetConfigReq) {
+		c.filterSpec.selectLeaves = append(c.filterSpec.selectLeaves, leaves...)
+	}
+}
+
+// WithDefaults sets the with-defaults mode ([RFC6243]) of [Session.GetConfig]
+// or [Session.Get], i.e to return the leaves with default values with
+// [DefaultsReportAll].  It can also be used with [Session.CopyConfig] to a
+// [URL].  The `<with-defaults>` parameter is sent after the
+// `<filter>` as some devices are sensitive to the order.  The device must
+// advertise the `:with-defaults` capability supporting `mode` or the
+// operation fails with an error wrapping [ErrCapabilityMissing].
+//
+// [RFC6243]: https://www.rfc-editor.org/rfc/rfc6243.html
+func WithDefaults(mode DefaultsMode) RPCOption {
+	return func(c *GetConfigReq) {
+		c.Defaults = mode
 	}
+}
 
+// WithContentMatch adds a content match node `<leaf>value</leaf>` to the last
+// element of the filter given with [WithFilter] (or of every branch given with
+// [WithFilters]) to only select the entries where `leaf` equals `value`.
+//
+// The XPath predicates (i.e `[name='eth0']`) are meant for list keys: they
+// identify the entry, so every distinct set of predicates becomes it's own
+// list entry in the filter.  WithContentMatch instead selects entries by the
+// value of any leaf without naming one:
+//
+//	GetConfig(ctx, Running,
+//		WithFilter("/interfaces/interface"),
+//		WithContentMatch("enabled", "true"))
+//
+// sends the filter
+//
+//	<interfaces><interface><enabled>true</enabled></interface></interfaces>
+//
+// which returns every enabled interface.  Following the subtree filtering rules
+// in [RFC6241 6.2.5] the content match nodes of an element are combined with a
+// logical AND and only constrain their parent element: the filter must end
+// with the list (i.e `/interfaces/interface`) and not with the container
+// around it (i.e `/interfaces`), otherwise the match is against a leaf of the
+// container that doesn't exist and nothing is returned.  Like with the
+// predicates, an entry with only content match nodes is returned whole unless
+// selection nodes are added with [WithSelectLeaves].
+//
+// [RFC6241 6.2.5]: https://www.rfc-editor.org/rfc/rfc6241.html#section-6.2.5
+func WithContentMatch(leaf, value string) RPCOption {
+	return func(c *GetConfigReq) {
+		c.filterSpec.contentMatches = append(c.filterSpec.contentMatches, xpathMatch{name: leaf, value: value})
+	}
 }
 
 // GetConfig implements the <get-config> rpc operation defined in [RFC6241 7.1].
-// `source` is the datastore to query.
+// `source` is the datastore to query.  A [URL] is not a valid source (see
+// [Session.CopyConfig] to copy a config from a url).  If nothing matched the filter an empty
+// config is returned.  A reply without `<data>` fails with
+// [ErrMissingData].
 //
 // [RFC6241 7.1]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.1
-func (s *Session) GetConfig(ctx context.Context, source Datastore,opts ...rpcOptions) ([]byte, error) {
+func (s *Session) GetConfig(ctx context.Context, source Datastore, opts ...RPCOption) ([]byte, error) {
+	resp, err := s.getConfig(ctx, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Config, nil
+}
+
+// prepareGetConfig builds the filter of `req` and checks the source and options
+// are supported by the device.
+func (s *Session) prepareGetConfig(req *GetConfigReq) error {
+	if err := checkGetConfigSource(req.Source); err != nil {
+		return err
+	}
+	if err := req.buildFilter(); err != nil {
+		return err
+	}
+	if req.Defaults != "" {
+		return s.checkDefaultsMode(req.Defaults)
+	}
+	return nil
+}
+
+// checkGetConfigSource returns an error unless `source` is the name of a
+// datastore.  RFC6241 only allows a `<url>` as the source of `<copy-config>` and
+// `<validate>` so a url (converted to a Datastore) is rejected.
+func checkGetConfigSource(source Datastore) error {
+	switch {
+	case source == "":
+		return errors.New("get-config source cannot be empty")
+	case strings.Contains(string(source), "://"):
+		return fmt.Errorf("get-config source %q is a url but only datastores can be read: use CopyConfig to copy a config from a url", source)
+	case !isNCName(string(source)):
+		return fmt.Errorf("get-config source %q is not a valid datastore name", source)
+	}
+	return nil
+}
+
+func (s *Session) getConfig(ctx context.Context, source Datastore, opts []RPCOption) (*GetConfigReply, error) {
 	req := GetConfigReq{
 		Source: source,
 	}
 	for _, opt := range opts {
 		opt(&req)
 	}
+	if err := s.prepareGetConfig(&req); err != nil {
+		return nil, err
+	}
 
 	var resp GetConfigReply
 	if err := s.Call(ctx, &req, &resp); err != nil {
 		return nil, err
 	}
+	if err := resp.checkData(); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
 
-	return resp.Config, nil
+// BatchErrors are the errors of [Session.GetConfigBatch] aligned with the
+// requests.  The error of a request that succeeded is nil.
+type BatchErrors []error
+
+func (errs BatchErrors) Error() string {
+	var (
+		failed int
+		first  error
+	)
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+		}
+		failed++
+	}
+	return fmt.Sprintf("netconf: %d of %d requests failed: %v", failed, len(errs), first)
+}
+
+// Unwrap returns the errors of the failed requests.
+func (errs BatchErrors) Unwrap() []error {
+	var out []error
+	for _, err := range errs {
+		if err != nil {
+			out = append(out, err)
+		}
+	}
+	return out
+}
+
+// GetConfigBatch issues all the `<get-config>` requests in `reqs` with at most
+// `concurrency` requests waiting for their reply at a time (at least one).
+// This is useful to fetch many list entries individually (i.e for a backup)
+// without overwhelming the device.
+//
+// The configs are returned in the order of the requests.  If any request fails
+// the returned error is a [BatchErrors] aligned with `reqs` and the configs of
+// the requests that succeeded are still returned.  Requests not issued yet when
+// `ctx` is done fail with the error of the context.
+func (s *Session) GetConfigBatch(ctx context.Context, reqs []GetConfigReq, concurrency int) ([][]byte, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		configs = make([][]byte, len(reqs))
+		errs    = make(BatchErrors, len(reqs))
+		next    = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < min(concurrency, len(reqs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				req := reqs[i]
+				if err := s.prepareGetConfig(&req); err != nil {
+					errs[i] = err
+					continue
+				}
+
+				var resp GetConfigReply
+				if err := s.Call(ctx, &req, &resp); err != nil {
+					errs[i] = err
+					continue
+				}
+				if err := resp.checkData(); err != nil {
+					errs[i] = err
+					continue
+				}
+				configs[i] = resp.Config
+			}
+		}()
+	}
+
+	for i := range reqs {
+		select {
+		case next <- i:
+			continue
+		case <-ctx.Done():
+		}
+
+		for ; i < len(reqs); i++ {
+			errs[i] = ctx.Err()
+		}
+		break
+	}
+	close(next)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return configs, errs
+		}
+	}
+	return configs, nil
+}
+
+// ConfigMeta is the metadata of a configuration returned by
+// [Session.GetConfigWithMeta].
+type ConfigMeta struct {
+	// LastModified is the time the configuration was last changed.
+	LastModified time.Time
+	// ETag is the entity tag of the configuration which changes whenever the
+	// configuration changes.
+	ETag string
+}
+
+// GetConfigWithMeta is like [Session.GetConfig] but also returns the
+// `last-modified` and `etag` metadata annotations ([RFC7952]) of the
+// configuration, as returned by devices supporting entity tags and timestamps
+// for NETCONF.  This allows change detection and conditional edits.
+//
+// The annotations are read from the `<data>` element of the reply or, if not
+// present there, from the first top level element of the configuration that
+// has them.  A zero ConfigMeta is returned if the device doesn't supply any.
+//
+// [RFC7952]: https://www.rfc-editor.org/rfc/rfc7952.html
+func (s *Session) GetConfigWithMeta(ctx context.Context, source Datastore, opts ...RPCOption) ([]byte, ConfigMeta, error) {
+	resp, err := s.getConfig(ctx, source, opts)
+	if err != nil {
+		return nil, ConfigMeta{}, err
+	}
+
+	meta, err := parseConfigMeta(resp)
+	if err != nil {
+		return nil, ConfigMeta{}, err
+	}
+	return resp.Config, meta, nil
+}
+
+// parseConfigMeta finds the metadata annotations in a `<get-config>` reply.
+func parseConfigMeta(resp *GetConfigReply) (ConfigMeta, error) {
+	var meta ConfigMeta
+	found, err := meta.fromAttrs(resp.Attrs)
+	if err != nil || found {
+		return meta, err
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(resp.Config))
+	for {
+		start, err := startElement(dec)
+		if err != nil {
+			// no (more) top level elements
+			return meta, nil
+		}
+
+		found, err := meta.fromAttrs(start.Attr)
+		if err != nil || found {
+			return meta, err
+		}
+		if err := dec.Skip(); err != nil {
+			return meta, nil
+		}
+	}
+}
+
+// fromAttrs sets the metadata from the annotations in attrs and reports if any
+// were found.
+func (m *ConfigMeta) fromAttrs(attrs []xml.Attr) (bool, error) {
+	var found bool
+	for _, attr := range attrs {
+		switch strings.ToLower(attr.Name.Local) {
+		case "last-modified":
+			t, err := time.Parse(time.RFC3339, attr.Value)
+			if err != nil {
+				return false, fmt.Errorf("invalid last-modified annotation %q: %w", attr.Value, err)
+			}
+			m.LastModified = t
+			found = true
+		case "etag":
+			m.ETag = attr.Value
+			found = true
+		}
+	}
+	return found, nil
+}
+
+// ErrNoData is returned by [GetConfigAs] when the device returned an empty
+// `<data>` element, i.e when the filter did not match anything.
+var ErrNoData = errors.New("netconf: no data returned")
+
+// GetConfigAs issues a `<get-config>` operation like [Session.GetConfig] and
+// decodes the returned config into a value of type T with encoding/xml.  T is
+// usually a struct mapping the top level element of the config.
+//
+// If the device returns no data the zero value of T and [ErrNoData] is
+// returned.
+func GetConfigAs[T any](ctx context.Context, s *Session, source Datastore, opts ...RPCOption) (T, error) {
+	var v T
+
+	config, err := s.GetConfig(ctx, source, opts...)
+	if err != nil {
+		return v, err
+	}
+
+	if len(bytes.TrimSpace(config)) == 0 {
+		return v, ErrNoData
+	}
+
+	if err := xml.Unmarshal(config, &v); err != nil {
+		return v, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return v, nil
+}
+
+// Snapshot will fetch the configuration of all the given `sources` datastores
+// with `<get-config>` and returns them keyed by the datastore.  The fetches are
+// issued concurrently on the session.
+//
+// If any of the fetches fail the configs that were fetched successfully are
+// still returned along with an error joining the errors of all failed fetches.
+func (s *Session) Snapshot(ctx context.Context, sources ...Datastore) (map[Datastore][]byte, error) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	configs := make(map[Datastore][]byte, len(sources))
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source Datastore) {
+			defer wg.Done()
+			config, err := s.GetConfig(ctx, source)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get %s config: %w", source, err))
+				return
+			}
+			configs[source] = config
+		}(source)
+	}
+	wg.Wait()
+
+	return configs, errors.Join(errs...)
 }
 
 // MergeStrategy defines the strategies for merging configuration in a
@@ -225,6 +927,19 @@ const (
 	RemoveConfig MergeStrategy = "remove"
 )
 
+// MarshalXMLAttr implements xml.MarshalerAttr.  When used as the `operation`
+// attribute in the NETCONF base namespace the attribute is encoded with the
+// `nc` prefix (i.e `nc:operation="delete"`) which is declared on the
+// `<config>` element.  Without this encoding/xml declares it's own (invalid
+// looking) `_` prefix for every element and an unqualified `operation` is
+// silently ignored by some devices, turning a delete into a merge.
+func (m MergeStrategy) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if name.Space == NamespaceBase10 {
+		name = xml.Name{Local: "nc:" + name.Local}
+	}
+	return xml.Attr{Name: name, Value: string(m)}, nil
+}
+
 // TestStrategy defines the beahvior for testing configuration before applying it in a `<edit-config>` operation.
 //
 // *Note*: in RFC6241 7.2 this is called the `test-option` parameter. Since the `option` term is already
@@ -308,76 +1023,475 @@ type EditConfigOption interface {
 	apply(*EditConfigReq)
 }
 
-// EditConfig issues the `<edit-config>` operation defined in [RFC6241 7.2] for
-// updating an existing target config datastore.
+// innerXML is used to pass along already encoded xml as the contents of an
+// element.
+type innerXML struct {
+	Inner []byte `xml:",innerxml"`
+}
+
+// configData is the value returned by [Config].
+type configData struct {
+	namespace string
+	inner     any
+}
+
+// Config wraps `inner` to be used as the config of [Session.EditConfig],
+// [Session.CopyConfig] or [Session.Validate].  The top level element of `inner`
+// (which must be a struct or a pointer to a struct) is placed in `namespace`,
+// which is usually the namespace of the YANG model the config belongs to,
+// regardless of the namespace in it's `XMLName` tag.
 //
-// [RFC6241 7.2]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.2
-func (s *Session) EditConfig(ctx context.Context, target Datastore, config any, opts ...EditConfigOption) error {
-	req := EditConfigReq{
-		Target: target,
+// The `<config>` element also declares the `nc` prefix for the NETCONF base
+// namespace.  Per-element operations (i.e `nc:operation="delete"`) must be in
+// the base namespace, so a field of `inner` for the operation attribute should
+// look like:
+//
+//	Operation MergeStrategy `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 operation,attr,omitempty"`
+//
+// A [MergeStrategy] in the base namespace is always encoded with the `nc`
+// prefix.
+//
+// Per-element operations override the default operation of the
+// `<edit-config>` (see [WithDefaultMergeStrategy]) for that element and it's
+// children.
+func Config(namespace string, inner any) any {
+	return &configData{namespace: namespace, inner: inner}
+}
+
+func (c *configData) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	rv := reflect.ValueOf(c.inner)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported config type %T: must be a struct", c.inner)
+	}
+
+	start.Attr = append(start.Attr, xml.Attr{
+		Name:  xml.Name{Local: "xmlns:nc"},
+		Value: NamespaceBase10,
+	})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	innerStart := xml.StartElement{
+		Name: xml.Name{Space: c.namespace, Local: operationName(c.inner)},
+	}
+	if err := e.EncodeElement(c.inner, innerStart); err != nil {
+		return err
 	}
 
-	// XXX: Should we use reflect here?
-	switch v := config.(type) {
+	return e.EncodeToken(start.End())
+}
+
+// wrapConfigSource converts a config passed into one of the operations taking a
+// `<config>` element (i.e `<edit-config>`, `<copy-config>`, `<validate>`) into
+// either a value to be encoded as the `<config>` element or a url.
+//
+// Strings, byte slices and io.Readers are expected to contain already encoded
+// xml and are passed verbatim.  io.Readers are not read until the message is
+// written to the transport (see [streamedXML]).  A [URL] is returned as url.
+// Anything else must be a struct (or implement xml.Marshaler) to be encoded by
+// encoding/xml.
+func wrapConfigSource(v any) (config any, url string, err error) {
+	switch v := v.(type) {
 	case string:
-		req.Config = struct {
-			Inner []byte `xml:",innerxml"`
-		}{Inner: []byte(v)}
+		return &innerXML{Inner: []byte(v)}, "", nil
 	case []byte:
-		req.Config = struct {
-			Inner []byte `xml:",innerxml"`
-		}{Inner: v}
+		return &innerXML{Inner: v}, "", nil
 	case URL:
-		req.URL = string(v)
-	default:
-		req.Config = config
+		return nil, string(v), nil
+	case io.Reader:
+		return newStreamedXML(v), "", nil
+	case Datastore:
+		return nil, "", fmt.Errorf("datastore %q cannot be used as a config", v)
+	case xml.Marshaler:
+		return v, "", nil
 	}
 
-	for _, opt := range opts {
-		opt.apply(&req)
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, "", fmt.Errorf("unsupported config type %T", v)
 	}
+	return &ncConfig{v}, "", nil
+}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+// ncConfig encodes a struct as the `<config>` element declaring the `nc`
+// prefix for any [MergeStrategy] operation attributes.
+type ncConfig struct {
+	v any
 }
 
-type CopyConfigReq struct {
-	XMLName xml.Name `xml:"copy-config"`
-	Source  any      `xml:"source"`
-	Target  any      `xml:"target"`
+func (c *ncConfig) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{
+		Name:  xml.Name{Local: "xmlns:nc"},
+		Value: NamespaceBase10,
+	})
+	return e.EncodeElement(c.v, start)
 }
 
-// CopyConfig issues the `<copy-config>` operation as defined in [RFC6241 7.3]
-// for copying an entire config to/from a source and target datastore.
-//
-// A `<config>` element defining a full config can be used as the source.
-//
-// If a device supports the `:url` capability than a [URL] object can be used
-// for the source or target datastore.
-//
-// [RFC6241 7.3] https://www.rfc-editor.org/rfc/rfc6241.html#section-7.3
-func (s *Session) CopyConfig(ctx context.Context, source, target any) error {
-	req := CopyConfigReq{
-		Source: source,
-		Target: target,
+// configSource returns the value to be used for a `<source>` element of a
+// operation that can take a datastore, a url or a inline `<config>` element.
+func configSource(v any) (any, error) {
+	if ds, ok := v.(Datastore); ok {
+		return ds, nil
 	}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	config, url, err := wrapConfigSource(v)
+	if err != nil {
+		return nil, err
+	}
+	if url != "" {
+		return URL(url), nil
+	}
+
+	return inlineConfig{config}, nil
 }
 
-type DeleteConfigReq struct {
-	XMLName xml.Name  `xml:"delete-config"`
-	Target  Datastore `xml:"target"`
+// inlineConfig is the `<config>` element of a `<source>`.
+type inlineConfig struct {
+	Config any `xml:"config"`
 }
 
-func (s *Session) DeleteConfig(ctx context.Context, target Datastore) error {
-	req := DeleteConfigReq{
-		Target: target,
+// streamsKey is the context key for the configs streamed by a request (see
+// [withStreamedConfig]).
+type streamsKey struct{}
+
+// withStreamedConfig returns a copy of `ctx` marking the request issued with it
+// as streaming `config` if it is read from an io.Reader (as returned by
+// [wrapConfigSource] or [configSource]).  Only marked requests are written with
+// [writeStreamed].
+func withStreamedConfig(ctx context.Context, config any) context.Context {
+	switch c := config.(type) {
+	case *streamedXML:
+		prev, _ := ctx.Value(streamsKey{}).([]*streamedXML)
+		streams := make([]*streamedXML, len(prev), len(prev)+1)
+		copy(streams, prev)
+		return context.WithValue(ctx, streamsKey{}, append(streams, c))
+	case inlineConfig:
+		return withStreamedConfig(ctx, c.Config)
 	}
+	return ctx
+}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+// EditConfig issues the `<edit-config>` operation defined in [RFC6241 7.2] for
+// updating an existing target config datastore.
+//
+// `config` can be a string, byte slice or io.Reader containing already encoded
+// xml, a struct to be encoded with encoding/xml, or a [URL] to load the config
+// from.  Any other type will return an error.
+//
+// An io.Reader is streamed directly to the transport as the contents of the
+// `<config>` element without being buffered in memory, which makes it suitable
+// for very large configs.  The contents are assumed to be well-formed xml.  If
+// reading fails mid-stream the error is returned and, as part of the message
+// may have been sent already, the session is closed to never leave a truncated
+// rpc for the device to process.  Other calls wait for their turn to write
+// while a config is streamed.
+//
+// Editing [Running] requires the `:writable-running` capability and editing
+// [Candidate] requires the `:candidate` capability.  If the server doesn't
+// support editing the target an error wrapping [ErrCapabilityMissing] is
+// returned without contacting the device.  On devices with only `:candidate`
+// the running config is changed by editing the candidate and committing it
+// with [Session.Commit].
+//
+// [RFC6241 7.2]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.2
+func (s *Session) EditConfig(ctx context.Context, target Datastore, config any, opts ...EditConfigOption) error {
+	if err := s.checkEditTarget(target); err != nil {
+		return err
+	}
+
+	req, err := newEditConfigReq(target, config, opts)
+	if err != nil {
+		return err
+	}
+
+	return s.callOK(withStreamedConfig(ctx, req.Config), req)
+}
+
+// checkEditTarget returns an error wrapping ErrCapabilityMissing if target is
+// the running or candidate datastore and the server cannot edit it directly.
+func (s *Session) checkEditTarget(target Datastore) error {
+	switch target {
+	case Running:
+		if _, ok := s.serverCaps.Lookup(":writable-running"); ok {
+			return nil
+		}
+		if _, ok := s.serverCaps.Lookup(":candidate"); ok {
+			return fmt.Errorf("%w: edit-config on running requires :writable-running, edit the candidate and commit it instead", ErrCapabilityMissing)
+		}
+		return s.requireCapability("edit-config on running", ":writable-running")
+	case Candidate:
+		if _, ok := s.serverCaps.Lookup(":candidate"); ok {
+			return nil
+		}
+		if _, ok := s.serverCaps.Lookup(":writable-running"); ok {
+			return fmt.Errorf("%w: edit-config on candidate requires :candidate, edit running directly instead", ErrCapabilityMissing)
+		}
+		return s.requireCapability("edit-config on candidate", ":candidate")
+	}
+	return nil
+}
+
+func newEditConfigReq(target Datastore, config any, opts []EditConfigOption) (*EditConfigReq, error) {
+	req := EditConfigReq{
+		Target: target,
+	}
+
+	var err error
+	req.Config, req.URL, err = wrapConfigSource(config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt.apply(&req)
+	}
+	return &req, nil
+}
+
+// EditResult is the result of an `<edit-config>` operation returned by
+// [Session.EditConfigResult].
+type EditResult struct {
+	// OK is true if the device replied with `<ok/>`, i.e. the complete
+	// configuration was applied.
+	OK bool
+	// Errors are all rpc-errors in the reply.  With [ContinueOnError] these
+	// are the parts of the configuration that were not applied.
+	Errors RPCErrors
+}
+
+// EditConfigResult issues an `<edit-config>` operation like
+// [Session.EditConfig] but returns all rpc-errors of the reply in an
+// [EditResult] instead of as an error.
+//
+// With [ContinueOnError] the device applies as much of the configuration as it
+// can and reports an rpc-error for every part it skipped.  These errors are
+// returned in the result and only a failure to issue the operation is returned
+// as an error.  With any other [ErrorStrategy] rpc-errors with an `error`
+// severity are returned as the error just like [Session.EditConfig] and the
+// result only contains warnings.
+func (s *Session) EditConfigResult(ctx context.Context, target Datastore, config any, opts ...EditConfigOption) (*EditResult, error) {
+	if err := s.checkEditTarget(target); err != nil {
+		return nil, err
+	}
+
+	req, err := newEditConfigReq(target, config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.Do(withStreamedConfig(ctx, req.Config), req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ErrorStrategy != ContinueOnError {
+		if err := s.replyErr(reply); err != nil {
+			return nil, err
+		}
+	}
+
+	ok, err := reply.hasOK()
+	if err != nil {
+		return nil, err
+	}
+
+	result := EditResult{
+		OK:     ok,
+		Errors: reply.Errors,
+	}
+	if !result.OK && len(result.Errors) == 0 {
+		return nil, ErrNoOK
+	}
+	return &result, nil
+}
+
+type CopyConfigReq struct {
+	XMLName xml.Name `xml:"copy-config"`
+	Source  any      `xml:"source"`
+	Target  any      `xml:"target"`
+	// Defaults is the with-defaults mode set with [WithDefaults].
+	Defaults DefaultsMode `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-with-defaults with-defaults,omitempty"`
+
+	// unsupportedOpt is set if a option other than [WithDefaults] was given.
+	unsupportedOpt bool
+}
+
+// CopyConfigOption is a optional argument to [Session.CopyConfig].  Only
+// [WithDefaults] is supported.
+type CopyConfigOption interface {
+	applyCopyConfig(*CopyConfigReq)
+}
+
+func (o RPCOption) applyCopyConfig(req *CopyConfigReq) {
+	var getReq GetConfigReq
+	o(&getReq)
+	if getReq.Defaults == "" {
+		req.unsupportedOpt = true
+		return
+	}
+	req.Defaults = getReq.Defaults
+}
+
+// CopyConfig issues the `<copy-config>` operation as defined in [RFC6241 7.3]
+// for copying an entire config to/from a source and target datastore.
+//
+// A `<config>` element defining a full config can be used as the source.  The
+// config can be given as any of the types accepted by [Session.EditConfig].
+//
+// If a device supports the `:url` capability than a [URL] object can be used
+// for the source and/or target datastore.  The url is checked against the
+// schemes advertised with the capability and an error wrapping
+// [ErrCapabilityMissing] is returned without sending the request if the device
+// doesn't support it.
+//
+// When backing up a config to a [URL] the leaves with default values are
+// included according to the with-defaults basic mode of the device.  Use
+// [WithDefaults] (i.e with [DefaultsReportAll]) to make the backup independent
+// of it so it can be restored verbatim.  As defined in [RFC6243 4.5.3] this is
+// only allowed with a URL target and the device must advertise the
+// `:with-defaults` capability with the mode, which is the case for most devices
+// supporting both `:url` and `:with-defaults`.
+//
+// [RFC6241 7.3] https://www.rfc-editor.org/rfc/rfc6241.html#section-7.3
+// [RFC6243 4.5.3]: https://www.rfc-editor.org/rfc/rfc6243.html#section-4.5.3
+func (s *Session) CopyConfig(ctx context.Context, source, target any, opts ...CopyConfigOption) error {
+	src, err := configSource(source)
+	if err != nil {
+		return err
+	}
+	if u, ok := src.(URL); ok {
+		if err := s.checkURL(u); err != nil {
+			return err
+		}
+	}
+
+	switch t := target.(type) {
+	case Datastore:
+	case URL:
+		if err := s.checkURL(t); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported copy-config target type %T", target)
+	}
+
+	req := CopyConfigReq{
+		Source: src,
+		Target: target,
+	}
+	for _, opt := range opts {
+		opt.applyCopyConfig(&req)
+	}
+	if req.unsupportedOpt {
+		return errors.New("copy-config only supports the WithDefaults option")
+	}
+	if req.Defaults != "" {
+		if _, ok := target.(URL); !ok {
+			return fmt.Errorf("with-defaults can only be used for copy-config to a url")
+		}
+		if err := s.checkDefaultsMode(req.Defaults); err != nil {
+			return err
+		}
+	}
+
+	return s.callOK(withStreamedConfig(ctx, src), &req)
+}
+
+type DeleteConfigReq struct {
+	XMLName xml.Name  `xml:"delete-config"`
+	Target  Datastore `xml:"target"`
+
+	// discardFallback is set with [WithCandidateDiscardFallback].
+	discardFallback bool
+}
+
+// DeleteConfigOption is a optional arguments to [Session.DeleteConfig] method
+type DeleteConfigOption interface {
+	apply(*DeleteConfigReq)
+}
+
+type candidateDiscardFallback bool
+
+func (o candidateDiscardFallback) apply(req *DeleteConfigReq) { req.discardFallback = bool(o) }
+
+// WithCandidateDiscardFallback resets the candidate datastore with
+// `<discard-changes>` if the device rejects a `<delete-config>` of the
+// candidate with a rpc-error.  Deleting the candidate is not defined by
+// [RFC6241 7.4] so devices either accept it or expect the changes to be
+// discarded instead, which both leave the candidate with the contents of the
+// running datastore.  It has no effect on other targets.
+//
+// [RFC6241 7.4]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.4
+func WithCandidateDiscardFallback() DeleteConfigOption { return candidateDiscardFallback(true) }
+
+// DeleteConfig issues the `<delete-config>` operation as defined in [RFC6241
+// 7.4] for deleting a configuration datastore.  Use [Session.DeleteConfigURL]
+// to delete a url.
+//
+// The running datastore cannot be deleted and is rejected without sending a
+// request.  Deleting the startup datastore requires the `:startup` capability,
+// otherwise an error wrapping [ErrCapabilityMissing] is returned.  The
+// candidate datastore is deleted with a plain `<delete-config>` unless
+// [WithCandidateDiscardFallback] is given.
+//
+// [RFC6241 7.4]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.4
+func (s *Session) DeleteConfig(ctx context.Context, target Datastore, opts ...DeleteConfigOption) error {
+	switch target {
+	case Running:
+		return errors.New("netconf: the running datastore cannot be deleted")
+	case Startup:
+		if err := s.requireCapability("delete-config on startup", ":startup"); err != nil {
+			return err
+		}
+	}
+
+	req := DeleteConfigReq{
+		Target: target,
+	}
+	for _, opt := range opts {
+		opt.apply(&req)
+	}
+
+	err := s.callOK(ctx, &req)
+	var rpcErr RPCError
+	if req.discardFallback && target == Candidate && errors.As(err, &rpcErr) {
+		if discardErr := s.DiscardChanges(ctx); discardErr != nil {
+			return fmt.Errorf("failed to discard changes after delete-config was rejected (%v): %w", err, discardErr)
+		}
+		return nil
+	}
+	return err
+}
+
+// deleteConfigURLReq is the `<delete-config>` issued by
+// [Session.DeleteConfigURL].
+type deleteConfigURLReq struct {
+	XMLName xml.Name `xml:"delete-config"`
+	Target  URL      `xml:"target"`
+}
+
+// DeleteConfigURL issues the `<delete-config>` operation as defined in
+// [RFC6241 7.4] for deleting the configuration at `url`.  It requires the
+// `:url` capability with the scheme of the url, otherwise an error wrapping
+// [ErrCapabilityMissing] is returned without sending a request.
+//
+// [RFC6241 7.4]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.4
+func (s *Session) DeleteConfigURL(ctx context.Context, url URL) error {
+	if err := s.checkURL(url); err != nil {
+		return err
+	}
+
+	req := deleteConfigURLReq{
+		Target: url,
+	}
+	return s.callOK(ctx, &req)
 }
 
 type LockReq struct {
@@ -385,31 +1499,179 @@ type LockReq struct {
 	Target  Datastore `xml:"target"`
 }
 
+// MarshalXML encodes the `<lock>` (or `<unlock>`) operation named by XMLName.
+// The name is written without a namespace so it inherits the one of the
+// enclosing `<rpc>` instead of being reset with `xmlns=""`.
+func (r LockReq) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: r.XMLName.Local}
+	if start.Name.Local == "" {
+		start.Name.Local = "lock"
+	}
+	inner := struct {
+		Target Datastore `xml:"target"`
+	}{r.Target}
+	return e.EncodeElement(&inner, start)
+}
+
+// Lock implements the `<lock>` rpc operation defined in [RFC6241 7.5].  The
+// read-only NMDA datastores ([Intended] and [Operational]) cannot be locked and
+// return an error without contacting the device.
+//
+// [RFC6241 7.5]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.5
 func (s *Session) Lock(ctx context.Context, target Datastore) error {
+	if err := checkLockable(target); err != nil {
+		return err
+	}
+
 	req := LockReq{
 		XMLName: xml.Name{Local: "lock"},
 		Target:  target,
 	}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	return s.callOK(ctx, &req)
 }
 
+// Unlock implements the `<unlock>` rpc operation defined in [RFC6241 7.6].
+//
+// [RFC6241 7.6]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.6
 func (s *Session) Unlock(ctx context.Context, target Datastore) error {
+	if err := checkLockable(target); err != nil {
+		return err
+	}
+
 	req := LockReq{
 		XMLName: xml.Name{Local: "unlock"},
 		Target:  target,
 	}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	return s.callOK(ctx, &req)
+}
+
+// lockReleaseTimeout bounds the `<unlock>` issued by the release function
+// returned from [Session.LockWithContext].
+const lockReleaseTimeout = 30 * time.Second
+
+// LockWithContext will lock the `target` datastore and return a release
+// function that unlocks it again.  This is intended to be used with defer to
+// make sure a lock is never leaked on an early return:
+//
+//	release, err := session.LockWithContext(ctx, netconf.Candidate)
+//	if err != nil { /* ... handle error ... */ }
+//	defer release()
+//
+// The release function is safe to call multiple times.  Only the first call
+// will issue the `<unlock>` operation and any following calls will return the
+// same result.  The unlock is issued with a fresh context so that it can still
+// be sent after `ctx` is done and doesn't inherit its per-call options such as
+// [WithMessageID], but it gives up after 30 seconds if the device doesn't
+// answer.
+//
+// No background renewer is provided as NETCONF has no operation to extend a
+// lock: it is held until it is released or the session ends (RFC6241 7.5).
+// Locks that are lost on a device dropping an idle session are best kept by
+// keeping the session alive on the transport, e.g. with SSH keepalives.
+func (s *Session) LockWithContext(ctx context.Context, target Datastore) (release func() error, err error) {
+	if err := s.Lock(ctx, target); err != nil {
+		return nil, err
+	}
+
+	var (
+		once      sync.Once
+		unlockErr error
+	)
+	release = func() error {
+		once.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), lockReleaseTimeout)
+			defer cancel()
+			unlockErr = s.Unlock(ctx, target)
+		})
+		return unlockErr
+	}
+	return release, nil
+}
+
+// LockWait locks the `target` datastore like [Session.Lock] but if the lock is
+// denied because it is held by another session it waits `retry` and tries
+// again until the lock is acquired or `ctx` is done.  If ctx is done while
+// waiting the last `lock-denied` error is returned wrapped together with the
+// context error.  Any other error is returned immediately.
+func (s *Session) LockWait(ctx context.Context, target Datastore, retry time.Duration) error {
+	for {
+		err := s.Lock(ctx, target)
+		if err == nil || !IsLockDenied(err) {
+			return err
+		}
+
+		timer := time.NewTimer(retry)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("netconf: gave up waiting for lock on %s: %w: %w", target, ctx.Err(), err)
+		}
+	}
+}
+
+// LockCleanCandidate discards any uncommitted changes in the candidate
+// datastore and then locks it, returning a release function like
+// [Session.LockWithContext].  This gives a clean starting point to build a new
+// candidate configuration from.  It requires the `:candidate` capability.
+//
+// If the lock fails after the changes were discarded no lock is held and no
+// release function is returned.  Note that another session may still modify
+// the candidate between the discard and the lock.
+func (s *Session) LockCleanCandidate(ctx context.Context) (release func() error, err error) {
+	if err := s.requireCapability("lock on candidate", ":candidate"); err != nil {
+		return nil, err
+	}
+
+	if err := s.DiscardChanges(ctx); err != nil {
+		return nil, err
+	}
+	return s.LockWithContext(ctx, Candidate)
 }
 
-/*
-func (s *Session) Get(ctx context.Context,  filter Filter) error {
-	panic("unimplemented")
+type GetReq struct {
+	XMLName  xml.Name     `xml:"get"`
+	Filter   string       `xml:",innerxml"`
+	Defaults DefaultsMode `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-with-defaults with-defaults,omitempty"`
+}
+
+// Get implements the `<get>` rpc operation defined in [RFC6241 7.7] to retrieve
+// both the running config and the state data of the device.  The same filter
+// options as for [Session.GetConfig] can be used.
+//
+// [RFC6241 7.7]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.7
+func (s *Session) Get(ctx context.Context, opts ...RPCOption) ([]byte, error) {
+	// the filter options are shared with <get-config>
+	var filterReq GetConfigReq
+	for _, opt := range opts {
+		opt(&filterReq)
+	}
+	if err := filterReq.buildFilter(); err != nil {
+		return nil, err
+	}
+
+	req := GetReq{
+		Filter:   filterReq.Filter,
+		Defaults: filterReq.Defaults,
+	}
+	if req.Defaults != "" {
+		if err := s.checkDefaultsMode(req.Defaults); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp GetConfigReply
+	if err := s.Call(ctx, &req, &resp); err != nil {
+		return nil, err
+	}
+	if err := resp.checkData(); err != nil {
+		return nil, err
+	}
+
+	return resp.Config, nil
 }
-*/
 
 type KillSessionReq struct {
 	XMLName   xml.Name `xml:"kill-session"`
@@ -421,22 +1683,202 @@ func (s *Session) KillSession(ctx context.Context, sessionID uint32) error {
 		SessionID: sessionID,
 	}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	return s.callOK(ctx, &req)
+}
+
+// IsLockDenied reports whether any error in err's tree is a `lock-denied`
+// [RPCError], i.e the lock is held by another session.
+func IsLockDenied(err error) bool {
+	var rpcErr RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Tag == ErrLockDenied
+}
+
+// LockHolder returns the session-id of the session holding the lock from a
+// `lock-denied` rpc-error returned by [Session.Lock].  A session-id of 0 means
+// the lock is held by a non-NETCONF entity.  ok is false if err is not a
+// `lock-denied` error or doesn't contain the session-id.
+func LockHolder(err error) (sessionID uint32, ok bool) {
+	var rpcErr RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Tag != ErrLockDenied {
+		return 0, false
+	}
+
+	var info struct {
+		SessionID *uint32 `xml:"session-id"`
+	}
+	raw := append(append([]byte("<error-info>"), rpcErr.Info...), "</error-info>"...)
+	if err := xml.Unmarshal(raw, &info); err != nil || info.SessionID == nil {
+		return 0, false
+	}
+	return *info.SessionID, true
+}
+
+// KillLockHolder finds the session holding the lock on the `target` datastore
+// and kills it with [Session.KillSession].
+//
+// The lock holder is found by trying to lock the datastore and reading the
+// session-id from the `lock-denied` error.  If the device doesn't include it
+// the locks in the ietf-netconf-monitoring model are used instead.  If the
+// datastore turns out to not be locked the lock is released again and an error
+// is returned.
+//
+// This is dangerous!  Killing a session aborts any operation in progress on it
+// and releases all of it's locks and uncommitted changes, which may belong to
+// another user or automation in the middle of a change.  Because of this
+// `confirm` must be true or nothing is done.  It never kills the session it is
+// called on.  The killed session-id is returned.
+func (s *Session) KillLockHolder(ctx context.Context, target Datastore, confirm bool) (uint32, error) {
+	if !confirm {
+		return 0, errors.New("netconf: refusing to kill the lock holder without confirmation")
+	}
+
+	lockErr := s.Lock(ctx, target)
+	if lockErr == nil {
+		if err := s.Unlock(ctx, target); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("netconf: %s datastore is not locked", target)
+	}
+
+	holder, ok := LockHolder(lockErr)
+	if !ok {
+		var rpcErr RPCError
+		if !errors.As(lockErr, &rpcErr) || rpcErr.Tag != ErrLockDenied {
+			return 0, lockErr
+		}
+
+		var err error
+		holder, err = s.monitoringLockHolder(ctx, target)
+		if err != nil {
+			return 0, fmt.Errorf("cannot find the lock holder: %w", err)
+		}
+	}
+
+	switch {
+	case holder == 0:
+		return 0, fmt.Errorf("netconf: %s datastore is locked by a non-NETCONF entity", target)
+	case uint64(holder) == s.sessionID:
+		return 0, fmt.Errorf("netconf: %s datastore is locked by this session (%d)", target, holder)
+	}
+
+	if err := s.KillSession(ctx, holder); err != nil {
+		return 0, err
+	}
+	return holder, nil
 }
 
 type ValidateReq struct {
 	XMLName xml.Name `xml:"validate"`
 	Source  any      `xml:"source"`
+
+	progress         func()
+	progressInterval time.Duration
+}
+
+// ValidateOption is a optional arguments to [Session.Validate] method
+type ValidateOption interface {
+	apply(*ValidateReq)
+}
+
+// defaultValidateProgressInterval is how often the progress function of
+// [WithValidateProgress] is called by default.
+const defaultValidateProgressInterval = 5 * time.Second
+
+type validateProgress func()
+type validateProgressInterval time.Duration
+
+func (o validateProgress) apply(req *ValidateReq) { req.progress = o }
+func (o validateProgressInterval) apply(req *ValidateReq) {
+	req.progressInterval = time.Duration(o)
+}
+
+// WithValidateProgress calls `fn` periodically (every 5 seconds unless changed
+// with [WithValidateProgressInterval]) for as long as the validation is in
+// progress.  This allows to log or otherwise report slow validations without
+// canceling them.  `fn` is never called after [Session.Validate] returned.
+func WithValidateProgress(fn func()) ValidateOption { return validateProgress(fn) }
+
+// WithValidateProgressInterval sets how often the function given with
+// [WithValidateProgress] is called.
+func WithValidateProgressInterval(d time.Duration) ValidateOption {
+	return validateProgressInterval(d)
 }
 
-func (s *Session) Validate(ctx context.Context, source any) error {
+// Validate issues the `<validate>` operation as defined in [RFC6241 8.6] to
+// validate the contents of the `source` datastore.  Like
+// [Session.CopyConfig] the source can be a datastore, a [URL] or a config.
+//
+// This requires the device to support the `:validate` capability.  A inline
+// config can be validated with either `:validate:1.0` or `:validate:1.1` (i.e
+// on devices without `:candidate`) and a [URL] requires the `:url`
+// capability as well.  Otherwise an error wrapping [ErrCapabilityMissing] is
+// returned.
+//
+// [RFC6241 8.6]: https://www.rfc-editor.org/rfc/rfc6241.html#section-8.6
+func (s *Session) Validate(ctx context.Context, source any, opts ...ValidateOption) error {
+	src, err := configSource(source)
+	if err != nil {
+		return err
+	}
+
+	switch src := src.(type) {
+	case Datastore:
+		err = s.requireCapability("validate", ":validate")
+	case URL:
+		err = s.requireCapability("validate", ":validate")
+		if err == nil {
+			err = s.checkURL(src)
+		}
+	default:
+		err = s.requireCapability("validate of a config", ":validate:1.1", ":validate:1.0")
+	}
+	if err != nil {
+		return err
+	}
+
 	req := ValidateReq{
-		Source: source,
+		Source:           src,
+		progressInterval: defaultValidateProgressInterval,
+	}
+	for _, opt := range opts {
+		opt.apply(&req)
 	}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	if req.progress != nil && req.progressInterval > 0 {
+		stop := tick(req.progressInterval, req.progress)
+		defer stop()
+	}
+
+	return s.callOK(withStreamedConfig(ctx, src), &req)
+}
+
+// tick calls `fn` every `interval` until the returned function is called.
+// Once stop returns `fn` is guaranteed to not be called anymore.
+func tick(interval time.Duration, fn func()) (stop func()) {
+	var (
+		done   = make(chan struct{})
+		exited = make(chan struct{})
+	)
+
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+	}
 }
 
 type CommitReq struct {
@@ -445,6 +1887,95 @@ type CommitReq struct {
 	ConfirmTimeout int64      `xml:"confirm-timeout,omitempty"`
 	Persist        string     `xml:"persist,omitempty"`
 	PersistID      string     `xml:"persist-id,omitempty"`
+	// comment, label and extension are set by the options and turned into
+	// annotations once the extension to use is known.
+	comment   string
+	label     string
+	extension *CommitExtension
+	// annotations are the vendor specific elements set with
+	// [WithCommitComment] and [WithCommitLabel].
+	annotations []commitAnnotation
+}
+
+// MarshalXML rejects a confirm-timeout without confirmed which is not a valid
+// `<commit>` ([RFC6241 8.4.5.1]).
+//
+// [RFC6241 8.4.5.1]: https://www.rfc-editor.org/rfc/rfc6241.html#section-8.4.5.1
+func (r *CommitReq) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if r.ConfirmTimeout != 0 && !r.Confirmed {
+		return errors.New("confirm-timeout can only be used with confirmed")
+	}
+	if r.ConfirmTimeout < 0 {
+		return fmt.Errorf("confirm-timeout %d cannot be negative", r.ConfirmTimeout)
+	}
+
+	// alias the type to not cause recursion calling e.Encode
+	type commitReq CommitReq
+	return e.Encode(struct {
+		*commitReq
+		Annotations []commitAnnotation
+	}{(*commitReq)(r), r.annotations})
+}
+
+// maxConfirmTimeout is the largest confirm-timeout (an uint32 of seconds).
+const maxConfirmTimeout = time.Duration(math.MaxUint32) * time.Second
+
+// validateConfirmTimeout checks a timeout set with [WithConfirmedTimeout].  The
+// timeout is sent in seconds so anything below one second would be sent as 0
+// (or dropped) instead of what was asked for.
+func validateConfirmTimeout(timeout time.Duration) error {
+	if timeout < time.Second || timeout > maxConfirmTimeout {
+		return fmt.Errorf("confirm timeout %s must be between 1s and %s", timeout, maxConfirmTimeout)
+	}
+	return nil
+}
+
+// CommitExtension describes the vendor specific elements a device accepts in
+// `<commit>` to annotate the commit with a comment or label for audit trails
+// (see [WithCommitComment] and [WithCommitLabel]).
+type CommitExtension struct {
+	// Capability is the capability the device must advertise for the
+	// extension to be used.  If empty the extension is always used.
+	Capability string
+	// Namespace is the namespace of the elements declared with Prefix.
+	Namespace string
+	Prefix    string
+	// Comment and Label are the names of the elements for the comment and the
+	// label.  An empty name means the device doesn't support it.
+	Comment string
+	Label   string
+}
+
+// JunosCommitExtension is the `<junos:comment>` element of Juniper devices
+// which advertise the `http://xml.juniper.net/netconf/junos/1.0` capability.
+// The comment is shown in the commit history (`show system commit`).
+var JunosCommitExtension = CommitExtension{
+	Capability: "http://xml.juniper.net/netconf/junos/1.0",
+	Namespace:  "http://xml.juniper.net/junos/*/junos",
+	Prefix:     "junos",
+	Comment:    "comment",
+}
+
+// commitExtensions are the extensions used if the device advertises their
+// capability and no extension was given with [WithCommitExtension].
+var commitExtensions = []*CommitExtension{&JunosCommitExtension}
+
+// commitAnnotation is a vendor specific element of a `<commit>`.
+type commitAnnotation struct {
+	ext   *CommitExtension
+	name  string
+	value string
+}
+
+func (a commitAnnotation) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: a.name}}
+	if a.ext.Prefix != "" {
+		start.Name.Local = a.ext.Prefix + ":" + a.name
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "xmlns:" + a.ext.Prefix}, Value: a.ext.Namespace}}
+	} else if a.ext.Namespace != "" {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: a.ext.Namespace}}
+	}
+	return e.EncodeElement(a.value, start)
 }
 
 // CommitOption is a optional arguments to [Session.Commit] method
@@ -470,6 +2001,17 @@ func (o persist) apply(req *CommitReq) {
 }
 func (o persistID) apply(req *CommitReq) { req.PersistID = string(o) }
 
+type commitComment string
+type commitLabel string
+type commitExtensionOpt CommitExtension
+
+func (o commitComment) apply(req *CommitReq) { req.comment = string(o) }
+func (o commitLabel) apply(req *CommitReq)   { req.label = string(o) }
+func (o commitExtensionOpt) apply(req *CommitReq) {
+	ext := CommitExtension(o)
+	req.extension = &ext
+}
+
 // RollbackOnError will restore the configuration back to before the
 // `<edit-config>` operation took place.  This requires the device to
 // support the `:rollback-on-error` capability.
@@ -483,7 +2025,8 @@ func (o persistID) apply(req *CommitReq) { req.PersistID = string(o) }
 func WithConfirmed() CommitOption { return confirmed(true) }
 
 // WithConfirmedTimeout is like `WithConfirmed` but using the given timeout
-// duration instead of the device's default.
+// duration instead of the device's default.  The timeout is sent in whole
+// seconds (fractions are truncated) and must be at least one second.
 func WithConfirmedTimeout(timeout time.Duration) CommitOption { return confirmedTimeout{timeout} }
 
 // WithPersist allows you to set a identifier to confirm a commit in another
@@ -497,20 +2040,189 @@ func WithPersist(id string) CommitOption { return persist(id) }
 // sesssion.
 func WithPersistID(id string) persistID { return persistID(id) }
 
+// WithCommitComment annotates the commit with a comment for audit trails using
+// the vendor specific element of the device.  The element is taken from the
+// extension given with [WithCommitExtension] or else from the known extensions
+// (i.e [JunosCommitExtension]) whose capability is advertised by the device.
+// Commit fails with an error wrapping [ErrCapabilityMissing] if the device
+// has no known extension supporting comments.
+func WithCommitComment(comment string) CommitOption { return commitComment(comment) }
+
+// WithCommitLabel is like [WithCommitComment] but annotates the commit with a
+// label (i.e to roll back to it by name) if the extension supports it.
+func WithCommitLabel(label string) CommitOption { return commitLabel(label) }
+
+// WithCommitExtension sets the vendor specific elements used for
+// [WithCommitComment] and [WithCommitLabel] instead of detecting them from the
+// capabilities of the device.
+func WithCommitExtension(ext CommitExtension) CommitOption { return commitExtensionOpt(ext) }
+
+// buildAnnotations turns the comment and label set with the options into the
+// elements of the commit extension of the device.
+func (s *Session) buildAnnotations(req *CommitReq) error {
+	if req.comment == "" && req.label == "" {
+		return nil
+	}
+
+	ext := req.extension
+	if ext == nil {
+		for _, known := range commitExtensions {
+			if _, ok := s.serverCaps.Lookup(known.Capability); ok {
+				ext = known
+				break
+			}
+		}
+		if ext == nil {
+			return fmt.Errorf("%w: commit comments and labels require a vendor extension (see WithCommitExtension)", ErrCapabilityMissing)
+		}
+	} else if ext.Capability != "" {
+		if err := s.requireCapability("commit extension", ext.Capability); err != nil {
+			return err
+		}
+	}
+
+	if req.comment != "" {
+		if ext.Comment == "" {
+			return fmt.Errorf("%w: commit extension in %s doesn't support comments", ErrCapabilityMissing, ext.Namespace)
+		}
+		req.annotations = append(req.annotations, commitAnnotation{ext: ext, name: ext.Comment, value: req.comment})
+	}
+	if req.label != "" {
+		if ext.Label == "" {
+			return fmt.Errorf("%w: commit extension in %s doesn't support labels", ErrCapabilityMissing, ext.Namespace)
+		}
+		req.annotations = append(req.annotations, commitAnnotation{ext: ext, name: ext.Label, value: req.label})
+	}
+	return nil
+}
+
+// pendingCommit is a confirmed commit issued on a session that has not been
+// confirmed or canceled yet.
+type pendingCommit struct {
+	persist string
+}
+
+// pendingPersist returns the persist of the confirmed commit issued on this
+// session with [WithPersist], if any.  Follow-ups to such a commit must give the
+// id as the device would otherwise look for a confirmed commit issued on this
+// session without persist and fail.
+func (s *Session) pendingPersist() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.confirmedCommit == nil {
+		return ""
+	}
+	return s.confirmedCommit.persist
+}
+
+// validatePersistID checks a persist or persist-id value set with
+// [WithPersist] or [WithPersistID].
+func validatePersistID(id string) error {
+	if id == "" {
+		return fmt.Errorf("persist id cannot be empty")
+	}
+	if strings.TrimSpace(id) != id {
+		return fmt.Errorf("persist id %q cannot have leading or trailing whitespace", id)
+	}
+	for _, r := range id {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("persist id %q cannot contain control characters", id)
+		}
+	}
+	return nil
+}
+
 // Commit will commit a canidate config to the running comming. This requires
 // the device to support the `:canidate` capability.
+//
+// Confirmed commits ([RFC6241 8.4]) go through the following states:
+//
+//   - A commit with [WithConfirmed] or [WithConfirmedTimeout] starts a
+//     confirmed commit which is rolled back unless confirmed before the
+//     timeout.  Issuing it again on the same session extends the timeout.
+//   - A commit with [WithPersist] (which implies [WithConfirmed]) starts a
+//     persistent confirmed commit that survives the session and can be
+//     confirmed or canceled from any session knowing the id.  Issuing it again
+//     with the same id extends the timeout.
+//   - A commit without the confirmed options confirms the confirmed commit.  A
+//     persistent confirmed commit must be confirmed with [WithPersistID]
+//     matching the id given to [WithPersist].
+//   - [Session.CancelCommit] rolls back the confirmed commit, with
+//     [WithPersistID] for a persistent confirmed commit.
+//
+// [WithPersistID] confirms a commit so it cannot be combined with
+// [WithConfirmed], [WithConfirmedTimeout] or [WithPersist].  The session keeps
+// track of the confirmed commit it issued and returns an error without
+// contacting the device if a persistent confirmed commit is confirmed,
+// extended or canceled without the id.  Confirming or canceling it from
+// another session is not seen by this session.
+//
+// [RFC6241 8.4]: https://www.rfc-editor.org/rfc/rfc6241.html#section-8.4
 func (s *Session) Commit(ctx context.Context, opts ...CommitOption) error {
+	_, err := s.CommitResult(ctx, opts...)
+	return err
+}
+
+// CommitResult is the outcome of a successful commit returned by
+// [Session.CommitResult].
+type CommitResult struct {
+	// Warnings are the rpc-errors with a `warning` severity the device
+	// returned with the `<ok/>` (i.e about deprecated config), if any.
+	Warnings RPCErrors
+}
+
+// CommitResult is like [Session.Commit] but also returns the warnings the
+// device sent with a successful commit so they can be logged.  Unless
+// [WithWarningsAsErrors] is used, in which case any warning fails the commit.
+func (s *Session) CommitResult(ctx context.Context, opts ...CommitOption) (*CommitResult, error) {
 	var req CommitReq
 	for _, opt := range opts {
+		switch o := opt.(type) {
+		case persist:
+			if err := validatePersistID(string(o)); err != nil {
+				return nil, err
+			}
+		case persistID:
+			if err := validatePersistID(string(o)); err != nil {
+				return nil, err
+			}
+		case confirmedTimeout:
+			if err := validateConfirmTimeout(o.Duration); err != nil {
+				return nil, err
+			}
+		}
 		opt.apply(&req)
 	}
 
 	if req.PersistID != "" && req.Confirmed {
-		return fmt.Errorf("PersistID cannot be used with Confirmed/ConfirmedTimeout or Persist options")
+		return nil, fmt.Errorf("PersistID cannot be used with Confirmed/ConfirmedTimeout or Persist options")
+	}
+	if p := s.pendingPersist(); p != "" && req.Persist == "" && req.PersistID == "" {
+		if req.Confirmed {
+			return nil, fmt.Errorf("confirmed commit was issued with persist %q: extending it requires WithPersist", p)
+		}
+		return nil, fmt.Errorf("confirmed commit was issued with persist %q: commit requires WithPersistID", p)
+	}
+	if err := s.buildAnnotations(&req); err != nil {
+		return nil, err
 	}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	resp, err := s.callOKResp(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	// A confirmed commit starts (or extends) the confirmed commit of this
+	// session while any other commit confirms it.
+	s.mu.Lock()
+	if req.Confirmed {
+		s.confirmedCommit = &pendingCommit{persist: req.Persist}
+	} else {
+		s.confirmedCommit = nil
+	}
+	s.mu.Unlock()
+
+	return &CommitResult{Warnings: resp.Warnings}, nil
 }
 
 // CancelCommitOption is a optional arguments to [Session.CancelCommit] method
@@ -525,14 +2237,124 @@ type CancelCommitReq struct {
 	PersistID string   `xml:"persist-id,omitempty"`
 }
 
+// CancelCommit cancels a ongoing confirmed commit as defined in [RFC6241
+// 8.4.4.1].  Without options it cancels the confirmed commit issued on the
+// same session.  A confirmed commit issued with [WithPersist] (from this or
+// another session) must be canceled with a matching [WithPersistID].
+//
+// If the confirmed commit of this session was issued with [WithPersist] and no
+// persist id is given an error is returned without contacting the device.
+//
+// [RFC6241 8.4.4.1]: https://www.rfc-editor.org/rfc/rfc6241.html#section-8.4.4.1
 func (s *Session) CancelCommit(ctx context.Context, opts ...CancelCommitOption) error {
 	var req CancelCommitReq
 	for _, opt := range opts {
+		if id, ok := opt.(persistID); ok {
+			if err := validatePersistID(string(id)); err != nil {
+				return err
+			}
+		}
 		opt.applyCancelCommit(&req)
 	}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	if p := s.pendingPersist(); p != "" && req.PersistID == "" {
+		return fmt.Errorf("confirmed commit was issued with persist %q: cancel-commit requires WithPersistID", p)
+	}
+
+	if err := s.callOK(ctx, &req); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.confirmedCommit = nil
+	s.mu.Unlock()
+
+	return nil
+}
+
+// ErrNoConfirmedCommit is returned by [Session.AbortConfirmedCommit] when no
+// confirmed commit issued on the session is pending.
+var ErrNoConfirmedCommit = errors.New("netconf: no pending confirmed commit issued on this session")
+
+// AbortConfirmedCommit rolls back the pending confirmed commit issued on this
+// session.  The two kinds of confirmed commits are aborted very differently
+// ([RFC6241 8.4]):
+//
+//   - A confirmed commit issued without [WithPersist] is bound to the session
+//     that issued it and is rolled back by the device when that session is
+//     terminated.  It is aborted by closing the session with [Session.Close],
+//     so the session cannot be used afterwards.
+//   - A persistent confirmed commit issued with [WithPersist] survives the
+//     session.  It is aborted with [Session.CancelCommit] using the persist id
+//     and the session stays open.
+//
+// Only the confirmed commit issued on this session is known to it.
+// [ErrNoConfirmedCommit] is returned if there is none (i.e it was confirmed or
+// never issued).  To abort a persistent confirmed commit issued by another
+// session use [Session.CancelCommit] with [WithPersistID].
+//
+// [RFC6241 8.4]: https://www.rfc-editor.org/rfc/rfc6241.html#section-8.4
+func (s *Session) AbortConfirmedCommit(ctx context.Context) error {
+	s.mu.Lock()
+	pending := s.confirmedCommit
+	s.mu.Unlock()
+
+	if pending == nil {
+		return ErrNoConfirmedCommit
+	}
+	if pending.persist != "" {
+		return s.CancelCommit(ctx, WithPersistID(pending.persist))
+	}
+	return s.Close(ctx)
+}
+
+type DiscardChangesReq struct {
+	XMLName xml.Name `xml:"discard-changes"`
+}
+
+// DiscardChanges implements the `<discard-changes>` rpc operation defined in
+// [RFC6241 8.3.4.2] to revert the candidate configuration to the current
+// running configuration.
+//
+// [RFC6241 8.3.4.2]: https://www.rfc-editor.org/rfc/rfc6241.html#section-8.3.4.2
+func (s *Session) DiscardChanges(ctx context.Context) error {
+	return s.callOK(ctx, &DiscardChangesReq{})
+}
+
+// ReplaceRunning replaces the complete running configuration with `config`
+// (which takes the same values as [Session.EditConfig]).  How this is done
+// depends on the capabilities of the device:
+//
+//   - with `:candidate` the candidate datastore is replaced with an
+//     `<edit-config>` using the [ReplaceConfig] default operation and then
+//     committed.  If either fails the changes are discarded again with
+//     `<discard-changes>`.
+//   - otherwise with `:writable-running` the running datastore is replaced
+//     directly with an `<edit-config>`.
+//
+// An error wrapping [ErrCapabilityMissing] is returned if the device supports
+// neither.  No locks are taken, use [Session.LockCleanCandidate] or
+// [Session.LockWithContext] to keep other sessions from making changes at the
+// same time.
+func (s *Session) ReplaceRunning(ctx context.Context, config any) error {
+	if _, ok := s.serverCaps.Lookup(":candidate"); ok {
+		err := s.EditConfig(ctx, Candidate, config, WithDefaultMergeStrategy(ReplaceConfig))
+		if err == nil {
+			err = s.Commit(ctx)
+		}
+		if err != nil {
+			if discardErr := s.DiscardChanges(ctx); discardErr != nil {
+				return errors.Join(err, fmt.Errorf("failed to discard candidate changes: %w", discardErr))
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := s.requireCapability("replacing the running config", ":candidate", ":writable-running"); err != nil {
+		return err
+	}
+	return s.EditConfig(ctx, Running, config, WithDefaultMergeStrategy(ReplaceConfig))
 }
 
 // CreateSubscriptionOption is a optional arguments to [Session.CreateSubscription] method
@@ -546,12 +2368,19 @@ type CreateSubscriptionReq struct {
 	Filter    string    `xml:",innerxml"`
 	StartTime string `xml:"startTime,omitempty"`
 	EndTime   string `xml:"endTime,omitempty"`
+
+	bufferSize   int
+	backpressure BackpressurePolicy
+	eventFilter  func(xml.Name) bool
 }
 
 type stream string
 type startTime time.Time
 type endTime time.Time
 type filter string
+type replayBuffer int
+type backpressure BackpressurePolicy
+type eventFilter func(xml.Name) bool
 
 func (o stream) apply(req *CreateSubscriptionReq) {
 	req.Stream = string(o)
@@ -562,19 +2391,71 @@ func (o startTime) apply(req *CreateSubscriptionReq) {
 func (o endTime) apply(req *CreateSubscriptionReq) {
 	req.EndTime = time.Time(o).Format(time.RFC3339)
 }
-func (o filter) apply(req *CreateSubscriptionReq){
-	subtree,err:=parseXPathToXML(string(o))
-	if(err==nil){
-		str:=`<filter type="subtree">%s</filter>`
-		req.Filter=fmt.Sprintf(str,subtree)
+func (o filter) apply(req *CreateSubscriptionReq) {
+	subtree, err := parseXPathToXML(string(o), filterSpec{})
+	if err == nil {
+		str := `<filter type="subtree">%s</filter>`
+		req.Filter = fmt.Sprintf(str, subtree)
 	}
 }
 
+func (o replayBuffer) apply(req *CreateSubscriptionReq) {
+	req.bufferSize = int(o)
+}
+func (o backpressure) apply(req *CreateSubscriptionReq) {
+	req.backpressure = BackpressurePolicy(o)
+}
+func (o eventFilter) apply(req *CreateSubscriptionReq) {
+	req.eventFilter = o
+}
+
 func WithStreamOption(s string) CreateSubscriptionOption        { return stream(s) }
 func WithStartTimeOption(st time.Time) CreateSubscriptionOption { return startTime(st) }
 func WithEndTimeOption(et time.Time) CreateSubscriptionOption   { return endTime(et) }
 func WithFilterOption(xpath string) CreateSubscriptionOption	{return filter(xpath)}
 
+// WithReplayBuffer buffers up to `n` notifications between the session and the
+// [NotificationHandler] which is then called from a separate goroutine.  This
+// bounds the memory used when a large replay window (see
+// [WithStartTimeOption]) floods the session faster than the handler keeps up,
+// with the behavior on a full buffer set by [WithBackpressure].  Without it the
+// handler is called synchronously from the receive loop.
+func WithReplayBuffer(n int) CreateSubscriptionOption { return replayBuffer(n) }
+
+// WithBackpressure sets the policy applied when the buffer set with
+// [WithReplayBuffer] is full.  It defaults to [BackpressureBlock].
+func WithBackpressure(policy BackpressurePolicy) CreateSubscriptionOption {
+	return backpressure(policy)
+}
+
+// WithEventFilter only passes the notifications whose event element name
+// (i.e `<netconf-config-change>` in [NamespaceNetconfNotifications]) matches
+// `match` to the [NotificationHandler].  The others are discarded and counted
+// in [SubscriptionState.Filtered].  Unlike [WithFilterOption] the filtering is
+// done by the client which is useful when the device ignores the filter or
+// when several consumers share a subscription.  The `<notificationComplete>`
+// notification is always passed on.
+//
+// `match` is called from the receive loop of the session so it must be quick.
+func WithEventFilter(match func(name xml.Name) bool) CreateSubscriptionOption {
+	return eventFilter(match)
+}
+
+// CreateSubscription implements the `<create-subscription>` rpc operation
+// defined in [RFC5277 2.1.1].  Notifications are delivered to the handler set
+// with [WithNotificationHandler].
+//
+// Unless the device advertises the `:interleave` capability no other rpc can be
+// issued on the session while the subscription is active and they fail with
+// [ErrNotInterleaved] (except for closing the session).  The subscription ends
+// with the `<notificationComplete>` notification once the stop time is
+// reached.  The health of the subscription can be monitored with
+// [Session.SubscriptionState] and [Session.SubscriptionStates].
+//
+// The buffer set with [WithReplayBuffer] is created by the first subscription
+// of the session and kept for any later one.
+//
+// [RFC5277 2.1.1]: https://www.rfc-editor.org/rfc/rfc5277.html#section-2.1.1
 func (s *Session) CreateSubscription(ctx context.Context, opts ...CreateSubscriptionOption) error {
 	var req CreateSubscriptionReq
 	for _, opt := range opts {
@@ -582,6 +2463,26 @@ func (s *Session) CreateSubscription(ctx context.Context, opts ...CreateSubscrip
 	}
 	// TODO: eventual custom notifications rpc logic, e.g. create subscription only if notification capability is present
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	// the buffer must be in place before the reply as the notifications may
+	// follow it immediately.
+	s.mu.Lock()
+	if req.bufferSize > 0 && s.notifQueue == nil && s.notificationHandler != nil {
+		s.notifQueue = newNotifQueue(req.bufferSize, req.backpressure, s.notificationHandler)
+	}
+	prevFilter := s.eventFilter
+	s.eventFilter = req.eventFilter
+	s.mu.Unlock()
+
+	if err := s.callOK(ctx, &req); err != nil {
+		// keep filtering any subscription already active.
+		s.mu.Lock()
+		s.eventFilter = prevFilter
+		s.mu.Unlock()
+		return err
+	}
+
+	s.mu.Lock()
+	s.setSubscription(SubscriptionState{Status: SubscriptionActive})
+	s.mu.Unlock()
+	return nil
 }
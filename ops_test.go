@@ -3,8 +3,13 @@ package netconf
 import (
 	"context"
 	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -35,6 +40,71 @@ func TestUnmarshalOk(t *testing.T) {
 	}
 }
 
+// encodeElementBool is ExtantBool encoded by marshaling an empty struct, to
+// check and benchmark the fast path of ExtantBool.MarshalXML against.
+type encodeElementBool bool
+
+func (b encodeElementBool) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if !b {
+		return nil
+	}
+	return e.EncodeElement(struct{}{}, start)
+}
+
+func TestMarshalExtantBool(t *testing.T) {
+	type msg struct {
+		XMLName xml.Name   `xml:"commit"`
+		Confirm ExtantBool `xml:"confirmed"`
+		Persist ExtantBool `xml:"urn:example persist"`
+		Unset   ExtantBool `xml:"unset"`
+		Value   string     `xml:"value"`
+	}
+	type ref struct {
+		XMLName xml.Name          `xml:"commit"`
+		Confirm encodeElementBool `xml:"confirmed"`
+		Persist encodeElementBool `xml:"urn:example persist"`
+		Unset   encodeElementBool `xml:"unset"`
+		Value   string            `xml:"value"`
+	}
+
+	for _, indent := range []string{"", "  "} {
+		var got, want strings.Builder
+		enc := xml.NewEncoder(&got)
+		enc.Indent("", indent)
+		assert.NoError(t, enc.Encode(&msg{Confirm: true, Persist: true, Value: "x"}))
+		enc = xml.NewEncoder(&want)
+		enc.Indent("", indent)
+		assert.NoError(t, enc.Encode(&ref{Confirm: true, Persist: true, Value: "x"}))
+
+		assert.Equal(t, want.String(), got.String())
+	}
+
+	out, err := xml.Marshal(&msg{Confirm: true, Persist: true, Value: "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, `<commit><confirmed></confirmed><persist xmlns="urn:example"></persist><value>x</value></commit>`, string(out))
+	assert.Equal(t, `<commit><confirmed/><persist xmlns="urn:example"/><value>x</value></commit>`, selfClose(out, len(out)))
+}
+
+func benchmarkMarshalBool[T ~bool](b *testing.B) {
+	v := struct {
+		XMLName xml.Name `xml:"commit"`
+		Confirm T        `xml:"confirmed"`
+	}{Confirm: true}
+	enc := xml.NewEncoder(io.Discard)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := enc.Encode(&v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalExtantBool(b *testing.B) {
+	b.Run("tokens", benchmarkMarshalBool[ExtantBool])
+	b.Run("encodeElement", benchmarkMarshalBool[encodeElementBool])
+}
+
 func TestMarshalDatastore(t *testing.T) {
 	tt := []struct {
 		input     Datastore
@@ -82,6 +152,460 @@ func TestGetConfig(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestGetConfigSource(t *testing.T) {
+	sess := newSession(newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+		panic("unexpected request")
+	}))
+
+	for _, source := range []Datastore{
+		"",
+		Datastore(URL("ftp://myserver.example.com/router.cfg")),
+		"<running/>",
+		"running candidate",
+	} {
+		_, err := sess.GetConfig(context.Background(), source)
+		assert.Errorf(t, err, "source %q", source)
+	}
+
+	_, err := sess.GetConfig(context.Background(), Datastore(URL("file:///backup.xml")))
+	assert.ErrorContains(t, err, "CopyConfig")
+}
+
+func TestGetConfigFilter(t *testing.T) {
+	tt := []struct {
+		name    string
+		options []RPCOption
+		matches []*regexp.Regexp
+	}{
+		{
+			name:    "xpath",
+			options: []RPCOption{WithFilter("/interfaces/interface")},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<filter type="subtree"><interfaces><interface/></interfaces></filter>`),
+			},
+		},
+		{
+			name: "namespace",
+			options: []RPCOption{
+				WithFilterNamespace("urn:ietf:params:xml:ns:yang:ietf-interfaces"),
+				WithFilter("/interfaces/interface[name='eth0']"),
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<filter type="subtree"><interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><interface><name>eth0</name></interface></interfaces></filter>`),
+			},
+		},
+		{
+			name: "selectLeaves",
+			options: []RPCOption{
+				WithFilter("/interfaces/interface[name='eth0']"),
+				WithSelectLeaves("name", "enabled", "mtu"),
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<filter type="subtree"><interfaces><interface><name>eth0</name><enabled/><mtu/></interface></interfaces></filter>`),
+			},
+		},
+		{
+			name:    "noType",
+			options: []RPCOption{WithFilter("/interfaces/interface"), WithFilterNoType()},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<get-config><source><running/></source><filter><interfaces><interface/></interfaces></filter></get-config>`),
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			go sess.recv()
+
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`)
+
+			_, err := sess.GetConfig(context.Background(), Running, tc.options...)
+			assert.NoError(t, err)
+
+			sentMsg, err := ts.popReq()
+			assert.NoError(t, err)
+
+			for _, match := range tc.matches {
+				assert.Regexp(t, match, string(sentMsg))
+			}
+		})
+	}
+}
+
+func TestGetConfigFilters(t *testing.T) {
+	tt := []struct {
+		name    string
+		options []RPCOption
+		want    string
+	}{
+		{
+			name: "prefixes",
+			options: []RPCOption{
+				WithFilterPrefix("if", "urn:ietf:params:xml:ns:yang:ietf-interfaces"),
+				WithFilterPrefix("rt", "urn:ietf:params:xml:ns:yang:ietf-routing"),
+				WithFilters("/if:interfaces", "/rt:routing"),
+			},
+			want: `<filter type="subtree">` +
+				`<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"/>` +
+				`<routing xmlns="urn:ietf:params:xml:ns:yang:ietf-routing"/>` +
+				`</filter>`,
+		},
+		{
+			name: "merged",
+			options: []RPCOption{
+				WithFilterNamespace("urn:ietf:params:xml:ns:yang:ietf-interfaces"),
+				WithFilters("/interfaces/interface[name='eth0']", "/interfaces/interface[name='eth1']"),
+				WithSelectLeaves("mtu"),
+			},
+			want: `<filter type="subtree"><interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">` +
+				`<interface><name>eth0</name><mtu/></interface>` +
+				`<interface><name>eth1</name><mtu/></interface>` +
+				`</interfaces></filter>`,
+		},
+		{
+			name: "contentMatch",
+			options: []RPCOption{
+				WithFilter("/interfaces/interface"),
+				WithContentMatch("enabled", "true"),
+			},
+			want: `<filter type="subtree"><interfaces><interface><enabled>true</enabled></interface></interfaces></filter>`,
+		},
+		{
+			name: "contentMatchWithKey",
+			options: []RPCOption{
+				WithFilters("/interfaces/interface[name='eth0']", "/interfaces/interface[name='eth1']"),
+				WithContentMatch("type", "ianaift:ethernetCsmacd"),
+				WithContentMatch("type", "ianaift:ethernetCsmacd"),
+				WithSelectLeaves("type", "mtu"),
+			},
+			want: `<filter type="subtree"><interfaces>` +
+				`<interface><name>eth0</name><type>ianaift:ethernetCsmacd</type><mtu/></interface>` +
+				`<interface><name>eth1</name><type>ianaift:ethernetCsmacd</type><mtu/></interface>` +
+				`</interfaces></filter>`,
+		},
+		{
+			name: "contentMatchNested",
+			options: []RPCOption{
+				WithFilter("/interfaces/interface[name='eth0']/ipv4/address"),
+				WithContentMatch("prefix-length", "24"),
+			},
+			want: `<filter type="subtree"><interfaces><interface><name>eth0</name>` +
+				`<ipv4><address><prefix-length>24</prefix-length></address></ipv4>` +
+				`</interface></interfaces></filter>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			go sess.recv()
+
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`)
+
+			_, err := sess.GetConfig(context.Background(), Running, tc.options...)
+			assert.NoError(t, err)
+
+			sent, err := ts.popReqString()
+			assert.NoError(t, err)
+			assert.Contains(t, sent, tc.want)
+		})
+	}
+}
+
+func TestGetConfigBatch(t *testing.T) {
+	var (
+		inflight, maxInflight atomic.Int32
+		msgIDRe               = regexp.MustCompile(`message-id="(\d+)"`)
+		nameRe                = regexp.MustCompile(`<name>(eth\d+)</name>`)
+	)
+	tr := newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+		in, _ := io.ReadAll(r)
+		n := inflight.Add(1)
+		for {
+			m := maxInflight.Load()
+			if n <= m || maxInflight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		inflight.Add(-1)
+
+		msgID := msgIDRe.FindSubmatch(in)[1]
+		name := nameRe.FindSubmatch(in)[1]
+		if string(name) == "eth3" {
+			fmt.Fprintf(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s">`+
+				`<rpc-error><error-type>application</error-type><error-tag>invalid-value</error-tag><error-severity>error</error-severity></rpc-error>`+
+				`</rpc-reply>`, msgID)
+		} else {
+			fmt.Fprintf(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s"><data><interface><name>%s</name></interface></data></rpc-reply>`, msgID, name)
+		}
+		w.Close()
+	})
+	sess := newSession(tr)
+	go sess.recv()
+
+	var reqs []GetConfigReq
+	for i := 0; i < 10; i++ {
+		reqs = append(reqs, GetConfigReq{
+			Source: Running,
+			Filter: fmt.Sprintf(`<filter type="subtree"><interfaces><interface><name>eth%d</name></interface></interfaces></filter>`, i),
+		})
+	}
+
+	configs, err := sess.GetConfigBatch(context.Background(), reqs, 3)
+	var errs BatchErrors
+	if !assert.ErrorAs(t, err, &errs) {
+		return
+	}
+	assert.Len(t, errs, len(reqs))
+	assert.Len(t, configs, len(reqs))
+	for i := range reqs {
+		if i == 3 {
+			assert.Nil(t, configs[i])
+			assert.ErrorContains(t, errs[i], "invalid-value")
+			continue
+		}
+		assert.NoError(t, errs[i])
+		assert.Equal(t, fmt.Sprintf("<interface><name>eth%d</name></interface>", i), string(configs[i]))
+	}
+	assert.LessOrEqual(t, maxInflight.Load(), int32(3))
+	assert.Greater(t, maxInflight.Load(), int32(1))
+}
+
+func TestGetConfigFiltersUnknownPrefix(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	_, err := sess.GetConfig(context.Background(), Running, WithFilters("/if:interfaces", "/routing"))
+	assert.ErrorContains(t, err, `unknown namespace prefix "if"`)
+}
+
+func TestGetConfigInvalidFilter(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	_, err := sess.GetConfig(context.Background(), Running, WithFilter("interfaces"))
+	assert.Error(t, err)
+}
+
+func TestGet(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data><interfaces><interface><name>eth0</name><oper-status>up</oper-status></interface></interfaces></data></rpc-reply>`)
+
+	got, err := sess.Get(context.Background(), WithFilter("/interfaces/interface[name='eth0']"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("<interfaces><interface><name>eth0</name><oper-status>up</oper-status></interface></interfaces>"), got)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<get><filter type="subtree"><interfaces><interface><name>eth0</name></interface></interfaces></filter></get>`)
+}
+
+func TestGetConfigWithMeta(t *testing.T) {
+	tt := []struct {
+		name  string
+		reply string
+		want  ConfigMeta
+	}{
+		{
+			name:  "data",
+			reply: `<data xmlns:txid="urn:ietf:params:xml:ns:netconf:txid:1.0" txid:etag="nc5152" txid:last-modified="2023-06-07T18:31:48Z"><system/></data>`,
+			want:  ConfigMeta{ETag: "nc5152", LastModified: time.Date(2023, time.June, 7, 18, 31, 48, 0, time.UTC)},
+		},
+		{
+			name:  "topLevel",
+			reply: `<data><interfaces/><system etag="nc42"/></data>`,
+			want:  ConfigMeta{ETag: "nc42"},
+		},
+		{
+			name:  "missing",
+			reply: `<data><system/></data>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			go sess.recv()
+
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` + tc.reply + `</rpc-reply>`)
+
+			_, meta, err := sess.GetConfigWithMeta(context.Background(), Running)
+			assert.NoError(t, err)
+			assert.True(t, tc.want.LastModified.Equal(meta.LastModified))
+			assert.Equal(t, tc.want.ETag, meta.ETag)
+		})
+	}
+}
+
+func TestGetConfigWithMetaInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data last-modified="yesterday"/></rpc-reply>`)
+
+	_, _, err := sess.GetConfigWithMeta(context.Background(), Running)
+	assert.ErrorContains(t, err, "last-modified")
+}
+
+func TestWithDefaults(t *testing.T) {
+	const withDefaultsCap = "urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit&also-supported=report-all,trim"
+
+	t.Run("get", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(withDefaultsCap)
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`)
+
+		_, err := sess.Get(context.Background(),
+			WithFilter("/interfaces-state"),
+			WithDefaults(DefaultsReportAll))
+		assert.NoError(t, err)
+
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<get><filter type="subtree"><interfaces-state/></filter>`+
+			`<with-defaults xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-with-defaults">report-all</with-defaults></get>`)
+	})
+
+	t.Run("getConfig", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(withDefaultsCap)
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`)
+
+		_, err := sess.GetConfig(context.Background(), Running,
+			WithDefaults(DefaultsExplicit),
+			WithFilter("/system"))
+		assert.NoError(t, err)
+
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<get-config><source><running/></source><filter type="subtree"><system/></filter>`+
+			`<with-defaults xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-with-defaults">explicit</with-defaults></get-config>`)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		_, err := sess.Get(context.Background(), WithDefaults(DefaultsTrim))
+		assert.ErrorIs(t, err, ErrCapabilityMissing)
+
+		sess.serverCaps = newCapabilitySet(withDefaultsCap)
+		_, err = sess.GetConfig(context.Background(), Running, WithDefaults(DefaultsReportAllTagged))
+		assert.ErrorIs(t, err, ErrCapabilityMissing)
+	})
+}
+
+func TestGetConfigEmptyData(t *testing.T) {
+	tt := []struct {
+		name    string
+		body    string
+		want    []byte
+		wantErr error
+	}{
+		{name: "selfClosing", body: "<data/>", want: []byte{}},
+		{name: "empty", body: "<data></data>", want: []byte{}},
+		{name: "missing", body: "", wantErr: ErrMissingData},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			go sess.recv()
+
+			reply := `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d">` + tc.body + `</rpc-reply>`
+			ts.queueRespString(fmt.Sprintf(reply, 1))
+			ts.queueRespString(fmt.Sprintf(reply, 2))
+
+			got, err := sess.GetConfig(context.Background(), Running)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+
+			got, err = sess.Get(context.Background())
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGetConfigAs(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data><system><host-name>darkstar</host-name></system></data></rpc-reply>`)
+
+	got, err := GetConfigAs[structuredCfgSystem](context.Background(), sess, Running)
+	assert.NoError(t, err)
+	assert.Equal(t, structuredCfgSystem{Hostname: "darkstar"}, got)
+
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><data>
+	</data></rpc-reply>`)
+
+	got, err = GetConfigAs[structuredCfgSystem](context.Background(), sess, Running)
+	assert.ErrorIs(t, err, ErrNoData)
+	assert.Equal(t, structuredCfgSystem{}, got)
+
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+}
+
+func TestSnapshot(t *testing.T) {
+	// reply to each get-config based on the requested source.  Requests are
+	// sent concurrently so the replies have to echo the message-id.
+	tr := newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+		var req struct {
+			MessageID string `xml:"message-id,attr"`
+			Source    struct {
+				Inner string `xml:",innerxml"`
+			} `xml:"get-config>source"`
+		}
+		if err := xml.NewDecoder(r).Decode(&req); err != nil {
+			panic(err)
+		}
+
+		body := "<data>" + req.Source.Inner + "</data>"
+		if req.Source.Inner == "<startup/>" {
+			body = "<rpc-error><error-type>application</error-type><error-tag>operation-not-supported</error-tag><error-severity>error</error-severity></rpc-error>"
+		}
+		fmt.Fprintf(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s">%s</rpc-reply>`, req.MessageID, body)
+		w.Close()
+	})
+	sess := newSession(tr)
+	go sess.recv()
+
+	got, err := sess.Snapshot(context.Background(), Running, Candidate, Startup)
+	var rpcErr RPCError
+	assert.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, ErrOperationNotSupported, rpcErr.Tag)
+
+	assert.Equal(t, map[Datastore][]byte{
+		Running:   []byte("<running/>"),
+		Candidate: []byte("<candidate/>"),
+	}, got)
+}
+
 type structuredCfg struct {
 	System structuredCfgSystem `xml:"system"`
 }
@@ -90,6 +614,11 @@ type structuredCfgSystem struct {
 	Hostname string `xml:"host-name"`
 }
 
+type namedCfgSystem struct {
+	XMLName  xml.Name `xml:"urn:example:other system"`
+	Hostname string   `xml:"host-name"`
+}
+
 const intfaceConfig = `
 <interfaces>
   <interface>
@@ -128,7 +657,7 @@ func TestEditConfig(t *testing.T) {
 			mustMatch: []*regexp.Regexp{
 				regexp.MustCompile(`<target>\S*<running/>\S*</target>`),
 				regexp.MustCompile(
-					`<config>\S*<system>\S*<host-name>darkstar</host-name>\S*</system>\S*</config>`,
+					`<config xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0">\S*<system>\S*<host-name>darkstar</host-name>\S*</system>\S*</config>`,
 				),
 			},
 			noMatch: []*regexp.Regexp{
@@ -163,6 +692,32 @@ func TestEditConfig(t *testing.T) {
 				regexp.MustCompile(`<system><services><ssh/></services></system>`),
 			},
 		},
+		{
+			name:   "reader config",
+			target: Running,
+			config: strings.NewReader("<system><services><ssh/></services></system>"),
+			mustMatch: []*regexp.Regexp{
+				regexp.MustCompile(`<config><system><services><ssh/></services></system></config>`),
+			},
+		},
+		{
+			name:   "namespaced config",
+			target: Running,
+			config: Config("urn:example:system", &structuredCfgSystem{Hostname: "darkstar"}),
+			mustMatch: []*regexp.Regexp{
+				regexp.MustCompile(
+					`<config xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0"><structuredCfgSystem xmlns="urn:example:system"><host-name>darkstar</host-name></structuredCfgSystem></config>`,
+				),
+			},
+		},
+		{
+			name:   "namespaced config with XMLName",
+			target: Running,
+			config: Config("urn:example:system", &namedCfgSystem{Hostname: "darkstar"}),
+			mustMatch: []*regexp.Regexp{
+				regexp.MustCompile(`<system xmlns="urn:example:system"><host-name>darkstar</host-name></system>`),
+			},
+		},
 		{
 			name:   "startup url no options",
 			target: Startup,
@@ -180,6 +735,7 @@ func TestEditConfig(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ts := newTestServer(t)
 			sess := newSession(ts.transport())
+			sess.serverCaps = newCapabilitySet(":candidate", ":writable-running")
 			go sess.recv()
 
 			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
@@ -203,6 +759,220 @@ func TestEditConfig(t *testing.T) {
 
 // TODO: TestEditConfigError()
 
+type errReader struct {
+	data []byte
+	err  error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestEditConfigReaderError(t *testing.T) {
+	sess := newSession(newReplyTransport())
+	sess.serverCaps = newCapabilitySet(":writable-running")
+	go sess.recv()
+
+	readErr := errors.New("disk on fire")
+	config := &errReader{data: []byte("<system>"), err: readErr}
+
+	err := sess.EditConfig(context.Background(), Running, config)
+	assert.ErrorIs(t, err, readErr)
+
+	// the truncated message is never finished so the session is closed.
+	select {
+	case <-sess.done:
+	case <-time.After(time.Second):
+		t.Fatal("session not closed after a partial write")
+	}
+}
+
+func TestEditConfigStreamUnlocked(t *testing.T) {
+	tr := newReplyTransport()
+	sess := newSession(tr)
+	sess.serverCaps = newCapabilitySet(":writable-running")
+	go sess.recv()
+
+	pr, pw := io.Pipe()
+	errs := make(chan error, 1)
+	go func() { errs <- sess.EditConfig(context.Background(), Running, pr) }()
+
+	// the session isn't locked while waiting for the config.
+	_, err := pw.Write([]byte("<system>"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, sess.InFlight())
+
+	_, err = pw.Write([]byte("</system>"))
+	assert.NoError(t, err)
+	pw.Close()
+
+	msg := <-tr.msgs
+	assert.Contains(t, msg, `<config><system></system></config>`)
+	tr.replies <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`
+	assert.NoError(t, <-errs)
+}
+
+func TestEditConfigTarget(t *testing.T) {
+	tt := []struct {
+		name    string
+		caps    []string
+		target  Datastore
+		wantMsg string
+	}{
+		{"runningCandidateOnly", []string{":candidate"}, Running, "edit the candidate and commit it"},
+		{"runningNoCaps", nil, Running, "requires :writable-running"},
+		{"candidateWritableRunningOnly", []string{":writable-running"}, Candidate, "edit running directly"},
+		{"candidateNoCaps", nil, Candidate, "requires :candidate"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sess := newSession(newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+				panic("unexpected request")
+			}))
+			sess.serverCaps = newCapabilitySet(tc.caps...)
+
+			err := sess.EditConfig(context.Background(), tc.target, "<system/>")
+			assert.ErrorIs(t, err, ErrCapabilityMissing)
+			assert.ErrorContains(t, err, tc.wantMsg)
+
+			_, err = sess.EditConfigResult(context.Background(), tc.target, "<system/>")
+			assert.ErrorIs(t, err, ErrCapabilityMissing)
+		})
+	}
+}
+
+func TestEditConfigResult(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(":candidate")
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">
+  <rpc-error>
+    <error-type>application</error-type>
+    <error-tag>invalid-value</error-tag>
+    <error-severity>error</error-severity>
+    <error-path>/interfaces/interface[name='eth0']/mtu</error-path>
+  </rpc-error>
+  <rpc-error>
+    <error-type>application</error-type>
+    <error-tag>unknown-element</error-tag>
+    <error-severity>error</error-severity>
+    <error-path>/interfaces/interface[name='eth1']/foo</error-path>
+  </rpc-error>
+</rpc-reply>`)
+
+	result, err := sess.EditConfigResult(context.Background(), Candidate, "<interfaces/>", WithErrorStrategy(ContinueOnError))
+	assert.NoError(t, err)
+	assert.False(t, result.OK)
+	assert.Len(t, result.Errors, 2)
+	assert.Equal(t, ErrUnknownElement, result.Errors[1].Tag)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<error-option>continue-on-error</error-option>`)
+
+	// errors fail the operation with any other error-option.
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2">
+  <rpc-error>
+    <error-type>application</error-type>
+    <error-tag>invalid-value</error-tag>
+    <error-severity>error</error-severity>
+  </rpc-error>
+</rpc-reply>`)
+
+	_, err = sess.EditConfigResult(context.Background(), Candidate, "<interfaces/>")
+	var rpcErr RPCError
+	assert.ErrorAs(t, err, &rpcErr)
+
+	// warnings are returned with the result.
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3">
+  <rpc-error>
+    <error-type>application</error-type>
+    <error-tag>operation-failed</error-tag>
+    <error-severity>warning</error-severity>
+  </rpc-error>
+  <ok/>
+</rpc-reply>`)
+
+	result, err = sess.EditConfigResult(context.Background(), Candidate, "<interfaces/>")
+	assert.NoError(t, err)
+	assert.True(t, result.OK)
+	assert.Len(t, result.Errors, 1)
+}
+
+func TestMergeStrategyAttr(t *testing.T) {
+	type iface struct {
+		Operation MergeStrategy `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 operation,attr,omitempty"`
+		Name      string        `xml:"name"`
+	}
+	type interfaces struct {
+		Interfaces []iface `xml:"interface"`
+	}
+
+	tt := []struct {
+		name   string
+		config any
+		want   string
+	}{
+		{
+			name: "struct",
+			config: struct {
+				Interfaces interfaces `xml:"interfaces"`
+			}{interfaces{[]iface{{Operation: DeleteConfig, Name: "eth0"}, {Name: "eth1"}}}},
+			want: `<config xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0"><interfaces>` +
+				`<interface nc:operation="delete"><name>eth0</name></interface>` +
+				`<interface><name>eth1</name></interface>` +
+				`</interfaces></config>`,
+		},
+		{
+			name:   "config",
+			config: Config("urn:example:interfaces", &interfaces{[]iface{{Operation: RemoveConfig, Name: "eth0"}}}),
+			want: `<config xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0"><interfaces xmlns="urn:example:interfaces">` +
+				`<interface nc:operation="remove"><name>eth0</name></interface>` +
+				`</interfaces></config>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			sess.serverCaps = newCapabilitySet(":candidate")
+			go sess.recv()
+
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+			err := sess.EditConfig(context.Background(), Candidate, tc.config)
+			assert.NoError(t, err)
+
+			sent, err := ts.popReqString()
+			assert.NoError(t, err)
+			assert.Contains(t, sent, tc.want)
+		})
+	}
+}
+
+func TestConfigUnsupported(t *testing.T) {
+	_, err := xml.Marshal(struct {
+		Config any `xml:"config"`
+	}{Config("urn:example", "<system/>")})
+	assert.Error(t, err)
+}
+
+func TestWrapConfigSourceUnsupported(t *testing.T) {
+	for _, v := range []any{nil, 42, Running, map[string]string{"foo": "bar"}, []string{"foo"}} {
+		_, _, err := wrapConfigSource(v)
+		assert.Errorf(t, err, "expected error for %T", v)
+	}
+}
+
 func TestCopyConfig(t *testing.T) {
 	tt := []struct {
 		name           string
@@ -214,8 +984,7 @@ func TestCopyConfig(t *testing.T) {
 			source: Running,
 			target: Startup,
 			matches: []*regexp.Regexp{
-				regexp.MustCompile(`<source>\S*<running/>\S*</source>`),
-				regexp.MustCompile(`<target>\S*<startup/>\S*</target>`),
+				regexp.MustCompile(`<copy-config><source><running/></source><target><startup/></target></copy-config>`),
 			},
 		},
 		{
@@ -223,8 +992,7 @@ func TestCopyConfig(t *testing.T) {
 			source: Running,
 			target: URL("ftp://myserver.example.com/router.cfg"),
 			matches: []*regexp.Regexp{
-				regexp.MustCompile(`<source>\S*<running/>\S*</source>`),
-				regexp.MustCompile(`<target>\S*<url>ftp://myserver.example.com/router.cfg</url>\S*</target>`),
+				regexp.MustCompile(`<copy-config><source><running/></source><target><url>ftp://myserver.example.com/router.cfg</url></target></copy-config>`),
 			},
 		},
 		{
@@ -232,8 +1000,15 @@ func TestCopyConfig(t *testing.T) {
 			source: URL("http://myserver.example.com/router.cfg"),
 			target: Candidate,
 			matches: []*regexp.Regexp{
-				regexp.MustCompile(`<source>\S*<url>http://myserver.example.com/router.cfg</url>\S*</source>`),
-				regexp.MustCompile(`<target>\S*<candidate/>\S*</target>`),
+				regexp.MustCompile(`<copy-config><source><url>http://myserver.example.com/router.cfg</url></source><target><candidate/></target></copy-config>`),
+			},
+		},
+		{
+			name:   "url->url",
+			source: URL("http://myserver.example.com/router.cfg"),
+			target: URL("ftp://myserver.example.com/backup.cfg"),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<copy-config><source><url>http://myserver.example.com/router.cfg</url></source><target><url>ftp://myserver.example.com/backup.cfg</url></target></copy-config>`),
 			},
 		},
 	}
@@ -242,6 +1017,7 @@ func TestCopyConfig(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ts := newTestServer(t)
 			sess := newSession(ts.transport())
+			sess.serverCaps = newCapabilitySet(":url:1.0?scheme=http,ftp")
 			go sess.recv()
 
 			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
@@ -255,32 +1031,133 @@ func TestCopyConfig(t *testing.T) {
 			for _, match := range tc.matches {
 				assert.Regexp(t, match, string(sentMsg))
 			}
+
+			// decode it back
+			var req struct {
+				Source struct {
+					Inner string `xml:",innerxml"`
+				} `xml:"copy-config>source"`
+				Target struct {
+					Inner string `xml:",innerxml"`
+				} `xml:"copy-config>target"`
+			}
+			assert.NoError(t, xml.Unmarshal(sentMsg, &req))
+			assert.Equal(t, copyConfigEndpoint(tc.source), req.Source.Inner)
+			assert.Equal(t, copyConfigEndpoint(tc.target), req.Target.Inner)
+		})
+	}
+}
+
+func copyConfigEndpoint(v any) string {
+	switch v := v.(type) {
+	case URL:
+		return "<url>" + string(v) + "</url>"
+	case Datastore:
+		return "<" + string(v) + "/>"
+	}
+	return ""
+}
+
+func TestCopyConfigWithDefaults(t *testing.T) {
+	caps := []string{
+		":url:1.0?scheme=file",
+		"urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=trim&also-supported=report-all",
+	}
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(caps...)
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	err := sess.CopyConfig(context.Background(), Running, URL("file:///backup.xml"), WithDefaults(DefaultsReportAll))
+	assert.NoError(t, err)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<copy-config><source><running/></source><target><url>file:///backup.xml</url></target>`+
+		`<with-defaults xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-with-defaults">report-all</with-defaults></copy-config>`)
+
+	// only allowed for url targets.
+	err = sess.CopyConfig(context.Background(), Running, Startup, WithDefaults(DefaultsReportAll))
+	assert.Error(t, err)
+
+	// the mode must be supported.
+	err = sess.CopyConfig(context.Background(), Running, URL("file:///backup.xml"), WithDefaults(DefaultsExplicit))
+	assert.ErrorIs(t, err, ErrCapabilityMissing)
+
+	// filters don't apply to copy-config.
+	err = sess.CopyConfig(context.Background(), Running, URL("file:///backup.xml"), WithFilter("/system"))
+	assert.Error(t, err)
+}
+
+func TestCopyConfigURLUnsupported(t *testing.T) {
+	tt := []struct {
+		name           string
+		caps           []string
+		source, target any
+	}{
+		{"noURLCapSource", []string{":candidate:1.0"}, URL("http://example.com/a.cfg"), Running},
+		{"noURLCapTarget", []string{":candidate:1.0"}, Running, URL("http://example.com/a.cfg")},
+		{"schemeSource", []string{":url:1.0?scheme=file"}, URL("sftp://example.com/a.cfg"), Running},
+		{"schemeTarget", []string{":url:1.0?scheme=file"}, Running, URL("sftp://example.com/a.cfg")},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sess := newSession(newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+				panic("unexpected request")
+			}))
+			sess.serverCaps = newCapabilitySet(tc.caps...)
+
+			err := sess.CopyConfig(context.Background(), tc.source, tc.target)
+			assert.ErrorIs(t, err, ErrCapabilityMissing)
 		})
 	}
 }
 
+// deleteConfig deletes `target` with [Session.DeleteConfig] or
+// [Session.DeleteConfigURL] for a URL.
+func deleteConfig(sess *Session, target any) error {
+	if url, ok := target.(URL); ok {
+		return sess.DeleteConfigURL(context.Background(), url)
+	}
+	return sess.DeleteConfig(context.Background(), target.(Datastore))
+}
+
 func TestDeleteConfig(t *testing.T) {
 	tt := []struct {
-		target  Datastore
+		name    string
+		target  any
 		matches []*regexp.Regexp
 	}{
 		{
+			name:   "startup",
 			target: Startup,
 			matches: []*regexp.Regexp{
 				regexp.MustCompile(`<delete-config>\S*<target>\S*<startup/>\S*</target>\S*</delete-config>`),
 			},
 		},
+		{
+			name:   "url",
+			target: URL("file://backup.cfg"),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<delete-config>\S*<target>\S*<url>file://backup.cfg</url>\S*</target>\S*</delete-config>`),
+			},
+		},
 	}
 
 	for _, tc := range tt {
-		t.Run(string(tc.target), func(t *testing.T) {
+		t.Run(tc.name, func(t *testing.T) {
 			ts := newTestServer(t)
 			sess := newSession(ts.transport())
+			sess.serverCaps = newCapabilitySet(":startup:1.0", ":url:1.0?scheme=file")
 			go sess.recv()
 
 			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
 
-			err := sess.DeleteConfig(context.Background(), tc.target)
+			err := deleteConfig(sess, tc.target)
 			assert.NoError(t, err)
 
 			sentMsg, err := ts.popReq()
@@ -293,6 +1170,118 @@ func TestDeleteConfig(t *testing.T) {
 	}
 }
 
+func TestDeleteConfigDiscardFallback(t *testing.T) {
+	// the mock rejects the unexpected delete-config with operation-not-supported.
+	m := NewMockSession(WithCapability(":candidate:1.0", ":startup:1.0"))
+	discard := m.On(&DiscardChangesReq{})
+
+	err := m.DeleteConfig(context.Background(), Candidate)
+	var rpcErr RPCError
+	if assert.ErrorAs(t, err, &rpcErr) {
+		assert.Equal(t, ErrOperationNotSupported, rpcErr.Tag)
+	}
+	assert.Equal(t, 0, m.Called(discard))
+
+	assert.NoError(t, m.DeleteConfig(context.Background(), Candidate, WithCandidateDiscardFallback()))
+	assert.Equal(t, 1, m.Called(discard))
+
+	// other targets never fall back.
+	err = m.DeleteConfig(context.Background(), Startup, WithCandidateDiscardFallback())
+	assert.Error(t, err)
+	assert.Equal(t, 1, m.Called(discard))
+}
+
+func TestDeleteConfigInvalid(t *testing.T) {
+	tt := []struct {
+		name    string
+		target  any
+		missing bool
+	}{
+		{"running", Running, false},
+		{"startupUnsupported", Startup, true},
+		{"urlUnsupported", URL("file://backup.cfg"), true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// any request sent would fail the test as the transport panics.
+			sess := newSession(newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+				panic("unexpected request")
+			}))
+			sess.serverCaps = newCapabilitySet(":candidate:1.0")
+
+			err := deleteConfig(sess, tc.target)
+			assert.Error(t, err)
+			assert.Equal(t, tc.missing, errors.Is(err, ErrCapabilityMissing))
+		})
+	}
+}
+
+func TestValidateProgress(t *testing.T) {
+	// slow device taking a while to reply
+	tr := newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+		io.Copy(io.Discard, r)
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		w.Close()
+	})
+	sess := newSession(tr)
+	sess.serverCaps = newCapabilitySet(":validate:1.1")
+	go sess.recv()
+
+	var ticks atomic.Int32
+	err := sess.Validate(context.Background(), Candidate,
+		WithValidateProgress(func() { ticks.Add(1) }),
+		WithValidateProgressInterval(5*time.Millisecond))
+	assert.NoError(t, err)
+
+	got := ticks.Load()
+	assert.Greater(t, got, int32(0))
+
+	// no more ticks after returning
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, got, ticks.Load())
+}
+
+func TestValidateCapability(t *testing.T) {
+	tt := []struct {
+		name    string
+		caps    []string
+		source  any
+		wantErr bool
+	}{
+		{"datastore validate:1.0", []string{":validate:1.0"}, Candidate, false},
+		{"datastore validate:1.1", []string{":validate:1.1"}, Candidate, false},
+		{"datastore missing", []string{":candidate:1.0"}, Candidate, true},
+		{"config validate:1.0", []string{":validate:1.0"}, "<system/>", false},
+		{"config validate:1.1", []string{":validate:1.1"}, "<system/>", false},
+		{"config missing", []string{":candidate:1.0"}, "<system/>", true},
+		{"url missing", []string{":validate:1.1"}, URL("file:///router.cfg"), true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			sess.serverCaps = newCapabilitySet(tc.caps...)
+			go sess.recv()
+
+			if tc.wantErr {
+				err := sess.Validate(context.Background(), tc.source)
+				assert.ErrorIs(t, err, ErrCapabilityMissing)
+				return
+			}
+
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+			err := sess.Validate(context.Background(), tc.source)
+			assert.NoError(t, err)
+
+			_, err = ts.popReq()
+			assert.NoError(t, err)
+		})
+	}
+}
+
 func TestValidateConfig(t *testing.T) {
 	tt := []struct {
 		name    string
@@ -306,14 +1295,43 @@ func TestValidateConfig(t *testing.T) {
 				regexp.MustCompile(`<validate>\S*<source>\S*<candidate/>\S*</source>\S*</validate>`),
 			},
 		},
-		// XXX: test []byte,string
-		// XXX: test xml object
+		{
+			name:   "string",
+			source: "<system><host-name>darkstar</host-name></system>",
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<validate>\S*<source>\S*<config>\S*<system><host-name>darkstar</host-name></system>\S*</config>\S*</source>\S*</validate>`),
+			},
+		},
+		{
+			name:   "byteslice",
+			source: []byte("<system><host-name>darkstar</host-name></system>"),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<source>\S*<config>\S*<system><host-name>darkstar</host-name></system>\S*</config>\S*</source>`),
+			},
+		},
+		{
+			name: "structured",
+			source: structuredCfg{
+				System: structuredCfgSystem{Hostname: "darkstar"},
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<source>\S*<config xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0">\S*<system>\S*<host-name>darkstar</host-name>\S*</system>\S*</config>\S*</source>`),
+			},
+		},
+		{
+			name:   "url",
+			source: URL("ftp://myserver.example.com/router.cfg"),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<source>\S*<url>ftp://myserver.example.com/router.cfg</url>\S*</source>`),
+			},
+		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			ts := newTestServer(t)
 			sess := newSession(ts.transport())
+			sess.serverCaps = newCapabilitySet(":validate:1.1", ":url:1.0")
 			go sess.recv()
 
 			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
@@ -337,34 +1355,229 @@ func TestLock(t *testing.T) {
 		matches []*regexp.Regexp
 	}{
 		{
-			target: Candidate,
-			matches: []*regexp.Regexp{
-				regexp.MustCompile(`<lock>\S*<target>\S*<candidate/>\S*</target>\S*</lock>`),
-			},
+			target: Running,
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<lock>\S*<target>\S*<running/>\S*</target>\S*</lock>`),
+			},
+		},
+		{
+			target: Candidate,
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<lock>\S*<target>\S*<candidate/>\S*</target>\S*</lock>`),
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(string(tc.target), func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			go sess.recv()
+
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+			err := sess.Lock(context.Background(), tc.target)
+			assert.NoError(t, err)
+
+			sentMsg, err := ts.popReq()
+			assert.NoError(t, err)
+
+			for _, match := range tc.matches {
+				assert.Regexp(t, match, string(sentMsg))
+			}
+		})
+	}
+}
+
+func TestNoOK(t *testing.T) {
+	tt := []struct {
+		name    string
+		reply   string
+		wantErr error
+	}{
+		{
+			name:    "empty",
+			reply:   `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"></rpc-reply>`,
+			wantErr: ErrNoOK,
+		},
+		{
+			name:    "unexpected content",
+			reply:   `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`,
+			wantErr: ErrNoOK,
+		},
+		{
+			name: "ok after warning",
+			reply: `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` +
+				`<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag><error-severity>warning</error-severity></rpc-error>` +
+				`<ok/></rpc-reply>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			go sess.recv()
+
+			ts.queueRespString(tc.reply)
+
+			err := sess.Lock(context.Background(), Candidate)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWithoutOK(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	const reply = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><lock-result>granted</lock-result></rpc-reply>`
+	ts.queueRespString(fmt.Sprintf(reply, 1))
+	ts.queueRespString(fmt.Sprintf(reply, 2))
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><rpc-error><error-type>protocol</error-type><error-tag>lock-denied</error-tag><error-severity>error</error-severity></rpc-error></rpc-reply>`)
+
+	// ok is still required by default.
+	err := sess.Lock(context.Background(), Candidate)
+	assert.ErrorIs(t, err, ErrNoOK)
+
+	var raw []byte
+	err = sess.Lock(WithoutOK(context.Background(), &raw), Candidate)
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), `<lock-result>granted</lock-result>`)
+
+	// rpc-errors still fail the operation.
+	err = sess.Lock(WithoutOK(context.Background(), nil), Candidate)
+	assert.True(t, IsLockDenied(err))
+}
+
+func TestWarningsAsErrors(t *testing.T) {
+	const (
+		warning = `<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag><error-severity>warning</error-severity><error-message>deprecated</error-message></rpc-error>`
+		fatal   = `<rpc-error><error-type>application</error-type><error-tag>invalid-value</error-tag><error-severity>error</error-severity><error-message>bad mtu</error-message></rpc-error>`
+	)
+
+	tt := []struct {
+		name             string
+		body             string
+		warningsAsErrors bool
+		wantErrs         []string
+		wantWarnings     []string
+	}{
+		{
+			name:         "warningOnly",
+			body:         warning + `<ok/>`,
+			wantWarnings: []string{"deprecated"},
+		},
+		{
+			name:     "errorOnly",
+			body:     fatal,
+			wantErrs: []string{"bad mtu"},
+		},
+		{
+			name:     "mixed",
+			body:     warning + fatal,
+			wantErrs: []string{"bad mtu"},
+		},
+		{
+			name:             "warningOnlyAsError",
+			body:             warning,
+			warningsAsErrors: true,
+			wantErrs:         []string{"deprecated"},
+		},
+		{
+			name:             "mixedAsErrors",
+			body:             warning + fatal,
+			warningsAsErrors: true,
+			wantErrs:         []string{"deprecated", "bad mtu"},
 		},
 	}
 
 	for _, tc := range tt {
-		t.Run(string(tc.target), func(t *testing.T) {
+		t.Run(tc.name, func(t *testing.T) {
 			ts := newTestServer(t)
-			sess := newSession(ts.transport())
+			sess := newSession(ts.transport(), WithWarningsAsErrors(tc.warningsAsErrors))
 			go sess.recv()
 
-			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
-
-			err := sess.Lock(context.Background(), tc.target)
-			assert.NoError(t, err)
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` + tc.body + `</rpc-reply>`)
 
-			sentMsg, err := ts.popReq()
-			assert.NoError(t, err)
+			var resp OKResp
+			err := sess.Call(context.Background(), &LockReq{Target: Candidate}, &resp)
+			if len(tc.wantErrs) == 0 {
+				assert.NoError(t, err)
+				assert.True(t, bool(resp.OK))
+			} else {
+				var gotErrs []string
+				switch err := err.(type) {
+				case RPCError:
+					gotErrs = append(gotErrs, err.Message)
+				case RPCErrors:
+					for _, e := range err {
+						gotErrs = append(gotErrs, e.Message)
+					}
+				default:
+					t.Fatalf("unexpected error %T: %v", err, err)
+				}
+				assert.Equal(t, tc.wantErrs, gotErrs)
+			}
 
-			for _, match := range tc.matches {
-				assert.Regexp(t, match, string(sentMsg))
+			var gotWarnings []string
+			for _, w := range resp.Warnings {
+				gotWarnings = append(gotWarnings, w.Message)
 			}
+			assert.Equal(t, tc.wantWarnings, gotWarnings)
 		})
 	}
 }
 
+func TestOKWithErrors(t *testing.T) {
+	t.Run("warning", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` +
+			`<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag><error-severity>warning</error-severity><error-message>partial</error-message></rpc-error>` +
+			`<ok/></rpc-reply>`)
+
+		var resp OKResp
+		err := sess.Call(context.Background(), &LockReq{Target: Candidate}, &resp)
+		assert.NoError(t, err)
+		assert.True(t, bool(resp.OK))
+		if assert.Len(t, resp.Warnings, 1) {
+			assert.Equal(t, SevWarning, resp.Warnings[0].Severity)
+			assert.Equal(t, "partial", resp.Warnings[0].Message)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` +
+			`<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag><error-severity>error</error-severity></rpc-error>` +
+			`<ok/></rpc-reply>`)
+
+		var resp OKResp
+		err := sess.Call(context.Background(), &LockReq{Target: Candidate}, &resp)
+
+		var partialErr *PartialSuccessError
+		if assert.ErrorAs(t, err, &partialErr) {
+			assert.Len(t, partialErr.Errors, 1)
+		}
+
+		var rpcErr RPCError
+		if assert.ErrorAs(t, err, &rpcErr) {
+			assert.Equal(t, ErrOperationFailed, rpcErr.Tag)
+		}
+	})
+}
+
 func TestUnlock(t *testing.T) {
 	tt := []struct {
 		target  Datastore
@@ -399,6 +1612,78 @@ func TestUnlock(t *testing.T) {
 	}
 }
 
+func TestLockReadOnly(t *testing.T) {
+	for _, target := range []Datastore{Operational, Intended} {
+		t.Run(string(target), func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			go sess.recv()
+
+			err := sess.Lock(context.Background(), target)
+			assert.ErrorContains(t, err, "RFC8342")
+			assert.Equal(t, uint64(0), sess.seq.Load())
+		})
+	}
+}
+
+func TestLockWithContext(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	release, err := sess.LockWithContext(context.Background(), Candidate)
+	assert.NoError(t, err)
+
+	sentMsg, err := ts.popReq()
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`<lock>\S*<target>\S*<candidate/>\S*</target>\S*</lock>`), string(sentMsg))
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	assert.NoError(t, release())
+
+	sentMsg, err = ts.popReq()
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`<unlock>\S*<target>\S*<candidate/>\S*</target>\S*</unlock>`), string(sentMsg))
+
+	// additional calls must not issue another unlock.
+	assert.NoError(t, release())
+	assert.NoError(t, release())
+	assert.Equal(t, uint64(2), sess.seq.Load())
+}
+
+func TestLockWithContextReleaseOptions(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="lock-1"><data>lock</data></rpc-reply>`)
+
+	var reply []byte
+	ctx := WithMessageID(context.Background(), "lock-1")
+	ctx = WithRPCAttr(ctx, xml.Attr{Name: xml.Name{Local: "user"}, Value: "admin"})
+	ctx = WithoutOK(ctx, &reply)
+	release, err := sess.LockWithContext(ctx, Candidate)
+	assert.NoError(t, err)
+
+	sentMsg, err := ts.popReq()
+	assert.NoError(t, err)
+	assert.Contains(t, string(sentMsg), `message-id="lock-1"`)
+	assert.Contains(t, string(sentMsg), `user="admin"`)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	assert.NoError(t, release())
+
+	// the unlock doesn't reuse the options of the lock call.
+	sentMsg, err = ts.popReq()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(sentMsg), `message-id="lock-1"`)
+	assert.NotContains(t, string(sentMsg), `user="admin"`)
+	assert.Contains(t, string(reply), "lock")
+}
+
 func TestKillSession(t *testing.T) {
 	tt := []struct {
 		id      uint32
@@ -496,6 +1781,150 @@ func TestCommit(t *testing.T) {
 	}
 }
 
+func TestCommitConfirmTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	// confirmed without a timeout uses the default of the device.
+	assert.NoError(t, sess.Commit(context.Background(), WithConfirmed()))
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<commit><confirmed></confirmed></commit>`)
+
+	// a timeout without confirmed is never sent.
+	err = sess.Call(context.Background(), &CommitReq{ConfirmTimeout: 60}, &OKResp{})
+	assert.ErrorContains(t, err, "confirm-timeout")
+	err = sess.Call(context.Background(), &CommitReq{Confirmed: true, ConfirmTimeout: -1}, &OKResp{})
+	assert.ErrorContains(t, err, "confirm-timeout")
+
+	for _, timeout := range []time.Duration{0, -time.Minute, 500 * time.Millisecond, maxConfirmTimeout + time.Second} {
+		err := sess.Commit(context.Background(), WithConfirmedTimeout(timeout))
+		assert.Errorf(t, err, "timeout %s", timeout)
+	}
+}
+
+func TestCommitResult(t *testing.T) {
+	const warning = `<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag><error-severity>warning</error-severity><error-message>statement has been deprecated</error-message></rpc-error>`
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` + warning + `<ok/></rpc-reply>`)
+	res, err := sess.CommitResult(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, res.Warnings, 1) {
+		assert.Equal(t, SevWarning, res.Warnings[0].Severity)
+		assert.Equal(t, "statement has been deprecated", res.Warnings[0].Message)
+	}
+	_, err = ts.popReqString()
+	assert.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	res, err = sess.CommitResult(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, res.Warnings)
+	_, err = ts.popReqString()
+	assert.NoError(t, err)
+
+	// plain commit ignores the warnings.
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3">` + warning + `<ok/></rpc-reply>`)
+	assert.NoError(t, sess.Commit(context.Background()))
+}
+
+func TestCommitSelfClosingTags(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithSelfClosingTags())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	err := sess.Commit(context.Background(), WithConfirmed())
+	assert.NoError(t, err)
+
+	sentMsg, err := ts.popReq()
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`<commit><confirmed/></commit>`), string(sentMsg))
+}
+
+func TestCommitComment(t *testing.T) {
+	const junosCap = "http://xml.juniper.net/netconf/junos/1.0"
+
+	tt := []struct {
+		name    string
+		caps    []string
+		options []CommitOption
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "junos",
+			caps:    []string{":candidate", junosCap},
+			options: []CommitOption{WithCommitComment("ticket #42 <mtu>")},
+			want:    `<commit><junos:comment xmlns:junos="http://xml.juniper.net/junos/*/junos">ticket #42 &lt;mtu&gt;</junos:comment></commit>`,
+		},
+		{
+			name:    "junosConfirmed",
+			caps:    []string{":candidate", junosCap},
+			options: []CommitOption{WithConfirmed(), WithCommitComment("ticket #42")},
+			want:    `<commit><confirmed></confirmed><junos:comment xmlns:junos="http://xml.juniper.net/junos/*/junos">ticket #42</junos:comment></commit>`,
+		},
+		{
+			name: "custom",
+			caps: []string{":candidate", "urn:example:commit-ext:1.0"},
+			options: []CommitOption{
+				WithCommitExtension(CommitExtension{
+					Capability: "urn:example:commit-ext:1.0",
+					Namespace:  "urn:example:commit-ext",
+					Comment:    "comment",
+					Label:      "label",
+				}),
+				WithCommitComment("nightly"),
+				WithCommitLabel("release-1"),
+			},
+			want: `<commit><comment xmlns="urn:example:commit-ext">nightly</comment><label xmlns="urn:example:commit-ext">release-1</label></commit>`,
+		},
+		{
+			name:    "noExtension",
+			caps:    []string{":candidate"},
+			options: []CommitOption{WithCommitComment("ticket #42")},
+			wantErr: ErrCapabilityMissing,
+		},
+		{
+			name:    "junosLabel",
+			caps:    []string{":candidate", junosCap},
+			options: []CommitOption{WithCommitLabel("release-1")},
+			wantErr: ErrCapabilityMissing,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			sess.serverCaps = newCapabilitySet(tc.caps...)
+			go sess.recv()
+
+			if tc.wantErr != nil {
+				err := sess.Commit(context.Background(), tc.options...)
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+			err := sess.Commit(context.Background(), tc.options...)
+			assert.NoError(t, err)
+
+			sent, err := ts.popReqString()
+			assert.NoError(t, err)
+			assert.Contains(t, sent, tc.want)
+		})
+	}
+}
+
 func TestCancelCommit(t *testing.T) {
 	tt := []struct {
 		name    string
@@ -538,6 +1967,182 @@ func TestCancelCommit(t *testing.T) {
 	}
 }
 
+func TestCancelCommitSameSession(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	err := sess.Commit(context.Background(), WithConfirmed())
+	assert.NoError(t, err)
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+
+	err = sess.CancelCommit(context.Background())
+	assert.NoError(t, err)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<cancel-commit></cancel-commit>`)
+}
+
+func TestCancelCommitPersist(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	err := sess.Commit(context.Background(), WithPersist("myid"))
+	assert.NoError(t, err)
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+
+	// the confirmed commit was issued with persist so a persist-id is required
+	// even on the same session.
+	err = sess.CancelCommit(context.Background())
+	assert.ErrorContains(t, err, "WithPersistID")
+
+	// cross-session cancel only needs the persist-id.
+	ts2 := newTestServer(t)
+	other := newSession(ts2.transport())
+	go other.recv()
+
+	ts2.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	err = other.CancelCommit(context.Background(), WithPersistID("myid"))
+	assert.NoError(t, err)
+
+	sent, err := ts2.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<cancel-commit><persist-id>myid</persist-id></cancel-commit>`)
+}
+
+func TestCommitPersistFlow(t *testing.T) {
+	okReply := func(id int) string {
+		return fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><ok/></rpc-reply>`, id)
+	}
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(okReply(1))
+	ts.queueRespString(okReply(2))
+
+	assert.NoError(t, sess.Commit(context.Background(), WithPersist("myid")))
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<commit><confirmed></confirmed><persist>myid</persist></commit>`)
+
+	// follow-ups on the same session need the id.
+	assert.ErrorContains(t, sess.Commit(context.Background()), "WithPersistID")
+	assert.ErrorContains(t, sess.Commit(context.Background(), WithConfirmed()), "WithPersist")
+	assert.ErrorContains(t, sess.CancelCommit(context.Background()), "WithPersistID")
+
+	// persist-id confirms so it cannot be used for a confirmed commit.
+	assert.Error(t, sess.Commit(context.Background(), WithPersistID("myid"), WithConfirmed()))
+	assert.Error(t, sess.Commit(context.Background(), WithPersist("myid"), WithPersistID("myid")))
+
+	// extending with the same persist is allowed.
+	assert.NoError(t, sess.Commit(context.Background(), WithPersist("myid"), WithConfirmedTimeout(time.Minute)))
+	sent, err = ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<commit><confirmed></confirmed><confirm-timeout>60</confirm-timeout><persist>myid</persist></commit>`)
+
+	// confirm from another session.
+	ts2 := newTestServer(t)
+	other := newSession(ts2.transport())
+	go other.recv()
+
+	ts2.queueRespString(okReply(1))
+
+	assert.NoError(t, other.Commit(context.Background(), WithPersistID("myid")))
+	sent, err = ts2.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<commit><persist-id>myid</persist-id></commit>`)
+
+	// confirming on the original session clears the pending commit.
+	ts.queueRespString(okReply(3))
+	ts.queueRespString(okReply(4))
+
+	assert.NoError(t, sess.Commit(context.Background(), WithPersistID("myid")))
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+
+	assert.NoError(t, sess.Commit(context.Background()))
+	sent, err = ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<commit></commit>`)
+}
+
+func TestAbortConfirmedCommit(t *testing.T) {
+	okReply := func(id int) string {
+		return fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><ok/></rpc-reply>`, id)
+	}
+
+	t.Run("none", func(t *testing.T) {
+		sess := newSession(newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+			panic("unexpected request")
+		}))
+		assert.ErrorIs(t, sess.AbortConfirmedCommit(context.Background()), ErrNoConfirmedCommit)
+	})
+
+	t.Run("nonPersistent", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(okReply(1))
+		ts.queueRespString(okReply(2))
+
+		assert.NoError(t, sess.Commit(context.Background(), WithConfirmed()))
+		_, err := ts.popReq()
+		assert.NoError(t, err)
+
+		// the session is closed so the device rolls back.
+		assert.NoError(t, sess.AbortConfirmedCommit(context.Background()))
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<close-session></close-session>`)
+	})
+
+	t.Run("persistent", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(okReply(1))
+		ts.queueRespString(okReply(2))
+
+		assert.NoError(t, sess.Commit(context.Background(), WithPersist("myid")))
+		_, err := ts.popReq()
+		assert.NoError(t, err)
+
+		assert.NoError(t, sess.AbortConfirmedCommit(context.Background()))
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<cancel-commit><persist-id>myid</persist-id></cancel-commit>`)
+
+		// the canceled commit is no longer pending.
+		assert.ErrorIs(t, sess.AbortConfirmedCommit(context.Background()), ErrNoConfirmedCommit)
+	})
+}
+
+func TestPersistIDInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	for _, id := range []string{"", " myid", "my\nid"} {
+		assert.Errorf(t, sess.CancelCommit(context.Background(), WithPersistID(id)), "persist-id %q", id)
+		assert.Errorf(t, sess.Commit(context.Background(), WithPersistID(id)), "persist-id %q", id)
+		assert.Errorf(t, sess.Commit(context.Background(), WithPersist(id)), "persist %q", id)
+	}
+}
+
 func TestCreateSubscription(t *testing.T) {
 	start := time.Date(2023, time.June, 07, 18, 31, 48, 00, time.UTC)
 	end := time.Date(2023, time.June, 07, 18, 33, 48, 00, time.UTC)
@@ -596,3 +2201,375 @@ func TestCreateSubscription(t *testing.T) {
 		})
 	}
 }
+
+const lockDeniedReply = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><rpc-error><error-type>protocol</error-type><error-tag>lock-denied</error-tag><error-severity>error</error-severity><error-info>%s</error-info></rpc-error></rpc-reply>`
+
+func TestLockHolder(t *testing.T) {
+	reply := Reply{
+		Errors: RPCErrors{{
+			Type:     ErrTypeProtocol,
+			Tag:      ErrLockDenied,
+			Severity: SevError,
+			Info:     RawXML("<session-id>454</session-id>"),
+		}},
+	}
+	id, ok := LockHolder(reply.Err())
+	assert.True(t, ok)
+	assert.Equal(t, uint32(454), id)
+
+	_, ok = LockHolder(errors.New("foo"))
+	assert.False(t, ok)
+}
+
+func TestKillLockHolder(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.sessionID = 1
+	go sess.recv()
+
+	ts.queueRespString(fmt.Sprintf(lockDeniedReply, 1, "<session-id>7</session-id>"))
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	killed, err := sess.KillLockHolder(context.Background(), Candidate, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), killed)
+
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<kill-session><session-id>7</session-id></kill-session>`)
+}
+
+func TestKillLockHolderMonitoring(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(monitoringCap)
+	go sess.recv()
+
+	ts.queueRespString(fmt.Sprintf(lockDeniedReply, 1, ""))
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><data>
+<netconf-state xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"><datastores>
+  <datastore><name>running</name></datastore>
+  <datastore><name>candidate</name><locks><global-lock><locked-by-session>9</locked-by-session><locked-time>2024-01-02T03:04:05Z</locked-time></global-lock></locks></datastore>
+</datastores></netconf-state></data></rpc-reply>`)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><ok/></rpc-reply>`)
+
+	killed, err := sess.KillLockHolder(context.Background(), Candidate, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(9), killed)
+
+	for i := 0; i < 3; i++ {
+		_, err = ts.popReq()
+		assert.NoError(t, err)
+	}
+}
+
+func TestKillLockHolderRefuse(t *testing.T) {
+	t.Run("noConfirm", func(t *testing.T) {
+		sess := newSession(newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+			panic("unexpected request")
+		}))
+		_, err := sess.KillLockHolder(context.Background(), Candidate, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("ownSession", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.sessionID = 7
+		go sess.recv()
+
+		ts.queueRespString(fmt.Sprintf(lockDeniedReply, 1, "<session-id>7</session-id>"))
+
+		_, err := sess.KillLockHolder(context.Background(), Candidate, true)
+		assert.ErrorContains(t, err, "locked by this session")
+
+		_, err = ts.popReq()
+		assert.NoError(t, err)
+	})
+
+	t.Run("notLocked", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+		_, err := sess.KillLockHolder(context.Background(), Candidate, true)
+		assert.ErrorContains(t, err, "not locked")
+
+		_, err = ts.popReq()
+		assert.NoError(t, err)
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, "<unlock>")
+	})
+}
+
+func TestLockCleanCandidate(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(":candidate")
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	release, err := sess.LockCleanCandidate(context.Background())
+	assert.NoError(t, err)
+
+	sentMsg, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sentMsg, `<discard-changes></discard-changes>`)
+
+	sentMsg, err = ts.popReqString()
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`<lock>\S*<target>\S*<candidate/>\S*</target>\S*</lock>`), sentMsg)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><ok/></rpc-reply>`)
+	assert.NoError(t, release())
+
+	sentMsg, err = ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sentMsg, `<unlock>`)
+}
+
+func TestLockCleanCandidateLockDenied(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(":candidate")
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2">
+  <rpc-error>
+    <error-type>protocol</error-type>
+    <error-tag>lock-denied</error-tag>
+    <error-severity>error</error-severity>
+    <error-info><session-id>7</session-id></error-info>
+  </rpc-error>
+</rpc-reply>`)
+
+	release, err := sess.LockCleanCandidate(context.Background())
+	assert.Nil(t, release)
+	holder, ok := LockHolder(err)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(7), holder)
+}
+
+func TestLockCleanCandidateCapability(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	_, err := sess.LockCleanCandidate(context.Background())
+	assert.ErrorIs(t, err, ErrCapabilityMissing)
+	assert.Equal(t, uint64(0), sess.seq.Load())
+}
+
+func TestReplaceRunning(t *testing.T) {
+	t.Run("candidate", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(":candidate", ":writable-running")
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+		err := sess.ReplaceRunning(context.Background(), "<system/>")
+		assert.NoError(t, err)
+
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<edit-config><target><candidate/></target><default-operation>replace</default-operation>`)
+
+		sent, err = ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<commit>`)
+	})
+
+	t.Run("candidateCommitFailed", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(":candidate")
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2">
+  <rpc-error>
+    <error-type>application</error-type>
+    <error-tag>operation-failed</error-tag>
+    <error-severity>error</error-severity>
+  </rpc-error>
+</rpc-reply>`)
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><ok/></rpc-reply>`)
+
+		err := sess.ReplaceRunning(context.Background(), "<system/>")
+		var rpcErr RPCError
+		assert.ErrorAs(t, err, &rpcErr)
+		assert.Equal(t, ErrOperationFailed, rpcErr.Tag)
+
+		for _, want := range []string{"<edit-config>", "<commit>", "<discard-changes>"} {
+			sent, err := ts.popReqString()
+			assert.NoError(t, err)
+			assert.Contains(t, sent, want)
+		}
+	})
+
+	t.Run("running", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		sess.serverCaps = newCapabilitySet(":writable-running")
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+		err := sess.ReplaceRunning(context.Background(), "<system/>")
+		assert.NoError(t, err)
+
+		sent, err := ts.popReqString()
+		assert.NoError(t, err)
+		assert.Contains(t, sent, `<edit-config><target><running/></target><default-operation>replace</default-operation>`)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		err := sess.ReplaceRunning(context.Background(), "<system/>")
+		assert.ErrorIs(t, err, ErrCapabilityMissing)
+	})
+}
+
+func TestCreateSubscriptionInterleave(t *testing.T) {
+	const (
+		event = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+			`<eventTime>2023-06-07T18:31:48Z</eventTime><event/></notification>`
+		notificationComplete = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+			`<eventTime>2023-06-07T18:33:48Z</eventTime>` +
+			`<notificationComplete xmlns="urn:ietf:params:xml:ns:netmod:notification"/>` +
+			`</notification>`
+	)
+
+	// notify sends a notification on the transport outside of any request.
+	notify := func(tr *testTransport, msg string) {
+		tr.out <- io.NopCloser(strings.NewReader(msg))
+	}
+
+	t.Run("interleave", func(t *testing.T) {
+		notifs := make(chan Notification, 1)
+		ts := newTestServer(t)
+		tr := ts.transport()
+		sess := newSession(tr, WithNotificationHandler(func(n Notification) { notifs <- n }))
+		sess.serverCaps = newCapabilitySet(":notification", ":interleave")
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		assert.NoError(t, sess.CreateSubscription(context.Background()))
+
+		// notifications and replies are routed independently.
+		notify(tr, event)
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+		assert.NoError(t, sess.Lock(context.Background(), Candidate))
+
+		select {
+		case n := <-notifs:
+			assert.Contains(t, string(n.Body), "<event/>")
+		case <-time.After(time.Second):
+			t.Fatal("notification not delivered")
+		}
+	})
+
+	t.Run("noInterleave", func(t *testing.T) {
+		notifs := make(chan Notification, 1)
+		ts := newTestServer(t)
+		tr := ts.transport()
+		sess := newSession(tr, WithNotificationHandler(func(n Notification) { notifs <- n }))
+		sess.serverCaps = newCapabilitySet(":notification")
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		assert.NoError(t, sess.CreateSubscription(context.Background()))
+
+		err := sess.Lock(context.Background(), Candidate)
+		assert.ErrorIs(t, err, ErrNotInterleaved)
+
+		// rpcs are allowed again once the subscription is complete.
+		notify(tr, notificationComplete)
+		select {
+		case <-notifs:
+		case <-time.After(time.Second):
+			t.Fatal("notification not delivered")
+		}
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+		assert.NoError(t, sess.Lock(context.Background(), Candidate))
+	})
+}
+
+func TestLockWait(t *testing.T) {
+	const lockDenied = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d">
+  <rpc-error>
+    <error-type>protocol</error-type>
+    <error-tag>lock-denied</error-tag>
+    <error-severity>error</error-severity>
+    <error-info><session-id>7</session-id></error-info>
+  </rpc-error>
+</rpc-reply>`
+
+	t.Run("granted", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(fmt.Sprintf(lockDenied, 1))
+		ts.queueRespString(fmt.Sprintf(lockDenied, 2))
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><ok/></rpc-reply>`)
+
+		err := sess.LockWait(context.Background(), Candidate, time.Millisecond)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), sess.seq.Load())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(fmt.Sprintf(lockDenied, 1))
+
+		// long enough for the first lock to be denied even on a loaded machine.
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		err := sess.LockWait(ctx, Candidate, time.Hour)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, IsLockDenied(err))
+		holder, ok := LockHolder(err)
+		assert.True(t, ok)
+		assert.Equal(t, uint32(7), holder)
+	})
+
+	t.Run("otherError", func(t *testing.T) {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport())
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">
+  <rpc-error>
+    <error-type>protocol</error-type>
+    <error-tag>access-denied</error-tag>
+    <error-severity>error</error-severity>
+  </rpc-error>
+</rpc-reply>`)
+
+		err := sess.LockWait(context.Background(), Candidate, time.Millisecond)
+		assert.Error(t, err)
+		assert.False(t, IsLockDenied(err))
+		assert.Equal(t, uint64(1), sess.seq.Load())
+	})
+}
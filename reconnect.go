@@ -0,0 +1,498 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTransactionLost is returned by the transactional operations of a
+// [ReconnectingSession] (i.e locking, editing and committing) when the
+// connection to the device was lost while the operation was in flight.  Any
+// locks and uncommitted changes of the lost session are gone.
+var ErrTransactionLost = errors.New("netconf: connection lost, locks and uncommitted changes are discarded")
+
+type reconnectConfig struct {
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	maxAttempts int
+}
+
+// ReconnectOption is a optional argument to [NewReconnecting].
+type ReconnectOption interface {
+	apply(*reconnectConfig)
+}
+
+type reconnectBackoffOpt struct{ min, max time.Duration }
+
+func (o reconnectBackoffOpt) apply(cfg *reconnectConfig) {
+	cfg.minBackoff = o.min
+	cfg.maxBackoff = o.max
+}
+
+// WithReconnectBackoff sets the delay before trying to re-dial after the
+// connection was lost.  The delay starts at `min` and is doubled after every
+// failed attempt up to `max`.  Defaults to 1 second and 1 minute.
+func WithReconnectBackoff(min, max time.Duration) ReconnectOption {
+	return reconnectBackoffOpt{min: min, max: max}
+}
+
+type maxReconnectAttemptsOpt int
+
+func (o maxReconnectAttemptsOpt) apply(cfg *reconnectConfig) {
+	cfg.maxAttempts = int(o)
+}
+
+// WithMaxReconnectAttempts limits how often re-dialing in the background is
+// tried after the connection was lost.  After that the session is only
+// re-dialed when the next operation is issued.  The default of 0 retries
+// forever.
+func WithMaxReconnectAttempts(n int) ReconnectOption {
+	return maxReconnectAttemptsOpt(n)
+}
+
+// ReconnectingSession wraps a [Session] that is transparently re-dialed (and
+// the hello messages re-negotiated) when the underlying transport fails.
+// Notification subscriptions created with
+// [ReconnectingSession.CreateSubscription] are re-established on the new
+// session.  Notifications are delivered to the handler set by the `dial`
+// function given to [NewReconnecting].
+//
+// Locks and the contents of the candidate datastore are tied to a session and
+// cannot survive a reconnect.  Read only operations (i.e `<get>`,
+// `<get-config>` and `<validate>`) are retried once on the new session when the
+// connection is lost while they are in flight.  Transactional operations (`<lock>`, `<unlock>`,
+// `<edit-config>`, `<copy-config>`, `<delete-config>`, `<commit>` and
+// `<cancel-commit>`) are never retried and instead return an error wrapping
+// [ErrTransactionLost].  Callers holding a lock should start the transaction
+// over (re-lock, re-edit) after such an error.
+type ReconnectingSession struct {
+	dial func(ctx context.Context) (*Session, error)
+	cfg  reconnectConfig
+
+	mu            sync.Mutex
+	sess          *Session
+	subscriptions [][]CreateSubscriptionOption
+	closed        bool
+	stop          chan struct{}
+	// dialing is closed once the dial in progress is done.
+	dialing chan struct{}
+}
+
+// NewReconnecting returns a [ReconnectingSession] that uses `dial` to create
+// (and re-create) the underlying session.  `dial` is not called until the first
+// operation is issued.
+func NewReconnecting(dial func(ctx context.Context) (*Session, error), opts ...ReconnectOption) *ReconnectingSession {
+	cfg := reconnectConfig{
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+
+	return &ReconnectingSession{
+		dial: dial,
+		cfg:  cfg,
+		stop: make(chan struct{}),
+	}
+}
+
+// Session returns the current underlying session, dialing a new one if there
+// is no connection.
+func (r *ReconnectingSession) Session(ctx context.Context) (*Session, error) {
+	r.mu.Lock()
+	// wait for a dial in progress instead of dialing a second session.
+	for !r.closed && r.sess == nil && r.dialing != nil {
+		dialing := r.dialing
+		r.mu.Unlock()
+		select {
+		case <-dialing:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		r.mu.Lock()
+	}
+
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if sess := r.sess; sess != nil {
+		r.mu.Unlock()
+		return sess, nil
+	}
+	subscriptions := append([][]CreateSubscriptionOption(nil), r.subscriptions...)
+	dialing := make(chan struct{})
+	r.dialing = dialing
+	r.mu.Unlock()
+
+	return r.connect(ctx, subscriptions, dialing)
+}
+
+// connect dials a new session and re-creates the subscriptions on it.  The dial
+// is done without holding r.mu so a hanging dial doesn't block Close or any
+// operation, and is canceled by Close.  `dialing` must be the channel published
+// in r.dialing by the caller.
+func (r *ReconnectingSession) connect(ctx context.Context, subscriptions [][]CreateSubscriptionOption, dialing chan struct{}) (*Session, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	sess, err := r.dialSession(ctx, subscriptions)
+
+	r.mu.Lock()
+	r.dialing = nil
+	close(dialing)
+	if r.closed {
+		// closed while dialing.
+		r.mu.Unlock()
+		if err == nil {
+			sess.tr.Close()
+		}
+		return nil, ErrClosed
+	}
+	if err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.sess = sess
+	r.mu.Unlock()
+
+	go r.watch(sess)
+	return sess, nil
+}
+
+// dialSession dials a new session and re-creates `subscriptions` on it.
+func (r *ReconnectingSession) dialSession(ctx context.Context, subscriptions [][]CreateSubscriptionOption) (*Session, error) {
+	sess, err := r.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, opts := range subscriptions {
+		if err := sess.CreateSubscription(ctx, opts...); err != nil {
+			sess.tr.Close()
+			return nil, fmt.Errorf("failed to re-create subscription: %w", err)
+		}
+	}
+	return sess, nil
+}
+
+// drop forgets the given session (if it is still the current one) and tears
+// down its transport.
+func (r *ReconnectingSession) drop(sess *Session) {
+	r.mu.Lock()
+	if r.sess == sess {
+		r.sess = nil
+	}
+	r.mu.Unlock()
+
+	sess.tr.Close()
+}
+
+// watch re-dials in the background once the connection of `sess` is lost so
+// that subscriptions continue to deliver notifications.
+func (r *ReconnectingSession) watch(sess *Session) {
+	select {
+	case <-sess.done:
+	case <-r.stop:
+		return
+	}
+
+	r.mu.Lock()
+	if r.sess == sess {
+		r.sess = nil
+	}
+	r.mu.Unlock()
+
+	// dialing is done without holding r.mu so a hanging dial doesn't block
+	// Close or any operation, and is canceled by Close.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-r.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	backoff := r.cfg.minBackoff
+	for attempt := 0; r.cfg.maxAttempts == 0 || attempt < r.cfg.maxAttempts; attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-r.stop:
+			timer.Stop()
+			return
+		}
+
+		r.mu.Lock()
+		// closed or already reconnected by an operation
+		if r.closed || r.sess != nil {
+			r.mu.Unlock()
+			return
+		}
+		// an operation is dialing, check again once the backoff expired.
+		if r.dialing != nil {
+			r.mu.Unlock()
+			continue
+		}
+		subscriptions := append([][]CreateSubscriptionOption(nil), r.subscriptions...)
+		dialing := make(chan struct{})
+		r.dialing = dialing
+		r.mu.Unlock()
+
+		newSess, err := r.dialSession(ctx, subscriptions)
+
+		r.mu.Lock()
+		r.dialing = nil
+		close(dialing)
+		if err == nil {
+			if r.closed || r.sess != nil {
+				// closed or reconnected by an operation while dialing.
+				r.mu.Unlock()
+				newSess.tr.Close()
+				return
+			}
+			r.sess = newSess
+			r.mu.Unlock()
+
+			go r.watch(newSess)
+			return
+		}
+		r.mu.Unlock()
+
+		backoff *= 2
+		if backoff > r.cfg.maxBackoff {
+			backoff = r.cfg.maxBackoff
+		}
+	}
+}
+
+// retry runs a read only operation and retries it once on a new session if the
+// connection was lost.
+func (r *ReconnectingSession) retry(ctx context.Context, fn func(*Session) error) error {
+	sess, err := r.Session(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(sess)
+	if !IsTransportError(err) {
+		return err
+	}
+	r.drop(sess)
+
+	sess, err = r.Session(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(sess)
+	if IsTransportError(err) {
+		r.drop(sess)
+	}
+	return err
+}
+
+// transaction runs an operation that depends on session state and reports
+// ErrTransactionLost if the connection was lost.
+func (r *ReconnectingSession) transaction(ctx context.Context, fn func(*Session) error) error {
+	sess, err := r.Session(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(sess)
+	if IsTransportError(err) {
+		r.drop(sess)
+		return fmt.Errorf("%w: %w", ErrTransactionLost, err)
+	}
+	return err
+}
+
+// once runs an operation that is not safe to be repeated.
+func (r *ReconnectingSession) once(ctx context.Context, fn func(*Session) error) error {
+	sess, err := r.Session(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(sess)
+	if IsTransportError(err) {
+		r.drop(sess)
+	}
+	return err
+}
+
+// Do issues a rpc on the current session.  See [Session.Do].  As it is unknown
+// if `req` was applied by the device it is never retried.
+func (r *ReconnectingSession) Do(ctx context.Context, req any) (*Reply, error) {
+	var reply *Reply
+	err := r.once(ctx, func(sess *Session) error {
+		var err error
+		reply, err = sess.Do(ctx, req)
+		return err
+	})
+	return reply, err
+}
+
+// Call issues a rpc on the current session.  See [Session.Call].  As it is
+// unknown if `req` was applied by the device it is never retried.
+func (r *ReconnectingSession) Call(ctx context.Context, req any, resp any) error {
+	return r.once(ctx, func(sess *Session) error {
+		return sess.Call(ctx, req, resp)
+	})
+}
+
+// Get issues a `<get>` operation.  See [Session.Get].
+func (r *ReconnectingSession) Get(ctx context.Context, opts ...RPCOption) ([]byte, error) {
+	var data []byte
+	err := r.retry(ctx, func(sess *Session) error {
+		var err error
+		data, err = sess.Get(ctx, opts...)
+		return err
+	})
+	return data, err
+}
+
+// GetConfig issues a `<get-config>` operation.  See [Session.GetConfig].
+func (r *ReconnectingSession) GetConfig(ctx context.Context, source Datastore, opts ...RPCOption) ([]byte, error) {
+	var config []byte
+	err := r.retry(ctx, func(sess *Session) error {
+		var err error
+		config, err = sess.GetConfig(ctx, source, opts...)
+		return err
+	})
+	return config, err
+}
+
+// Snapshot fetches the config of several datastores.  See [Session.Snapshot].
+func (r *ReconnectingSession) Snapshot(ctx context.Context, sources ...Datastore) (map[Datastore][]byte, error) {
+	var configs map[Datastore][]byte
+	err := r.retry(ctx, func(sess *Session) error {
+		var err error
+		configs, err = sess.Snapshot(ctx, sources...)
+		return err
+	})
+	return configs, err
+}
+
+// Validate issues a `<validate>` operation.  See [Session.Validate].
+func (r *ReconnectingSession) Validate(ctx context.Context, source any, opts ...ValidateOption) error {
+	return r.retry(ctx, func(sess *Session) error {
+		return sess.Validate(ctx, source, opts...)
+	})
+}
+
+// EditConfig issues a `<edit-config>` operation.  See [Session.EditConfig].
+func (r *ReconnectingSession) EditConfig(ctx context.Context, target Datastore, config any, opts ...EditConfigOption) error {
+	return r.transaction(ctx, func(sess *Session) error {
+		return sess.EditConfig(ctx, target, config, opts...)
+	})
+}
+
+// CopyConfig issues a `<copy-config>` operation.  See [Session.CopyConfig].
+func (r *ReconnectingSession) CopyConfig(ctx context.Context, source, target any, opts ...CopyConfigOption) error {
+	return r.transaction(ctx, func(sess *Session) error {
+		return sess.CopyConfig(ctx, source, target, opts...)
+	})
+}
+
+// DeleteConfig issues a `<delete-config>` operation.  See
+// [Session.DeleteConfig].
+func (r *ReconnectingSession) DeleteConfig(ctx context.Context, target Datastore, opts ...DeleteConfigOption) error {
+	return r.transaction(ctx, func(sess *Session) error {
+		return sess.DeleteConfig(ctx, target, opts...)
+	})
+}
+
+// DeleteConfigURL issues a `<delete-config>` operation for a url.  See
+// [Session.DeleteConfigURL].
+func (r *ReconnectingSession) DeleteConfigURL(ctx context.Context, url URL) error {
+	return r.transaction(ctx, func(sess *Session) error {
+		return sess.DeleteConfigURL(ctx, url)
+	})
+}
+
+// Lock issues a `<lock>` operation.  See [Session.Lock].  The lock is released
+// by the device when the connection is lost.
+func (r *ReconnectingSession) Lock(ctx context.Context, target Datastore) error {
+	return r.transaction(ctx, func(sess *Session) error {
+		return sess.Lock(ctx, target)
+	})
+}
+
+// Unlock issues a `<unlock>` operation.  See [Session.Unlock].
+func (r *ReconnectingSession) Unlock(ctx context.Context, target Datastore) error {
+	return r.transaction(ctx, func(sess *Session) error {
+		return sess.Unlock(ctx, target)
+	})
+}
+
+// Commit issues a `<commit>` operation.  See [Session.Commit].
+func (r *ReconnectingSession) Commit(ctx context.Context, opts ...CommitOption) error {
+	return r.transaction(ctx, func(sess *Session) error {
+		return sess.Commit(ctx, opts...)
+	})
+}
+
+// CancelCommit issues a `<cancel-commit>` operation.  See
+// [Session.CancelCommit].
+func (r *ReconnectingSession) CancelCommit(ctx context.Context, opts ...CancelCommitOption) error {
+	return r.transaction(ctx, func(sess *Session) error {
+		return sess.CancelCommit(ctx, opts...)
+	})
+}
+
+// KillSession issues a `<kill-session>` operation.  See [Session.KillSession].
+func (r *ReconnectingSession) KillSession(ctx context.Context, sessionID uint32) error {
+	return r.once(ctx, func(sess *Session) error {
+		return sess.KillSession(ctx, sessionID)
+	})
+}
+
+// CreateSubscription issues a `<create-subscription>` operation.  See
+// [Session.CreateSubscription].  On success the subscription is re-created
+// every time the session is re-dialed.
+func (r *ReconnectingSession) CreateSubscription(ctx context.Context, opts ...CreateSubscriptionOption) error {
+	err := r.retry(ctx, func(sess *Session) error {
+		return sess.CreateSubscription(ctx, opts...)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.subscriptions = append(r.subscriptions, opts)
+	r.mu.Unlock()
+	return nil
+}
+
+// Close closes the current session and stops any reconnecting.
+func (r *ReconnectingSession) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.stop)
+	sess := r.sess
+	r.sess = nil
+	r.mu.Unlock()
+
+	if sess == nil {
+		return nil
+	}
+	return sess.Close(ctx)
+}
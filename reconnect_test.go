@@ -0,0 +1,265 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// deviceTransport answers every rpc with `<ok/>` (or with it's name as the data
+// for `<get-config>`) and can be killed to simulate a lost connection.
+type deviceTransport struct {
+	*testTransport
+	name       string
+	ops        chan string
+	dead       chan struct{}
+	once       sync.Once
+	failWrites atomic.Bool
+}
+
+func newDeviceTransport(name string) *deviceTransport {
+	t := &deviceTransport{
+		name: name,
+		ops:  make(chan string, 16),
+		dead: make(chan struct{}),
+	}
+	t.testTransport = newTestTransport(t.handle)
+	return t
+}
+
+func (t *deviceTransport) handle(r io.ReadCloser, w io.WriteCloser) {
+	var req struct {
+		MessageID string `xml:"message-id,attr"`
+		Op        struct {
+			XMLName xml.Name
+		} `xml:",any"`
+	}
+	if err := xml.NewDecoder(r).Decode(&req); err != nil {
+		panic(err)
+	}
+	io.Copy(io.Discard, r)
+	t.ops <- req.Op.XMLName.Local
+
+	body := "<ok/>"
+	if req.Op.XMLName.Local == "get-config" {
+		body = fmt.Sprintf("<data>%s</data>", t.name)
+	}
+	fmt.Fprintf(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s">%s</rpc-reply>`, req.MessageID, body)
+	w.Close()
+}
+
+func (t *deviceTransport) MsgReader() (io.ReadCloser, error) {
+	select {
+	case r := <-t.out:
+		return r, nil
+	case <-t.dead:
+		return nil, io.EOF
+	}
+}
+
+func (t *deviceTransport) MsgWriter() (io.WriteCloser, error) {
+	select {
+	case <-t.dead:
+		return nil, io.ErrClosedPipe
+	default:
+	}
+	if t.failWrites.Load() {
+		return nil, io.ErrClosedPipe
+	}
+	return t.testTransport.MsgWriter()
+}
+
+func (t *deviceTransport) Close() error {
+	t.once.Do(func() { close(t.dead) })
+	return nil
+}
+
+// deviceDialer dials a new deviceTransport named after the number of dials.
+type deviceDialer struct {
+	mu         sync.Mutex
+	transports []*deviceTransport
+}
+
+func (d *deviceDialer) dial(ctx context.Context) (*Session, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tr := newDeviceTransport(fmt.Sprint(len(d.transports) + 1))
+	d.transports = append(d.transports, tr)
+
+	sess := newSession(tr)
+	// the device mixes rpcs with the notification subscription.
+	sess.serverCaps = newCapabilitySet(":interleave", ":candidate")
+	go sess.recv()
+	return sess, nil
+}
+
+func (d *deviceDialer) transport(i int) *deviceTransport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i >= len(d.transports) {
+		return nil
+	}
+	return d.transports[i]
+}
+
+func (d *deviceDialer) dials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.transports)
+}
+
+func TestReconnectingSession(t *testing.T) {
+	var d deviceDialer
+	r := NewReconnecting(d.dial, WithReconnectBackoff(time.Millisecond, time.Millisecond))
+	defer r.Close(context.Background())
+
+	ctx := context.Background()
+	assert.NoError(t, r.CreateSubscription(ctx))
+
+	config, err := r.GetConfig(ctx, Running)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), config)
+
+	// lose the connection and wait for the session to be re-dialed in the
+	// background with the subscription re-created.
+	d.transport(0).Close()
+	assert.Eventually(t, func() bool { return d.dials() == 2 }, time.Second, time.Millisecond)
+
+	select {
+	case op := <-d.transport(1).ops:
+		assert.Equal(t, "create-subscription", op)
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not re-created")
+	}
+
+	config, err = r.GetConfig(ctx, Running)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), config)
+}
+
+func TestReconnectingSessionRetry(t *testing.T) {
+	var d deviceDialer
+	r := NewReconnecting(d.dial, WithReconnectBackoff(time.Hour, time.Hour))
+	defer r.Close(context.Background())
+
+	ctx := context.Background()
+	_, err := r.Session(ctx)
+	assert.NoError(t, err)
+
+	d.transport(0).failWrites.Store(true)
+
+	config, err := r.GetConfig(ctx, Running)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), config)
+}
+
+func TestReconnectingSessionTransactionLost(t *testing.T) {
+	var d deviceDialer
+	r := NewReconnecting(d.dial, WithReconnectBackoff(time.Hour, time.Hour))
+	defer r.Close(context.Background())
+
+	ctx := context.Background()
+	assert.NoError(t, r.Lock(ctx, Candidate))
+
+	d.transport(0).failWrites.Store(true)
+
+	err := r.EditConfig(ctx, Candidate, "<foo/>")
+	assert.True(t, errors.Is(err, ErrTransactionLost))
+	assert.True(t, IsTransportError(err))
+	assert.Equal(t, 1, d.dials())
+
+	// the next operation starts on a new session
+	config, err := r.GetConfig(ctx, Running)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), config)
+}
+
+func TestReconnectingSessionCloseWhileDialing(t *testing.T) {
+	var d deviceDialer
+	dialing := make(chan struct{})
+	canceled := make(chan struct{})
+	dial := func(ctx context.Context) (*Session, error) {
+		if d.dials() == 0 {
+			return d.dial(ctx)
+		}
+		// the device never answers the re-dial.
+		close(dialing)
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+	r := NewReconnecting(dial, WithReconnectBackoff(time.Millisecond, time.Millisecond))
+
+	ctx := context.Background()
+	_, err := r.Session(ctx)
+	assert.NoError(t, err)
+
+	d.transport(0).Close()
+	select {
+	case <-dialing:
+	case <-time.After(time.Second):
+		t.Fatal("session was not re-dialed")
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- r.Close(ctx) }()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on the re-dial")
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("re-dial was not canceled by Close")
+	}
+}
+
+func TestReconnectingSessionCloseWhileConnecting(t *testing.T) {
+	dialing := make(chan struct{})
+	dial := func(ctx context.Context) (*Session, error) {
+		// the device never answers the dial.
+		close(dialing)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	r := NewReconnecting(dial)
+
+	ctx := context.Background()
+	connected := make(chan error, 1)
+	go func() {
+		_, err := r.Session(ctx)
+		connected <- err
+	}()
+	select {
+	case <-dialing:
+	case <-time.After(time.Second):
+		t.Fatal("session was not dialed")
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- r.Close(ctx) }()
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on the dial")
+	}
+
+	select {
+	case err := <-connected:
+		assert.ErrorIs(t, err, ErrClosed)
+	case <-time.After(time.Second):
+		t.Fatal("dial was not canceled by Close")
+	}
+}
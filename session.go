@@ -1,6 +1,7 @@
 package netconf
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
 	"errors"
@@ -8,19 +9,101 @@ import (
 	"io"
 	"log"
 	"net"
+	"os"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/DinbandhuKumarSingh/netconf/transport"
 )
 
 var ErrClosed = errors.New("closed connection")
 
+// ErrSessionClosed is returned by the calls still waiting for their reply when
+// the session is closed with [Session.Close].
+var ErrSessionClosed = errors.New("netconf: session closed")
+
+// ErrRPCCanceled is returned by the calls canceled with
+// [Session.CancelInFlight].
+var ErrRPCCanceled = errors.New("netconf: rpc canceled")
+
+// ErrMessageIDMismatch is returned when the `message-id` of a `<rpc-reply>`
+// does not match the `message-id` of the `<rpc>` request it was received for.
+// This usually indicates a buggy device or that the framing of the session has
+// become out of sync.
+type ErrMessageIDMismatch struct {
+	Sent     uint64
+	Received uint64
+	// RawSent and RawReceived are the message-ids as sent which, unlike Sent
+	// and Received, are also set for ids that are not numbers (see
+	// [WithMessageID]).
+	RawSent     string
+	RawReceived string
+}
+
+func (e ErrMessageIDMismatch) Error() string {
+	return fmt.Sprintf("netconf: reply message-id %q does not match request message-id %q", e.RawReceived, e.RawSent)
+}
+
+// ErrDuplicateMessageID is returned when a request is issued with a message-id
+// (see [WithMessageID]) that is already used by a request waiting for it's
+// reply.
+var ErrDuplicateMessageID = errors.New("netconf: duplicate in-flight message-id")
+
+// ErrNotInterleaved is returned when issuing a rpc while a notification
+// subscription is active on a session with a device that doesn't advertise
+// the `:interleave` capability defined in [RFC5277 6].
+//
+// [RFC5277 6]: https://www.rfc-editor.org/rfc/rfc5277.html#section-6
+var ErrNotInterleaved = errors.New("netconf: rpc issued during a notification subscription without :interleave")
+
+// TransportError is returned when a request could not be written to or a reply
+// could not be read from the underlying transport (i.e the connection to the
+// device was lost).  Errors returned by the device itself are returned as
+// [RPCError] instead.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("netconf: transport error: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// IsTransportError reports whether any error in err's tree is a
+// [TransportError].
+func IsTransportError(err error) bool {
+	var terr *TransportError
+	return errors.As(err, &terr)
+}
+
 type sessionConfig struct {
-	capabilities        []string
-	notificationHandler NotificationHandler
-	disconnectionHandler ConnectionHandler
+	capabilities            []string
+	notificationHandler     NotificationHandler
+	disconnectionHandler    ConnectionHandler
+	selfClosingTags         bool
+	operationTimeout        time.Duration
+	callMiddleware          []CallMiddleware
+	metricsObserver         func(MetricEvent)
+	tracer                  Tracer
+	xmlDeclaration          bool
+	indentPrefix            string
+	indent                  string
+	username                string
+	readTimeout             time.Duration
+	writeTimeout            time.Duration
+	notifIdleTimeout        time.Duration
+	maxChunkSize            int
+	warningsAsErrors        bool
+	helloTimeout            time.Duration
+	capabilityChangeUpdates bool
+	base10                  bool
+	maxReplySize            int
+	timeoutHints            []TimeoutHint
 }
 
 type SessionOption interface {
@@ -58,20 +141,371 @@ func WithDisconnectHandler(dh ConnectionHandler) SessionOption{
 	return disconnectionHandlerOpt(dh)
 }
 
+type selfClosingTagsOpt bool
+
+func (o selfClosingTagsOpt) apply(cfg *sessionConfig) {
+	cfg.selfClosingTags = bool(o)
+}
+
+// WithSelfClosingTags will collapse empty elements in outgoing messages (i.e
+// `<confirmed></confirmed>`) into self-closing tags (`<confirmed/>`).  Both
+// forms are equivalent XML, however some vendors only accept the latter.
+func WithSelfClosingTags() SessionOption {
+	return selfClosingTagsOpt(true)
+}
+
+type operationTimeoutOpt time.Duration
+
+func (o operationTimeoutOpt) apply(cfg *sessionConfig) {
+	cfg.operationTimeout = time.Duration(o)
+}
+
+// WithOperationTimeout sets a default timeout for every rpc operation issued on
+// the session.  The timeout is only applied when the context passed into the
+// operation has no deadline of it's own, so an explicit deadline set by the
+// caller always wins.
+//
+// This only bounds the request/reply of an rpc and does not affect the lifetime
+// of the session or the delivery of notifications.
+func WithOperationTimeout(d time.Duration) SessionOption {
+	return operationTimeoutOpt(d)
+}
+
+type callMiddlewareOpt []CallMiddleware
+
+func (o callMiddlewareOpt) apply(cfg *sessionConfig) {
+	cfg.callMiddleware = append(cfg.callMiddleware, o...)
+}
+
+// WithCallMiddleware wraps every [Session.Call] (and so every operation method
+// like [Session.GetConfig]) with the given middleware.  The first middleware is
+// the outermost one.  Requests issued directly with [Session.Do] are not
+// wrapped.
+func WithCallMiddleware(mw ...CallMiddleware) SessionOption {
+	return callMiddlewareOpt(mw)
+}
+
+type metricsObserverOpt func(MetricEvent)
+
+func (o metricsObserverOpt) apply(cfg *sessionConfig) {
+	cfg.metricsObserver = o
+}
+
+// WithMetricsObserver registers a function that is called with a
+// [MetricEvent] after every rpc issued on the session, including the ones that
+// failed.  The observer is called synchronously so it should not block.
+func WithMetricsObserver(fn func(MetricEvent)) SessionOption {
+	return metricsObserverOpt(fn)
+}
+
+type tracerOpt struct{ Tracer }
+
+func (o tracerOpt) apply(cfg *sessionConfig) {
+	cfg.tracer = o.Tracer
+}
+
+// WithTracer starts a span with the given [Tracer] for every rpc issued on the
+// session.  The span is a child of any span carried in the context passed to
+// the operation, is named after the operation and records the message-id, the
+// datastores and any error.
+func WithTracer(t Tracer) SessionOption {
+	return tracerOpt{t}
+}
+
+type xmlDeclarationOpt struct{}
+
+func (o xmlDeclarationOpt) apply(cfg *sessionConfig) {
+	cfg.xmlDeclaration = true
+}
+
+// WithXMLDeclaration prepends the xml declaration (`<?xml version="1.0"
+// encoding="UTF-8"?>`) to every message sent on the session.  By default it is
+// omitted which is allowed by the XML specification.
+func WithXMLDeclaration() SessionOption {
+	return xmlDeclarationOpt{}
+}
+
+type indentOpt struct{ prefix, indent string }
+
+func (o indentOpt) apply(cfg *sessionConfig) {
+	cfg.indentPrefix = o.prefix
+	cfg.indent = o.indent
+}
+
+// WithIndent pretty prints the messages sent on the session with the given
+// prefix and indentation (see [xml.Encoder.Indent]).  Config passed as already
+// encoded xml (i.e a string, byte slice or io.Reader) is sent as-is.  By default
+// messages are sent without any indentation.
+func WithIndent(prefix, indent string) SessionOption {
+	return indentOpt{prefix: prefix, indent: indent}
+}
+
+type usernameOpt string
+
+func (o usernameOpt) apply(cfg *sessionConfig) {
+	cfg.username = string(o)
+}
+
+// WithUsername sets the username reported by [Session.Username].  For
+// sessions opened with [DialTLS] this overrides the username derived from the
+// client certificate.
+func WithUsername(name string) SessionOption {
+	return usernameOpt(name)
+}
+
+type transportTimeoutOpt struct{ read, write time.Duration }
+
+func (o transportTimeoutOpt) apply(cfg *sessionConfig) {
+	cfg.readTimeout = o.read
+	cfg.writeTimeout = o.write
+}
+
+// WithTransportTimeout sets deadlines on the underlying connection as a
+// defense against dead connections that would otherwise hang forever (i.e a
+// stalled TCP connection), independent of the context passed to an operation.
+// A zero duration disables the timeout.
+//
+// The write timeout bounds writing each message.  The read timeout bounds
+// reading the next message while at least one rpc is waiting for it's reply
+// (as well as the server hello).  While no rpc is outstanding the session
+// legitimately waits for notifications for long periods so the read timeout is
+// not applied; use [WithNotificationIdleTimeout] to bound that as well.  When
+// a timeout expires the transport is closed and all outstanding and future
+// requests fail with a [TransportError].
+//
+// The transport must implement [transport.Deadliner] (the SSH transport only
+// when created with Dial or DialConn) or [Open] fails.
+func WithTransportTimeout(read, write time.Duration) SessionOption {
+	return transportTimeoutOpt{read: read, write: write}
+}
+
+type notifIdleTimeoutOpt time.Duration
+
+func (o notifIdleTimeoutOpt) apply(cfg *sessionConfig) {
+	cfg.notifIdleTimeout = time.Duration(o)
+}
+
+// WithNotificationIdleTimeout closes the session if no message at all is
+// received for the given duration while no rpc is waiting for it's reply.
+// This should be longer than the expected interval between notifications.
+// See [WithTransportTimeout] for the timeout used while waiting for replies.
+func WithNotificationIdleTimeout(d time.Duration) SessionOption {
+	return notifIdleTimeoutOpt(d)
+}
+
+type base10Opt bool
+
+func (o base10Opt) apply(cfg *sessionConfig) {
+	cfg.base10 = bool(o)
+}
+
+// WithBase10 only advertises NETCONF 1.0 (`:base:1.0`) in the hello of the
+// client so that the end-of-message framing is used even if the server
+// supports NETCONF 1.1.  This works around devices with a broken chunked
+// framing.  Opening the session fails if the server only supports 1.1.
+func WithBase10() SessionOption {
+	return base10Opt(true)
+}
+
+type maxChunkSizeOpt int
+
+func (o maxChunkSizeOpt) apply(cfg *sessionConfig) {
+	cfg.maxChunkSize = int(o)
+}
+
+// WithMaxChunkSize limits the size of the chunks sent once the session uses
+// chunked framing (`:base:1.1`), splitting larger messages into multiple
+// chunks.  This helps with devices that mishandle large chunks.  The size must
+// be between 1 and 4294967295 as defined in [RFC6242 4.2] and the transport
+// must support it (all transports of this package do) or [Open] fails.
+//
+// [RFC6242 4.2]: https://www.rfc-editor.org/rfc/rfc6242.html#section-4.2
+func WithMaxChunkSize(n int) SessionOption {
+	return maxChunkSizeOpt(n)
+}
+
+type warningsAsErrorsOpt bool
+
+func (o warningsAsErrorsOpt) apply(cfg *sessionConfig) {
+	cfg.warningsAsErrors = bool(o)
+}
+
+// WithWarningsAsErrors controls if rpc-errors with a `warning` severity fail
+// an operation.  By default (false) only rpc-errors with an `error` severity
+// fail the operation and warnings alone succeed.  The warnings are attached to
+// [OKResp.Warnings] for operations replying with `<ok/>` and are available in
+// [Reply.Errors] for requests issued with [Session.Do].  With true warnings fail
+// the operation like errors.
+func WithWarningsAsErrors(enabled bool) SessionOption {
+	return warningsAsErrorsOpt(enabled)
+}
+
+type helloTimeoutOpt time.Duration
+
+func (o helloTimeoutOpt) apply(cfg *sessionConfig) {
+	cfg.helloTimeout = time.Duration(o)
+}
+
+// WithHelloTimeout bounds the hello exchange when opening the session,
+// independent of any timeout used to establish the transport.  If the server
+// hello isn't received within `d` (i.e the device accepted the connection but
+// hangs) the transport is closed and [Open] fails with an error wrapping
+// [ErrHelloTimeout].  A zero duration (the default) waits forever.
+func WithHelloTimeout(d time.Duration) SessionOption {
+	return helloTimeoutOpt(d)
+}
+
+// DefaultMaxReplySize is the maximum size of a message received from the
+// server unless set otherwise with [WithMaxReplySize].
+const DefaultMaxReplySize = 64 << 20
+
+// ErrReplyTooLarge is returned (wrapped) when a message received from the
+// server exceeds the size set with [WithMaxReplySize].
+var ErrReplyTooLarge = errors.New("netconf: message exceeds the maximum size")
+
+type maxReplySizeOpt int
+
+func (o maxReplySizeOpt) apply(cfg *sessionConfig) {
+	cfg.maxReplySize = int(o)
+}
+
+// WithMaxReplySize limits the size in bytes of a single message received from
+// the server (the hello, a rpc-reply or a notification) to protect against
+// broken or malicious devices sending enormous or unterminated messages.  A
+// request whose reply exceeds the limit fails with an error wrapping
+// [ErrReplyTooLarge], the rest of the reply is discarded and the session
+// remains usable.  A notification that is too large is dropped.  Replies
+// streamed with [Session.GetConfigStream] are not limited as they are not
+// buffered.
+//
+// It defaults to [DefaultMaxReplySize].  A size of zero or less disables the
+// limit.
+func WithMaxReplySize(bytes int) SessionOption {
+	return maxReplySizeOpt(bytes)
+}
+
+// sizeLimitReader fails reading a message once more than `max` bytes were
+// read.
+type sizeLimitReader struct {
+	r    io.Reader
+	max  int64
+	left int64
+	// off disables the limit.
+	off bool
+}
+
+func newSizeLimitReader(r io.Reader, max int) *sizeLimitReader {
+	return &sizeLimitReader{r: r, max: int64(max), left: int64(max), off: max <= 0}
+}
+
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	if r.off {
+		return r.r.Read(p)
+	}
+
+	// read one byte more than allowed to tell if the limit is exceeded.
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+	n, err := r.r.Read(p)
+	if int64(n) <= r.left {
+		r.left -= int64(n)
+		return n, err
+	}
+	n = int(r.left)
+	r.left = 0
+	return n, fmt.Errorf("%w of %d bytes", ErrReplyTooLarge, r.max)
+}
+
+type capabilityChangeUpdatesOpt bool
+
+func (o capabilityChangeUpdatesOpt) apply(cfg *sessionConfig) {
+	cfg.capabilityChangeUpdates = bool(o)
+}
+
+// WithCapabilityChangeUpdates controls if the server capabilities of the
+// session are updated when a `<netconf-capability-change>` notification
+// ([CapabilityChangeEvent]) is received.  By default (false) the capabilities
+// are the ones advertised in the server hello for the lifetime of the session.
+// With true the added, deleted and modified capabilities of the event are
+// applied before the notification is passed to the [NotificationHandler], so
+// [Session.ServerCapabilities] and the capability checks of the operations
+// stay accurate on long-lived sessions.  The notifications are only received
+// with a subscription to the `NETCONF` stream (see
+// [Session.CreateSubscription]).
+func WithCapabilityChangeUpdates(enabled bool) SessionOption {
+	return capabilityChangeUpdatesOpt(enabled)
+}
+
+// ErrHelloTimeout is returned (wrapped) by [Open] when the hello exchange
+// didn't complete within the timeout set with [WithHelloTimeout].
+var ErrHelloTimeout = errors.New("netconf: timed out waiting for the server hello")
+
 // Session is represents a netconf session to a one given device.
 type Session struct {
 	tr        transport.Transport
 	sessionID uint64
 	seq       atomic.Uint64
 
-	clientCaps          capabilitySet
-	serverCaps          capabilitySet
-	notificationHandler NotificationHandler
-	disconnectionHandler ConnectionHandler
-
-	mu      sync.Mutex
-	reqs    map[uint64]*req
-	closing bool
+	clientCaps              capabilitySet
+	serverCaps              capabilitySet
+	notificationHandler     NotificationHandler
+	disconnectionHandler    ConnectionHandler
+	selfClosingTags         bool
+	operationTimeout        time.Duration
+	caller                  Caller
+	metricsObserver         func(MetricEvent)
+	tracer                  Tracer
+	xmlDeclaration          bool
+	indentPrefix            string
+	indent                  string
+	username                string
+	readTimeout             time.Duration
+	writeTimeout            time.Duration
+	notifIdleTimeout        time.Duration
+	maxChunkSize            int
+	warningsAsErrors        bool
+	helloTimeout            time.Duration
+	capabilityChangeUpdates bool
+	maxReplySize            int
+	timeoutHints            []TimeoutHint
+	chunked                 bool
+	// serverHello is the raw hello message received from the server.
+	serverHello []byte
+
+	// writeMu serializes the messages written to the transport.
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	reqs   map[string]*req
+	reqSeq uint64
+	// canceled are the message-ids of the requests whose call gave up on the
+	// reply (see [Session.forgetReq]) which is yet to be discarded.
+	// canceledOrder holds them oldest first to bound their number.
+	canceled      map[string]struct{}
+	canceledOrder []string
+	// forgotten is set once any call gave up on its reply.  From then on a
+	// reply with an unknown message-id may be a late reply and is never
+	// handed to another request.
+	forgotten bool
+	closing   bool
+	// done is closed once the receive loop has exited (i.e the transport is
+	// gone) and no more replies will be received.
+	done chan struct{}
+	// confirmedCommit is the pending confirmed commit issued on this session,
+	// if any.
+	confirmedCommit *pendingCommit
+	// subscription is the state of the notification subscription created
+	// with [Session.CreateSubscription] which is also sent on
+	// subscriptionStates.
+	subscription       SubscriptionState
+	subscriptionStates chan SubscriptionState
+	// notifQueue buffers the notifications passed to the handler when the
+	// subscription was created with [WithReplayBuffer].
+	notifQueue *notifQueue
+	// eventFilter is the filter set with [WithEventFilter] for the current
+	// subscription.
+	eventFilter func(xml.Name) bool
 }
 
 // NotificationHandler function allows to work with received notifications.
@@ -84,29 +518,84 @@ type ConnectionHandler func()
 func newSession(transport transport.Transport, opts ...SessionOption) *Session {
 	cfg := sessionConfig{
 		capabilities: DefaultCapabilities,
+		maxReplySize: DefaultMaxReplySize,
 	}
 
 	for _, opt := range opts {
 		opt.apply(&cfg)
 	}
 
+	if cfg.base10 {
+		caps := make([]string, 0, len(cfg.capabilities))
+		for _, c := range cfg.capabilities {
+			if c != baseCap11 {
+				caps = append(caps, c)
+			}
+		}
+		cfg.capabilities = caps
+	}
+
 	s := &Session{
-		tr:                  transport,
-		clientCaps:          newCapabilitySet(cfg.capabilities...),
-		reqs:                make(map[uint64]*req),
-		notificationHandler: cfg.notificationHandler,
-		disconnectionHandler: cfg.disconnectionHandler,
+		tr:                      transport,
+		clientCaps:              newCapabilitySet(cfg.capabilities...),
+		reqs:                    make(map[string]*req),
+		done:                    make(chan struct{}),
+		notificationHandler:     cfg.notificationHandler,
+		disconnectionHandler:    cfg.disconnectionHandler,
+		selfClosingTags:         cfg.selfClosingTags,
+		operationTimeout:        cfg.operationTimeout,
+		metricsObserver:         cfg.metricsObserver,
+		tracer:                  cfg.tracer,
+		xmlDeclaration:          cfg.xmlDeclaration,
+		indentPrefix:            cfg.indentPrefix,
+		indent:                  cfg.indent,
+		username:                cfg.username,
+		readTimeout:             cfg.readTimeout,
+		writeTimeout:            cfg.writeTimeout,
+		notifIdleTimeout:        cfg.notifIdleTimeout,
+		maxChunkSize:            cfg.maxChunkSize,
+		warningsAsErrors:        cfg.warningsAsErrors,
+		helloTimeout:            cfg.helloTimeout,
+		capabilityChangeUpdates: cfg.capabilityChangeUpdates,
+		maxReplySize:            cfg.maxReplySize,
+		timeoutHints:            cfg.timeoutHints,
+		subscriptionStates:      make(chan SubscriptionState, 1),
+	}
+
+	s.caller = CallerFunc(s.call)
+	for i := len(cfg.callMiddleware) - 1; i >= 0; i-- {
+		s.caller = cfg.callMiddleware[i](s.caller)
 	}
 	return s
 }
 
-// Open will create a new Session with th=e given transport and open it with the
-// necessary hello messages.
-func Open(transport transport.Transport, opts ...SessionOption) (*Session, error) {
+// Transport is the message oriented transport a Session runs on (see
+// [transport.Transport]).  Implementations are provided for SSH
+// ([github.com/DinbandhuKumarSingh/netconf/transport/ssh]), TLS
+// ([github.com/DinbandhuKumarSingh/netconf/transport/tls]) and any byte stream
+// ([transport.Stream]).  Any other implementation (i.e an in-memory transport
+// for testing) can be used with [Open].
+type Transport = transport.Transport
+
+// Open will create a new Session with the given transport and open it with the
+// necessary hello messages.  See [NewSession] to open a session over a byte
+// stream.
+func Open(transport Transport, opts ...SessionOption) (*Session, error) {
 	s := newSession(transport, opts...)
 
-	// this needs a timeout of some sort.
-	if err := s.handshake(); err != nil {
+	if err := s.checkDeadlines(); err != nil {
+		s.tr.Close()
+		return nil, err
+	}
+
+	if s.maxChunkSize != 0 {
+		if err := s.setMaxChunkSize(); err != nil {
+			s.tr.Close()
+			return nil, err
+		}
+	}
+
+	if err := s.timedHandshake(); err != nil {
 		s.tr.Close()
 		return nil, err
 	}
@@ -115,15 +604,106 @@ func Open(transport transport.Transport, opts ...SessionOption) (*Session, error
 	return s, nil
 }
 
+// NewSession opens a new Session over any bidirectional byte stream (i.e a
+// net.Conn, a serial port or a WebSocket connection).  The stream is wrapped in
+// a [transport.Stream] that handles the framing (including the upgrade to
+// chunked framing after the hello exchange) independently of any message
+// boundaries of the stream.  A WebSocket connection can be adapted with
+// something like `websocket.NetConn` from `nhooyr.io/websocket`.
+//
+// `ctx` bounds the hello exchange only.  The stream is closed if the session
+// cannot be opened.
+func NewSession(ctx context.Context, rwc io.ReadWriteCloser, opts ...SessionOption) (*Session, error) {
+	tr := transport.NewStream(rwc)
+
+	// abort the hello exchange by closing the stream.
+	stop := context.AfterFunc(ctx, func() { tr.Close() })
+
+	s, err := Open(tr, opts...)
+	if !stop() {
+		if err == nil {
+			s.tr.Close()
+		}
+		return nil, ctx.Err()
+	}
+	return s, err
+}
+
+// checkDeadlines returns an error if transport timeouts are configured but the
+// transport doesn't support deadlines.
+func (s *Session) checkDeadlines() error {
+	if s.readTimeout == 0 && s.writeTimeout == 0 && s.notifIdleTimeout == 0 {
+		return nil
+	}
+	if _, ok := s.tr.(transport.Deadliner); !ok {
+		return fmt.Errorf("transport timeouts require a transport with deadlines: %w", transport.ErrDeadlineUnsupported)
+	}
+	return nil
+}
+
+// setReadDeadline sets the read deadline of the transport to `d` from now.  A
+// zero duration clears the deadline.  Does nothing if no transport timeouts
+// are configured.
+func (s *Session) setReadDeadline(d time.Duration) error {
+	dl, ok := s.tr.(transport.Deadliner)
+	if !ok || (s.readTimeout == 0 && s.notifIdleTimeout == 0) {
+		return nil
+	}
+
+	var deadline time.Time
+	if d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	return dl.SetReadDeadline(deadline)
+}
+
+// updateReadDeadline sets the read deadline for the next message depending on
+// whether any rpc is waiting for it's reply.  Must be called with s.mu held.
+func (s *Session) updateReadDeadline() error {
+	if len(s.reqs) > 0 {
+		return s.setReadDeadline(s.readTimeout)
+	}
+	return s.setReadDeadline(s.notifIdleTimeout)
+}
+
+// setMaxChunkSize configures the max chunk size set with [WithMaxChunkSize] on
+// the transport.
+func (s *Session) setMaxChunkSize() error {
+	t, ok := s.tr.(interface{ SetMaxChunkSize(int) error })
+	if !ok {
+		return fmt.Errorf("transport %T does not support setting the max chunk size", s.tr)
+	}
+	return t.SetMaxChunkSize(s.maxChunkSize)
+}
+
+// timedHandshake runs the handshake bounded by the timeout set with
+// [WithHelloTimeout].  The transport is closed to abort the handshake as not
+// all transports support deadlines.
+func (s *Session) timedHandshake() error {
+	if s.helloTimeout <= 0 {
+		return s.handshake()
+	}
+
+	timer := time.AfterFunc(s.helloTimeout, func() { s.tr.Close() })
+	err := s.handshake()
+	if !timer.Stop() {
+		return fmt.Errorf("%w after %s", ErrHelloTimeout, s.helloTimeout)
+	}
+	return err
+}
+
 // handshake exchanges handshake messages and reports if there are any errors.
 func (s *Session) handshake() error {
 	clientMsg := helloMsg{
 		Capabilities: s.clientCaps.All(),
 	}
-	if err := s.writeMsg(&clientMsg); err != nil {
+	if _, err := s.writeMsg(&clientMsg); err != nil {
 		return fmt.Errorf("failed to write hello message: %w", err)
 	}
 
+	if err := s.setReadDeadline(s.readTimeout); err != nil {
+		return err
+	}
 	r, err := s.tr.MsgReader()
 	if err != nil {
 		return err
@@ -131,51 +711,153 @@ func (s *Session) handshake() error {
 	// TODO: capture this error some how (ah defer and errors)
 	defer r.Close()
 
-	var serverMsg helloMsg
-	if err := xml.NewDecoder(r).Decode(&serverMsg); err != nil {
+	raw, err := io.ReadAll(newSizeLimitReader(r, s.maxReplySize))
+	if err != nil {
 		return fmt.Errorf("failed to read server hello message: %w", err)
 	}
 
-	if serverMsg.SessionID == 0 {
-		return fmt.Errorf("server did not return a session-id")
+	serverMsg, err := parseServerHello(raw)
+	if err != nil {
+		return err
 	}
 
-	if len(serverMsg.Capabilities) == 0 {
-		return fmt.Errorf("server did not return any capabilities")
+	version, err := negotiateVersion(s.clientCaps.All(), serverMsg.Capabilities)
+	if err != nil {
+		return err
 	}
 
 	s.serverCaps = newCapabilitySet(serverMsg.Capabilities...)
 	s.sessionID = serverMsg.SessionID
+	s.serverHello = raw
 
 	// upgrade the transport if we are on a larger version and the transport
 	// supports it.
-	const baseCap11 = baseCap + ":1.1"
-	if s.serverCaps.Has(baseCap11) && s.clientCaps.Has(baseCap11) {
+	if version == baseCap11 {
 		if upgrader, ok := s.tr.(interface{ Upgrade() }); ok {
 			upgrader.Upgrade()
+			s.chunked = true
 		}
 	}
 
 	return nil
 }
 
+// parseServerHello decodes the `<hello>` message of the server checking that it
+// has the session-id assigned by the server and capabilities.
+func parseServerHello(raw []byte) (helloMsg, error) {
+	var msg helloMsg
+	if err := xml.Unmarshal(raw, &msg); err != nil {
+		return helloMsg{}, fmt.Errorf("failed to read server hello message: %w", err)
+	}
+
+	if msg.SessionID == 0 {
+		return helloMsg{}, fmt.Errorf("server did not return a session-id")
+	}
+
+	if len(msg.Capabilities) == 0 {
+		return helloMsg{}, fmt.Errorf("server did not return any capabilities")
+	}
+	return msg, nil
+}
+
+const (
+	baseCap10 = baseCap + ":1.0"
+	baseCap11 = baseCap + ":1.1"
+)
+
+// negotiateVersion returns the base capability of the NETCONF version used by
+// the session (see [RFC6241 8.1]): `urn:ietf:params:netconf:base:1.1` (chunked
+// framing) if both the client and the server advertise it, otherwise
+// `urn:ietf:params:netconf:base:1.0` which every server must support.
+//
+// [RFC6241 8.1]: https://www.rfc-editor.org/rfc/rfc6241.html#section-8.1
+func negotiateVersion(client, server []string) (string, error) {
+	clientCaps, serverCaps := newCapabilitySet(client...), newCapabilitySet(server...)
+	if !serverCaps.Has(baseCap10) && !serverCaps.Has(baseCap11) {
+		return "", fmt.Errorf("server did not advertise any base capability")
+	}
+
+	if clientCaps.Has(baseCap11) && serverCaps.Has(baseCap11) {
+		return baseCap11, nil
+	}
+	if !serverCaps.Has(baseCap10) {
+		return "", fmt.Errorf("server only supports %s which the client did not advertise", baseCap11)
+	}
+	return baseCap10, nil
+}
+
 // SessionID returns the current session ID exchanged in the hello messages.
 // Will return 0 if there is no session ID.
 func (s *Session) SessionID() uint64 {
 	return s.sessionID
 }
 
+// ServerHello returns the `<hello>` message received from the server when the
+// session was opened, exactly as sent by the server.  This gives access to
+// everything in the hello (i.e vendor specific elements or the exact
+// capability URIs) to detect the type or software version of a device.  The
+// returned slice is a copy.
+func (s *Session) ServerHello() []byte {
+	return bytes.Clone(s.serverHello)
+}
+
+// Username returns the NETCONF username of the session set with [WithUsername]
+// or derived from the client certificate by [DialTLS].  Returns an empty string
+// if it is not known.
+func (s *Session) Username() string {
+	return s.username
+}
+
+// FramingInfo describes the framing of the messages of a session (see
+// [Session.Framing]).
+type FramingInfo struct {
+	// Chunked is true if the session uses the chunked framing of NETCONF 1.1.
+	// Otherwise the end-of-message (`]]>]]>`) framing of NETCONF 1.0 is used.
+	Chunked bool
+	// MaxChunkSize is the maximum size of the chunks sent with chunked
+	// framing set with [WithMaxChunkSize].  Zero means messages are not split
+	// into chunks of a fixed size.
+	MaxChunkSize int
+}
+
+// Framing reports the framing negotiated for the session.  This is mostly
+// useful to debug interop problems with the framing of a device.
+func (s *Session) Framing() FramingInfo {
+	info := FramingInfo{Chunked: s.chunked}
+	if info.Chunked {
+		info.MaxChunkSize = s.maxChunkSize
+	}
+	return info
+}
+
 // ClientCapabilities will return the capabilities initialized with the session.
 func (s *Session) ClientCapabilities() []string {
 	return s.clientCaps.All()
 }
 
 // ServerCapabilities will return the capabilities returned by the server in
-// it's hello message.
+// it's hello message, updated by capability change notifications when enabled
+// with [WithCapabilityChangeUpdates].
 func (s *Session) ServerCapabilities() []string {
 	return s.serverCaps.All()
 }
 
+// Capabilities returns the parsed capabilities returned by the server in it's
+// hello message.  Capabilities that fail to parse are returned with only the
+// URN set to the raw capability.
+func (s *Session) Capabilities() []Capability {
+	raw := s.serverCaps.All()
+	caps := make([]Capability, 0, len(raw))
+	for _, cap := range raw {
+		c, err := ParseCapability(cap)
+		if err != nil {
+			c = Capability{URN: cap}
+		}
+		caps = append(caps, c)
+	}
+	return caps
+}
+
 // startElement will walk though a xml.Decode until it finds a start element
 // and returns it.
 func startElement(d *xml.Decoder) (*xml.StartElement, error) {
@@ -194,59 +876,204 @@ func startElement(d *xml.Decoder) (*xml.StartElement, error) {
 type req struct {
 	reply chan Reply
 	ctx   context.Context
+	// abort fails the call waiting for the reply with the error sent on it.
+	abort chan error
+	// seq orders the requests by the time they were issued.
+	seq uint64
+	// raw is set if the complete reply message should be kept (see
+	// [Session.CallWithReply]).
+	raw bool
+	// stream is set if the `<data>` of the reply should be streamed (see
+	// [Session.GetConfigStream]).
+	stream bool
+}
+
+// rawCapture buffers the bytes of a message read from the transport until it
+// is disabled.
+type rawCapture struct {
+	bytes.Buffer
+	off bool
+}
+
+func (c *rawCapture) Write(p []byte) (int, error) {
+	if c.off {
+		return len(p), nil
+	}
+	return c.Buffer.Write(p)
 }
 
 func (s *Session) recvMsg() error {
+	s.mu.Lock()
+	err := s.updateReadDeadline()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
 	r, err := s.tr.MsgReader()
 	if err != nil {
 		return err
 	}
 	defer r.Close()
-	dec := xml.NewDecoder(r)
+
+	// capture the raw message until we know if the request wants it.
+	var capture rawCapture
+	limit := newSizeLimitReader(r, s.maxReplySize)
+	tee := io.TeeReader(limit, &capture)
+	dec := xml.NewDecoder(tee)
 
 	root, err := startElement(dec)
 	if err != nil {
 		return err
 	}
 
-	const (
-		ncNamespace    = "urn:ietf:params:xml:ns:netconf:base:1.0"
-		notifNamespace = "urn:ietf:params:xml:ns:netconf:notification:1.0"
-	)
-
 	switch root.Name {
-	case xml.Name{Space: notifNamespace, Local: "notification"}:
-		if s.notificationHandler == nil {
-			return nil
-		}
+	case xml.Name{Space: NamespaceNotification, Local: "notification"}:
 		var notif Notification
 		if err := dec.DecodeElement(&notif, root); err != nil {
 			return fmt.Errorf("failed to decode notification message: %w", err)
 		}
-		s.notificationHandler(notif)
-	case xml.Name{Space: ncNamespace, Local: "rpc-reply"}:
+		if err := notif.decodeEvent(); err != nil {
+			log.Printf("netconf: %v", err)
+		}
+		if event, ok := notif.Event.(*CapabilityChangeEvent); ok && s.capabilityChangeUpdates {
+			s.serverCaps.Remove(event.Deleted...)
+			s.serverCaps.Replace(event.Modified...)
+			s.serverCaps.Add(event.Added...)
+		}
+
+		s.mu.Lock()
+		filter := s.eventFilter
+		s.mu.Unlock()
+		complete := isNotificationComplete(notif)
+		// the end of the subscription is always delivered.
+		deliver := complete || filter == nil || filter(eventName(notif))
+
+		s.mu.Lock()
+		if s.subscription.Status == SubscriptionActive {
+			state := s.subscription
+			if complete {
+				state.Status = SubscriptionCompleted
+			} else {
+				state.Events++
+				if !deliver {
+					state.Filtered++
+				}
+			}
+			s.setSubscription(state)
+		}
+		s.mu.Unlock()
+		if deliver {
+			s.deliverNotification(notif)
+		}
+	case xml.Name{Space: NamespaceBase10, Local: "rpc-reply"}:
+		pending := s.pendingReq(root)
+		if pending != nil && pending.stream {
+			limit.off = true
+			return s.recvStream(dec, root, &capture, tee)
+		}
+
+		keepRaw := pending != nil && pending.raw
+		if !keepRaw {
+			capture.off = true
+			capture.Reset()
+		}
+
 		var reply Reply
 		if err := dec.DecodeElement(&reply, root); err != nil {
+			s.failReply(root, err)
 			// What should we do here?  Kill the connection?
 			return fmt.Errorf("failed to decode rpc-reply message: %w", err)
 		}
-		ok, req := s.req(reply.MessageID)
-		if !ok {
-			return fmt.Errorf("cannot find reply channel for message-id: %d", reply.MessageID)
-		}
+		reply.size = int(dec.InputOffset())
 
-		select {
-		case req.reply <- reply:
-			return nil
-		case <-req.ctx.Done():
-			return fmt.Errorf("message %d context canceled: %s", reply.MessageID, req.ctx.Err().Error())
+		if keepRaw {
+			if _, err := io.Copy(io.Discard, tee); err != nil {
+				s.failReply(root, err)
+				return fmt.Errorf("failed to read rpc-reply message: %w", err)
+			}
+			reply.raw = capture.Bytes()
 		}
+
+		return s.deliver(reply)
 	default:
 		return fmt.Errorf("unknown message type: %q", root.Name.Local)
 	}
 	return nil
 }
 
+// failReply fails the request waiting for the reply starting with `root` if
+// reading the reply failed because it is too large.  Other errors keep the
+// request waiting as before.
+func (s *Session) failReply(root *xml.StartElement, err error) {
+	if !errors.Is(err, ErrReplyTooLarge) {
+		return
+	}
+	for _, attr := range root.Attr {
+		if attr.Name.Local == "message-id" {
+			_ = s.deliver(Reply{
+				MessageID:    numericMessageID(attr.Value),
+				RawMessageID: attr.Value,
+				err:          err,
+			})
+			return
+		}
+	}
+}
+
+// deliver hands `reply` to the request waiting for it.
+func (s *Session) deliver(reply Reply) error {
+	ok, req := s.req(reply.RawMessageID)
+	if !ok {
+		return fmt.Errorf("cannot find reply channel for message-id: %q", reply.RawMessageID)
+	}
+
+	select {
+	case req.reply <- reply:
+		return nil
+	case <-req.ctx.Done():
+		return fmt.Errorf("message %q context canceled: %s", reply.RawMessageID, req.ctx.Err().Error())
+	}
+}
+
+// isNotificationComplete reports if `notif` is the `<notificationComplete>`
+// notification sent when a subscription ends (see [RFC5277 2.2.1]).
+//
+// [RFC5277 2.2.1]: https://www.rfc-editor.org/rfc/rfc5277.html#section-2.2.1
+func isNotificationComplete(notif Notification) bool {
+	dec := xml.NewDecoder(bytes.NewReader(notif.Body))
+	for {
+		start, err := startElement(dec)
+		if err != nil {
+			return false
+		}
+		if start.Name.Local == "notificationComplete" && start.Name.Space == NamespaceNetmodNotification {
+			return true
+		}
+		if err := dec.Skip(); err != nil {
+			return false
+		}
+	}
+}
+
+// eventName returns the name of the event element of `notif` (i.e
+// `<netconf-config-change>`), the first one following the eventTime.
+func eventName(notif Notification) xml.Name {
+	dec := xml.NewDecoder(bytes.NewReader(notif.Body))
+	for {
+		start, err := startElement(dec)
+		if err != nil {
+			return xml.Name{}
+		}
+		if start.Name.Local != "eventTime" {
+			return start.Name
+		}
+		if err := dec.Skip(); err != nil {
+			return xml.Name{}
+		}
+	}
+}
+
 // recv is the main receive loop.  It runs concurrently to be able to handle
 // interleaved messages (like notifications).
 func (s *Session) recv() {
@@ -255,7 +1082,14 @@ func (s *Session) recv() {
 
 	for {
 		err = s.recvMsg()
-		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &opErr) {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			// the connection is considered dead so make sure nothing else can
+			// be written to it either.
+			log.Printf("netconf: transport read timeout: %v", err)
+			s.tr.Close()
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &opErr) ||
+			errors.Is(err, os.ErrDeadlineExceeded) {
 			if s.disconnectionHandler!=nil{
 				s.disconnectionHandler()
 			}
@@ -272,53 +1106,300 @@ func (s *Session) recv() {
 	for _, req := range s.reqs {
 		close(req.reply)
 	}
+	close(s.done)
+
+	if s.subscription.Status == SubscriptionActive {
+		state := s.subscription
+		state.Status = SubscriptionTerminated
+		if !s.closing {
+			state.Err = err
+		}
+		s.setSubscription(state)
+	}
+	close(s.subscriptionStates)
+	if s.notifQueue != nil {
+		s.notifQueue.close()
+	}
 
 	if !s.closing {
 		log.Printf("netconf: connection closed unexpectedly")
 	}
 }
 
-func (s *Session) req(msgID uint64) (bool, *req) {
+// pendingReq returns the request waiting for the `<rpc-reply>` starting with
+// `root`, if any.
+func (s *Session) pendingReq(root *xml.StartElement) *req {
+	for _, attr := range root.Attr {
+		if attr.Name.Local != "message-id" {
+			continue
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.reqs[attr.Value]
+	}
+	return nil
+}
+
+func (s *Session) req(msgID string) (bool, *req) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	req, ok := s.reqs[msgID]
-	if !ok {
+	if _, canceled := s.canceled[msgID]; !ok && canceled {
+		delete(s.canceled, msgID)
 		return false, nil
 	}
+	if !ok {
+		// A reply for an unknown message-id while there is exactly one
+		// outstanding request is most likely the device echoing back the wrong
+		// message-id.  Hand the reply to that request so the mismatch can be
+		// reported to the caller instead of leaving it waiting forever.  This
+		// is only safe as long as no call gave up on its reply, otherwise it
+		// may be the late reply of that call.
+		if len(s.reqs) != 1 || s.forgotten {
+			return false, nil
+		}
+		for id, r := range s.reqs {
+			msgID, req = id, r
+		}
+	}
 	delete(s.reqs, msgID)
 	return true, req
 }
 
-func (s *Session) writeMsg(v any) error {
+// maxPooledBufSize is the size above which encode buffers are not reused so
+// that a single large request doesn't pin its memory.
+const maxPooledBufSize = 1 << 20
+
+// encodeBuf is a buffer requests are encoded into along with an encoder
+// writing to it which is kept as creating one is costly.
+type encodeBuf struct {
+	bytes.Buffer
+	enc *xml.Encoder
+}
+
+// encodeBufPool holds the encodeBufs.  Each one is only used by a single
+// writeMsg at a time.
+var encodeBufPool = sync.Pool{
+	New: func() any { return new(encodeBuf) },
+}
+
+func getEncodeBuf() *encodeBuf {
+	buf := encodeBufPool.Get().(*encodeBuf)
+	buf.Reset()
+	if buf.enc == nil {
+		buf.enc = xml.NewEncoder(&buf.Buffer)
+	}
+	return buf
+}
+
+func putEncodeBuf(buf *encodeBuf) {
+	if buf.Cap() > maxPooledBufSize {
+		return
+	}
+	encodeBufPool.Put(buf)
+}
+
+// writeMsg encodes and writes a message to the transport returning the number
+// of bytes written.
+func (s *Session) writeMsg(v any) (int, error) {
+	// encode the message up front so that it can be post-processed before
+	// being framed by the transport.  The buffer is reused by later messages
+	// so nothing may keep a reference to it once the message is written.
+	buf := getEncodeBuf()
+	defer putEncodeBuf(buf)
+	if s.xmlDeclaration {
+		buf.WriteString(xml.Header)
+	}
+
+	// the indentation state of an encoder carries over to the next message so
+	// a new one is used when indenting or collapsing empty elements.
+	enc := buf.enc
+	var selfClosing *selfClosingWriter
+	if s.indentPrefix != "" || s.indent != "" || s.selfClosingTags {
+		var w io.Writer = &buf.Buffer
+		if s.selfClosingTags {
+			selfClosing = &selfClosingWriter{w: &buf.Buffer}
+			w = selfClosing
+		}
+		enc = xml.NewEncoder(w)
+		enc.Indent(s.indentPrefix, s.indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		// the encoder may be left in the middle of an element.
+		buf.enc = nil
+		return 0, err
+	}
+	if selfClosing != nil {
+		selfClosing.Flush()
+	}
+
+	msg := buf.Bytes()
+
+	var streams []*streamedXML
+	if req, ok := v.(*request); ok {
+		streams = req.streams
+	}
+
+	// messages can't be interleaved on the transport.
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if dl, ok := s.tr.(transport.Deadliner); ok && s.writeTimeout > 0 {
+		if err := dl.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
+			return 0, &TransportError{Err: err}
+		}
+	}
+
 	w, err := s.tr.MsgWriter()
 	if err != nil {
-		return err
+		return 0, &TransportError{Err: err}
 	}
-	defer w.Close()
 
-	if err := xml.NewEncoder(w).Encode(v); err != nil {
-		return err
+	n := len(msg)
+	if len(streams) > 0 {
+		cw := &countingWriter{w: w}
+		if err := writeStreamed(cw, msg, streams); err != nil {
+			return cw.n, s.abortMsg(w, err)
+		}
+		n = cw.n
+	} else if _, err := w.Write(msg); err != nil {
+		return 0, s.abortMsg(w, &TransportError{Err: err})
 	}
-	return nil
+
+	if err := w.Close(); err != nil {
+		return n, &TransportError{Err: err}
+	}
+	return n, nil
 }
 
-func (s *Session) send(ctx context.Context, msg *request) (chan Reply, error) {
+// abortMsg aborts the message being written to `w` after writing failed with
+// `err`.
+//
+// A message containing the end-of-message delimiter is rejected by the
+// transport before it is sent, which isn't a transport error.  Otherwise part
+// of the message may have been sent already so the transport is closed without
+// finishing the message, as the device would process the truncated rpc and
+// reply with an error no call is waiting for.
+func (s *Session) abortMsg(w io.WriteCloser, err error) error {
+	if errors.Is(err, transport.ErrDelimiterInMessage) {
+		closeErr := w.Close()
+		if closeErr == nil || closeErr == transport.ErrDelimiterInMessage {
+			return transport.ErrDelimiterInMessage
+		}
+		err = &TransportError{Err: closeErr}
+	}
+
+	s.tr.Close()
+	_ = w.Close()
+	return err
+}
+
+func (s *Session) send(ctx context.Context, msg *request) (*req, int, error) {
+	r, err := s.addReq(ctx, msg.MessageID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the message is written without holding s.mu so that streaming a large
+	// config doesn't block the receive loop or other calls waiting for their
+	// replies.
+	n, err := s.writeMsg(msg)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if err != nil {
+		if s.reqs[msg.MessageID] == r {
+			delete(s.reqs, msg.MessageID)
+		}
+		return nil, n, err
+	}
 
-	if err := s.writeMsg(msg); err != nil {
-		return nil, err
+	// the receive loop may already be waiting for the next message without
+	// a deadline.
+	if s.readTimeout > 0 {
+		if err := s.setReadDeadline(s.readTimeout); err != nil {
+			delete(s.reqs, msg.MessageID)
+			return nil, n, &TransportError{Err: err}
+		}
+	}
+
+	return r, n, nil
+}
+
+// addReq registers the request waiting for the reply with the message-id `id`
+// before the request is written.
+func (s *Session) addReq(ctx context.Context, id string) (*req, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// no replies can be received anymore so don't wait for one.
+	select {
+	case <-s.done:
+		return nil, &TransportError{Err: ErrClosed}
+	default:
+	}
+
+	if _, ok := s.reqs[id]; ok {
+		return nil, fmt.Errorf("%w: %q", ErrDuplicateMessageID, id)
 	}
 
-	// cap of 1 makes sure we don't block on send
-	ch := make(chan Reply, 1)
-	s.reqs[msg.MessageID] = &req{
-		reply: ch,
-		ctx:   ctx,
+	s.reqSeq++
+	r := &req{
+		// cap of 1 makes sure we don't block on send
+		reply:  make(chan Reply, 1),
+		ctx:    ctx,
+		abort:  make(chan error, 1),
+		seq:    s.reqSeq,
+		raw:    ctx.Value(rawReplyKey{}) != nil,
+		stream: ctx.Value(streamReplyKey{}) != nil,
 	}
+	s.reqs[id] = r
+	return r, nil
+}
 
-	return ch, nil
+// messageIDKey is the context key for the message-id set with
+// [WithMessageID].
+type messageIDKey struct{}
+
+// WithMessageID returns a copy of `ctx` that issues a request with the given
+// `message-id` instead of a generated one.  It is honored by [Session.Do] and
+// so by [Session.Call] and every operation method.  This is useful to correlate
+// requests with external systems or when replaying captured sessions.
+//
+// The reply is matched by this id.  Issuing a request with a id that is already
+// used by a request waiting for it's reply fails with [ErrDuplicateMessageID].
+// Requests without a id use increasing numbers starting at 1 which should be
+// avoided for explicit ids.
+func WithMessageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, messageIDKey{}, id)
+}
+
+// rpcAttrsKey is the context key for the attributes set with [WithRPCAttr].
+type rpcAttrsKey struct{}
+
+// WithRPCAttr returns a copy of `ctx` that adds the given attribute to the
+// `<rpc>` element of the request.  It is honored by [Session.Do] and so by
+// [Session.Call] and every operation method.  Calling it multiple times adds
+// all of the attributes.  This is needed for some vendor dialects that require
+// extra attributes or namespace declarations on the envelope:
+//
+//	ctx = netconf.WithRPCAttr(ctx, xml.Attr{Name: xml.Name{Space: "xmlns", Local: "junos"}, Value: "http://xml.juniper.net/junos/*/junos"})
+//
+// Namespace declarations can be given either with the `xmlns` space as above or
+// as `xmlns:junos` in the local name.  The `message-id` attribute is set by the
+// session and cannot be overridden.
+func WithRPCAttr(ctx context.Context, attr xml.Attr) context.Context {
+	if attr.Name.Space == "xmlns" {
+		// encoding/xml would treat `xmlns` as a namespace url and declare a
+		// prefix for it so write the declaration as-is instead.
+		attr.Name = xml.Name{Local: "xmlns:" + attr.Name.Local}
+	}
+
+	prev, _ := ctx.Value(rpcAttrsKey{}).([]xml.Attr)
+	attrs := make([]xml.Attr, len(prev), len(prev)+1)
+	copy(attrs, prev)
+	return context.WithValue(ctx, rpcAttrsKey{}, append(attrs, attr))
 }
 
 // Do issues a rpc call for the given NETCONF operation returning a Reply.  RPC
@@ -326,57 +1407,288 @@ func (s *Session) send(ctx context.Context, msg *request) (chan Reply, error) {
 // converted into go errors automatically.  Instead use `reply.Err()` or
 // `reply.RPCErrors` to access the errors and/or warnings.
 func (s *Session) Do(ctx context.Context, req any) (*Reply, error) {
-	msg := &request{
-		MessageID: s.seq.Add(1),
-		Operation: req,
+	if _, ok := ctx.Deadline(); !ok && s.operationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.operationTimeout)
+		defer cancel()
 	}
 
-	ch, err := s.send(ctx, msg)
+	if err := s.checkInterleave(); err != nil {
+		return nil, err
+	}
+
+	msgID, ok := ctx.Value(messageIDKey{}).(string)
+	switch {
+	case !ok:
+		msgID = strconv.FormatUint(s.seq.Add(1), 10)
+	case msgID == "":
+		return nil, errors.New("netconf: message-id cannot be empty")
+	}
+
+	attrs, _ := ctx.Value(rpcAttrsKey{}).([]xml.Attr)
+	for _, attr := range attrs {
+		if attr.Name == (xml.Name{Local: "message-id"}) {
+			return nil, errors.New("netconf: message-id cannot be set with WithRPCAttr")
+		}
+	}
+
+	op, err := s.applyTimeoutHint(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
+	streams, _ := ctx.Value(streamsKey{}).([]*streamedXML)
+	msg := &request{
+		MessageID: msgID,
+		Attrs:     attrs,
+		Operation: op,
+		streams:   streams,
+	}
+
+	var (
+		reply *Reply
+		sent  int
+	)
+	if s.metricsObserver != nil {
+		start := time.Now()
+		defer func() {
+			s.observe(req, msg.MessageID, start, sent, reply, err)
+		}()
+	}
+
+	if s.tracer != nil {
+		var span Span
+		ctx, span = s.startSpan(ctx, req, msg.MessageID)
+		defer func() { endSpan(span, reply, err) }()
+	}
+
+	reply, sent, err = s.do(ctx, msg)
+	return reply, err
+}
+
+// checkInterleave returns [ErrNotInterleaved] if a notification subscription is
+// active and the device doesn't support `:interleave`.  Closing the session is
+// always allowed.
+func (s *Session) checkInterleave() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscription.Status != SubscriptionActive || s.closing {
+		return nil
+	}
+	if _, ok := s.serverCaps.Lookup(":interleave"); ok {
+		return nil
+	}
+	return ErrNotInterleaved
+}
+
+func (s *Session) do(ctx context.Context, msg *request) (*Reply, int, error) {
+	r, sent, err := s.send(ctx, msg)
+	if err != nil {
+		return nil, sent, err
+	}
+
 	// wait for reply or context to be cancelled.
 	select {
-	case reply, ok := <-ch:
+	case reply, ok := <-r.reply:
 		if !ok {
-			return nil, ErrClosed
+			return nil, sent, &TransportError{Err: ErrClosed}
+		}
+		if reply.err != nil {
+			return nil, sent, reply.err
 		}
-		return &reply, nil
+		if reply.RawMessageID != msg.MessageID {
+			return nil, sent, ErrMessageIDMismatch{
+				Sent:        numericMessageID(msg.MessageID),
+				Received:    reply.MessageID,
+				RawSent:     msg.MessageID,
+				RawReceived: reply.RawMessageID,
+			}
+		}
+		return &reply, sent, nil
 	case <-ctx.Done():
 		// remove any existing request
 		s.mu.Lock()
-		delete(s.reqs, msg.MessageID)
+		if s.reqs[msg.MessageID] == r {
+			s.forgetReq(msg.MessageID)
+		}
+		// don't time out the session waiting for the canceled reply.
+		_ = s.updateReadDeadline()
 		s.mu.Unlock()
 
-		return nil, ctx.Err()
+		return nil, sent, ctx.Err()
+	case err := <-r.abort:
+		return nil, sent, err
+	}
+}
+
+// InFlight returns the message-ids of the requests waiting for their reply in
+// the order they were issued.
+func (s *Session) InFlight() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.reqs))
+	for id := range s.reqs {
+		ids = append(ids, id)
 	}
+	sort.Slice(ids, func(i, j int) bool { return s.reqs[ids[i]].seq < s.reqs[ids[j]].seq })
+	return ids
+}
+
+// CancelInFlight stops waiting for the replies of the requests with the given
+// message-ids (see [Session.InFlight]), or of all of them if none are given.
+// Their calls return [ErrRPCCanceled] and replies received for them later are
+// discarded.  The operations are not aborted on the device.  It returns the
+// number of requests canceled, ids without a waiting request are ignored.
+func (s *Session) CancelInFlight(ids ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.abortInFlight(ErrRPCCanceled, ids...)
+}
+
+// maxCanceled is the number of message-ids of forgotten requests kept to
+// discard their late replies.
+const maxCanceled = 1024
+
+// forgetReq removes the request waiting for the reply with the message-id
+// `id` after its call gave up on it.  The id is kept so that a late reply is
+// discarded instead of being handed to another request.  s.mu must be held.
+func (s *Session) forgetReq(id string) {
+	delete(s.reqs, id)
+	if s.canceled == nil {
+		s.canceled = make(map[string]struct{})
+	}
+	s.canceled[id] = struct{}{}
+	s.canceledOrder = append(s.canceledOrder, id)
+	s.forgotten = true
+
+	// devices may never answer canceled requests so only the most recent ids
+	// are kept.  A late reply for an older one is still not handed to another
+	// request as the session has forgotten a reply.
+	for len(s.canceledOrder) > maxCanceled {
+		delete(s.canceled, s.canceledOrder[0])
+		s.canceledOrder = s.canceledOrder[1:]
+	}
+}
+
+// abortInFlight fails the calls waiting for the replies of the given requests
+// (or all of them) with `err`.  s.mu must be held.
+func (s *Session) abortInFlight(err error, ids ...string) int {
+	if len(ids) == 0 {
+		for id := range s.reqs {
+			ids = append(ids, id)
+		}
+	}
+
+	var n int
+	for _, id := range ids {
+		req, ok := s.reqs[id]
+		if !ok {
+			continue
+		}
+		s.forgetReq(id)
+		req.abort <- err
+		n++
+	}
+	if n > 0 {
+		// don't time out the session waiting for the aborted replies.
+		_ = s.updateReadDeadline()
+	}
+	return n
 }
 
 // Call issues a rpc message with `req` as the body and decodes the reponse into
 // a pointer at `resp`.  Any Call errors are presented as a go error.
 func (s *Session) Call(ctx context.Context, req any, resp any) error {
+	return s.caller.Call(ctx, req, resp)
+}
+
+// rawReplyKey is the context key for the destination of the raw reply of
+// [Session.CallWithReply].
+type rawReplyKey struct{}
+
+// CallWithReply is like [Session.Call] but also returns the complete
+// `<rpc-reply>` message as received from the device.  This gives access to
+// warnings and vendor specific elements or attributes of a reply that are
+// otherwise discarded (i.e next to a `<ok/>`).  `raw` is returned for replies
+// with rpc-errors as well.
+//
+// Replies are only kept in raw form for requests issued with CallWithReply.
+func (s *Session) CallWithReply(ctx context.Context, req any, resp any) (raw []byte, err error) {
+	ctx = context.WithValue(ctx, rawReplyKey{}, &raw)
+	err = s.Call(ctx, req, resp)
+	return raw, err
+}
+
+// replyErr returns the rpc-errors of the reply that fail the operation as
+// configured with [WithWarningsAsErrors].
+func (s *Session) replyErr(reply *Reply) error {
+	if s.warningsAsErrors {
+		return reply.Err(SevError, SevWarning)
+	}
+	return reply.Err()
+}
+
+func (s *Session) call(ctx context.Context, req any, resp any) error {
 	reply, err := s.Do(ctx, &req)
 	if err != nil {
 		return err
 	}
 
-	if err := reply.Err(); err != nil {
-		return err
+	if raw, ok := ctx.Value(rawReplyKey{}).(*[]byte); ok {
+		*raw = reply.raw
 	}
 
-	if err := reply.Decode(&resp); err != nil {
+	if err := s.replyErr(reply); err != nil {
+		if ok, _ := reply.hasOK(); ok {
+			return &PartialSuccessError{Errors: reply.Errors}
+		}
 		return err
 	}
 
-	return nil
+	// the remaining rpc-errors are warnings that come with the `<ok/>`.
+	if ok, isOK := resp.(*OKResp); isOK && len(reply.Errors) > 0 {
+		ok.Warnings = reply.Errors
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(reply.Body))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			// an empty reply leaves resp untouched.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		// rpc-errors with a warning severity may come before the contents of
+		// the reply and a `<ok/>` may come after any vendor specific elements.
+		_, wantOK := resp.(*OKResp)
+		if start.Name.Local == "rpc-error" || (wantOK && start.Name.Local != "ok") {
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return dec.DecodeElement(&resp, &start)
+	}
 }
 
 // Close will gracefully close the sessions first by sending a `close-session`
-// operation to the remote and then closing the underlying transport
+// operation to the remote and then closing the underlying transport.  Calls
+// still waiting for their reply fail with [ErrSessionClosed].
 func (s *Session) Close(ctx context.Context) error {
 	s.mu.Lock()
 	s.closing = true
+	s.abortInFlight(ErrSessionClosed)
 	s.mu.Unlock()
 
 	type closeSession struct {
@@ -1,10 +1,21 @@
 package netconf
 
 import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/DinbandhuKumarSingh/netconf/transport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,9 +27,11 @@ type testServer struct {
 
 func newTestServer(t *testing.T) *testServer {
 	return &testServer{
-		t:   t,
-		in:  make(chan []byte),
-		out: make(chan []byte),
+		t: t,
+		// buffered so received requests are popped in order.
+		in: make(chan []byte, 16),
+		// buffered so queued responses are sent in order.
+		out: make(chan []byte, 16),
 	}
 }
 
@@ -28,7 +41,11 @@ func (s *testServer) handle(r io.ReadCloser, w io.WriteCloser) {
 		panic(fmt.Sprintf("testerver: failed to read incomming message: %v", err))
 	}
 	s.t.Logf("testserver recv: %s", in)
-	go func() { s.in <- in }()
+	select {
+	case s.in <- in:
+	default:
+		go func() { s.in <- in }()
+	}
 
 	out, ok := <-s.out
 	if !ok {
@@ -46,7 +63,7 @@ func (s *testServer) handle(r io.ReadCloser, w io.WriteCloser) {
 	}
 }
 
-func (s *testServer) queueResp(p []byte)         { go func() { s.out <- p }() }
+func (s *testServer) queueResp(p []byte)         { s.out <- p }
 func (s *testServer) queueRespString(str string) { s.queueResp([]byte(str)) }
 func (s *testServer) popReq() ([]byte, error) {
 	msg, ok := <-s.in
@@ -120,6 +137,14 @@ const (
   </capabilities>
 </hello>`
 
+	helloNoBase = `
+<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+  <capabilities>
+	<capability>urn:ietf:params:netconf:capability:candidate:1.0</capability>
+  </capabilities>
+  <session-id>42</session-id>
+</hello>`
+
 	helloNoCaps = `
 <hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
   <capabilities></capabilities>
@@ -138,6 +163,7 @@ func TestHello(t *testing.T) {
 		{"bad xml", helloBadXML, true, 0},
 		{"no capabilities", helloNoCaps, true, 0},
 		{"no session-id", helloNoSessID, true, 0},
+		{"no base", helloNoBase, true, 0},
 	}
 
 	for _, tc := range tt {
@@ -158,3 +184,753 @@ func TestHello(t *testing.T) {
 		})
 	}
 }
+
+func TestNegotiateVersion(t *testing.T) {
+	tt := []struct {
+		name    string
+		client  []string
+		server  []string
+		want    string
+		wantErr bool
+	}{
+		{"both11", DefaultCapabilities, []string{baseCap10, baseCap11}, baseCap11, false},
+		{"server10", DefaultCapabilities, []string{baseCap10, ":candidate"}, baseCap10, false},
+		{"server11Only", DefaultCapabilities, []string{baseCap11}, baseCap11, false},
+		{"client10", []string{baseCap10}, []string{baseCap10, baseCap11}, baseCap10, false},
+		{"serverNoBase", DefaultCapabilities, []string{":candidate"}, "", true},
+		{"client10Server11Only", []string{baseCap10}, []string{baseCap11}, "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := negotiateVersion(tc.client, tc.server)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBase10(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithBase10())
+	assert.NotContains(t, sess.ClientCapabilities(), baseCap11)
+	assert.Contains(t, sess.ClientCapabilities(), baseCap10)
+
+	ts.queueRespString(helloGood)
+	assert.NoError(t, sess.handshake())
+	assert.False(t, sess.Framing().Chunked)
+	assert.Equal(t, uint64(42), sess.SessionID())
+
+	hello, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.NotContains(t, hello, baseCap11)
+}
+
+func TestNewSession(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		go io.Copy(io.Discard, server)
+		io.WriteString(server, helloGood+"]]>]]>")
+	}()
+
+	sess, err := NewSession(context.Background(), client)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sess.tr.Close()
+	assert.Equal(t, uint64(42), sess.SessionID())
+
+	hello := sess.ServerHello()
+	assert.Equal(t, helloGood, string(hello))
+
+	// the returned hello is a copy.
+	hello[0] = 'x'
+	assert.Equal(t, helloGood, string(sess.ServerHello()))
+}
+
+func TestNewSessionCanceled(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	// never reply with a hello.
+	go io.Copy(io.Discard, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := NewSession(ctx, client)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestHelloTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	// never reply with a hello.
+	go io.Copy(io.Discard, server)
+
+	start := time.Now()
+	_, err := NewSession(context.Background(), client, WithHelloTimeout(50*time.Millisecond))
+	assert.ErrorIs(t, err, ErrHelloTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// the transport is closed.
+	_, err = client.Write([]byte("x"))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+	// a hello within the timeout opens the session.
+	client, server = net.Pipe()
+	defer server.Close()
+	go func() {
+		go io.Copy(io.Discard, server)
+		io.WriteString(server, helloGood+"]]>]]>")
+	}()
+
+	sess, err := NewSession(context.Background(), client, WithHelloTimeout(time.Second))
+	if assert.NoError(t, err) {
+		sess.tr.Close()
+	}
+}
+
+// serveStream runs a minimal NETCONF server on `conn` that exchanges hellos
+// (sending `hello`) and then calls `reply` for every request.  Requests are not
+// replied to if reply returns an empty string.
+func serveStream(conn io.ReadWriteCloser, hello string, reply func(n int) string) {
+	tr := transport.NewStream(conn)
+	defer tr.Close()
+
+	readMsg := func() error {
+		r, err := tr.MsgReader()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(io.Discard, r)
+		return err
+	}
+	writeMsg := func(msg string) error {
+		w, err := tr.MsgWriter()
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, msg); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+
+	if readMsg() != nil || writeMsg(hello) != nil {
+		return
+	}
+	if strings.Contains(hello, "base:1.1") {
+		tr.Upgrade()
+	}
+
+	for n := 1; ; n++ {
+		if readMsg() != nil {
+			return
+		}
+		if msg := reply(n); msg != "" {
+			if writeMsg(msg) != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestTransportTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	go serveStream(server, helloGood, func(n int) string {
+		// never reply to the second request.
+		if n == 2 {
+			return ""
+		}
+		return fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><ok/></rpc-reply>`, n)
+	})
+
+	sess, err := NewSession(context.Background(), client, WithTransportTimeout(50*time.Millisecond, time.Second))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sess.tr.Close()
+
+	// an idle session waiting for notifications must not time out.
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, sess.Lock(context.Background(), Candidate))
+
+	err = sess.Lock(context.Background(), Candidate)
+	assert.True(t, IsTransportError(err), "expected transport error, got %v", err)
+
+	// the session is closed after the timeout.
+	err = sess.Lock(context.Background(), Candidate)
+	assert.True(t, IsTransportError(err), "expected transport error, got %v", err)
+}
+
+func TestNotificationIdleTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	go serveStream(server, helloGood, func(int) string { return "" })
+
+	disconnected := make(chan struct{})
+	sess, err := NewSession(context.Background(), client,
+		WithNotificationIdleTimeout(50*time.Millisecond),
+		WithDisconnectHandler(func() { close(disconnected) }))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sess.tr.Close()
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("session was not closed after the notification idle timeout")
+	}
+}
+
+func TestTransportTimeoutUnsupported(t *testing.T) {
+	ts := newTestServer(t)
+	_, err := Open(ts.transport(), WithTransportTimeout(time.Second, time.Second))
+	assert.ErrorIs(t, err, transport.ErrDeadlineUnsupported)
+}
+
+func TestDelimiterInMessage(t *testing.T) {
+	const hello10 = `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">` +
+		`<capabilities><capability>urn:ietf:params:netconf:base:1.0</capability>` +
+		`<capability>urn:ietf:params:netconf:capability:writable-running:1.0</capability></capabilities>` +
+		`<session-id>42</session-id></hello>`
+
+	client, server := net.Pipe()
+	go serveStream(server, hello10, func(n int) string {
+		return fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><ok/></rpc-reply>`, n+1)
+	})
+
+	sess, err := NewSession(context.Background(), client)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sess.tr.Close()
+
+	err = sess.EditConfig(context.Background(), Running, "<system><motd>]]>]]></motd></system>")
+	assert.ErrorIs(t, err, transport.ErrDelimiterInMessage)
+	assert.False(t, IsTransportError(err))
+
+	// nothing was sent so the session can still be used.
+	assert.NoError(t, sess.Lock(context.Background(), Running))
+}
+
+func TestMaxChunkSize(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	var raw bytes.Buffer
+	go func() {
+		f := transport.NewFramer(server, server)
+		f.DebugCapture(&raw, nil)
+
+		r, _ := f.MsgReader()
+		io.Copy(io.Discard, r)
+		w, _ := f.MsgWriter()
+		io.WriteString(w, helloGood)
+		w.Close()
+		f.Upgrade()
+
+		r, _ = f.MsgReader()
+		io.Copy(io.Discard, r)
+		w, _ = f.MsgWriter()
+		io.WriteString(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		w.Close()
+	}()
+
+	sess, err := NewSession(context.Background(), client, WithMaxChunkSize(16))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sess.tr.Close()
+	assert.Equal(t, FramingInfo{Chunked: true, MaxChunkSize: 16}, sess.Framing())
+
+	assert.NoError(t, sess.Lock(context.Background(), Candidate))
+
+	_, rpc, ok := strings.Cut(raw.String(), "]]>]]>")
+	assert.True(t, ok)
+	sizes := regexp.MustCompile(`\n#(\d+)\n`).FindAllStringSubmatch(rpc, -1)
+	assert.Greater(t, len(sizes), 1)
+	for _, size := range sizes {
+		n, err := strconv.Atoi(size[1])
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, n, 16)
+	}
+}
+
+func TestMaxChunkSizeInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	_, err := Open(ts.transport(), WithMaxChunkSize(-1))
+	assert.Error(t, err)
+}
+
+func TestCallWithReply(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	const reply = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:junos="http://xml.juniper.net/junos/23.2R1/junos" message-id="1">` +
+		`<junos:warning>statement has no contents; ignored</junos:warning><ok/></rpc-reply>`
+	ts.queueRespString(reply)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	var resp OKResp
+	raw, err := sess.CallWithReply(context.Background(), &LockReq{Target: Candidate}, &resp)
+	assert.NoError(t, err)
+	assert.True(t, bool(resp.OK))
+	assert.Equal(t, reply, string(raw))
+
+	// plain calls don't keep the raw reply
+	resp = OKResp{}
+	err = sess.Call(context.Background(), &LockReq{Target: Candidate}, &resp)
+	assert.NoError(t, err)
+	assert.True(t, bool(resp.OK))
+}
+
+func TestWithMessageID(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ctx := WithMessageID(context.Background(), "bus-42")
+
+	errCh := make(chan error, 1)
+	go func() {
+		var resp OKResp
+		errCh <- sess.Call(ctx, &LockReq{Target: Candidate}, &resp)
+	}()
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `message-id="bus-42"`)
+
+	// the first request is still waiting for it's reply.
+	_, err = sess.Do(ctx, &LockReq{Target: Candidate})
+	assert.ErrorIs(t, err, ErrDuplicateMessageID)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="bus-42"><ok/></rpc-reply>`)
+	assert.NoError(t, <-errCh)
+
+	_, err = sess.Do(WithMessageID(context.Background(), ""), &LockReq{Target: Candidate})
+	assert.Error(t, err)
+}
+
+func TestWithRPCAttr(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ctx := WithRPCAttr(context.Background(), xml.Attr{Name: xml.Name{Space: "xmlns", Local: "junos"}, Value: "http://xml.juniper.net/junos/*/junos"})
+	ctx = WithRPCAttr(ctx, xml.Attr{Name: xml.Name{Local: "xmlns:nc"}, Value: NamespaceBase10})
+	ctx = WithRPCAttr(ctx, xml.Attr{Name: xml.Name{Local: "format"}, Value: "text"})
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	assert.NoError(t, sess.Lock(ctx, Candidate))
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1" xmlns:junos="http://xml.juniper.net/junos/*/junos" xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" format="text">`)
+
+	// attributes are per call.
+	assert.NoError(t, sess.Lock(context.Background(), Candidate))
+	sent, err = ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2">`)
+
+	_, err = sess.Do(WithRPCAttr(context.Background(), xml.Attr{Name: xml.Name{Local: "message-id"}, Value: "x"}), &LockReq{Target: Candidate})
+	assert.Error(t, err)
+}
+
+func TestMessageIDMismatch(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	var resp OKResp
+	err := sess.Call(context.Background(), &LockReq{Target: Candidate}, &resp)
+
+	var mismatchErr ErrMessageIDMismatch
+	assert.True(t, errors.As(err, &mismatchErr))
+	assert.Equal(t, ErrMessageIDMismatch{Sent: 1, Received: 2, RawSent: "1", RawReceived: "2"}, mismatchErr)
+
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+}
+
+func TestOperationTimeout(t *testing.T) {
+	tt := []struct {
+		name           string
+		defaultTimeout time.Duration
+		callTimeout    time.Duration
+	}{
+		{"default", 20 * time.Millisecond, 0},
+		{"explicitShorter", time.Hour, 20 * time.Millisecond},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport(), WithOperationTimeout(tc.defaultTimeout))
+			go sess.recv()
+
+			// never send a reply so the operation has to time out.
+			ctx := context.Background()
+			if tc.callTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.callTimeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			var resp OKResp
+			err := sess.Call(ctx, &LockReq{Target: Candidate}, &resp)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Less(t, time.Since(start), 5*time.Second)
+
+			// the server is done logging the request before the test ends.
+			_, err = ts.popReq()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+type failingTransport struct{ err error }
+
+func (t *failingTransport) MsgReader() (io.ReadCloser, error)  { select {} }
+func (t *failingTransport) MsgWriter() (io.WriteCloser, error) { return nil, t.err }
+func (t *failingTransport) Close() error                       { return nil }
+
+func TestTransportError(t *testing.T) {
+	sess := newSession(&failingTransport{err: io.ErrClosedPipe})
+
+	var resp OKResp
+	err := sess.Call(context.Background(), &LockReq{Target: Candidate}, &resp)
+	assert.True(t, IsTransportError(err))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+	var rpcErr RPCError
+	assert.False(t, errors.As(err, &rpcErr))
+}
+
+func TestTransportErrorRPCError(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><rpc-error><error-type>protocol</error-type><error-tag>lock-denied</error-tag><error-severity>error</error-severity></rpc-error></rpc-reply>`)
+
+	var resp OKResp
+	err := sess.Call(context.Background(), &LockReq{Target: Candidate}, &resp)
+	assert.False(t, IsTransportError(err))
+
+	var rpcErr RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+}
+
+func TestCallMiddleware(t *testing.T) {
+	var order []string
+	mw := func(name string) CallMiddleware {
+		return func(next Caller) Caller {
+			return CallerFunc(func(ctx context.Context, req any, resp any) error {
+				order = append(order, name+":before")
+				err := next.Call(ctx, req, resp)
+				order = append(order, name+":after")
+				return err
+			})
+		}
+	}
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithCallMiddleware(mw("outer"), mw("inner")))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	err := sess.Lock(context.Background(), Candidate)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"outer:before", "inner:before", "inner:after", "outer:after"}, order)
+
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+}
+
+func TestXMLDeclarationIndent(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithXMLDeclaration(), WithIndent("", "  "))
+	sess.serverCaps = newCapabilitySet(":writable-running")
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	err := sess.EditConfig(context.Background(), Running, "<system>\n<host-name>darkstar</host-name></system>")
+	assert.NoError(t, err)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">
+  <edit-config>
+    <target><running/></target>
+    <config><system>
+<host-name>darkstar</host-name></system></config>
+  </edit-config>
+</rpc>`
+	assert.Equal(t, want, sent)
+}
+
+// msgCaptureTransport records the messages written to it.
+type msgCaptureTransport struct {
+	msgs chan string
+}
+
+type msgCaptureWriter struct {
+	bytes.Buffer
+	msgs chan string
+}
+
+func (w *msgCaptureWriter) Close() error {
+	if w.msgs != nil {
+		w.msgs <- w.String()
+	}
+	return nil
+}
+
+func (t *msgCaptureTransport) MsgReader() (io.ReadCloser, error) { return nil, io.EOF }
+func (t *msgCaptureTransport) MsgWriter() (io.WriteCloser, error) {
+	return &msgCaptureWriter{msgs: t.msgs}, nil
+}
+func (t *msgCaptureTransport) Close() error { return nil }
+
+func TestWriteMsgConcurrent(t *testing.T) {
+	const n = 50
+
+	tr := &msgCaptureTransport{msgs: make(chan string, n)}
+	sess := newSession(tr)
+
+	type echo struct {
+		XMLName xml.Name `xml:"echo"`
+		Value   string   `xml:"value"`
+	}
+
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			_, err := sess.writeMsg(&echo{Value: strings.Repeat(strconv.Itoa(i), i)})
+			errs <- err
+		}(i)
+	}
+
+	want := make(map[string]bool)
+	got := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		assert.NoError(t, <-errs)
+		want["<echo><value>"+strings.Repeat(strconv.Itoa(i), i)+"</value></echo>"] = true
+		got[<-tr.msgs] = true
+	}
+	assert.Equal(t, want, got)
+
+	// a failed encoding doesn't leave anything behind for the next message.
+	_, err := sess.writeMsg(make(chan int))
+	assert.Error(t, err)
+	_, err = sess.writeMsg(&echo{Value: "after"})
+	assert.NoError(t, err)
+	assert.Equal(t, "<echo><value>after</value></echo>", <-tr.msgs)
+}
+
+func BenchmarkWriteMsg(b *testing.B) {
+	sess := newSession(&msgCaptureTransport{})
+	req := &GetConfigReq{Source: Running}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := sess.writeMsg(&request{MessageID: "1", Operation: req}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMaxReplySize(t *testing.T) {
+	const okReply = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`
+
+	// the session stops reading the reply that is too large so the replies
+	// are written ignoring errors.
+	replies := make(chan string, 2)
+	tr := newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_, _ = io.WriteString(w, <-replies)
+		w.Close()
+	})
+
+	notifs := make(chan Notification, 2)
+	sess := newSession(tr, WithMaxReplySize(200), WithNotificationHandler(func(n Notification) { notifs <- n }))
+	go sess.recv()
+
+	replies <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>` +
+		strings.Repeat("<x/>", 100) + `</data></rpc-reply>`
+	_, err := sess.GetConfig(context.Background(), Running)
+	assert.ErrorIs(t, err, ErrReplyTooLarge)
+
+	// the session is still usable.
+	replies <- okReply
+	assert.NoError(t, sess.Unlock(context.Background(), Candidate))
+
+	// notifications that are too large are dropped.
+	tr.out <- io.NopCloser(strings.NewReader(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+		`<eventTime>2023-06-07T18:31:48Z</eventTime>` + strings.Repeat("<event/>", 100) + `</notification>`))
+	tr.out <- io.NopCloser(strings.NewReader(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+		`<eventTime>2023-06-07T18:31:49Z</eventTime><event/></notification>`))
+	select {
+	case n := <-notifs:
+		assert.Equal(t, 49, n.EventTime.Second())
+	case <-time.After(time.Second):
+		t.Fatal("notification not received")
+	}
+}
+
+func TestSizeLimitReader(t *testing.T) {
+	for _, tc := range []struct {
+		max     int
+		wantErr bool
+	}{
+		{10, false},
+		{9, true},
+		{0, false},
+	} {
+		got, err := io.ReadAll(newSizeLimitReader(strings.NewReader("0123456789"), tc.max))
+		if tc.wantErr {
+			assert.ErrorIs(t, err, ErrReplyTooLarge)
+			assert.Equal(t, "012345678", string(got))
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, "0123456789", string(got))
+	}
+}
+
+// replyTransport records the messages written to it and reads the replies
+// sent on `replies` until it is closed.
+type replyTransport struct {
+	msgCaptureTransport
+	replies chan string
+	once    sync.Once
+}
+
+func newReplyTransport() *replyTransport {
+	return &replyTransport{
+		msgCaptureTransport: msgCaptureTransport{msgs: make(chan string, 16)},
+		replies:             make(chan string, 16),
+	}
+}
+
+func (t *replyTransport) MsgReader() (io.ReadCloser, error) {
+	reply, ok := <-t.replies
+	if !ok {
+		return nil, io.EOF
+	}
+	return io.NopCloser(strings.NewReader(reply)), nil
+}
+
+func (t *replyTransport) Close() error {
+	t.once.Do(func() { close(t.replies) })
+	return nil
+}
+
+func TestCancelInFlight(t *testing.T) {
+	tr := newReplyTransport()
+	sess := newSession(tr)
+	go sess.recv()
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- sess.Unlock(context.Background(), Candidate) }()
+		<-tr.msgs
+	}
+	assert.ElementsMatch(t, []string{"1", "2"}, sess.InFlight())
+
+	assert.Equal(t, 1, sess.CancelInFlight("1", "unknown"))
+	assert.ErrorIs(t, <-errs, ErrRPCCanceled)
+	assert.Equal(t, []string{"2"}, sess.InFlight())
+
+	// the late reply of the canceled request is discarded.
+	tr.replies <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`
+	tr.replies <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`
+	assert.NoError(t, <-errs)
+	assert.Empty(t, sess.InFlight())
+}
+
+func TestCloseInFlight(t *testing.T) {
+	tr := newReplyTransport()
+	sess := newSession(tr)
+	go sess.recv()
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- sess.Unlock(context.Background(), Candidate) }()
+		<-tr.msgs
+	}
+
+	closeErr := make(chan error, 1)
+	go func() { closeErr <- sess.Close(context.Background()) }()
+
+	for i := 0; i < 2; i++ {
+		assert.ErrorIs(t, <-errs, ErrSessionClosed)
+	}
+
+	msg := <-tr.msgs
+	assert.Contains(t, msg, `message-id="3"><close-session`)
+	assert.Equal(t, []string{"3"}, sess.InFlight())
+	tr.replies <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><ok/></rpc-reply>`
+	assert.NoError(t, <-closeErr)
+}
+
+func TestLateReplyAfterTimeout(t *testing.T) {
+	tr := newReplyTransport()
+	sess := newSession(tr)
+	go sess.recv()
+
+	// the first call times out before the device replies.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := sess.Unlock(ctx, Candidate)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	<-tr.msgs
+
+	errs := make(chan error, 1)
+	go func() { errs <- sess.Unlock(context.Background(), Candidate) }()
+	<-tr.msgs
+
+	// the late reply is discarded instead of being handed to the outstanding
+	// request which still gets its own reply.
+	tr.replies <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`
+	tr.replies <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`
+	assert.NoError(t, <-errs)
+}
+
+func TestForgetReqBounded(t *testing.T) {
+	sess := newSession(newReplyTransport())
+
+	sess.mu.Lock()
+	for i := 0; i < maxCanceled+10; i++ {
+		sess.forgetReq(strconv.Itoa(i))
+	}
+	sess.mu.Unlock()
+
+	// only the most recent ids are kept.
+	assert.Len(t, sess.canceled, maxCanceled)
+	assert.NotContains(t, sess.canceled, "0")
+	assert.Contains(t, sess.canceled, strconv.Itoa(maxCanceled+9))
+}
@@ -0,0 +1,40 @@
+package netconf
+
+import (
+	"context"
+
+	ncssh "github.com/DinbandhuKumarSingh/netconf/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// NewSessionFromSSHClient opens a new session on an already established ssh
+// connection by opening a channel for the `netconf` subsystem ([RFC6242]) and
+// running the hello exchange.  This allows reusing ssh connections that are
+// set up with jump hosts, custom authentication or pooling.  If the device
+// doesn't provide the subsystem an error wrapping
+// [ncssh.ErrSubsystemUnavailable] is returned.
+//
+// Closing the session closes the channel but not `client` which stays
+// usable (i.e for other sessions) and must be closed by the caller.  If `ctx`
+// is done before the hello exchange completed the channel is closed and the
+// error of the context is returned.
+//
+// [RFC6242]: https://www.rfc-editor.org/rfc/rfc6242.html
+func NewSessionFromSSHClient(ctx context.Context, client *ssh.Client, opts ...SessionOption) (*Session, error) {
+	tr, err := ncssh.NewTransport(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// abort the hello exchange by closing the channel.
+	stop := context.AfterFunc(ctx, func() { tr.Close() })
+
+	s, err := Open(tr, opts...)
+	if !stop() {
+		if err == nil {
+			s.tr.Close()
+		}
+		return nil, ctx.Err()
+	}
+	return s, err
+}
@@ -0,0 +1,98 @@
+package netconf
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"testing"
+
+	ncssh "github.com/DinbandhuKumarSingh/netconf/transport/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// newSSHClient returns a ssh client connected to a local ssh server that
+// serves NETCONF on the `subsystem` ssh subsystem.
+func newSSHClient(t *testing.T, subsystem string) *ssh.Client {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	// net.Pipe can't be used as both sides send their version first.
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		serverConn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_, chans, reqs, err := ssh.NewServerConn(serverConn, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+
+		for newChannel := range chans {
+			ch, reqs, err := newChannel.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				for req := range reqs {
+					var payload struct{ Name string }
+					ok := req.Type == "subsystem" &&
+						ssh.Unmarshal(req.Payload, &payload) == nil &&
+						payload.Name == subsystem
+					_ = req.Reply(ok, nil)
+					if ok {
+						go serveStream(ch, helloGood, func(n int) string {
+							return fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><ok/></rpc-reply>`, n)
+						})
+					}
+				}
+			}()
+		}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, ln.Addr().String(), &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	require.NoError(t, err)
+	client := ssh.NewClient(sshConn, chans, reqs)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestNewSessionFromSSHClient(t *testing.T) {
+	client := newSSHClient(t, "netconf")
+
+	sess, err := NewSessionFromSSHClient(context.Background(), client)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), sess.SessionID())
+
+	assert.NoError(t, sess.Lock(context.Background(), Candidate))
+	assert.NoError(t, sess.Close(context.Background()))
+
+	// the client is not closed with the session.
+	sess, err = NewSessionFromSSHClient(context.Background(), client)
+	require.NoError(t, err)
+	assert.NoError(t, sess.Close(context.Background()))
+}
+
+func TestNewSessionFromSSHClientNoSubsystem(t *testing.T) {
+	client := newSSHClient(t, "xml")
+
+	_, err := NewSessionFromSSHClient(context.Background(), client)
+	assert.ErrorIs(t, err, ncssh.ErrSubsystemUnavailable)
+}
@@ -0,0 +1,236 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// streamReplyKey is the context key marking requests issued by
+// [Session.GetConfigStream].
+type streamReplyKey struct{}
+
+// streamFlushSize is the amount of `<data>` buffered before it is handed to the
+// reader of [Session.GetConfigStream].
+const streamFlushSize = 32 * 1024
+
+// errStreamClosed is returned by streamData when the reader of the stream was
+// closed before all of `<data>` was read.
+var errStreamClosed = errors.New("netconf: reply stream closed")
+
+// GetConfigStream is like [Session.GetConfig] but instead of buffering the
+// complete reply it streams the contents of the `<data>` element as they are
+// read from the transport.  This keeps the memory usage low for very large
+// configs which can then be parsed incrementally:
+//
+//	r, err := sess.GetConfigStream(ctx, netconf.Running)
+//	if err != nil { /* ... */ }
+//	defer r.Close()
+//
+//	dec := xml.NewDecoder(r)
+//	for {
+//		tok, err := dec.Token()
+//		/* ... */
+//	}
+//
+// The reader returns [io.EOF] at the end of `<data>`.  No other message
+// (replies and notifications) can be received while the reply is streamed so
+// the reader must be read until the end or closed.  Closing the reader early
+// skips the rest of the reply.  If `ctx` is done while reading the reader fails
+// with the error of the context.
+//
+// RPC errors are returned by GetConfigStream itself as for [Session.GetConfig].
+func (s *Session) GetConfigStream(ctx context.Context, source Datastore, opts ...RPCOption) (io.ReadCloser, error) {
+	req := GetConfigReq{
+		Source: source,
+	}
+	for _, opt := range opts {
+		opt(&req)
+	}
+	if err := s.prepareGetConfig(&req); err != nil {
+		return nil, err
+	}
+
+	reply, err := s.Do(context.WithValue(ctx, streamReplyKey{}, true), &req)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.stream == nil {
+		// the reply was not streamed (i.e it has rpc-errors or warnings before
+		// `<data>`) so it's decoded as usual.
+		if err := s.replyErr(reply); err != nil {
+			return nil, err
+		}
+
+		var body struct {
+			Data GetConfigReply `xml:"data"`
+		}
+		if err := xml.Unmarshal([]byte("<rpc-reply>"+string(reply.Body)+"</rpc-reply>"), &body); err != nil {
+			return nil, fmt.Errorf("failed to decode get-config reply: %w", err)
+		}
+		if err := body.Data.checkData(); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(strings.NewReader(string(body.Data.Config))), nil
+	}
+
+	stream := reply.stream
+	// unblock a pending read once ctx is done.
+	stop := context.AfterFunc(ctx, func() { stream.CloseWithError(ctx.Err()) })
+	return &dataReader{PipeReader: stream, ctx: ctx, stop: stop}, nil
+}
+
+// dataReader is the reader returned by [Session.GetConfigStream].
+type dataReader struct {
+	*io.PipeReader
+	ctx  context.Context
+	stop func() bool
+}
+
+func (r *dataReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.PipeReader.Read(p)
+}
+
+func (r *dataReader) Close() error {
+	r.stop()
+	return r.PipeReader.Close()
+}
+
+// recvStream receives the `<rpc-reply>` starting with `root` for a request
+// issued by [Session.GetConfigStream].  If the first element of the reply is
+// `<data>` a stream of it's contents is delivered to the request and the
+// contents are copied to the stream as they are read from `r`.  Otherwise the
+// reply is decoded and delivered as usual.
+func (s *Session) recvStream(dec *xml.Decoder, root *xml.StartElement, capture *rawCapture, r io.Reader) error {
+	var msgID string
+	for _, attr := range root.Attr {
+		if attr.Name.Local == "message-id" {
+			msgID = attr.Value
+		}
+	}
+
+	isData, err := firstChildIsData(dec)
+	if err != nil {
+		return fmt.Errorf("failed to decode rpc-reply message: %w", err)
+	}
+
+	if !isData {
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return fmt.Errorf("failed to read rpc-reply message: %w", err)
+		}
+
+		var reply Reply
+		if err := xml.Unmarshal(capture.Bytes(), &reply); err != nil {
+			return fmt.Errorf("failed to decode rpc-reply message: %w", err)
+		}
+		reply.size = capture.Len()
+		return s.deliver(reply)
+	}
+
+	pr, pw := io.Pipe()
+	err = s.deliver(Reply{
+		MessageID:    numericMessageID(msgID),
+		RawMessageID: msgID,
+		size:         int(dec.InputOffset()),
+		stream:       pr,
+	})
+	if err == nil {
+		err = s.streamData(pw, dec, capture)
+	}
+
+	switch {
+	case err == errStreamClosed:
+		// the reader is gone, the rest of the reply is skipped below.
+	case err != nil:
+		pw.CloseWithError(err)
+		return err
+	default:
+		pw.Close()
+	}
+
+	capture.off = true
+	capture.Reset()
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return fmt.Errorf("failed to read rpc-reply message: %w", err)
+	}
+	return nil
+}
+
+// firstChildIsData reads up to the first child element of the `<rpc-reply>`
+// and reports if it is `<data>`.
+func firstChildIsData(dec *xml.Decoder) (bool, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return tok.Name == xml.Name{Space: NamespaceBase10, Local: "data"}, nil
+		case xml.EndElement:
+			return false, nil
+		}
+	}
+}
+
+// streamData copies the raw contents of the `<data>` element, whose start tag
+// was just read with `dec`, to w.  The bytes are taken as-is from `capture`
+// (which receives everything read by `dec`) so the contents are not re-encoded
+// and only the bytes not yet handed to w are kept in memory.
+func (s *Session) streamData(w io.Writer, dec *xml.Decoder, capture *rawCapture) error {
+	// drop everything up to and including the `<data>` start tag.
+	base := dec.InputOffset()
+	capture.Next(int(base))
+
+	flush := func(off int64) error {
+		if off == base {
+			return nil
+		}
+		if _, err := w.Write(capture.Next(int(off - base))); err != nil {
+			return errStreamClosed
+		}
+		base = off
+
+		// the reader keeps up so don't time out in the middle of a large
+		// reply.
+		return s.setReadDeadline(s.readTimeout)
+	}
+
+	depth := 0
+	for {
+		off := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			// the message ended early which doesn't mean the transport is
+			// gone so io.EOF must not be returned.
+			return errors.New("failed to read rpc-reply message: unexpected end of message in <data>")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read rpc-reply message: %w", err)
+		}
+
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return flush(off)
+			}
+			depth--
+		}
+
+		if dec.InputOffset()-base >= streamFlushSize {
+			if err := flush(dec.InputOffset()); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package netconf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/DinbandhuKumarSingh/netconf/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetConfigStream(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	const data = `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">` +
+		`<interface><name>eth0</name><description><![CDATA[<uplink>]]></description><enabled/></interface>` +
+		`<!-- comment --><interface><name>eth1</name></interface>` +
+		`</interfaces>`
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>` + data + `</data></rpc-reply>`)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	r, err := sess.GetConfigStream(context.Background(), Running, WithFilter("/interfaces"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(got))
+	assert.NoError(t, r.Close())
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<filter type="subtree"><interfaces/></filter>`)
+
+	// the session can be used again after the stream.
+	assert.NoError(t, sess.Lock(context.Background(), Candidate))
+}
+
+func TestGetConfigStreamReplies(t *testing.T) {
+	tt := []struct {
+		name    string
+		reply   string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "rpcError",
+			reply: `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` +
+				`<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag><error-severity>error</error-severity></rpc-error>` +
+				`</rpc-reply>`,
+			wantErr: "operation-failed",
+		},
+		{
+			name: "warning",
+			reply: `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` +
+				`<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag><error-severity>warning</error-severity></rpc-error>` +
+				`<data><system/></data></rpc-reply>`,
+			want: "<system/>",
+		},
+		{
+			name:  "emptyData",
+			reply: `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`,
+		},
+		{
+			name:    "noData",
+			reply:   `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"></rpc-reply>`,
+			wantErr: ErrMissingData.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport())
+			go sess.recv()
+
+			ts.queueRespString(tc.reply)
+
+			r, err := sess.GetConfigStream(context.Background(), Running)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestGetConfigStreamClose(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	var data strings.Builder
+	for i := 0; data.Len() < 4*streamFlushSize; i++ {
+		fmt.Fprintf(&data, "<interface><name>eth%d</name></interface>", i)
+	}
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data><interfaces>` + data.String() + `</interfaces></data></rpc-reply>`)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	r, err := sess.GetConfigStream(context.Background(), Running)
+	if !assert.NoError(t, err) {
+		return
+	}
+	buf := make([]byte, 16)
+	_, err = io.ReadFull(r, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "<interfaces><int", string(buf))
+
+	// closing early skips the rest of the reply and releases the session.
+	assert.NoError(t, r.Close())
+	assert.NoError(t, sess.Lock(context.Background(), Candidate))
+}
+
+func TestGetConfigStreamCanceled(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data><system/></data></rpc-reply>`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := sess.GetConfigStream(ctx, Running)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer r.Close()
+
+	cancel()
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGetConfigStreamChunked(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	var data strings.Builder
+	for i := 0; data.Len() < 3*streamFlushSize; i++ {
+		fmt.Fprintf(&data, "<interface><name>eth%d</name><mtu>1500</mtu></interface>", i)
+	}
+
+	go func() {
+		f := transport.NewFramer(server, server)
+
+		r, _ := f.MsgReader()
+		io.Copy(io.Discard, r)
+		w, _ := f.MsgWriter()
+		io.WriteString(w, helloGood)
+		w.Close()
+		f.Upgrade()
+		// split the reply (and so tags) over many chunks.
+		f.SetMaxChunkSize(7)
+
+		r, _ = f.MsgReader()
+		io.Copy(io.Discard, r)
+		w, _ = f.MsgWriter()
+		io.WriteString(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>`+data.String()+`</data></rpc-reply>`)
+		w.Close()
+	}()
+
+	sess, err := NewSession(context.Background(), client)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sess.tr.Close()
+
+	r, err := sess.GetConfigStream(context.Background(), Running)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, data.String(), string(got))
+}
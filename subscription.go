@@ -0,0 +1,186 @@
+package netconf
+
+// SubscriptionStatus is the lifecycle status of the notification subscription
+// of a session.
+type SubscriptionStatus int
+
+const (
+	// SubscriptionNone means no subscription was created on the session.
+	SubscriptionNone SubscriptionStatus = iota
+	// SubscriptionActive means a subscription was created with
+	// [Session.CreateSubscription] and notifications are being delivered.
+	SubscriptionActive
+	// SubscriptionCompleted means the device ended the subscription with the
+	// `<notificationComplete>` notification (i.e the stop time was reached).
+	SubscriptionCompleted
+	// SubscriptionTerminated means the session ended while the subscription
+	// was active.
+	SubscriptionTerminated
+)
+
+func (s SubscriptionStatus) String() string {
+	switch s {
+	case SubscriptionNone:
+		return "none"
+	case SubscriptionActive:
+		return "active"
+	case SubscriptionCompleted:
+		return "completed"
+	case SubscriptionTerminated:
+		return "terminated"
+	}
+	return "unknown"
+}
+
+// SubscriptionState is a snapshot of the health of the notification
+// subscription of a session.
+type SubscriptionState struct {
+	Status SubscriptionStatus
+	// Events is the number of notifications received since the subscription
+	// was created, not counting the `<notificationComplete>` notification.
+	Events uint64
+	// Dropped is the number of notifications discarded without being passed
+	// to the [NotificationHandler] because the buffer set with
+	// [WithReplayBuffer] was full (see [BackpressureDropOldest]).
+	Dropped uint64
+	// Filtered is the number of notifications discarded by the filter set
+	// with [WithEventFilter].
+	Filtered uint64
+	// Err is the error that ended the session for a terminated subscription.
+	// It is nil if the session was closed with [Session.Close].
+	Err error
+}
+
+// SubscriptionState returns the current state of the notification subscription
+// of the session.
+func (s *Session) SubscriptionState() SubscriptionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscription
+}
+
+// SubscriptionStates returns a channel receiving the state of the notification
+// subscription every time it changes (the subscription is created, a
+// notification is received, the subscription completes or the session ends).
+// This can be used to monitor that the subscription is alive without
+// inspecting the notifications.
+//
+// Only the latest state is kept: if the receiver falls behind intermediate
+// states are dropped so the session never blocks on it.  The channel is
+// closed once the session is closed (after sending the final state).  All
+// calls return the same channel.
+func (s *Session) SubscriptionStates() <-chan SubscriptionState {
+	return s.subscriptionStates
+}
+
+// setSubscription updates the subscription state and publishes it on the
+// channel returned by [Session.SubscriptionStates].  Must be called with s.mu
+// held.
+func (s *Session) setSubscription(state SubscriptionState) {
+	s.subscription = state
+
+	// replace any state the receiver has not picked up yet.  Only senders hold
+	// s.mu so this never blocks.
+	select {
+	case <-s.subscriptionStates:
+	default:
+	}
+	s.subscriptionStates <- state
+}
+
+// BackpressurePolicy selects what happens when a notification is received
+// while the buffer set with [WithReplayBuffer] is full.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock stops reading from the session until the
+	// [NotificationHandler] catches up.  No notification is lost but replies
+	// to other requests are delayed as well.
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDropOldest discards the oldest buffered notification to make
+	// room for the new one, counting it in [SubscriptionState.Dropped].
+	BackpressureDropOldest
+)
+
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case BackpressureBlock:
+		return "block"
+	case BackpressureDropOldest:
+		return "drop-oldest"
+	}
+	return "unknown"
+}
+
+// notifQueue buffers the notifications between the receive loop and the
+// [NotificationHandler] which is called from its own goroutine.
+type notifQueue struct {
+	ch     chan Notification
+	policy BackpressurePolicy
+}
+
+func newNotifQueue(size int, policy BackpressurePolicy, handler NotificationHandler) *notifQueue {
+	q := &notifQueue{
+		ch:     make(chan Notification, size),
+		policy: policy,
+	}
+	go func() {
+		for notif := range q.ch {
+			handler(notif)
+		}
+	}()
+	return q
+}
+
+// push queues `notif` applying the backpressure policy.  It reports the number
+// of notifications dropped to make room for it.  Only the receive loop pushes
+// to the queue.
+func (q *notifQueue) push(notif Notification) (dropped uint64) {
+	if q.policy == BackpressureBlock {
+		q.ch <- notif
+		return 0
+	}
+
+	for {
+		select {
+		case q.ch <- notif:
+			return dropped
+		default:
+		}
+		// the handler may have taken the oldest one in the meantime.
+		select {
+		case <-q.ch:
+			dropped++
+		default:
+		}
+	}
+}
+
+// close stops the handler goroutine once the queued notifications are
+// delivered.
+func (q *notifQueue) close() {
+	close(q.ch)
+}
+
+// deliverNotification passes `notif` to the [NotificationHandler], through the
+// buffer of the subscription if any.
+func (s *Session) deliverNotification(notif Notification) {
+	s.mu.Lock()
+	q := s.notifQueue
+	s.mu.Unlock()
+
+	if q == nil {
+		if s.notificationHandler != nil {
+			s.notificationHandler(notif)
+		}
+		return
+	}
+
+	if dropped := q.push(notif); dropped > 0 {
+		s.mu.Lock()
+		state := s.subscription
+		state.Dropped += dropped
+		s.setSubscription(state)
+		s.mu.Unlock()
+	}
+}
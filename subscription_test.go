@@ -0,0 +1,239 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionStates(t *testing.T) {
+	const (
+		event = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+			`<eventTime>2023-06-07T18:31:48Z</eventTime><event/></notification>`
+		notificationComplete = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+			`<eventTime>2023-06-07T18:33:48Z</eventTime>` +
+			`<notificationComplete xmlns="urn:ietf:params:xml:ns:netmod:notification"/>` +
+			`</notification>`
+	)
+
+	// notify sends a message on the transport outside of any request.
+	notify := func(tr *testTransport, msg string) {
+		tr.out <- io.NopCloser(strings.NewReader(msg))
+	}
+
+	// next returns the next state sent on states.
+	next := func(t *testing.T, states <-chan SubscriptionState) SubscriptionState {
+		t.Helper()
+		select {
+		case state, ok := <-states:
+			require.True(t, ok, "states channel closed")
+			return state
+		case <-time.After(time.Second):
+			t.Fatal("no subscription state received")
+		}
+		return SubscriptionState{}
+	}
+
+	t.Run("completed", func(t *testing.T) {
+		ts := newTestServer(t)
+		tr := ts.transport()
+		sess := newSession(tr)
+		go sess.recv()
+
+		states := sess.SubscriptionStates()
+		assert.Equal(t, SubscriptionNone, sess.SubscriptionState().Status)
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		require.NoError(t, sess.CreateSubscription(context.Background()))
+		assert.Equal(t, SubscriptionState{Status: SubscriptionActive}, next(t, states))
+
+		notify(tr, event)
+		assert.Equal(t, SubscriptionState{Status: SubscriptionActive, Events: 1}, next(t, states))
+		notify(tr, event)
+		assert.Equal(t, SubscriptionState{Status: SubscriptionActive, Events: 2}, next(t, states))
+
+		notify(tr, notificationComplete)
+		assert.Equal(t, SubscriptionState{Status: SubscriptionCompleted, Events: 2}, next(t, states))
+		assert.Equal(t, SubscriptionCompleted, sess.SubscriptionState().Status)
+
+		// the channel is closed with the session.
+		notify(tr, "")
+		select {
+		case _, ok := <-states:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("states channel not closed")
+		}
+	})
+
+	t.Run("terminated", func(t *testing.T) {
+		ts := newTestServer(t)
+		tr := ts.transport()
+		sess := newSession(tr)
+		go sess.recv()
+
+		states := sess.SubscriptionStates()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		require.NoError(t, sess.CreateSubscription(context.Background()))
+		notify(tr, event)
+
+		// the connection is lost.
+		notify(tr, "")
+		<-sess.done
+
+		// only the latest state is kept.
+		state := next(t, states)
+		assert.Equal(t, SubscriptionTerminated, state.Status)
+		assert.Equal(t, uint64(1), state.Events)
+		assert.ErrorIs(t, state.Err, io.EOF)
+
+		_, ok := <-states
+		assert.False(t, ok)
+	})
+}
+
+func TestReplayBuffer(t *testing.T) {
+	// event returns a notification identified by the seconds of its eventTime.
+	event := func(i int) string {
+		return fmt.Sprintf(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">`+
+			`<eventTime>2023-06-07T18:31:%02dZ</eventTime><event/></notification>`, i)
+	}
+
+	// setup returns a session subscribed with the options whose handler is
+	// blocked until release is closed.  The first notification is passed to
+	// the handler before it returns.
+	setup := func(t *testing.T, opts ...CreateSubscriptionOption) (tr *testTransport, sess *Session, received chan int, release chan struct{}) {
+		received = make(chan int, 10)
+		release = make(chan struct{})
+		started := make(chan struct{})
+		var once sync.Once
+		handler := func(notif Notification) {
+			once.Do(func() { close(started) })
+			<-release
+			received <- notif.EventTime.Second()
+		}
+
+		ts := newTestServer(t)
+		tr = ts.transport()
+		sess = newSession(tr, WithNotificationHandler(handler))
+		go sess.recv()
+
+		ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		require.NoError(t, sess.CreateSubscription(context.Background(), opts...))
+
+		tr.out <- io.NopCloser(strings.NewReader(event(1)))
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("handler not called")
+		}
+		return tr, sess, received, release
+	}
+
+	collect := func(t *testing.T, received chan int, n int) []int {
+		t.Helper()
+		var got []int
+		for len(got) < n {
+			select {
+			case i := <-received:
+				got = append(got, i)
+			case <-time.After(time.Second):
+				t.Fatalf("received %v, want %d notifications", got, n)
+			}
+		}
+		return got
+	}
+
+	t.Run("block", func(t *testing.T) {
+		tr, sess, received, release := setup(t, WithReplayBuffer(2))
+
+		// the session stops reading once the buffer is full.
+		go func() {
+			for i := 2; i <= 5; i++ {
+				tr.out <- io.NopCloser(strings.NewReader(event(i)))
+			}
+		}()
+		require.Eventually(t, func() bool { return sess.SubscriptionState().Events == 4 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, uint64(4), sess.SubscriptionState().Events)
+
+		close(release)
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, collect(t, received, 5))
+		state := sess.SubscriptionState()
+		assert.Equal(t, uint64(5), state.Events)
+		assert.Zero(t, state.Dropped)
+	})
+
+	t.Run("dropOldest", func(t *testing.T) {
+		tr, sess, received, release := setup(t, WithReplayBuffer(2), WithBackpressure(BackpressureDropOldest))
+
+		// the session never blocks on the handler.
+		for i := 2; i <= 5; i++ {
+			tr.out <- io.NopCloser(strings.NewReader(event(i)))
+		}
+		require.Eventually(t, func() bool { return sess.SubscriptionState().Dropped == 2 }, time.Second, time.Millisecond)
+
+		close(release)
+		assert.Equal(t, []int{1, 4, 5}, collect(t, received, 3))
+		state := sess.SubscriptionState()
+		assert.Equal(t, uint64(5), state.Events)
+		assert.Equal(t, uint64(2), state.Dropped)
+	})
+}
+
+func TestEventFilter(t *testing.T) {
+	const (
+		configChange = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+			`<eventTime>2023-06-07T18:31:48Z</eventTime>` +
+			`<netconf-config-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications"/></notification>`
+		sessionStart = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+			`<eventTime>2023-06-07T18:31:49Z</eventTime>` +
+			`<netconf-session-start xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications"/></notification>`
+		linkDown = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+			`<eventTime>2023-06-07T18:31:50Z</eventTime><link-down xmlns="urn:example:if"/></notification>`
+		notificationComplete = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">` +
+			`<eventTime>2023-06-07T18:33:48Z</eventTime>` +
+			`<notificationComplete xmlns="urn:ietf:params:xml:ns:netmod:notification"/>` +
+			`</notification>`
+	)
+
+	notifs := make(chan Notification, 10)
+	ts := newTestServer(t)
+	tr := ts.transport()
+	sess := newSession(tr, WithNotificationHandler(func(n Notification) { notifs <- n }))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	require.NoError(t, sess.CreateSubscription(context.Background(), WithEventFilter(func(name xml.Name) bool {
+		return name == xml.Name{Space: NamespaceNetconfNotifications, Local: "netconf-config-change"}
+	})))
+
+	for _, msg := range []string{sessionStart, configChange, linkDown, configChange, notificationComplete} {
+		tr.out <- io.NopCloser(strings.NewReader(msg))
+	}
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case n := <-notifs:
+			got = append(got, eventName(n).Local)
+		case <-time.After(time.Second):
+			t.Fatalf("received %v", got)
+		}
+	}
+	assert.Equal(t, []string{"netconf-config-change", "netconf-config-change", "notificationComplete"}, got)
+
+	state := sess.SubscriptionState()
+	assert.Equal(t, SubscriptionCompleted, state.Status)
+	assert.Equal(t, uint64(4), state.Events)
+	assert.Equal(t, uint64(2), state.Filtered)
+}
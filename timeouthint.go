@@ -0,0 +1,199 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// TimeoutHint describes a vendor specific element a device accepts in an
+// operation to bound how long it works on it (see [WithServerTimeoutHint]).
+//
+// NETCONF has no standard element for this.  The name, namespace and unit of
+// the element differ per vendor, platform and operation and have to be taken
+// from the rpc reference of the device, so no hints are built in.  For example
+// a device documenting a `<timeout>` element in seconds for `<validate>` in the
+// `urn:example:timeout` namespace would be described as:
+//
+//	netconf.TimeoutHint{
+//		Capability: "urn:example:timeout",
+//		Namespace:  "urn:example:timeout",
+//		Prefix:     "ex",
+//		Element:    "timeout",
+//		Unit:       time.Second,
+//		Operations: []string{"validate"},
+//	}
+type TimeoutHint struct {
+	// Capability is the capability the device must advertise for the hint to
+	// be sent.  If empty the hint is always sent.
+	Capability string
+	// Namespace is the namespace of the element declared with Prefix.
+	Namespace string
+	Prefix    string
+	// Element is the name of the element added as the last child of the
+	// operation.
+	Element string
+	// Unit is the unit of the value of the element.  The remaining time is
+	// truncated to whole units.  Defaults to seconds.
+	Unit time.Duration
+	// Operations are the local names of the operations the device accepts the
+	// element in (i.e `validate`).  If empty the hint is sent with every
+	// operation.
+	Operations []string
+}
+
+type serverTimeoutHintOpt TimeoutHint
+
+func (o serverTimeoutHintOpt) apply(cfg *sessionConfig) {
+	cfg.timeoutHints = append(cfg.timeoutHints, TimeoutHint(o))
+}
+
+// WithServerTimeoutHint sends the time remaining until the deadline of the
+// context of an operation to the device in the vendor specific element
+// described by `hint`, so that the device can abort a long running operation
+// (i.e a `<validate>` of a large config) at the same time the client gives up
+// on it.  The deadline includes the default set with [WithOperationTimeout].
+//
+// The hint is only added to the operations listed in the hint when the device
+// advertises its capability and the context has a deadline.  The option can be
+// given multiple times to describe the elements of different vendors, the
+// first one matching the operation and the capabilities of the device is used.
+// Operations streaming a config from an io.Reader are sent without a hint.
+func WithServerTimeoutHint(hint TimeoutHint) SessionOption {
+	return serverTimeoutHintOpt(hint)
+}
+
+// applyTimeoutHint returns the operation `op` with the remaining time of `ctx`
+// added in the first matching hint configured with [WithServerTimeoutHint],
+// or `op` unchanged if no hint applies.
+func (s *Session) applyTimeoutHint(ctx context.Context, op any) (any, error) {
+	if len(s.timeoutHints) == 0 {
+		return op, nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return op, nil
+	}
+	if ctx.Value(streamsKey{}) != nil {
+		return op, nil
+	}
+
+	var raw []byte
+	switch op := op.(type) {
+	case string:
+		raw = []byte(op)
+	case []byte:
+		raw = op
+	default:
+		var err error
+		if raw, err = xml.Marshal(op); err != nil {
+			return nil, err
+		}
+	}
+
+	name := encodedOperationName(raw)
+	for _, hint := range s.timeoutHints {
+		if len(hint.Operations) > 0 && !slices.Contains(hint.Operations, name) {
+			continue
+		}
+		if hint.Capability != "" {
+			if _, ok := s.serverCaps.Lookup(hint.Capability); !ok {
+				continue
+			}
+		}
+
+		unit := hint.Unit
+		if unit <= 0 {
+			unit = time.Second
+		}
+		// never send 0 which devices may take as no timeout at all.
+		value := max(time.Until(deadline)/unit, 1)
+
+		hinted, ok := appendChildXML(raw, hint.element(int64(value)))
+		if !ok {
+			return nil, fmt.Errorf("netconf: cannot add timeout hint to operation %q", name)
+		}
+		return hinted, nil
+	}
+	return op, nil
+}
+
+// element encodes the hint element with the given value.
+func (h TimeoutHint) element(value int64) []byte {
+	name, xmlns := h.Element, "xmlns"
+	if h.Prefix != "" {
+		name = h.Prefix + ":" + h.Element
+		xmlns += ":" + h.Prefix
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<" + name)
+	if h.Namespace != "" {
+		buf.WriteString(" " + xmlns + `="`)
+		_ = xml.EscapeText(&buf, []byte(h.Namespace))
+		buf.WriteByte('"')
+	}
+	fmt.Fprintf(&buf, ">%d</%s>", value, name)
+	return buf.Bytes()
+}
+
+// encodedOperationName returns the local name of the first element of the
+// encoded operation.
+func encodedOperationName(raw []byte) string {
+	i := bytes.IndexByte(raw, '<')
+	for i >= 0 && i+1 < len(raw) && (raw[i+1] == '?' || raw[i+1] == '!') {
+		// skip declarations and comments
+		next := bytes.IndexByte(raw[i+1:], '<')
+		if next < 0 {
+			return ""
+		}
+		i += next + 1
+	}
+	if i < 0 {
+		return ""
+	}
+
+	name := raw[i+1:]
+	if end := bytes.IndexAny(name, " \t\r\n/>"); end >= 0 {
+		name = name[:end]
+	}
+	if j := bytes.IndexByte(name, ':'); j >= 0 {
+		name = name[j+1:]
+	}
+	return string(name)
+}
+
+// appendChildXML adds `child` as the last child of the root element of the
+// encoded xml `raw`.  A self-closing root element is expanded.
+func appendChildXML(raw, child []byte) ([]byte, bool) {
+	trimmed := bytes.TrimRight(raw, " \t\r\n")
+	if !bytes.HasSuffix(trimmed, []byte(">")) {
+		return nil, false
+	}
+
+	out := make([]byte, 0, len(raw)+len(child)+16)
+	if end := bytes.LastIndex(trimmed, []byte("</")); end >= 0 {
+		out = append(out, trimmed[:end]...)
+		out = append(out, child...)
+		return append(out, trimmed[end:]...), true
+	}
+
+	// without any end tag the root must be a single self-closing element.
+	if !bytes.HasSuffix(trimmed, []byte("/>")) {
+		return nil, false
+	}
+	start := bytes.LastIndexByte(trimmed, '<')
+	name := string(trimmed[start+1:])
+	if end := strings.IndexAny(name, " \t\r\n/"); end >= 0 {
+		name = name[:end]
+	}
+	out = append(out, trimmed[:len(trimmed)-2]...)
+	out = append(out, '>')
+	out = append(out, child...)
+	return append(out, "</"+name+">"...), true
+}
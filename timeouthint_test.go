@@ -0,0 +1,135 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerTimeoutHint(t *testing.T) {
+	hint := TimeoutHint{
+		Capability: "urn:example:timeout",
+		Namespace:  "urn:example:timeout",
+		Prefix:     "ex",
+		Element:    "timeout",
+		Operations: []string{"validate"},
+	}
+
+	tt := []struct {
+		name    string
+		caps    []string
+		timeout time.Duration
+		hints   []TimeoutHint
+		op      any
+		want    string
+	}{
+		{
+			name:    "validate",
+			caps:    []string{hint.Capability},
+			timeout: 90*time.Second + 500*time.Millisecond,
+			hints:   []TimeoutHint{hint},
+			op:      &ValidateReq{Source: Candidate},
+			want:    `<validate><source><candidate/></source><ex:timeout xmlns:ex="urn:example:timeout">90</ex:timeout></validate>`,
+		},
+		{
+			name:    "milliseconds",
+			caps:    []string{hint.Capability},
+			timeout: time.Minute,
+			hints:   []TimeoutHint{{Element: "timeout-ms", Unit: time.Millisecond}},
+			op:      `<check/>`,
+			want:    `<check><timeout-ms>`,
+		},
+		{
+			name:    "atLeastOneUnit",
+			timeout: 200 * time.Millisecond,
+			hints:   []TimeoutHint{{Namespace: "urn:example:timeout", Element: "timeout"}},
+			op:      `<check/>`,
+			want:    `<check><timeout xmlns="urn:example:timeout">1</timeout></check>`,
+		},
+		{
+			name:    "noCapability",
+			timeout: time.Minute,
+			hints:   []TimeoutHint{hint},
+			op:      &ValidateReq{Source: Candidate},
+			want:    `<validate><source><candidate/></source></validate>`,
+		},
+		{
+			name:    "otherOperation",
+			caps:    []string{hint.Capability},
+			timeout: time.Minute,
+			hints:   []TimeoutHint{hint},
+			op:      &LockReq{Target: Running},
+			want:    `<lock><target><running/></target></lock>`,
+		},
+		{
+			name:  "noDeadline",
+			caps:  []string{hint.Capability},
+			hints: []TimeoutHint{hint},
+			op:    &ValidateReq{Source: Candidate},
+			want:  `<validate><source><candidate/></source></validate>`,
+		},
+		{
+			name:    "firstMatching",
+			caps:    []string{"urn:example:other"},
+			timeout: time.Minute + 500*time.Millisecond,
+			hints:   []TimeoutHint{hint, {Capability: "urn:example:other", Element: "max-time"}},
+			op:      &ValidateReq{Source: Candidate},
+			want:    `<validate><source><candidate/></source><max-time>60</max-time></validate>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []SessionOption
+			for _, h := range tc.hints {
+				opts = append(opts, WithServerTimeoutHint(h))
+			}
+
+			ts := newTestServer(t)
+			sess := newSession(ts.transport(), opts...)
+			sess.serverCaps = newCapabilitySet(tc.caps...)
+			go sess.recv()
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+			_, err := sess.Do(ctx, tc.op)
+			assert.NoError(t, err)
+
+			sentMsg, err := ts.popReqString()
+			assert.NoError(t, err)
+			assert.Contains(t, sentMsg, tc.want)
+		})
+	}
+}
+
+func TestAppendChildXML(t *testing.T) {
+	tt := []struct {
+		name string
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"element", `<a><b>1</b></a>`, `<a><b>1</b><c/></a>`, true},
+		{"selfClosing", `<a/>`, `<a><c/></a>`, true},
+		{"selfClosingAttrs", `<ex:a xmlns:ex="urn:x" />`, `<ex:a xmlns:ex="urn:x" ><c/></ex:a>`, true},
+		{"trailingSpace", "<a></a>\n", `<a><c/></a>`, true},
+		{"text", `a`, ``, false},
+		{"unterminated", `<a>`, ``, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := appendChildXML([]byte(tc.raw), []byte(`<c/>`))
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package netconf
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+
+	nctls "github.com/DinbandhuKumarSingh/netconf/transport/tls"
+)
+
+// DefaultTLSPort is the port assigned to NETCONF over TLS in [RFC7589].
+//
+// [RFC7589]: https://www.rfc-editor.org/rfc/rfc7589.html
+const DefaultTLSPort = "6513"
+
+// DialTLS connects to a NETCONF over TLS server as defined in [RFC7589] and
+// opens a new session with the hello exchange (including the upgrade to
+// chunked framing, same as any other transport).  If `addr` has no port the
+// [DefaultTLSPort] is used.
+//
+// The username of the session (see [Session.Username]) is derived from the
+// first client certificate in `cfg` with [nctls.CertUsername] unless set
+// explicitly with [WithUsername].
+//
+// [RFC7589]: https://www.rfc-editor.org/rfc/rfc7589.html
+func DialTLS(ctx context.Context, addr string, cfg *tls.Config, opts ...SessionOption) (*Session, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultTLSPort)
+	}
+
+	tr, err := nctls.Dial(ctx, "tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if username := tlsCertUsername(cfg); username != "" {
+		// prepend so an explicit WithUsername still wins.
+		opts = append([]SessionOption{usernameOpt(username)}, opts...)
+	}
+
+	return Open(tr, opts...)
+}
+
+// tlsCertUsername returns the username for the first client certificate of the
+// config, if any.
+func tlsCertUsername(cfg *tls.Config) string {
+	if cfg == nil || len(cfg.Certificates) == 0 {
+		return ""
+	}
+
+	cert := cfg.Certificates[0]
+	leaf := cert.Leaf
+	if leaf == nil {
+		if len(cert.Certificate) == 0 {
+			return ""
+		}
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return ""
+		}
+	}
+	return nctls.CertUsername(leaf)
+}
@@ -0,0 +1,94 @@
+package netconf
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCert returns a self-signed certificate with the given common name.
+func newTestCert(t *testing.T, cn string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestDialTLS(t *testing.T) {
+	serverCert := newTestCert(t, "server")
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		io.WriteString(conn, `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities><capability>urn:ietf:params:netconf:base:1.0</capability></capabilities><session-id>42</session-id></hello>]]>]]>`)
+
+		// read until the client hangs up.
+		io.Copy(io.Discard, bufio.NewReader(conn))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg := &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates:       []tls.Certificate{newTestCert(t, "admin")},
+	}
+
+	sess, err := DialTLS(ctx, ln.Addr().String(), cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sess.tr.Close()
+
+	assert.Equal(t, uint64(42), sess.SessionID())
+	assert.Equal(t, "127.0.0.1", sess.Username())
+}
+
+func TestTLSUsernameOverride(t *testing.T) {
+	cfg := &tls.Config{Certificates: []tls.Certificate{newTestCert(t, "admin")}}
+	assert.Equal(t, "127.0.0.1", tlsCertUsername(cfg))
+
+	s := newSession(nil, usernameOpt(tlsCertUsername(cfg)), WithUsername("override"))
+	assert.Equal(t, "override", s.Username())
+}
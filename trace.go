@@ -0,0 +1,101 @@
+package netconf
+
+import (
+	"context"
+	"reflect"
+)
+
+// Tracer starts a span for every rpc issued on a session.  It is a minimal
+// interface to not depend on a specific tracing library.  An adapter for
+// OpenTelemetry looks like:
+//
+//	type otelTracer struct{ tracer trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, netconf.Span) {
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ span trace.Span }
+//
+//	func (s otelSpan) SetAttribute(key string, value any) {
+//		s.span.SetAttributes(attribute.String(key, fmt.Sprint(value)))
+//	}
+//
+//	func (s otelSpan) RecordError(err error) {
+//		s.span.RecordError(err)
+//		s.span.SetStatus(codes.Error, err.Error())
+//	}
+//
+//	func (s otelSpan) End() { s.span.End() }
+//
+// See [WithTracer].
+type Tracer interface {
+	// Start starts a new span named `name` as a child of any span in `ctx`.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced rpc started by a [Tracer].
+type Span interface {
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+// Span attributes set for every rpc.
+const (
+	// AttrMessageID is the message-id of the rpc.
+	AttrMessageID = "netconf.message_id"
+	// AttrSource is the source datastore of the operation, if any.
+	AttrSource = "netconf.source"
+	// AttrTarget is the target datastore of the operation, if any.
+	AttrTarget = "netconf.target"
+)
+
+// startSpan starts a span for the rpc named after the operation.
+func (s *Session) startSpan(ctx context.Context, req any, msgID string) (context.Context, Span) {
+	ctx, span := s.tracer.Start(ctx, "netconf "+operationName(req))
+	span.SetAttribute(AttrMessageID, msgID)
+
+	if ds, ok := requestDatastore(req, "Source"); ok {
+		span.SetAttribute(AttrSource, string(ds))
+	}
+	if ds, ok := requestDatastore(req, "Target"); ok {
+		span.SetAttribute(AttrTarget, string(ds))
+	}
+	return ctx, span
+}
+
+// endSpan records any error of the rpc (including rpc-errors in the reply) and
+// ends the span.
+func endSpan(span Span, reply *Reply, err error) {
+	if err == nil && reply != nil {
+		err = reply.Err()
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// requestDatastore returns the datastore in the struct field `field` of a
+// operation struct.
+func requestDatastore(req any, field string) (Datastore, bool) {
+	rv := reflect.ValueOf(req)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "", false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return "", false
+	}
+
+	fv := rv.FieldByName(field)
+	if !fv.IsValid() || !fv.CanInterface() {
+		return "", false
+	}
+	ds, ok := fv.Interface().(Datastore)
+	return ds, ok && ds != ""
+}
@@ -0,0 +1,66 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+type testSpan struct {
+	name  string
+	attrs map[string]any
+	errs  []error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)              { s.errs = append(s.errs, err) }
+func (s *testSpan) End()                               { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: make(map[string]any)}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, ctxKey{}, span), span
+}
+
+func TestTracer(t *testing.T) {
+	var tracer testTracer
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithTracer(&tracer))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`)
+	_, err := sess.GetConfig(context.Background(), Running)
+	assert.NoError(t, err)
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><rpc-error><error-type>protocol</error-type><error-tag>lock-denied</error-tag><error-severity>error</error-severity></rpc-error></rpc-reply>`)
+	assert.Error(t, sess.Lock(context.Background(), Candidate))
+	_, err = ts.popReq()
+	assert.NoError(t, err)
+
+	assert.Len(t, tracer.spans, 2)
+
+	span := tracer.spans[0]
+	assert.Equal(t, "netconf get-config", span.name)
+	assert.Equal(t, "1", span.attrs[AttrMessageID])
+	assert.Equal(t, "running", span.attrs[AttrSource])
+	assert.NotContains(t, span.attrs, AttrTarget)
+	assert.Empty(t, span.errs)
+	assert.True(t, span.ended)
+
+	span = tracer.spans[1]
+	assert.Equal(t, "netconf lock", span.name)
+	assert.Equal(t, "candidate", span.attrs[AttrTarget])
+	assert.Len(t, span.errs, 1)
+	assert.True(t, span.ended)
+}
@@ -16,6 +16,13 @@ import (
 // framing in RFC6242
 var ErrMalformedChunk = errors.New("netconf: invalid chunk")
 
+// ErrDelimiterInMessage is returned when writing a message containing the
+// End-of-Message delimiter (`]]>]]>`) with End-of-Message framing.  The
+// delimiter would end the message early on the remote side and corrupt the
+// framing of the session.  Chunked framing, used once both sides support
+// `:base:1.1`, has no such restriction.
+var ErrDelimiterInMessage = errors.New("netconf: message contains the end-of-message delimiter ]]>]]>")
+
 type frameReader interface {
 	io.ReadCloser
 	io.ByteReader
@@ -44,6 +51,9 @@ type Framer struct {
 	curWriter frameWriter
 
 	upgraded bool
+	// maxChunkSize is the maximum size of a chunk written with chunked
+	// framing.  Zero means every write is sent as a single chunk.
+	maxChunkSize uint32
 }
 
 // NewFramer return a new Framer to be used against the given io.Reader and io.Writer.
@@ -115,17 +125,43 @@ func (t *Framer) Upgrade() {
 	t.upgraded = true
 }
 
+// Upgraded reports if the Framer switched to Chunked framing.
+func (t *Framer) Upgraded() bool {
+	return t.upgraded
+}
+
+// SetMaxChunkSize limits the size of the chunks written with Chunked framing to
+// n bytes, splitting larger writes into multiple chunks.  The size must be
+// within the bounds of a chunk-size defined in RFC6242 section 4.2 (1 to
+// 4294967295).  By default every write is sent as a single chunk.
+func (t *Framer) SetMaxChunkSize(n int) error {
+	if n < 1 || uint64(n) > maxChunk {
+		return fmt.Errorf("netconf: invalid max chunk size %d: must be between 1 and %d", n, uint64(maxChunk))
+	}
+	t.maxChunkSize = uint32(n)
+	return nil
+}
+
+// MaxChunkSize returns the maximum size of chunks written with Chunked framing
+// set with SetMaxChunkSize or zero if it is not limited.
+func (t *Framer) MaxChunkSize() int {
+	return int(t.maxChunkSize)
+}
+
 // MsgReader returns a new io.Reader that is good for reading exactly one netconf
 // message.
 //
 // Only one reader can be used at a time.  When this is called with an existing
 // reader then the underlying reader is advanced to the start of the next message
 // and invalidates the old reader before returning a new one.
+//
+// A UTF-8 byte order mark at the start of the message is dropped and reading a
+// message that is not valid UTF-8 fails with an *InvalidUTF8Error.
 func (t *Framer) MsgReader() (io.ReadCloser, error) {
 	if t.upgraded {
-		t.curReader = &chunkReader{r: t.br}
+		t.curReader = newUTF8Reader(&chunkReader{r: t.br})
 	} else {
-		t.curReader = &eomReader{r: t.br}
+		t.curReader = newUTF8Reader(&eomReader{r: t.br})
 	}
 	return t.curReader, nil
 }
@@ -141,9 +177,9 @@ func (t *Framer) MsgWriter() (io.WriteCloser, error) {
 	}
 
 	if t.upgraded {
-		t.curWriter = &chunkWriter{w: t.bw}
+		t.curWriter = &chunkWriter{w: t.bw, max: t.maxChunkSize}
 	} else {
-		t.curWriter = &eomWriter{w: t.bw}
+		t.curWriter = &eomWriter{w: t.bw, dst: t.w}
 	}
 	return t.curWriter, nil
 }
@@ -156,9 +192,16 @@ const maxChunk = math.MaxUint32
 type chunkReader struct {
 	r         *bufio.Reader
 	chunkLeft uint32
+	// eof is set once the end-of-chunks marker was read so the reader doesn't
+	// read into the next message.
+	eof bool
 }
 
 func (r *chunkReader) readHeader() error {
+	if r.eof {
+		return io.EOF
+	}
+
 	peeked, err := r.r.Peek(4)
 	switch err {
 	case nil:
@@ -187,6 +230,7 @@ func (r *chunkReader) readHeader() error {
 		// not strictly needed but it is the responsibility of this function to
 		// update chunkLeft.
 		r.chunkLeft = 0
+		r.eof = true
 		return io.EOF
 	}
 
@@ -219,7 +263,9 @@ func (r *chunkReader) Read(p []byte) (int, error) {
 		return 0, ErrInvalidIO
 	}
 	// make sure we can't try to read more than the max chunk
-	p = p[:maxChunk]
+	if len(p) > maxChunk {
+		p = p[:maxChunk]
+	}
 
 	// done with existing chunk so grab the next one
 	if r.chunkLeft <= 0 {
@@ -291,6 +337,8 @@ func (r *chunkReader) Close() error {
 
 type chunkWriter struct {
 	w *bufio.Writer
+	// max is the maximum size of a chunk or zero to write p as one chunk.
+	max uint32
 }
 
 func (w *chunkWriter) Write(p []byte) (int, error) {
@@ -298,11 +346,25 @@ func (w *chunkWriter) Write(p []byte) (int, error) {
 		return 0, ErrInvalidIO
 	}
 
-	if _, err := fmt.Fprintf(w.w, "\n#%d\n", len(p)); err != nil {
-		return 0, err
-	}
+	var n int
+	for len(p) > 0 {
+		chunk := p
+		if w.max > 0 && uint64(len(chunk)) > uint64(w.max) {
+			chunk = chunk[:w.max]
+		}
+
+		if _, err := fmt.Fprintf(w.w, "\n#%d\n", len(chunk)); err != nil {
+			return n, err
+		}
 
-	return w.w.Write(p)
+		written, err := w.w.Write(chunk)
+		n += written
+		if err != nil {
+			return n, err
+		}
+		p = p[len(chunk):]
+	}
+	return n, nil
 }
 
 func (w *chunkWriter) Close() error {
@@ -320,6 +382,9 @@ var endOfMsg = []byte("]]>]]>")
 
 type eomReader struct {
 	r *bufio.Reader
+	// eof is set once the end of the message marker was read so the reader
+	// doesn't read into the next message.
+	eof bool
 }
 
 func (r *eomReader) Read(p []byte) (int, error) {
@@ -340,6 +405,9 @@ func (r *eomReader) ReadByte() (byte, error) {
 	if r.r == nil {
 		return 0, ErrInvalidIO
 	}
+	if r.eof {
+		return 0, io.EOF
+	}
 
 	b, err := r.r.ReadByte()
 	if err != nil {
@@ -365,6 +433,7 @@ func (r *eomReader) ReadByte() (byte, error) {
 				return 0, err
 			}
 
+			r.eof = true
 			return 0, io.EOF
 		}
 	}
@@ -390,19 +459,68 @@ func (r *eomReader) Close() error {
 
 type eomWriter struct {
 	w *bufio.Writer
+	// dst is the writer underneath w used to discard an aborted message.
+	dst io.Writer
+
+	// n is the number of bytes written to the message so far and tail holds
+	// the last bytes of it to find a delimiter spanning multiple writes.
+	n    int
+	tail []byte
+	err  error
 }
 
 func (w *eomWriter) Write(p []byte) (int, error) {
 	if w.w == nil {
 		return 0, ErrInvalidIO
 	}
-	return w.w.Write(p)
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	if w.containsDelimiter(p) {
+		w.err = ErrDelimiterInMessage
+		return 0, w.err
+	}
+
+	n, err := w.w.Write(p)
+	w.n += n
+	return n, err
+}
+
+// containsDelimiter reports if the message would contain the end-of-message
+// delimiter after writing p.
+func (w *eomWriter) containsDelimiter(p []byte) bool {
+	if bytes.Contains(p, endOfMsg) {
+		return true
+	}
+
+	head := p
+	if len(head) > len(endOfMsg)-1 {
+		head = head[:len(endOfMsg)-1]
+	}
+	found := bytes.Contains(append(w.tail, head...), endOfMsg)
+
+	w.tail = append(w.tail, p...)
+	if len(w.tail) > len(endOfMsg)-1 {
+		w.tail = append(w.tail[:0], w.tail[len(w.tail)-(len(endOfMsg)-1):]...)
+	}
+	return found
 }
 
 func (w *eomWriter) Close() error {
 	// poison the writer to prevent writes after close
 	defer func() { w.w = nil }()
 
+	if w.err != nil {
+		// discard the aborted message if none of it was sent yet so the
+		// framing is still intact.
+		if w.n > w.w.Buffered() {
+			return fmt.Errorf("%w: message was partially written", w.err)
+		}
+		w.w.Reset(w.dst)
+		return w.err
+	}
+
 	if err := w.w.WriteByte('\n'); err != nil {
 		return err
 	}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -127,7 +128,7 @@ func TestChunkReaderRead(t *testing.T) {
 
 func TestChunkWriter(t *testing.T) {
 	buf := bytes.Buffer{}
-	w := &chunkWriter{bufio.NewWriter(&buf)}
+	w := &chunkWriter{w: bufio.NewWriter(&buf)}
 
 	n, err := w.Write([]byte("foo"))
 	assert.NoError(t, err)
@@ -144,6 +145,36 @@ func TestChunkWriter(t *testing.T) {
 	assert.Equal(t, want, buf.Bytes())
 }
 
+func TestChunkWriterMaxChunkSize(t *testing.T) {
+	buf := bytes.Buffer{}
+	f := NewFramer(nil, &buf)
+	f.Upgrade()
+	assert.NoError(t, f.SetMaxChunkSize(4))
+
+	w, err := f.MsgWriter()
+	assert.NoError(t, err)
+
+	n, err := w.Write([]byte("abcdefghij"))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	// empty writes must not produce an (invalid) empty chunk.
+	n, err = w.Write(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "\n#4\nabcd\n#4\nefgh\n#2\nij\n##\n", buf.String())
+}
+
+func TestSetMaxChunkSizeInvalid(t *testing.T) {
+	f := NewFramer(nil, nil)
+	assert.Error(t, f.SetMaxChunkSize(0))
+	assert.Error(t, f.SetMaxChunkSize(-1))
+	assert.NoError(t, f.SetMaxChunkSize(1))
+	assert.Equal(t, 1, f.MaxChunkSize())
+}
+
 func BenchmarkChunkedReadByte(b *testing.B) {
 	src := bytes.NewReader(rfcChunkedRPC)
 	readers := []struct {
@@ -247,7 +278,7 @@ func TestEOMReadByte(t *testing.T) {
 	for _, tc := range framedTests {
 		t.Run(tc.name, func(t *testing.T) {
 			r := &eomReader{
-				bufio.NewReader(bytes.NewReader(tc.input)),
+				r: bufio.NewReader(bytes.NewReader(tc.input)),
 			}
 
 			buf := make([]byte, 8192)
@@ -293,6 +324,22 @@ func TestEOMRead(t *testing.T) {
 	}
 }
 
+func TestEOMCloseAfterEOF(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader("foo]]>]]>bar]]>]]>"))
+
+	r := &eomReader{r: br}
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(got))
+	// closing after the end of the message must not consume the next one.
+	assert.NoError(t, r.Close())
+
+	r = &eomReader{r: br}
+	got, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(got))
+}
+
 func TestEOMWriter(t *testing.T) {
 	buf := bytes.Buffer{}
 	w := &eomWriter{w: bufio.NewWriter(&buf)}
@@ -308,6 +355,58 @@ func TestEOMWriter(t *testing.T) {
 	assert.Equal(t, want, buf.Bytes())
 }
 
+func TestEOMWriterDelimiter(t *testing.T) {
+	tt := []struct {
+		name   string
+		writes []string
+	}{
+		{"single", []string{"<value>]]>]]></value>"}},
+		{"split", []string{"<value>]]>", "]]></value>"}},
+		{"splitBytes", []string{"<value>]", "]", ">]", "]>", "</value>"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			f := NewFramer(nil, &buf)
+
+			w, err := f.MsgWriter()
+			assert.NoError(t, err)
+
+			var writeErr error
+			for _, p := range tc.writes {
+				if _, writeErr = io.WriteString(w, p); writeErr != nil {
+					break
+				}
+			}
+			assert.ErrorIs(t, writeErr, ErrDelimiterInMessage)
+			assert.Equal(t, ErrDelimiterInMessage, w.Close())
+
+			// the aborted message is discarded and the next one is intact.
+			w, err = f.MsgWriter()
+			assert.NoError(t, err)
+			_, err = io.WriteString(w, "<rpc/>")
+			assert.NoError(t, err)
+			assert.NoError(t, w.Close())
+			assert.Equal(t, "<rpc/>\n]]>]]>", buf.String())
+		})
+	}
+}
+
+func TestEOMWriterDelimiterPartial(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := &eomWriter{w: bufio.NewWriterSize(&buf, 16), dst: &buf}
+
+	_, err := w.Write([]byte("<config><value>a long value</value>"))
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("]]>]]>"))
+	assert.ErrorIs(t, err, ErrDelimiterInMessage)
+
+	err = w.Close()
+	assert.ErrorIs(t, err, ErrDelimiterInMessage)
+	assert.NotEqual(t, ErrDelimiterInMessage, err)
+}
+
 // force benchmarks to not use any fancy ReadFroms's or other shortcuts
 type onlyReader struct {
 	io.Reader
@@ -2,17 +2,30 @@ package ssh
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/DinbandhuKumarSingh/netconf/transport"
 	"golang.org/x/crypto/ssh"
 )
 
+// ErrSubsystemUnavailable is returned (wrapped) when the ssh server rejects the
+// request for the NETCONF subsystem (i.e it is not enabled on the device) or
+// the command set with WithSSHExec.
+var ErrSubsystemUnavailable = errors.New("ssh: netconf subsystem unavailable")
+
 // alias it to a private type so we can make it private when embedding
 type framer = transport.Framer //nolint:golint,unused
 
+var (
+	_ transport.Transport = (*Transport)(nil)
+	_ transport.Deadliner = (*Transport)(nil)
+)
+
 // Transport implements RFC6242 for implementing NETCONF protocol over SSH.
 type Transport struct {
 	c     *ssh.Client
@@ -24,9 +37,85 @@ type Transport struct {
 	// when used with `Dial`.
 	managed bool
 
+	// conn is the underlying network connection if it is known (i.e the
+	// transport was created with Dial or DialConn).  It is used to set
+	// deadlines.
+	conn net.Conn
+
+	// keepAlive is the interval set with WithSSHKeepAlive.  stop is closed on
+	// Close to stop the keepalive goroutine which closes keepAliveDone once it
+	// exited.
+	keepAlive     time.Duration
+	stop          chan struct{}
+	stopOnce      sync.Once
+	keepAliveDone chan struct{}
+
+	// subsystem or exec are used to start NETCONF on the ssh channel.  Only
+	// one of them is set.
+	subsystem string
+	exec      string
+
 	*framer
 }
 
+// defaultSubsystem is the ssh subsystem for NETCONF defined in RFC6242.
+const defaultSubsystem = "netconf"
+
+// Option is a optional argument to Dial, DialConn or NewTransport.
+type Option interface {
+	apply(*Transport)
+}
+
+type keepAliveOpt time.Duration
+
+func (o keepAliveOpt) apply(t *Transport) { t.keepAlive = time.Duration(o) }
+
+// keepAliveRequest is the global request sent as keepalive.  It is the same as
+// sent by OpenSSH's ServerAliveInterval and any reply (even a failure as for
+// unknown requests) shows the peer is alive.
+const keepAliveRequest = "keepalive@openssh.com"
+
+type subsystemOpt string
+
+func (o subsystemOpt) apply(t *Transport) {
+	t.subsystem = string(o)
+	t.exec = ""
+}
+
+// WithSSHSubsystem starts NETCONF with the ssh subsystem `name` instead of the
+// standard `netconf` subsystem, for devices or test servers that provide it
+// under a different name.
+func WithSSHSubsystem(name string) Option { return subsystemOpt(name) }
+
+type execOpt string
+
+func (o execOpt) apply(t *Transport) {
+	t.exec = string(o)
+	t.subsystem = ""
+}
+
+// WithSSHExec starts NETCONF by executing `cmd` on the ssh channel instead of
+// requesting a subsystem.  This is needed for devices without a NETCONF
+// subsystem that start a NETCONF session from their CLI, i.e older Cisco IOS
+// devices with `xml-mode netconf need-trailer` which use the end-of-message
+// framing.  It overrides WithSSHSubsystem (and vice versa).
+func WithSSHExec(cmd string) Option { return execOpt(cmd) }
+
+// WithSSHKeepAlive sends a `keepalive@openssh.com` global request on the ssh
+// connection every `interval`.  If the device doesn't reply within the next
+// interval the ssh connection is closed, which fails any pending NETCONF
+// operation with a transport error.  A interval of 0 (the default) disables
+// keepalives.
+//
+// Unlike a NETCONF rpc round-trip such as a `<get>` issued with
+// netconf.Session.Call, which waits for the device to process it, the
+// keepalives are handled by the ssh layer of the device and don't interfere
+// with the NETCONF session.  They detect dead peers
+// and keep idle connections open behind firewalls that drop them.  Note that
+// for transports created with NewTransport the ssh.Client is closed as well
+// when a keepalive is not replied to.
+func WithSSHKeepAlive(interval time.Duration) Option { return keepAliveOpt(interval) }
+
 // Dial will connect to a ssh server and issues a transport, it's used as a
 // convenience function as essentially is the same as
 //
@@ -35,13 +124,23 @@ type Transport struct {
 //	 	t, err := NewTransport(c)
 //
 // When the transport is closed the underlying connection is also closed.
-func Dial(ctx context.Context, network, addr string, config *ssh.ClientConfig) (*Transport, error) {
+func Dial(ctx context.Context, network, addr string, config *ssh.ClientConfig, opts ...Option) (*Transport, error) {
 	d := net.Dialer{Timeout: config.Timeout}
 	conn, err := d.DialContext(ctx, network, addr)
 	if err != nil {
 		return nil, err
 	}
 
+	return DialConn(ctx, conn, addr, config, opts...)
+}
+
+// DialConn is like Dial but runs the ssh handshake over an already established
+// connection.  This is used for NETCONF call home ([RFC8071]) where the device
+// opens the TCP connection to the client.  When the transport is closed `conn`
+// is closed as well.
+//
+// [RFC8071]: https://www.rfc-editor.org/rfc/rfc8071.html
+func DialConn(ctx context.Context, conn net.Conn, addr string, config *ssh.ClientConfig, opts ...Option) (*Transport, error) {
 	// Setup a go routine to monitor the context and close the connection.  This
 	// is needed as the underlying ssh library doesn't support contexts so this
 	// approximates a context based cancelation/timeout for the ssh handshake.
@@ -62,6 +161,9 @@ func Dial(ctx context.Context, network, addr string, config *ssh.ClientConfig) (
 
 	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
 	if err != nil {
+		close(done)
+		conn.Close()
+
 		// if there is a context timeout return that error instead of the actual
 		// error from ssh.NewClientConn.
 		if ctx.Err() != nil {
@@ -72,18 +174,33 @@ func Dial(ctx context.Context, network, addr string, config *ssh.ClientConfig) (
 	close(done) // make sure we cleanup the context monitor routine
 
 	client := ssh.NewClient(sshConn, chans, reqs)
-	return newTransport(client, true)
+	t, err := newTransport(client, true, opts)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	t.conn = conn
+	return t, nil
 }
 
 // NewTransport will create a new ssh transport as defined in RFC6242 for use
 // with netconf.  Unlike Dial, the underlying client will not be automatically
 // closed when the transport is closed (however any sessions and subsystems
 // are still closed).
-func NewTransport(client *ssh.Client) (*Transport, error) {
-	return newTransport(client, false)
+func NewTransport(client *ssh.Client, opts ...Option) (*Transport, error) {
+	return newTransport(client, false, opts)
 }
 
-func newTransport(client *ssh.Client, managed bool) (*Transport, error) {
+func newTransport(client *ssh.Client, managed bool, opts []Option) (*Transport, error) {
+	t := &Transport{
+		c:         client,
+		managed:   managed,
+		subsystem: defaultSubsystem,
+	}
+	for _, opt := range opts {
+		opt.apply(t)
+	}
+
 	sess, err := client.NewSession()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ssh session: %w", err)
@@ -91,27 +208,90 @@ func newTransport(client *ssh.Client, managed bool) (*Transport, error) {
 
 	w, err := sess.StdinPipe()
 	if err != nil {
+		sess.Close()
 		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
 	}
 
 	r, err := sess.StdoutPipe()
 	if err != nil {
+		sess.Close()
 		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	const subsystem = "netconf"
-	if err := sess.RequestSubsystem(subsystem); err != nil {
-		return nil, fmt.Errorf("failed to start netconf ssh subsytem: %w", err)
+	if err := t.start(sess); err != nil {
+		sess.Close()
+		return nil, err
 	}
 
-	return &Transport{
-		c:       client,
-		managed: managed,
-		sess:    sess,
-		stdin:   w,
+	t.sess = sess
+	t.stdin = w
+	t.framer = transport.NewFramer(r, w)
 
-		framer: transport.NewFramer(r, w),
-	}, nil
+	if t.keepAlive > 0 {
+		t.stop = make(chan struct{})
+		t.keepAliveDone = make(chan struct{})
+		go t.keepAliveLoop()
+	}
+
+	return t, nil
+}
+
+// start requests the subsystem or executes the command that starts NETCONF on
+// the channel.  This is the same as sess.RequestSubsystem or sess.Start but
+// tells a rejected request apart from a failed connection.
+func (t *Transport) start(sess *ssh.Session) error {
+	kind, name, payload := "subsystem", t.subsystem, ssh.Marshal(struct{ Name string }{t.subsystem})
+	if t.exec != "" {
+		kind, name, payload = "exec", t.exec, ssh.Marshal(struct{ Command string }{t.exec})
+	}
+
+	ok, err := sess.SendRequest(kind, true, payload)
+	if err != nil {
+		return fmt.Errorf("failed to start netconf ssh %s %q: %w", kind, name, err)
+	}
+	if !ok {
+		return fmt.Errorf("failed to start netconf ssh %s %q: %w", kind, name, ErrSubsystemUnavailable)
+	}
+	return nil
+}
+
+// keepAliveLoop sends the keepalives set with WithSSHKeepAlive until the
+// transport is closed or the device stops replying.
+func (t *Transport) keepAliveLoop() {
+	defer close(t.keepAliveDone)
+
+	ticker := time.NewTicker(t.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+
+		// SendRequest blocks until the reply so wait for it here to be able to
+		// give up.
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := t.c.SendRequest(keepAliveRequest, true, nil)
+			replied <- err
+		}()
+
+		select {
+		case <-t.stop:
+			return
+		case err := <-replied:
+			if err != nil {
+				// the connection is gone.
+				return
+			}
+		case <-ticker.C:
+			// no reply within the interval: the device is considered dead.
+			t.c.Close()
+			return
+		}
+	}
 }
 
 // Close will close the underlying transport.  If the connection was created
@@ -123,6 +303,11 @@ func (t *Transport) Close() error {
 	// "lowest" abstraction layer error
 	var retErr error
 
+	if t.stop != nil {
+		t.stopOnce.Do(func() { close(t.stop) })
+		<-t.keepAliveDone
+	}
+
 	if err := t.stdin.Close(); err != nil {
 		retErr = fmt.Errorf("failed to close ssh stdin: %w", err)
 	}
@@ -139,3 +324,35 @@ func (t *Transport) Close() error {
 
 	return retErr
 }
+
+// SetReadDeadline sets the read deadline of the underlying network connection.
+// As the connection is shared by all channels of the ssh connection this also
+// applies to any ssh protocol messages (i.e keepalives).  Transports created
+// with NewTransport don't know the underlying connection and return
+// transport.ErrDeadlineUnsupported.
+func (t *Transport) SetReadDeadline(deadline time.Time) error {
+	if t.conn == nil {
+		return transport.ErrDeadlineUnsupported
+	}
+	return t.conn.SetReadDeadline(deadline)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying network
+// connection.  See SetReadDeadline.
+func (t *Transport) SetWriteDeadline(deadline time.Time) error {
+	if t.conn == nil {
+		return transport.ErrDeadlineUnsupported
+	}
+	return t.conn.SetWriteDeadline(deadline)
+}
+
+// CallHome returns a function that establishes a transport over a connection
+// opened by a device for NETCONF call home ([RFC8071]).  It is meant to be used
+// with netconf.WithCallHomeTransport.
+//
+// [RFC8071]: https://www.rfc-editor.org/rfc/rfc8071.html
+func CallHome(config *ssh.ClientConfig) func(ctx context.Context, conn net.Conn) (transport.Transport, error) {
+	return func(ctx context.Context, conn net.Conn) (transport.Transport, error) {
+		return DialConn(ctx, conn, conn.RemoteAddr().String(), config)
+	}
+}
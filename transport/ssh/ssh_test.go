@@ -7,7 +7,9 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,6 +18,8 @@ import (
 
 type testServer struct {
 	addr net.Addr
+	// keepAlives counts the keepalive requests received.
+	keepAlives atomic.Int32
 }
 
 const hostkey = `
@@ -64,6 +68,10 @@ func newTestServer(t *testing.T, handlerFn func(*testing.T, ssh.Channel, <-chan
 		return nil, err
 	}
 
+	srv := &testServer{
+		addr: ln.Addr(),
+	}
+
 	go func() {
 		nconn, err := ln.Accept()
 		if err != nil {
@@ -76,7 +84,16 @@ func newTestServer(t *testing.T, handlerFn func(*testing.T, ssh.Channel, <-chan
 			t.Logf("failed to create ssh conn: %v", err)
 			return
 		}
-		go ssh.DiscardRequests(reqs)
+		go func() {
+			for req := range reqs {
+				if req.Type == keepAliveRequest {
+					srv.keepAlives.Add(1)
+				}
+				if req.WantReply {
+					_ = req.Reply(false, nil)
+				}
+			}
+		}()
 
 		for newChannel := range chans {
 			if newChannel.ChannelType() != "session" {
@@ -94,9 +111,7 @@ func newTestServer(t *testing.T, handlerFn func(*testing.T, ssh.Channel, <-chan
 		}
 	}()
 
-	return &testServer{
-		addr: ln.Addr(),
-	}, nil
+	return srv, nil
 }
 
 func TestTransport(t *testing.T) {
@@ -151,3 +166,107 @@ func TestTransport(t *testing.T) {
 	want := out + "\n]]>]]>"
 	assert.Equal(t, want, srvIn.String())
 }
+
+func TestKeepAlive(t *testing.T) {
+	server, err := newTestServer(t, func(t *testing.T, ch ssh.Channel, reqs <-chan *ssh.Request) {
+		go func() {
+			for req := range reqs {
+				_ = req.Reply(req.Type == "subsystem", nil)
+			}
+		}()
+		_, _ = io.Copy(io.Discard, ch)
+	})
+	require.NoError(t, err)
+
+	config := &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	tr, err := Dial(context.Background(), "tcp", server.addr.String(), config, WithSSHKeepAlive(10*time.Millisecond))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return server.keepAlives.Load() >= 2 }, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, tr.Close())
+	select {
+	case <-tr.keepAliveDone:
+	default:
+		t.Fatal("keepalive goroutine still running after Close")
+	}
+
+	// no more keepalives are sent.
+	sent := server.keepAlives.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, sent, server.keepAlives.Load())
+}
+
+func TestChannelRequest(t *testing.T) {
+	tt := []struct {
+		name     string
+		opts     []Option
+		wantType string
+		wantErr  error
+	}{
+		{
+			name:     "renamedSubsystem",
+			opts:     []Option{WithSSHSubsystem("xmlagent")},
+			wantType: "subsystem",
+		},
+		{
+			name:     "exec",
+			opts:     []Option{WithSSHExec("xml-mode netconf need-trailer")},
+			wantType: "exec",
+		},
+		{
+			name:     "lastWins",
+			opts:     []Option{WithSSHExec("xml-mode netconf need-trailer"), WithSSHSubsystem("xmlagent")},
+			wantType: "subsystem",
+		},
+		{
+			name:    "defaultSubsystem",
+			wantErr: ErrSubsystemUnavailable,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			server, err := newTestServer(t, func(t *testing.T, ch ssh.Channel, reqs <-chan *ssh.Request) {
+				go func() {
+					for req := range reqs {
+						var ok bool
+						switch req.Type {
+						case "subsystem":
+							var payload struct{ Name string }
+							ok = ssh.Unmarshal(req.Payload, &payload) == nil && payload.Name == "xmlagent"
+						case "exec":
+							var payload struct{ Command string }
+							ok = ssh.Unmarshal(req.Payload, &payload) == nil && payload.Command == "xml-mode netconf need-trailer"
+						}
+						_ = req.Reply(ok, nil)
+						if ok {
+							_, _ = io.WriteString(ch, req.Type+"]]>]]>")
+						}
+					}
+				}()
+				_, _ = io.Copy(io.Discard, ch)
+			})
+			require.NoError(t, err)
+
+			config := &ssh.ClientConfig{
+				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			}
+			tr, err := Dial(context.Background(), "tcp", server.addr.String(), config, tc.opts...)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			defer tr.Close()
+
+			r, err := tr.MsgReader()
+			require.NoError(t, err)
+			msg, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantType, string(msg))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"io"
+	"time"
+)
+
+// alias it to a private type so we can make it private when embedding
+type framer = Framer //nolint:golint,unused
+
+// Stream is a Transport over any bidirectional byte stream like a net.Conn, a
+// serial port or a WebSocket connection adapted to a byte stream.
+//
+// Framing (End-of-Message or Chunked after `Upgrade`) is done on the byte
+// stream itself so it is independent of any message boundaries of the
+// underlying protocol.  For example a NETCONF message can be split across (or
+// share) WebSocket messages.
+type Stream struct {
+	rwc io.ReadWriteCloser
+	*framer
+}
+
+// NewStream returns a new Stream transport reading and writing messages to
+// `rwc`.
+func NewStream(rwc io.ReadWriteCloser) *Stream {
+	return &Stream{
+		rwc:    rwc,
+		framer: NewFramer(rwc, rwc),
+	}
+}
+
+// Close will close the underlying stream.
+func (t *Stream) Close() error {
+	return t.rwc.Close()
+}
+
+// SetReadDeadline sets the read deadline of the underlying stream if it
+// supports deadlines (i.e a net.Conn), otherwise ErrDeadlineUnsupported is
+// returned.
+func (t *Stream) SetReadDeadline(deadline time.Time) error {
+	d, ok := t.rwc.(Deadliner)
+	if !ok {
+		return ErrDeadlineUnsupported
+	}
+	return d.SetReadDeadline(deadline)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying stream if it
+// supports deadlines (i.e a net.Conn), otherwise ErrDeadlineUnsupported is
+// returned.
+func (t *Stream) SetWriteDeadline(deadline time.Time) error {
+	d, ok := t.rwc.(Deadliner)
+	if !ok {
+		return ErrDeadlineUnsupported
+	}
+	return d.SetWriteDeadline(deadline)
+}
+
+var (
+	_ Transport = (*Stream)(nil)
+	_ Deadliner = (*Stream)(nil)
+)
@@ -3,7 +3,10 @@ package tls
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"net"
+	"time"
 
 	"github.com/DinbandhuKumarSingh/netconf/transport"
 )
@@ -11,6 +14,11 @@ import (
 // alias it to a private type so we can make it private when embedding
 type framer = transport.Framer //nolint:golint,unused
 
+var (
+	_ transport.Transport = (*Transport)(nil)
+	_ transport.Deadliner = (*Transport)(nil)
+)
+
 // Transport implements RFC7589 for implementing NETCONF over TLS.
 type Transport struct {
 	conn *tls.Conn
@@ -25,9 +33,22 @@ func Dial(ctx context.Context, network, addr string, config *tls.Config) (*Trans
 		return nil, err
 	}
 
+	return DialConn(ctx, conn, config)
+}
+
+// DialConn runs the TLS handshake as the client over an already established
+// connection and returns a Transport.  This is used for NETCONF call home
+// ([RFC8071]) where the device opens the TCP connection to the client.  `conn`
+// is closed if the handshake fails.
+//
+// [RFC8071]: https://www.rfc-editor.org/rfc/rfc8071.html
+func DialConn(ctx context.Context, conn net.Conn, config *tls.Config) (*Transport, error) {
 	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	return NewTransport(tlsConn), nil
-
 }
 
 // NewTransport takes an already connected tls transport and returns a new
@@ -43,3 +64,40 @@ func NewTransport(conn *tls.Conn) *Transport {
 func (t *Transport) Close() error {
 	return t.conn.Close()
 }
+
+// SetReadDeadline sets the read deadline of the underlying TLS connection.
+func (t *Transport) SetReadDeadline(deadline time.Time) error {
+	return t.conn.SetReadDeadline(deadline)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying TLS connection.
+func (t *Transport) SetWriteDeadline(deadline time.Time) error {
+	return t.conn.SetWriteDeadline(deadline)
+}
+
+// CertUsername derives a NETCONF username from a client certificate following
+// the fallback mapping types of the cert-to-name list in [RFC7589 7] and
+// [RFC7407]: the first rfc822Name (email) subjectAltName, then the first
+// dNSName, then the first iPAddress and finally the subject common name.
+// IPv6 addresses are mapped to 32 lowercase hex characters without colons.
+//
+// The actual mapping is configured on the server.  This is mostly useful for
+// logging and auditing on the client.
+//
+// [RFC7589 7]: https://www.rfc-editor.org/rfc/rfc7589.html#section-7
+// [RFC7407]: https://www.rfc-editor.org/rfc/rfc7407.html
+func CertUsername(cert *x509.Certificate) string {
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.IPAddresses) > 0:
+		ip := cert.IPAddresses[0]
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.String()
+		}
+		return hex.EncodeToString(ip)
+	}
+	return cert.Subject.CommonName
+}
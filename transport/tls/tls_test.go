@@ -0,0 +1,57 @@
+package tls
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertUsername(t *testing.T) {
+	tt := []struct {
+		name string
+		cert x509.Certificate
+		want string
+	}{
+		{
+			name: "email",
+			cert: x509.Certificate{
+				Subject:        pkix.Name{CommonName: "admin"},
+				EmailAddresses: []string{"admin@example.com"},
+				DNSNames:       []string{"client.example.com"},
+			},
+			want: "admin@example.com",
+		},
+		{
+			name: "dns",
+			cert: x509.Certificate{
+				Subject:  pkix.Name{CommonName: "admin"},
+				DNSNames: []string{"client.example.com"},
+			},
+			want: "client.example.com",
+		},
+		{
+			name: "ipv4",
+			cert: x509.Certificate{IPAddresses: []net.IP{net.ParseIP("192.0.2.1")}},
+			want: "192.0.2.1",
+		},
+		{
+			name: "ipv6",
+			cert: x509.Certificate{IPAddresses: []net.IP{net.ParseIP("2001:db8::1")}},
+			want: "20010db8000000000000000000000001",
+		},
+		{
+			name: "common name",
+			cert: x509.Certificate{Subject: pkix.Name{CommonName: "admin"}},
+			want: "admin",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, CertUsername(&tc.cert))
+		})
+	}
+}
@@ -3,6 +3,7 @@ package transport
 import (
 	"errors"
 	"io"
+	"time"
 )
 
 var (
@@ -14,6 +15,10 @@ var (
 	// message io.Reader or a message io.Writer when they are no longer valid.
 	// (i.e a new reader or writer has been obtained)
 	ErrInvalidIO = errors.New("netconf: read/write on invalid io")
+
+	// ErrDeadlineUnsupported is returned when setting a deadline on a
+	// transport whose underlying connection doesn't support deadlines.
+	ErrDeadlineUnsupported = errors.New("netconf: transport does not support deadlines")
 )
 
 // Transport is used for a netconf.Session to talk to the device.  It is message
@@ -34,3 +39,10 @@ type Transport interface {
 	// Close will close the underlying transport.
 	Close() error
 }
+
+// Deadliner is implemented by transports that can set read and write deadlines
+// on the underlying connection (see net.Conn).  A zero time means no deadline.
+type Deadliner interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
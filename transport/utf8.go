@@ -0,0 +1,248 @@
+package transport
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ErrInvalidUTF8 is returned (wrapped in a *InvalidUTF8Error) when reading a
+// message that is not valid UTF-8.  RFC6241 requires all NETCONF messages to
+// be encoded in UTF-8.
+var ErrInvalidUTF8 = errors.New("netconf: message is not valid UTF-8")
+
+// InvalidUTF8Error is returned when reading a message containing a byte
+// sequence that is not valid UTF-8.  It describes where the message is
+// corrupted instead of the opaque error of the xml decoder.
+type InvalidUTF8Error struct {
+	// Offset is the offset within the message of the start of the invalid
+	// sequence.
+	Offset int64
+	// Context holds the bytes around the invalid byte.
+	Context []byte
+	// ContextOffset is the offset of the first byte of Context within the
+	// message.
+	ContextOffset int64
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("%v: invalid byte at offset %d, message bytes from offset %d:\n%s",
+		ErrInvalidUTF8, e.Offset, e.ContextOffset, hex.Dump(e.Context))
+}
+
+func (e *InvalidUTF8Error) Unwrap() error { return ErrInvalidUTF8 }
+
+const (
+	// utf8Context is the number of bytes before and after the invalid byte
+	// kept for the InvalidUTF8Error.
+	utf8Context = 16
+)
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// utf8Reader wraps the reader of a message to strip a leading UTF-8 byte order
+// mark, which some devices send but encoding/xml rejects, and to validate that
+// the message is UTF-8.
+type utf8Reader struct {
+	r   frameReader
+	off int64
+	// started is set once the start of the message was checked for a BOM.
+	started bool
+	// partial holds bytes read but not yet validated, either the start of the
+	// message read while looking for a BOM or a sequence that was cut off at
+	// the end of the last read.
+	partial    []byte
+	partialBuf [utf8.UTFMax]byte
+
+	// pending are the validated bytes in buf not yet returned.  buf is only
+	// used for reads too small to hold a complete sequence (i.e ReadByte).
+	pending []byte
+	buf     [512]byte
+
+	// recent are the last bytes read for the context of an error.
+	recent []byte
+	err    error
+}
+
+func newUTF8Reader(r frameReader) *utf8Reader {
+	return &utf8Reader{r: r}
+}
+
+func (r *utf8Reader) Read(p []byte) (int, error) {
+	if len(r.pending) > 0 {
+		n := copy(p, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if !r.started {
+		r.started = true
+		if err := r.stripBOM(); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(p) < utf8.UTFMax {
+		n, err := r.read(r.buf[:])
+		r.pending = r.buf[:n]
+		n = copy(p, r.pending)
+		r.pending = r.pending[n:]
+		if len(r.pending) > 0 {
+			// the error is returned again by the next read
+			return n, nil
+		}
+		return n, err
+	}
+	return r.read(p)
+}
+
+func (r *utf8Reader) ReadByte() (byte, error) {
+	if len(r.pending) > 0 {
+		b := r.pending[0]
+		r.pending = r.pending[1:]
+		return b, nil
+	}
+
+	var b [1]byte
+	for {
+		n, err := r.Read(b[:])
+		if n > 0 {
+			return b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// read reads into `p` (which must hold at least utf8.UTFMax bytes) and
+// validates what was read.  A sequence cut off at the end is kept in partial
+// and returned by the next read once it is complete.
+func (r *utf8Reader) read(p []byte) (int, error) {
+	n := copy(p, r.partial)
+	r.partial = r.partial[:0]
+	for {
+		m, err := r.r.Read(p[n:])
+		n += m
+		if err != nil {
+			r.err = err
+		}
+
+		valid, verr := r.validate(p[:n], err == io.EOF)
+		if verr != nil {
+			r.err = verr
+			return valid, verr
+		}
+		r.partial = append(r.partialBuf[:0], p[valid:n]...)
+		if valid > 0 || err != nil {
+			return valid, err
+		}
+		// only the start of a sequence was read.
+		n = copy(p, r.partial)
+		r.partial = r.partial[:0]
+	}
+}
+
+// validate checks that `b` is UTF-8 and returns the number of bytes up to the
+// end of the last complete sequence.  The remaining bytes are the start of a
+// sequence which is invalid if `eof` is set.
+func (r *utf8Reader) validate(b []byte, eof bool) (int, error) {
+	if utf8.Valid(b) {
+		r.advance(b)
+		return len(b), nil
+	}
+
+	i := 0
+	for i < len(b) {
+		if b[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if !utf8.FullRune(b[i:]) {
+			if !eof {
+				break
+			}
+			// the message ends in the middle of a sequence.
+			r.advance(b[:i])
+			return i, r.invalid(b[i:], nil)
+		}
+		c, size := utf8.DecodeRune(b[i:])
+		if c == utf8.RuneError && size == 1 {
+			r.advance(b[:i])
+			return i, r.invalid(b[i:], r.r)
+		}
+		i += size
+	}
+	r.advance(b[:i])
+	return i, nil
+}
+
+// advance moves the offset past the valid bytes `b`.
+func (r *utf8Reader) advance(b []byte) {
+	r.off += int64(len(b))
+	if len(b) > utf8Context {
+		b = b[len(b)-utf8Context:]
+	}
+	if drop := len(r.recent) + len(b) - utf8Context; drop > 0 {
+		r.recent = r.recent[:copy(r.recent, r.recent[drop:])]
+	}
+	r.recent = append(r.recent, b...)
+}
+
+// stripBOM reads the start of the message dropping a byte order mark.  Any
+// other bytes read are kept in partial.
+func (r *utf8Reader) stripBOM() error {
+	for i := range utf8BOM {
+		b, err := r.r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r.partial = append(r.partialBuf[:len(r.partial)], b)
+		if b != utf8BOM[i] {
+			return nil
+		}
+	}
+	r.partial = nil
+	r.off = int64(len(utf8BOM))
+	return nil
+}
+
+// invalid returns the error for an invalid sequence at the start of `rest`.
+// If `more` is set bytes are read from it to show what follows the invalid
+// byte.
+func (r *utf8Reader) invalid(rest []byte, more io.ByteReader) error {
+	if len(rest) > utf8Context+1 {
+		rest = rest[:utf8Context+1]
+	}
+	context := append([]byte(nil), r.recent...)
+	context = append(context, rest...)
+	for i := len(rest); more != nil && i <= utf8Context; i++ {
+		b, err := more.ReadByte()
+		if err != nil {
+			break
+		}
+		context = append(context, b)
+	}
+
+	return &InvalidUTF8Error{
+		Offset:        r.off,
+		Context:       context,
+		ContextOffset: r.off - int64(len(r.recent)),
+	}
+}
+
+// Close consumes the rest of the message without validating it.
+func (r *utf8Reader) Close() error {
+	return r.r.Close()
+}
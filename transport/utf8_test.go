@@ -0,0 +1,127 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgReaderBOM(t *testing.T) {
+	const reply = "\xef\xbb\xbf<rpc-reply message-id=\"1\"><ok/></rpc-reply>"
+
+	f := NewFramer(strings.NewReader(reply+"]]>]]>"+reply+"]]>]]>"), io.Discard)
+	for i := 0; i < 2; i++ {
+		r, err := f.MsgReader()
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, `<rpc-reply message-id="1"><ok/></rpc-reply>`, string(got))
+		assert.NoError(t, r.Close())
+	}
+
+	// a BOM is only stripped at the start of the message.
+	f = NewFramer(strings.NewReader("<a>\xef\xbb\xbf</a>]]>]]>"), io.Discard)
+	r, err := f.MsgReader()
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "<a>\xef\xbb\xbf</a>", string(got))
+}
+
+func TestMsgReaderInvalidUTF8(t *testing.T) {
+	tt := []struct {
+		name       string
+		msg        string
+		wantOffset int64
+	}{
+		{"latin1", "<rpc-reply><data>caf\xe9</data></rpc-reply>", 20},
+		{"overlong", "<a>\xc0\xaf</a>", 3},
+		{"surrogate", "<a>\xed\xa0\x80</a>", 3},
+		{"truncated", "<a/>\xe2\x82", 4},
+		{"bomOffset", "\xef\xbb\xbf<a>\xff</a>", 6},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFramer(strings.NewReader(tc.msg+"]]>]]><ok/>]]>]]>"), io.Discard)
+			r, err := f.MsgReader()
+			require.NoError(t, err)
+
+			_, err = io.ReadAll(r)
+			assert.ErrorIs(t, err, ErrInvalidUTF8)
+			var utf8Err *InvalidUTF8Error
+			if assert.True(t, errors.As(err, &utf8Err)) {
+				assert.Equal(t, tc.wantOffset, utf8Err.Offset)
+				assert.Contains(t, err.Error(), "00000000")
+			}
+
+			// the rest of the message is skipped.
+			assert.NoError(t, r.Close())
+			r, err = f.MsgReader()
+			require.NoError(t, err)
+			got, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, "<ok/>", string(got))
+		})
+	}
+}
+
+// chunksReader returns one of `chunks` per read.
+type chunksReader struct {
+	chunks []string
+}
+
+func (r *chunksReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func (r *chunksReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := r.Read(b[:])
+	return b[0], err
+}
+
+func (r *chunksReader) Close() error { return nil }
+
+func TestUTF8ReaderSplitSequence(t *testing.T) {
+	r := newUTF8Reader(&chunksReader{chunks: []string{"<a>\xe2", "\x82", "\xac</a>"}})
+
+	// the data available is returned right away without the start of the
+	// cut off sequence.
+	buf := make([]byte, 64)
+	n, err := r.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "<a>", string(buf[:n]))
+
+	n, err = r.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "€</a>", string(buf[:n]))
+
+	_, err = r.Read(buf)
+	assert.Equal(t, io.EOF, err)
+
+	// reading byte by byte validates the same.
+	r = newUTF8Reader(&chunksReader{chunks: []string{"<a>\xe2", "\x82", "\xac</a>"}})
+	var got []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		got = append(got, b)
+	}
+	assert.Equal(t, "<a>€</a>", string(got))
+}
@@ -0,0 +1,122 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInvalidReply is returned (wrapped) by [ValidateReply] when the data of a
+// reply doesn't conform to the expected structure.
+var ErrInvalidReply = errors.New("netconf: reply does not match the expected structure")
+
+// ReplyValidator validates the data returned by a request (i.e the config
+// returned by [Session.GetConfig]) before it's used.  Implementations can do
+// simple structural checks like [StructuralValidator] or wrap an XSD or YANG
+// validation library, which this package doesn't depend on.
+type ReplyValidator interface {
+	Validate(data []byte) error
+}
+
+// ReplyValidatorFunc is an adapter to allow the use of an ordinary function as
+// a [ReplyValidator].
+type ReplyValidatorFunc func(data []byte) error
+
+// Validate calls f(data).
+func (f ReplyValidatorFunc) Validate(data []byte) error {
+	return f(data)
+}
+
+// ValidateReply validates `data` with `validator`.  Any error is wrapped with
+// [ErrInvalidReply].
+//
+//	config, err := sess.GetConfig(ctx, netconf.Running)
+//	if err != nil { /* ... */ }
+//	if err := netconf.ValidateReply(config, validator); err != nil { /* ... */ }
+func ValidateReply(data []byte, validator ReplyValidator) error {
+	if validator == nil {
+		return errors.New("netconf: no reply validator")
+	}
+	if err := validator.Validate(data); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidReply, err)
+	}
+	return nil
+}
+
+// StructuralValidator is a [ReplyValidator] checking the elements of the data
+// without a schema.  Paths are the local names of the elements from the top
+// level separated by `/` (i.e `/interfaces/interface/name`).  All the
+// problems found are reported together.
+type StructuralValidator struct {
+	// Namespace, if not empty, is the namespace the top level elements must
+	// be in.
+	Namespace string
+	// Required are the paths of the elements that must be present at least
+	// once.
+	Required []string
+	// Allowed, if not empty, are the paths of the only elements that may be
+	// present along with their ancestors and everything below them.
+	Allowed []string
+}
+
+// Validate implements [ReplyValidator].
+func (v *StructuralValidator) Validate(data []byte) error {
+	var (
+		errs []error
+		path []string
+		seen = make(map[string]bool)
+	)
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("malformed xml: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			path = append(path, tok.Name.Local)
+			p := "/" + strings.Join(path, "/")
+
+			if len(path) == 1 && v.Namespace != "" && tok.Name.Space != v.Namespace {
+				errs = append(errs, fmt.Errorf("element %s in namespace %q instead of %q", p, tok.Name.Space, v.Namespace))
+			}
+			if len(v.Allowed) > 0 && !v.allowed(p) {
+				errs = append(errs, fmt.Errorf("unexpected element %s", p))
+				if err := dec.Skip(); err != nil {
+					return fmt.Errorf("malformed xml: %w", err)
+				}
+				path = path[:len(path)-1]
+				continue
+			}
+			seen[p] = true
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+
+	for _, p := range v.Required {
+		if !seen["/"+strings.Trim(p, "/")] {
+			errs = append(errs, fmt.Errorf("missing element %s", p))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// allowed reports if the element at `path` is allowed by [StructuralValidator.Allowed].
+func (v *StructuralValidator) allowed(path string) bool {
+	for _, a := range v.Allowed {
+		a = "/" + strings.Trim(a, "/")
+		if a == path || strings.HasPrefix(a, path+"/") || strings.HasPrefix(path, a+"/") {
+			return true
+		}
+	}
+	return false
+}
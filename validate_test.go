@@ -0,0 +1,73 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuralValidator(t *testing.T) {
+	const config = `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">` +
+		`<interface><name>eth0</name><mtu>1500</mtu><ipv4><address>10.0.0.1</address></ipv4></interface>` +
+		`</interfaces>`
+
+	validator := &StructuralValidator{
+		Namespace: "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+		Required:  []string{"/interfaces/interface/name"},
+		Allowed:   []string{"/interfaces/interface/name", "/interfaces/interface/mtu", "/interfaces/interface/ipv4"},
+	}
+
+	tt := []struct {
+		name    string
+		data    string
+		wantErr []string
+	}{
+		{"valid", config, nil},
+		{"empty", ``, []string{"missing element /interfaces/interface/name"}},
+		{"namespace", `<interfaces xmlns="urn:example"><interface><name>eth0</name></interface></interfaces>`,
+			[]string{`element /interfaces in namespace "urn:example"`}},
+		{"unexpected", `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">` +
+			`<interface><name>eth0</name><speed>1000</speed></interface><system/></interfaces>`,
+			[]string{"unexpected element /interfaces/interface/speed", "unexpected element /interfaces/system"}},
+		{"missingAndUnexpected", `<system xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"/>`,
+			[]string{"unexpected element /system", "missing element /interfaces/interface/name"}},
+		{"malformed", `<interfaces><interface></interfaces>`, []string{"malformed xml"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateReply([]byte(tc.data), validator)
+			if tc.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidReply)
+			for _, want := range tc.wantErr {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
+
+func TestValidateReplyGetConfig(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>` +
+		`<system xmlns="urn:example:system"><hostname>r1</hostname></system></data></rpc-reply>`)
+	config, err := sess.GetConfig(context.Background(), Running)
+	require.NoError(t, err)
+
+	assert.NoError(t, ValidateReply(config, &StructuralValidator{Required: []string{"/system/hostname"}}))
+
+	errBadShape := errors.New("bad shape")
+	err = ValidateReply(config, ReplyValidatorFunc(func(data []byte) error { return errBadShape }))
+	assert.ErrorIs(t, err, ErrInvalidReply)
+	assert.ErrorIs(t, err, errBadShape)
+
+	assert.Error(t, ValidateReply(config, nil))
+}
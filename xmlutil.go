@@ -0,0 +1,84 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+// writeRawToken writes a token as returned from xml.Decoder.RawToken back out as
+// xml.  Unlike xml.Encoder the namespace prefixes are kept as-is and no
+// namespace declarations are added or changed.
+func writeRawToken(buf *bytes.Buffer, tok xml.Token) error {
+	switch t := tok.(type) {
+	case xml.StartElement:
+		buf.WriteByte('<')
+		writeRawName(buf, t.Name)
+		for _, attr := range t.Attr {
+			buf.WriteByte(' ')
+			writeRawName(buf, attr.Name)
+			buf.WriteString(`="`)
+			writeRawEscaped(buf, []byte(attr.Value), true)
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('>')
+	case xml.EndElement:
+		buf.WriteString("</")
+		writeRawName(buf, t.Name)
+		buf.WriteByte('>')
+	case xml.CharData:
+		writeRawEscaped(buf, t, false)
+	case xml.Comment:
+		buf.WriteString("<!--")
+		buf.Write(t)
+		buf.WriteString("-->")
+	case xml.ProcInst:
+		buf.WriteString("<?")
+		buf.WriteString(t.Target)
+		if len(t.Inst) > 0 {
+			buf.WriteByte(' ')
+			buf.Write(t.Inst)
+		}
+		buf.WriteString("?>")
+	case xml.Directive:
+		buf.WriteString("<!")
+		buf.Write(t)
+		buf.WriteByte('>')
+	}
+	return nil
+}
+
+// writeRawName writes the (unresolved) prefixed name of an element or
+// attribute.
+func writeRawName(buf *bytes.Buffer, name xml.Name) {
+	if name.Space != "" {
+		buf.WriteString(name.Space)
+		buf.WriteByte(':')
+	}
+	buf.WriteString(name.Local)
+}
+
+// writeRawEscaped writes text escaping only the characters that would otherwise
+// change the markup (and `"` in attribute values).  Unlike xml.EscapeText
+// newlines and tabs are kept as-is.
+func writeRawEscaped(buf *bytes.Buffer, text []byte, attr bool) {
+	last := 0
+	for i, c := range text {
+		var esc string
+		switch {
+		case c == '&':
+			esc = "&amp;"
+		case c == '<':
+			esc = "&lt;"
+		case c == '>':
+			esc = "&gt;"
+		case c == '"' && attr:
+			esc = "&quot;"
+		default:
+			continue
+		}
+		buf.Write(text[last:i])
+		buf.WriteString(esc)
+		last = i + 1
+	}
+	buf.Write(text[last:])
+}
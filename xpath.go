@@ -0,0 +1,271 @@
+package netconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// xpathStep is a single location step of a XPath expression (i.e
+// `interface[name='eth0']`).
+type xpathStep struct {
+	// prefix is the optional namespace prefix of the step (i.e `if` for
+	// `if:interfaces`).
+	prefix string
+	name   string
+	// matches are the `key='value'` predicates of the step which become
+	// content match nodes in a subtree filter.
+	matches []xpathMatch
+}
+
+func (s xpathStep) hasMatch(name string) bool {
+	for _, m := range s.matches {
+		if m.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type xpathMatch struct {
+	name  string
+	value string
+}
+
+// parseXPath parses the subset of XPath that can be expressed as a subtree
+// filter: an absolute location path of child steps (optionally with a namespace
+// prefix) with optional `key='value'` predicates.  A quote in a value is
+// escaped by doubling it or with a backslash (see scanXPathLiteral).
+//
+// Any other predicate (i.e `[1]`, `[position()=1]` or `[mtu > 1500]`) cannot be
+// expressed in a subtree filter and returns an error naming the offending step
+// instead of silently selecting more than asked for.
+func parseXPath(xpath string) ([]xpathStep, error) {
+	if !strings.HasPrefix(xpath, "/") {
+		return nil, fmt.Errorf("invalid XPath %q: must start with '/'", xpath)
+	}
+
+	var steps []xpathStep
+
+	rest := xpath
+	for rest != "" {
+		if rest[0] != '/' {
+			return nil, fmt.Errorf("invalid XPath %q: expected '/' at %q", xpath, rest)
+		}
+		rest = rest[1:]
+
+		n := xpathNameLen(rest)
+		if n == 0 {
+			return nil, fmt.Errorf("invalid XPath %q: expected element name at %q", xpath, "/"+rest)
+		}
+		step := xpathStep{name: rest[:n]}
+		rest = rest[n:]
+
+		if strings.HasPrefix(rest, ":") {
+			m := xpathNameLen(rest[1:])
+			if m == 0 {
+				return nil, fmt.Errorf("invalid XPath %q: expected element name after prefix %q", xpath, step.name)
+			}
+			step.prefix, step.name = step.name, rest[1:m+1]
+			rest = rest[m+1:]
+		}
+
+		for strings.HasPrefix(rest, "[") {
+			end := xpathPredicateEnd(rest)
+			if end == -2 {
+				return nil, fmt.Errorf("invalid XPath %q: unterminated quoted value in step %q", xpath, step.name+rest)
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("invalid XPath %q: unterminated predicate in step %q", xpath, step.name+rest)
+			}
+			pred := rest[1:end]
+			rest = rest[end+1:]
+			segment := step.name + "[" + pred + "]"
+
+			if isXPathPosition(pred) {
+				return nil, fmt.Errorf("invalid XPath %q: positional predicate in step %q cannot be expressed in a subtree filter", xpath, segment)
+			}
+
+			match, ok := parseXPathMatch(pred)
+			if !ok && strings.ContainsAny(pred, `'"`) {
+				return nil, fmt.Errorf("invalid XPath %q: malformed quoted value in step %q", xpath, segment)
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid XPath %q: unsupported predicate in step %q: only [name='value'] predicates are supported", xpath, segment)
+			}
+			step.matches = append(step.matches, match)
+		}
+
+		steps = append(steps, step)
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid XPath %q: no location steps", xpath)
+	}
+
+	return steps, nil
+}
+
+// xpathNameLen returns the length of the element name at the start of s.
+func xpathNameLen(s string) int {
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case i > 0 && (r >= '0' && r <= '9' || r == '-' || r == '.'):
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
+
+// xpathPredicateEnd returns the index of the `]` closing the predicate at the
+// start of s taking quoted strings into account or -1 if there is none (-2 if
+// a quoted string is not terminated).
+func xpathPredicateEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			_, n := scanXPathLiteral(s[i:])
+			if n < 0 {
+				return -2
+			}
+			i += n - 1
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// scanXPathLiteral scans the quoted string literal at the start of s.  It
+// returns the unescaped value and the length of the literal including the
+// quotes, or -1 if it is not terminated.
+//
+// Inside the literal the quote character is escaped either by doubling it as
+// in XPath 2.0 or with a backslash (`"say \"hi\""`), and `\\` is a backslash.
+// Any other backslash is kept as-is, so a value ending in a backslash must
+// have it doubled as done by quoteXPathLiteral.
+func scanXPathLiteral(s string) (string, int) {
+	quote := s[0]
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == quote || s[i+1] == '\\'):
+			value.WriteByte(s[i+1])
+			i++
+		case c == quote && i+1 < len(s) && s[i+1] == quote:
+			value.WriteByte(quote)
+			i++
+		case c == quote:
+			return value.String(), i + 1
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", -1
+}
+
+func isXPathPosition(pred string) bool {
+	pred = strings.TrimSpace(pred)
+	if pred == "" {
+		return false
+	}
+	for _, r := range pred {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseXPathMatch parses a `name='value'` (or `name="value"`) predicate.
+func parseXPathMatch(pred string) (xpathMatch, bool) {
+	pred = strings.TrimSpace(pred)
+
+	n := xpathNameLen(pred)
+	if n == 0 {
+		return xpathMatch{}, false
+	}
+	name := pred[:n]
+
+	rest := strings.TrimSpace(pred[n:])
+	if !strings.HasPrefix(rest, "=") {
+		return xpathMatch{}, false
+	}
+	rest = strings.TrimSpace(rest[1:])
+
+	if len(rest) < 2 || (rest[0] != '\'' && rest[0] != '"') {
+		return xpathMatch{}, false
+	}
+	value, n := scanXPathLiteral(rest)
+	if n != len(rest) {
+		return xpathMatch{}, false
+	}
+	return xpathMatch{name: name, value: value}, true
+}
+
+// XPathSegment is a location step of an XPath built with [XPath].
+type XPathSegment struct {
+	// Name is the name of the element, optionally with a namespace prefix
+	// (i.e `if:interfaces`).
+	Name string
+	// Keys select the entries of a list.
+	Keys []XPathKey
+}
+
+// XPathKey is a `[name='value']` predicate of an [XPathSegment].
+type XPathKey struct {
+	Name  string
+	Value string
+}
+
+// Elem returns the [XPathSegment] for the element `name` with the given keys.
+func Elem(name string, keys ...XPathKey) XPathSegment {
+	return XPathSegment{Name: name, Keys: keys}
+}
+
+// Key returns the predicate selecting the list entries whose key leaf `name`
+// has the given value.  The value is used as-is and may contain any character
+// including quotes, backslashes and brackets.
+func Key(name, value string) XPathKey {
+	return XPathKey{Name: name, Value: value}
+}
+
+// XPath builds the absolute XPath of the segments for [WithFilter] and the
+// other functions taking the XPath subset of subtree filters, quoting the key
+// values so that quotes or brackets in them can't break the expression:
+//
+//	netconf.XPath(
+//		netconf.Elem("interfaces"),
+//		netconf.Elem("interface", netconf.Key("name", "eth0")),
+//		netconf.Elem("description"),
+//	) // /interfaces/interface[name='eth0']/description
+//
+// A value with both single and double quotes is quoted with single quotes and
+// the single quotes in it doubled as in XPath 2.0, which is understood by the
+// filter functions of this package but not by XPath 1.0 servers.  Backslashes
+// are doubled as the filter functions take `\'` and `\"` as escaped quotes.
+func XPath(segments ...XPathSegment) string {
+	var sb strings.Builder
+	for _, seg := range segments {
+		sb.WriteString("/" + seg.Name)
+		for _, k := range seg.Keys {
+			sb.WriteString("[" + k.Name + "=" + quoteXPathLiteral(k.Value) + "]")
+		}
+	}
+	return sb.String()
+}
+
+// quoteXPathLiteral returns `s` as a quoted string literal understood by
+// scanXPathLiteral.
+func quoteXPathLiteral(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	switch {
+	case !strings.Contains(s, "'"):
+		return "'" + s + "'"
+	case !strings.Contains(s, `"`):
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
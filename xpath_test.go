@@ -0,0 +1,254 @@
+package netconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseXPathToXML(t *testing.T) {
+	tt := []struct {
+		name  string
+		xpath string
+		want  string
+	}{
+		{"single", "/interfaces", "<interfaces/>"},
+		{"nested", "/interfaces/interface", "<interfaces><interface/></interfaces>"},
+		{
+			name:  "predicate",
+			xpath: `/library/book[title="Go Programming"]`,
+			want:  "<library><book><title>Go Programming</title></book></library>",
+		},
+		{
+			name:  "multiplePredicates",
+			xpath: "/routes/route[vrf='red'][prefix = '10.0.0.0/8']",
+			want:  "<routes><route><vrf>red</vrf><prefix>10.0.0.0/8</prefix></route></routes>",
+		},
+		{
+			name:  "bracketInValue",
+			xpath: "/a/b[name='x]y']",
+			want:  "<a><b><name>x]y</name></b></a>",
+		},
+		{
+			name:  "escapedValue",
+			xpath: "/a/b[name='<&>']",
+			want:  "<a><b><name>&lt;&amp;&gt;</name></b></a>",
+		},
+		{
+			name:  "spaces",
+			xpath: "/system/motd[ text = '  Authorized  use only ' ]",
+			want:  "<system><motd><text>  Authorized  use only </text></motd></system>",
+		},
+		{
+			name:  "backslashEscapedQuote",
+			xpath: `/library/book[title="He said \"hi\""]`,
+			want:  "<library><book><title>He said &#34;hi&#34;</title></book></library>",
+		},
+		{
+			name:  "doubledQuote",
+			xpath: "/library/book[title='it''s']",
+			want:  "<library><book><title>it&#39;s</title></book></library>",
+		},
+		{
+			name:  "otherQuote",
+			xpath: `/library/book[title='say "hi"'][author="O'Brien"]`,
+			want:  "<library><book><title>say &#34;hi&#34;</title><author>O&#39;Brien</author></book></library>",
+		},
+		{
+			name:  "backslashes",
+			xpath: `/files/file[path='C:\dir\\'][name='a\b']`,
+			want:  `<files><file><path>C:\dir\</path><name>a\b</name></file></files>`,
+		},
+		{
+			name:  "specialChars",
+			xpath: `/a/b[name="<it's> & \"more\""]`,
+			want:  "<a><b><name>&lt;it&#39;s&gt; &amp; &#34;more&#34;</name></b></a>",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseXPathToXML(tc.xpath, filterSpec{})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseXPathToXMLEmptySelection(t *testing.T) {
+	tt := []struct {
+		name   string
+		xpaths []string
+		spec   filterSpec
+		want   string
+	}{
+		{
+			name:   "allEntries",
+			xpaths: []string{"/if:interfaces/interface"},
+			spec:   filterSpec{prefixes: map[string]string{"if": "urn:ietf:params:xml:ns:yang:ietf-interfaces"}},
+			want:   `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><interface/></interfaces>`,
+		},
+		{
+			name:   "alongsideEntry",
+			xpaths: []string{"/interfaces/interface[name='eth0']/mtu", "/interfaces/interface"},
+			want:   "<interfaces><interface><name>eth0</name><mtu/></interface><interface/></interfaces>",
+		},
+		{
+			// only a element without any content is a selection node.
+			name:   "selectLeaves",
+			xpaths: []string{"/interfaces/interface"},
+			spec:   filterSpec{selectLeaves: []string{"name"}},
+			want:   "<interfaces><interface><name/></interface></interfaces>",
+		},
+		{
+			name:   "contentMatch",
+			xpaths: []string{"/interfaces/interface"},
+			spec:   filterSpec{contentMatches: []xpathMatch{{name: "enabled", value: "true"}}},
+			want:   "<interfaces><interface><enabled>true</enabled></interface></interfaces>",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseXPathsToXML(tc.xpaths, tc.spec)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseXPathsToXMLAncestor(t *testing.T) {
+	tt := []struct {
+		name   string
+		xpaths []string
+		want   string
+	}{
+		{
+			name:   "ancestorFirst",
+			xpaths: []string{"/interfaces", "/interfaces/interface[name='eth0']"},
+			want:   "<interfaces/>",
+		},
+		{
+			name:   "ancestorLast",
+			xpaths: []string{"/interfaces/interface[name='eth0']/mtu", "/interfaces/interface[name='eth1']", "/interfaces"},
+			want:   "<interfaces/>",
+		},
+		{
+			name:   "entry",
+			xpaths: []string{"/interfaces/interface[name='eth0']/mtu", "/interfaces/interface[name='eth0']", "/system"},
+			want:   "<interfaces><interface><name>eth0</name></interface></interfaces><system/>",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseXPathsToXML(tc.xpaths, filterSpec{})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseXPathPrefix(t *testing.T) {
+	steps, err := parseXPath("/if:interfaces/interface[name='eth0']")
+	assert.NoError(t, err)
+	assert.Equal(t, []xpathStep{
+		{prefix: "if", name: "interfaces"},
+		{name: "interface", matches: []xpathMatch{{name: "name", value: "eth0"}}},
+	}, steps)
+}
+
+func TestParseXPathErrors(t *testing.T) {
+	tt := []struct {
+		name    string
+		xpath   string
+		segment string
+	}{
+		{"relative", "interfaces", "must start with '/'"},
+		{"positional", "/interfaces/interface[2]/name", "positional predicate in step \"interface[2]\""},
+		{"function", "/interfaces/interface[position()=1]", "interface[position()=1]"},
+		{"comparison", "/interfaces/interface[mtu > 1500]", "interface[mtu > 1500]"},
+		{"unquoted", "/interfaces/interface[name=eth0]", "interface[name=eth0]"},
+		{"unterminated", "/interfaces/interface[name='eth0'", "unterminated predicate"},
+		{"unterminatedQuote", "/interfaces/interface[name='eth0]", "unterminated quoted value"},
+		{"escapedClosingQuote", `/interfaces/interface[name='eth0\']`, "unterminated quoted value"},
+		{"mismatchedQuotes", `/interfaces/interface[name='eth0"]`, "unterminated quoted value"},
+		{"textAfterQuote", "/interfaces/interface[name='eth' 0]", "malformed quoted value"},
+		{"emptyStep", "/interfaces//interface", "expected element name"},
+		{"trailingSlash", "/interfaces/", "expected element name"},
+		{"emptyPrefixed", "/if:/interface", "expected element name after prefix"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseXPath(tc.xpath)
+			assert.ErrorContains(t, err, tc.segment)
+		})
+	}
+}
+
+func TestParseXPathBuiltBackslashes(t *testing.T) {
+	for _, value := range []string{`\`, `C:\`, `C:\dir\`, `a\b`, `a\\b`, `\\`, `\'`, `\"`, `it\'s "\hi\"`} {
+		t.Run(value, func(t *testing.T) {
+			xpath := XPath(Elem("files"), Elem("file", Key("path", value)))
+			steps, err := parseXPath(xpath)
+			if assert.NoError(t, err, xpath) && assert.Len(t, steps, 2) && assert.Len(t, steps[1].matches, 1) {
+				assert.Equal(t, value, steps[1].matches[0].value, xpath)
+			}
+		})
+	}
+}
+
+func TestXPath(t *testing.T) {
+	tt := []struct {
+		name      string
+		segments  []XPathSegment
+		want      string
+		wantValue string
+	}{
+		{"plain", []XPathSegment{Elem("interfaces"), Elem("interface", Key("name", "eth0"))},
+			"/interfaces/interface[name='eth0']", "eth0"},
+		{"singleQuote", []XPathSegment{Elem("users"), Elem("user", Key("name", "o'brien"))},
+			`/users/user[name="o'brien"]`, "o'brien"},
+		{"doubleQuote", []XPathSegment{Elem("books"), Elem("book", Key("title", `He said "hi"`))},
+			`/books/book[title='He said "hi"']`, `He said "hi"`},
+		{"bothQuotes", []XPathSegment{Elem("books"), Elem("book", Key("title", `it's "hi"`))},
+			`/books/book[title='it''s "hi"']`, `it's "hi"`},
+		{"brackets", []XPathSegment{Elem("acl"), Elem("rule", Key("match", "a]b[c"))},
+			"/acl/rule[match='a]b[c']", "a]b[c"},
+		{"injection", []XPathSegment{Elem("acl"), Elem("rule", Key("name", "x'] | /secret['"))},
+			`/acl/rule[name="x'] | /secret['"]`, "x'] | /secret['"},
+		{"trailingBackslash", []XPathSegment{Elem("files"), Elem("file", Key("path", `C:\`))},
+			`/files/file[path='C:\\']`, `C:\`},
+		{"doubledBackslash", []XPathSegment{Elem("files"), Elem("file", Key("path", `a\\b`))},
+			`/files/file[path='a\\\\b']`, `a\\b`},
+		{"backslashQuote", []XPathSegment{Elem("books"), Elem("book", Key("title", `it\'s`))},
+			`/books/book[title="it\\'s"]`, `it\'s`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := XPath(tc.segments...)
+			assert.Equal(t, tc.want, got)
+
+			// the value makes it unchanged into the filter.
+			steps, err := parseXPath(got)
+			if assert.NoError(t, err) {
+				assert.Len(t, steps, len(tc.segments))
+				last := steps[len(steps)-1]
+				if assert.Len(t, last.matches, 1) {
+					assert.Equal(t, tc.wantValue, last.matches[0].value)
+				}
+			}
+		})
+	}
+
+	t.Run("multipleKeys", func(t *testing.T) {
+		got := XPath(Elem("if:routes"), Elem("route", Key("vrf", "red"), Key("prefix", "10.0.0.0/8")), Elem("next-hop"))
+		assert.Equal(t, "/if:routes/route[vrf='red'][prefix='10.0.0.0/8']/next-hop", got)
+
+		filter, err := parseXPathToXML(XPath(Elem("a"), Elem("b", Key("name", `<it's "&">`))), filterSpec{})
+		assert.NoError(t, err)
+		assert.Equal(t, "<a><b><name>&lt;it&#39;s &#34;&amp;&#34;&gt;</name></b></a>", filter)
+	})
+}
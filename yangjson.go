@@ -0,0 +1,209 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XMLConfigToJSON converts a XML config subtree (i.e as returned by
+// [Session.GetConfig]) into the JSON encoding of YANG data defined in
+// [RFC7951]:
+//
+//   - members are named after the element and qualified with the name of the
+//     YANG module (`ietf-interfaces:interfaces`) for top level elements and
+//     whenever the namespace differs from the parent element.
+//   - repeated sibling elements (list entries and leaf-lists) become arrays.
+//   - leaf values that are qualified names with a declared prefix (i.e
+//     identityrefs like `ianaift:ethernetCsmacd`) are qualified with the name
+//     of the module instead of the prefix.
+//   - empty leaves are encoded as `[null]` like the `empty` type.
+//   - attributes (including the operation and with-defaults attributes) are
+//     dropped.
+//
+// The module names are looked up by namespace in `lib` (see
+// [Session.YANGLibrary]).  If `lib` is nil or doesn't have the namespace the
+// conversion is best effort: the name is the last segment of the namespace
+// (i.e `ietf-interfaces` for `urn:ietf:params:xml:ns:yang:ietf-interfaces`).
+//
+// Without the YANG schema the types of the nodes are not known.  So leaf values
+// are always encoded as strings (where RFC7951 uses numbers and booleans for
+// some types) and a list or leaf-list with a single entry is encoded as a
+// object or a value instead of an array with one element.
+//
+// [RFC7951]: https://www.rfc-editor.org/rfc/rfc7951.html
+func XMLConfigToJSON(config []byte, lib *YANGLibrary) ([]byte, error) {
+	var (
+		roots []*jsonElem
+		stack []*jsonElem
+	)
+
+	dec := xml.NewDecoder(bytes.NewReader(config))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert config to json: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			el := &jsonElem{name: tok.Name}
+			for _, attr := range tok.Attr {
+				if attr.Name.Space == "xmlns" {
+					if el.scope == nil {
+						el.scope = make(map[string]string)
+					}
+					el.scope[attr.Name.Local] = attr.Value
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				el.parent = parent
+				parent.children = append(parent.children, el)
+			} else {
+				roots = append(roots, el)
+			}
+			stack = append(stack, el)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(tok)
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, errors.New("failed to convert config to json: unexpected EOF")
+	}
+
+	enc := jsonEncoder{modules: make(map[string]string)}
+	if lib != nil {
+		for _, m := range lib.Modules {
+			if _, ok := enc.modules[m.Namespace]; !ok && m.Namespace != "" {
+				enc.modules[m.Namespace] = m.Name
+			}
+		}
+	}
+	enc.members(roots, "")
+	return enc.out.Bytes(), nil
+}
+
+type jsonElem struct {
+	name     xml.Name
+	text     bytes.Buffer
+	children []*jsonElem
+	parent   *jsonElem
+	// scope holds the prefixes declared on the element.
+	scope map[string]string
+}
+
+// lookupPrefix returns the namespace bound to prefix on el or it's ancestors.
+func (el *jsonElem) lookupPrefix(prefix string) (string, bool) {
+	for ; el != nil; el = el.parent {
+		if ns, ok := el.scope[prefix]; ok {
+			return ns, ns != ""
+		}
+	}
+	return "", false
+}
+
+type jsonEncoder struct {
+	out bytes.Buffer
+	// modules maps namespaces to YANG module names.
+	modules map[string]string
+}
+
+// module returns the name of the YANG module of the namespace.
+func (e *jsonEncoder) module(ns string) string {
+	if name, ok := e.modules[ns]; ok {
+		return name
+	}
+	name := strings.TrimRight(ns, "/")
+	if i := strings.LastIndexAny(name, ":/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" {
+		name = ns
+	}
+	e.modules[ns] = name
+	return name
+}
+
+// members encodes elems as the members of a object.  Siblings with the same
+// name are grouped into an array at the position of the first one.
+func (e *jsonEncoder) members(elems []*jsonElem, parentNS string) {
+	var names []xml.Name
+	groups := make(map[xml.Name][]*jsonElem)
+	for _, el := range elems {
+		if _, ok := groups[el.name]; !ok {
+			names = append(names, el.name)
+		}
+		groups[el.name] = append(groups[el.name], el)
+	}
+
+	e.out.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			e.out.WriteByte(',')
+		}
+
+		member := name.Local
+		if name.Space != "" && name.Space != parentNS {
+			member = e.module(name.Space) + ":" + member
+		}
+		e.string(member)
+		e.out.WriteByte(':')
+
+		group := groups[name]
+		if len(group) == 1 {
+			e.value(group[0])
+			continue
+		}
+		e.out.WriteByte('[')
+		for j, el := range group {
+			if j > 0 {
+				e.out.WriteByte(',')
+			}
+			e.value(el)
+		}
+		e.out.WriteByte(']')
+	}
+	e.out.WriteByte('}')
+}
+
+func (e *jsonEncoder) value(el *jsonElem) {
+	if len(el.children) > 0 {
+		e.members(el.children, el.name.Space)
+		return
+	}
+
+	text := strings.TrimSpace(el.text.String())
+	if text == "" {
+		e.out.WriteString("[null]")
+		return
+	}
+
+	if prefix, local, ok := strings.Cut(text, ":"); ok && isNCName(prefix) && isNCName(local) {
+		if ns, ok := el.lookupPrefix(prefix); ok {
+			text = e.module(ns) + ":" + local
+		}
+	}
+	e.string(text)
+}
+
+func (e *jsonEncoder) string(s string) {
+	// keep `<`, `>` and `&` as-is, they are common in config values.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// encoding a string never fails.
+	_ = enc.Encode(s)
+	e.out.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,72 @@
+package netconf
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXMLConfigToJSON(t *testing.T) {
+	const config = `
+<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces" xmlns:ianaift="urn:ietf:params:xml:ns:yang:iana-if-type">
+  <interface>
+    <name>eth0</name>
+    <type>ianaift:ethernetCsmacd</type>
+    <enabled>true</enabled>
+    <ipv4 xmlns="urn:ietf:params:xml:ns:yang:ietf-ip">
+      <address><ip>192.0.2.1</ip><prefix-length>24</prefix-length></address>
+      <address><ip>192.0.2.2</ip><prefix-length>24</prefix-length></address>
+    </ipv4>
+  </interface>
+  <interface>
+    <name>eth1</name>
+    <description>uplink &lt;core&gt;</description>
+    <link-up-down-trap-enable/>
+  </interface>
+</interfaces>
+<system xmlns="urn:example:system" xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="merge">
+  <dns><search>a.example</search><search>b.example</search></dns>
+</system>`
+
+	tt := []struct {
+		name string
+		lib  *YANGLibrary
+		want string
+	}{
+		{
+			name: "withoutLibrary",
+			want: `{"ietf-interfaces:interfaces":{"interface":[` +
+				`{"name":"eth0","type":"iana-if-type:ethernetCsmacd","enabled":"true","ietf-ip:ipv4":{"address":[{"ip":"192.0.2.1","prefix-length":"24"},{"ip":"192.0.2.2","prefix-length":"24"}]}},` +
+				`{"name":"eth1","description":"uplink <core>","link-up-down-trap-enable":[null]}]},` +
+				`"system:system":{"dns":{"search":["a.example","b.example"]}}}`,
+		},
+		{
+			name: "withLibrary",
+			lib: &YANGLibrary{Modules: []YANGModule{
+				{Name: "example-system", Namespace: "urn:example:system"},
+				{Name: "ietf-ip", Namespace: "urn:ietf:params:xml:ns:yang:ietf-ip"},
+			}},
+			want: `{"ietf-interfaces:interfaces":{"interface":[` +
+				`{"name":"eth0","type":"iana-if-type:ethernetCsmacd","enabled":"true","ietf-ip:ipv4":{"address":[{"ip":"192.0.2.1","prefix-length":"24"},{"ip":"192.0.2.2","prefix-length":"24"}]}},` +
+				`{"name":"eth1","description":"uplink <core>","link-up-down-trap-enable":[null]}]},` +
+				`"example-system:system":{"dns":{"search":["a.example","b.example"]}}}`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := XMLConfigToJSON([]byte(config), tc.lib)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+			assert.True(t, json.Valid(got))
+		})
+	}
+
+	got, err := XMLConfigToJSON(nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(got))
+
+	_, err = XMLConfigToJSON([]byte(`<interfaces><interface>`), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,230 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+)
+
+// Capabilities advertising the revision of the ietf-yang-library model.
+const (
+	// yangLibraryCap10 is advertised for `/modules-state` defined in RFC7895.
+	yangLibraryCap10 = ":yang-library:1.0"
+	// yangLibraryCap11 is advertised for `/yang-library` defined in RFC8525.
+	yangLibraryCap11 = ":yang-library:1.1"
+)
+
+// Default revisions of the ietf-yang-library model if the capability has no
+// `revision` parameter.
+const (
+	yangLibraryRevision10 = "2016-06-21"
+	yangLibraryRevision11 = "2019-01-04"
+)
+
+// YANGLibrary lists the YANG modules implemented by a device as returned by
+// [Session.YANGLibrary].
+type YANGLibrary struct {
+	// Revision is the revision of the ietf-yang-library model used by the
+	// device (i.e `2016-06-21` for [RFC7895] or `2019-01-04` for [RFC8525]).
+	//
+	// [RFC7895]: https://www.rfc-editor.org/rfc/rfc7895.html
+	// [RFC8525]: https://www.rfc-editor.org/rfc/rfc8525.html
+	Revision string
+	// ContentID identifies the set of modules.  It changes whenever the
+	// modules change (`module-set-id` in RFC7895 and `content-id` in
+	// RFC8525).
+	ContentID string
+	Modules   []YANGModule
+}
+
+// Module returns the module with the given name, if any.  If the module is
+// listed multiple times (i.e in different module sets) the first one is
+// returned.
+func (l *YANGLibrary) Module(name string) (YANGModule, bool) {
+	for _, m := range l.Modules {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return YANGModule{}, false
+}
+
+// YANG conformance types of a module.
+const (
+	// ConformanceImplement is a module that is implemented by the device.
+	ConformanceImplement = "implement"
+	// ConformanceImport is a module only used for it's imported definitions.
+	ConformanceImport = "import"
+)
+
+// YANGModule is a YANG module listed in the ietf-yang-library model.
+type YANGModule struct {
+	Name      string
+	Revision  string
+	Namespace string
+	// Features are the features of the module enabled on the device.
+	Features []string
+	// Deviations are the names of the modules that contain deviations for
+	// this module.
+	Deviations []string
+	// ConformanceType is either [ConformanceImplement] or
+	// [ConformanceImport].
+	ConformanceType string
+	Submodules      []YANGSubmodule
+	// ModuleSet is the name of the module set the module belongs to (RFC8525
+	// only).
+	ModuleSet string
+}
+
+// YANGSubmodule is a submodule of a [YANGModule].
+type YANGSubmodule struct {
+	Name     string `xml:"name"`
+	Revision string `xml:"revision"`
+}
+
+// YANGLibrary returns the YANG modules, features and deviations implemented by
+// the device from the ietf-yang-library model.  `/yang-library` ([RFC8525]) is
+// used if the device advertises the `:yang-library:1.1` capability, otherwise
+// `/modules-state` ([RFC7895]) for `:yang-library:1.0`.  An error wrapping
+// [ErrCapabilityMissing] is returned if the device advertises neither.
+//
+// [RFC7895]: https://www.rfc-editor.org/rfc/rfc7895.html
+// [RFC8525]: https://www.rfc-editor.org/rfc/rfc8525.html
+func (s *Session) YANGLibrary(ctx context.Context) (*YANGLibrary, error) {
+	if c, ok := s.serverCaps.Lookup(yangLibraryCap11); ok {
+		return s.yangLibrary(ctx, c)
+	}
+	if c, ok := s.serverCaps.Lookup(yangLibraryCap10); ok {
+		return s.modulesState(ctx, c)
+	}
+	return nil, fmt.Errorf("%w: yang library requires %s or %s", ErrCapabilityMissing, yangLibraryCap11, yangLibraryCap10)
+}
+
+// getYANGLibraryState fetches the given top level container of the
+// ietf-yang-library model with `<get>` and decodes it into v.
+func (s *Session) getYANGLibraryState(ctx context.Context, container string, v any) error {
+	data, err := s.Get(ctx,
+		WithFilter("/"+container),
+		WithFilterNamespace(NamespaceYANGLibrary))
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", container, err)
+	}
+	return nil
+}
+
+type yangLibraryModule struct {
+	Name       string          `xml:"name"`
+	Revision   string          `xml:"revision"`
+	Namespace  string          `xml:"namespace"`
+	Features   []string        `xml:"feature"`
+	Deviations []string        `xml:"deviation"`
+	Submodules []YANGSubmodule `xml:"submodule"`
+}
+
+// yangLibrary fetches `/yang-library` as defined in RFC8525.
+func (s *Session) yangLibrary(ctx context.Context, c Capability) (*YANGLibrary, error) {
+	var state struct {
+		ContentID  string `xml:"content-id"`
+		ModuleSets []struct {
+			Name              string              `xml:"name"`
+			Modules           []yangLibraryModule `xml:"module"`
+			ImportOnlyModules []yangLibraryModule `xml:"import-only-module"`
+		} `xml:"module-set"`
+	}
+	if err := s.getYANGLibraryState(ctx, "yang-library", &state); err != nil {
+		return nil, err
+	}
+
+	lib := &YANGLibrary{
+		Revision:  capabilityParam(c, "revision", yangLibraryRevision11),
+		ContentID: state.ContentID,
+	}
+	if lib.ContentID == "" {
+		lib.ContentID = capabilityParam(c, "content-id", "")
+	}
+
+	for _, set := range state.ModuleSets {
+		for _, m := range set.Modules {
+			lib.Modules = append(lib.Modules, m.module(set.Name, ConformanceImplement))
+		}
+		for _, m := range set.ImportOnlyModules {
+			lib.Modules = append(lib.Modules, m.module(set.Name, ConformanceImport))
+		}
+	}
+	return lib, nil
+}
+
+func (m yangLibraryModule) module(set, conformance string) YANGModule {
+	return YANGModule{
+		Name:            m.Name,
+		Revision:        m.Revision,
+		Namespace:       m.Namespace,
+		Features:        m.Features,
+		Deviations:      m.Deviations,
+		ConformanceType: conformance,
+		Submodules:      m.Submodules,
+		ModuleSet:       set,
+	}
+}
+
+// modulesState fetches `/modules-state` as defined in RFC7895.
+func (s *Session) modulesState(ctx context.Context, c Capability) (*YANGLibrary, error) {
+	var state struct {
+		ModuleSetID string `xml:"module-set-id"`
+		Modules     []struct {
+			Name       string   `xml:"name"`
+			Revision   string   `xml:"revision"`
+			Namespace  string   `xml:"namespace"`
+			Features   []string `xml:"feature"`
+			Deviations []struct {
+				Name string `xml:"name"`
+			} `xml:"deviation"`
+			ConformanceType string          `xml:"conformance-type"`
+			Submodules      []YANGSubmodule `xml:"submodule"`
+		} `xml:"module"`
+	}
+	if err := s.getYANGLibraryState(ctx, "modules-state", &state); err != nil {
+		return nil, err
+	}
+
+	lib := &YANGLibrary{
+		Revision:  capabilityParam(c, "revision", yangLibraryRevision10),
+		ContentID: state.ModuleSetID,
+	}
+	if lib.ContentID == "" {
+		lib.ContentID = capabilityParam(c, "module-set-id", "")
+	}
+
+	for _, m := range state.Modules {
+		mod := YANGModule{
+			Name:            m.Name,
+			Revision:        m.Revision,
+			Namespace:       m.Namespace,
+			Features:        m.Features,
+			ConformanceType: m.ConformanceType,
+			Submodules:      m.Submodules,
+		}
+		for _, d := range m.Deviations {
+			mod.Deviations = append(mod.Deviations, d.Name)
+		}
+		lib.Modules = append(lib.Modules, mod)
+	}
+	return lib, nil
+}
+
+// capabilityParam returns the value of the parameter `key` of a capability or
+// `def` if it is not set.
+func capabilityParam(c Capability, key, def string) string {
+	if v, ok := c.Params[key]; ok && v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,131 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYANGLibraryModulesState(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(":yang-library:1.0?revision=2016-06-21&module-set-id=abc")
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>
+<modules-state xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-library">
+  <module-set-id>abc</module-set-id>
+  <module>
+    <name>ietf-interfaces</name>
+    <revision>2018-02-20</revision>
+    <namespace>urn:ietf:params:xml:ns:yang:ietf-interfaces</namespace>
+    <feature>arbitrary-names</feature>
+    <feature>pre-provisioning</feature>
+    <deviation><name>example-deviations</name><revision>2020-01-01</revision></deviation>
+    <conformance-type>implement</conformance-type>
+  </module>
+  <module>
+    <name>ietf-yang-types</name>
+    <revision>2013-07-15</revision>
+    <namespace>urn:ietf:params:xml:ns:yang:ietf-yang-types</namespace>
+    <conformance-type>import</conformance-type>
+    <submodule><name>ietf-yang-types-sub</name><revision>2013-07-15</revision></submodule>
+  </module>
+</modules-state>
+</data></rpc-reply>`)
+
+	lib, err := sess.YANGLibrary(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &YANGLibrary{
+		Revision:  "2016-06-21",
+		ContentID: "abc",
+		Modules: []YANGModule{
+			{
+				Name:            "ietf-interfaces",
+				Revision:        "2018-02-20",
+				Namespace:       "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+				Features:        []string{"arbitrary-names", "pre-provisioning"},
+				Deviations:      []string{"example-deviations"},
+				ConformanceType: ConformanceImplement,
+			},
+			{
+				Name:            "ietf-yang-types",
+				Revision:        "2013-07-15",
+				Namespace:       "urn:ietf:params:xml:ns:yang:ietf-yang-types",
+				ConformanceType: ConformanceImport,
+				Submodules:      []YANGSubmodule{{Name: "ietf-yang-types-sub", Revision: "2013-07-15"}},
+			},
+		},
+	}, lib)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<filter type="subtree"><modules-state xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-library"/></filter>`)
+}
+
+func TestYANGLibrary(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(
+		":yang-library:1.0?revision=2016-06-21&module-set-id=abc",
+		":yang-library:1.1?revision=2019-01-04&content-id=42",
+	)
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>
+<yang-library xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-library">
+  <module-set>
+    <name>config-modules</name>
+    <module>
+      <name>ietf-interfaces</name>
+      <revision>2018-02-20</revision>
+      <namespace>urn:ietf:params:xml:ns:yang:ietf-interfaces</namespace>
+      <feature>if-mib</feature>
+      <deviation>example-deviations</deviation>
+    </module>
+    <import-only-module>
+      <name>ietf-yang-types</name>
+      <revision>2013-07-15</revision>
+      <namespace>urn:ietf:params:xml:ns:yang:ietf-yang-types</namespace>
+    </import-only-module>
+  </module-set>
+  <content-id>42</content-id>
+</yang-library>
+</data></rpc-reply>`)
+
+	lib, err := sess.YANGLibrary(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "2019-01-04", lib.Revision)
+	assert.Equal(t, "42", lib.ContentID)
+	assert.Len(t, lib.Modules, 2)
+
+	mod, ok := lib.Module("ietf-interfaces")
+	assert.True(t, ok)
+	assert.Equal(t, YANGModule{
+		Name:            "ietf-interfaces",
+		Revision:        "2018-02-20",
+		Namespace:       "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+		Features:        []string{"if-mib"},
+		Deviations:      []string{"example-deviations"},
+		ConformanceType: ConformanceImplement,
+		ModuleSet:       "config-modules",
+	}, mod)
+
+	mod, ok = lib.Module("ietf-yang-types")
+	assert.True(t, ok)
+	assert.Equal(t, ConformanceImport, mod.ConformanceType)
+
+	sent, err := ts.popReqString()
+	assert.NoError(t, err)
+	assert.Contains(t, sent, `<yang-library xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-library"/>`)
+}
+
+func TestYANGLibraryUnsupported(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	_, err := sess.YANGLibrary(context.Background())
+	assert.ErrorIs(t, err, ErrCapabilityMissing)
+}
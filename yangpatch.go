@@ -0,0 +1,188 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// YANGPatchOperation is the operation of a [YANGPatchEdit].
+type YANGPatchOperation string
+
+const (
+	// YANGPatchCreate creates the target which must not exist.
+	YANGPatchCreate YANGPatchOperation = "create"
+	// YANGPatchDelete deletes the target which must exist.
+	YANGPatchDelete YANGPatchOperation = "delete"
+	// YANGPatchInsert inserts the value into a user-ordered list or leaf-list
+	// at the position given by Where and Point.
+	YANGPatchInsert YANGPatchOperation = "insert"
+	// YANGPatchMerge merges the value with the target.
+	YANGPatchMerge YANGPatchOperation = "merge"
+	// YANGPatchMove moves the target within a user-ordered list or leaf-list
+	// to the position given by Where and Point.
+	YANGPatchMove YANGPatchOperation = "move"
+	// YANGPatchReplace replaces the target with the value.
+	YANGPatchReplace YANGPatchOperation = "replace"
+	// YANGPatchRemove deletes the target if it exists.
+	YANGPatchRemove YANGPatchOperation = "remove"
+)
+
+// YANGPatchWhere is the position of an entry inserted or moved with
+// [YANGPatchInsert] or [YANGPatchMove].
+type YANGPatchWhere string
+
+const (
+	YANGPatchBefore YANGPatchWhere = "before"
+	YANGPatchAfter  YANGPatchWhere = "after"
+	YANGPatchFirst  YANGPatchWhere = "first"
+	YANGPatchLast   YANGPatchWhere = "last"
+)
+
+// YANGPatch is a YANG Patch document as defined in [RFC8072]: an ordered list
+// of edits applied as a single transaction.
+//
+// [RFC8072]: https://www.rfc-editor.org/rfc/rfc8072.html
+type YANGPatch struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:yang:ietf-yang-patch yang-patch"`
+	// PatchID identifies the patch, i.e in error reports.
+	PatchID string          `xml:"patch-id"`
+	Comment string          `xml:"comment,omitempty"`
+	Edits   []YANGPatchEdit `xml:"edit"`
+}
+
+// YANGPatchEdit is an edit of a [YANGPatch].
+type YANGPatchEdit struct {
+	// EditID identifies the edit and must be unique within the patch.
+	EditID    string             `xml:"edit-id"`
+	Operation YANGPatchOperation `xml:"operation"`
+	// Target is the data-resource-identifier of the node the edit applies to
+	// (i.e `/ietf-interfaces:interfaces/interface=eth0`).
+	Target string `xml:"target"`
+	// Point is the data-resource-identifier of the entry that Where is
+	// relative to for [YANGPatchBefore] and [YANGPatchAfter].
+	Point string         `xml:"point,omitempty"`
+	Where YANGPatchWhere `xml:"where,omitempty"`
+	// Value is the data node(s) of a create, insert, merge or replace edit.
+	// Like the config of [Session.EditConfig] it can be a string or byte
+	// slice of already encoded xml or a struct to be encoded with
+	// encoding/xml.
+	Value any `xml:"-"`
+}
+
+func (e YANGPatchEdit) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	var value any
+	if e.Value != nil {
+		if _, ok := e.Value.(io.Reader); ok {
+			return fmt.Errorf("yang-patch edit %q: unsupported value type %T", e.EditID, e.Value)
+		}
+
+		var (
+			url string
+			err error
+		)
+		value, url, err = wrapConfigSource(e.Value)
+		if err != nil {
+			return fmt.Errorf("yang-patch edit %q: %w", e.EditID, err)
+		}
+		if url != "" {
+			return fmt.Errorf("yang-patch edit %q: url %q cannot be used as a value", e.EditID, url)
+		}
+	}
+
+	type edit YANGPatchEdit
+	v := struct {
+		edit
+		Value any `xml:"value,omitempty"`
+	}{edit(e), value}
+	return enc.EncodeElement(&v, start)
+}
+
+// validate checks the patch against the constraints of the ietf-yang-patch
+// model.
+func (p *YANGPatch) validate() error {
+	if p.PatchID == "" {
+		return errors.New("yang-patch requires a patch-id")
+	}
+	if len(p.Edits) == 0 {
+		return fmt.Errorf("yang-patch %q has no edits", p.PatchID)
+	}
+
+	ids := make(map[string]bool, len(p.Edits))
+	for _, e := range p.Edits {
+		if e.EditID == "" {
+			return fmt.Errorf("yang-patch %q: edit without edit-id", p.PatchID)
+		}
+		if ids[e.EditID] {
+			return fmt.Errorf("yang-patch %q: duplicate edit-id %q", p.PatchID, e.EditID)
+		}
+		ids[e.EditID] = true
+
+		if e.Target == "" {
+			return fmt.Errorf("yang-patch edit %q: target cannot be empty", e.EditID)
+		}
+
+		switch e.Operation {
+		case YANGPatchCreate, YANGPatchMerge, YANGPatchReplace, YANGPatchInsert:
+			if e.Value == nil {
+				return fmt.Errorf("yang-patch edit %q: %s requires a value", e.EditID, e.Operation)
+			}
+		case YANGPatchDelete, YANGPatchRemove, YANGPatchMove:
+			if e.Value != nil {
+				return fmt.Errorf("yang-patch edit %q: %s cannot have a value", e.EditID, e.Operation)
+			}
+		default:
+			return fmt.Errorf("yang-patch edit %q: invalid operation %q", e.EditID, e.Operation)
+		}
+
+		isPositioned := e.Operation == YANGPatchInsert || e.Operation == YANGPatchMove
+		switch e.Where {
+		case "":
+			if isPositioned {
+				return fmt.Errorf("yang-patch edit %q: %s requires where", e.EditID, e.Operation)
+			}
+		case YANGPatchBefore, YANGPatchAfter:
+			if e.Point == "" {
+				return fmt.Errorf("yang-patch edit %q: where %s requires a point", e.EditID, e.Where)
+			}
+		case YANGPatchFirst, YANGPatchLast:
+			if e.Point != "" {
+				return fmt.Errorf("yang-patch edit %q: where %s cannot have a point", e.EditID, e.Where)
+			}
+		default:
+			return fmt.Errorf("yang-patch edit %q: invalid where %q", e.EditID, e.Where)
+		}
+		if e.Where != "" && !isPositioned {
+			return fmt.Errorf("yang-patch edit %q: where is only valid for insert and move", e.EditID)
+		}
+	}
+	return nil
+}
+
+// EditConfigPatch applies a [YANGPatch] to the target datastore.  Unlike the
+// merge-style config of [Session.EditConfig] the patch is an ordered list of
+// explicit edits applied as a single transaction.  The patch is validated
+// against the constraints of the ietf-yang-patch model before it's sent.
+//
+// RFC8072 defines YANG Patch for RESTCONF only and NETCONF has no standard
+// operation for it.  The patch document is sent as the contents of the
+// `<config>` of an `<edit-config>` on the target, so it only works with
+// devices accepting patches this way.  It requires the device to advertise
+// the ietf-yang-patch module (see [NamespaceYANGPatch]) as well as the
+// capabilities needed to edit the target like [Session.EditConfig].  Check the
+// documentation of the device before relying on it.
+func (s *Session) EditConfigPatch(ctx context.Context, target Datastore, patch YANGPatch) error {
+	if err := s.requireCapability("yang-patch", NamespaceYANGPatch); err != nil {
+		return err
+	}
+	if err := patch.validate(); err != nil {
+		return err
+	}
+
+	type patchConfig struct {
+		Patch *YANGPatch
+	}
+	return s.EditConfig(ctx, target, &patchConfig{Patch: &patch})
+}
@@ -0,0 +1,128 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const yangPatchCap = "urn:ietf:params:xml:ns:yang:ietf-yang-patch?module=ietf-yang-patch&revision=2017-02-22"
+
+func TestEditConfigPatch(t *testing.T) {
+	// like a config the struct is the `<value>` element.
+	type mtuValue struct {
+		MTU struct {
+			XMLName xml.Name `xml:"urn:ietf:params:xml:ns:yang:ietf-interfaces mtu"`
+			Value   int      `xml:",chardata"`
+		}
+	}
+	var mtu mtuValue
+	mtu.MTU.Value = 9000
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = newCapabilitySet(yangPatchCap, ":candidate")
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+
+	err := sess.EditConfigPatch(context.Background(), Candidate, YANGPatch{
+		PatchID: "add-eth1",
+		Edits: []YANGPatchEdit{
+			{
+				EditID:    "1",
+				Operation: YANGPatchCreate,
+				Target:    "/ietf-interfaces:interfaces/interface=eth1",
+				Value:     `<interface xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><name>eth1</name></interface>`,
+			},
+			{
+				EditID:    "2",
+				Operation: YANGPatchMerge,
+				Target:    "/ietf-interfaces:interfaces/interface=eth1/mtu",
+				Value:     &mtu,
+			},
+			{
+				EditID:    "3",
+				Operation: YANGPatchMove,
+				Target:    "/ietf-interfaces:interfaces/interface=eth1",
+				Where:     YANGPatchBefore,
+				Point:     "/ietf-interfaces:interfaces/interface=eth0",
+			},
+			{
+				EditID:    "4",
+				Operation: YANGPatchRemove,
+				Target:    "/ietf-interfaces:interfaces/interface=eth2",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	sent, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, sent, `<edit-config><target><candidate/></target><config xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0">`+
+		`<yang-patch xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-patch"><patch-id>add-eth1</patch-id>`+
+		`<edit><edit-id>1</edit-id><operation>create</operation><target>/ietf-interfaces:interfaces/interface=eth1</target>`+
+		`<value><interface xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><name>eth1</name></interface></value></edit>`+
+		`<edit><edit-id>2</edit-id><operation>merge</operation><target>/ietf-interfaces:interfaces/interface=eth1/mtu</target>`+
+		`<value xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0"><mtu xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">9000</mtu></value></edit>`+
+		`<edit><edit-id>3</edit-id><operation>move</operation><target>/ietf-interfaces:interfaces/interface=eth1</target>`+
+		`<point>/ietf-interfaces:interfaces/interface=eth0</point><where>before</where></edit>`+
+		`<edit><edit-id>4</edit-id><operation>remove</operation><target>/ietf-interfaces:interfaces/interface=eth2</target></edit>`+
+		`</yang-patch></config></edit-config>`)
+}
+
+func TestEditConfigPatchInvalid(t *testing.T) {
+	edit := func(e YANGPatchEdit) YANGPatch {
+		if e.EditID == "" {
+			e.EditID = "1"
+		}
+		if e.Target == "" {
+			e.Target = "/example:system"
+		}
+		return YANGPatch{PatchID: "p", Edits: []YANGPatchEdit{e}}
+	}
+
+	tt := []struct {
+		name  string
+		patch YANGPatch
+	}{
+		{"noPatchID", YANGPatch{Edits: []YANGPatchEdit{{EditID: "1", Operation: YANGPatchRemove, Target: "/example:system"}}}},
+		{"noEdits", YANGPatch{PatchID: "p"}},
+		{"duplicateEditID", YANGPatch{PatchID: "p", Edits: []YANGPatchEdit{
+			{EditID: "1", Operation: YANGPatchRemove, Target: "/example:a"},
+			{EditID: "1", Operation: YANGPatchRemove, Target: "/example:b"},
+		}}},
+		{"invalidOperation", edit(YANGPatchEdit{Operation: "patch"})},
+		{"createWithoutValue", edit(YANGPatchEdit{Operation: YANGPatchCreate})},
+		{"deleteWithValue", edit(YANGPatchEdit{Operation: YANGPatchDelete, Value: "<system/>"})},
+		{"insertWithoutWhere", edit(YANGPatchEdit{Operation: YANGPatchInsert, Value: "<entry/>"})},
+		{"afterWithoutPoint", edit(YANGPatchEdit{Operation: YANGPatchMove, Where: YANGPatchAfter})},
+		{"firstWithPoint", edit(YANGPatchEdit{Operation: YANGPatchMove, Where: YANGPatchFirst, Point: "/example:b"})},
+		{"whereOnMerge", edit(YANGPatchEdit{Operation: YANGPatchMerge, Where: YANGPatchLast, Value: "<system/>"})},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sess := newSession(newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+				panic("unexpected request")
+			}))
+			sess.serverCaps = newCapabilitySet(yangPatchCap, ":candidate")
+
+			assert.Error(t, sess.EditConfigPatch(context.Background(), Candidate, tc.patch))
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		sess := newSession(newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+			panic("unexpected request")
+		}))
+		sess.serverCaps = newCapabilitySet(":candidate")
+
+		err := sess.EditConfigPatch(context.Background(), Candidate, edit(YANGPatchEdit{Operation: YANGPatchRemove}))
+		assert.ErrorIs(t, err, ErrCapabilityMissing)
+	})
+}